@@ -0,0 +1,296 @@
+package deej
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+// browserTabController adjusts the volume of media playing in a browser's focused tab by talking to
+// the browser over the Chrome DevTools protocol. this only works for Chromium-based browsers started
+// with --remote-debugging-port, and is very much experimental
+type browserTabController struct {
+	logger *zap.SugaredLogger
+
+	// serializes tab lookups and volume changes, and guards focusedTabs
+	lock sync.Mutex
+
+	httpClient *http.Client
+
+	// open DevTools connections, keyed by their websocket URL. tabs are queried all at once, so this has its own lock
+	connectionsLock sync.Mutex
+	connections     map[string]*websocket.Conn
+	messageID       atomic.Int64
+
+	// the focused tab is looked up at most this often per debugging port
+	focusedTabs map[int]cachedBrowserTab
+}
+
+type cachedBrowserTab struct {
+	debuggerURL string
+	lookedUp    time.Time
+}
+
+// a single entry from the browser's /json target list
+type browserTarget struct {
+	Type                 string `json:"type"`
+	Title                string `json:"title"`
+	WebSocketDebuggerURL string `json:"webSocketDebuggerUrl"`
+}
+
+type devToolsResponse struct {
+	ID     int `json:"id"`
+	Result struct {
+		Result struct {
+			Value json.RawMessage `json:"value"`
+		} `json:"result"`
+	} `json:"result"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+const (
+	browserTabCacheDuration = 350 * time.Millisecond
+	browserRequestTimeout   = 500 * time.Millisecond
+
+	// ranks a tab by how likely it is to be the one the user is looking at
+	browserTabFocusExpression = `(document.hasFocus() ? 2 : 0) + (document.visibilityState === "visible" ? 1 : 0)`
+
+	// sets the volume of every media element on the page
+	browserTabVolumeExpression = `document.querySelectorAll("audio, video").forEach(function (m) { m.volume = %.2f; }); true`
+)
+
+var errNoBrowserTab = errors.New("no focused browser tab found")
+
+func newBrowserTabController(logger *zap.SugaredLogger) *browserTabController {
+	return &browserTabController{
+		logger:      logger.Named("browser_tabs"),
+		httpClient:  &http.Client{Timeout: browserRequestTimeout},
+		connections: make(map[string]*websocket.Conn),
+		focusedTabs: make(map[int]cachedBrowserTab),
+	}
+}
+
+// SetFocusedTabVolume sets the volume of media in the focused tab of the browser listening on the given debugging port
+func (c *browserTabController) SetFocusedTabVolume(port int, volume float32) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	debuggerURL, err := c.focusedTab(port)
+	if err != nil {
+		return fmt.Errorf("find focused tab: %w", err)
+	}
+
+	deadline := time.Now().Add(browserRequestTimeout)
+	if _, err := c.evaluate(debuggerURL, fmt.Sprintf(browserTabVolumeExpression, volume), deadline); err != nil {
+		return fmt.Errorf("set tab volume: %w", err)
+	}
+
+	c.logger.Debugw("Adjusted focused tab volume", "port", port, "volume", volume)
+
+	return nil
+}
+
+// Close drops all open DevTools connections
+func (c *browserTabController) Close() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.connectionsLock.Lock()
+	defer c.connectionsLock.Unlock()
+
+	for debuggerURL, conn := range c.connections {
+		_ = conn.Close()
+		delete(c.connections, debuggerURL)
+	}
+}
+
+func (c *browserTabController) focusedTab(port int) (string, error) {
+	if cached, ok := c.focusedTabs[port]; ok && cached.lookedUp.Add(browserTabCacheDuration).After(time.Now()) {
+		return cached.debuggerURL, nil
+	}
+
+	targets, err := c.listTargets(port)
+	if err != nil {
+		return "", err
+	}
+
+	c.dropClosedTabs(port, targets)
+
+	// background tabs are throttled and can take a while to answer, so ask all of them at once
+	// and give up on the stragglers together, rather than waiting on each one in turn
+	deadline := time.Now().Add(browserRequestTimeout)
+	scores := make([]int, len(targets))
+
+	var wg sync.WaitGroup
+	for targetIdx, target := range targets {
+		if target.Type != "page" || target.WebSocketDebuggerURL == "" {
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			value, err := c.evaluate(target.WebSocketDebuggerURL, browserTabFocusExpression, deadline)
+			if err != nil {
+				c.logger.Debugw("Failed to query tab focus", "title", target.Title, "error", err)
+				return
+			}
+
+			// a tab that doesn't answer with a number isn't a candidate, same as one that doesn't answer at all
+			_ = json.Unmarshal(value, &scores[targetIdx])
+		}()
+	}
+	wg.Wait()
+
+	bestURL := ""
+	bestScore := 0
+
+	for targetIdx, score := range scores {
+		if score > bestScore {
+			bestURL = targets[targetIdx].WebSocketDebuggerURL
+			bestScore = score
+		}
+	}
+
+	if bestURL == "" {
+		return "", errNoBrowserTab
+	}
+
+	c.focusedTabs[port] = cachedBrowserTab{debuggerURL: bestURL, lookedUp: time.Now()}
+
+	return bestURL, nil
+}
+
+func (c *browserTabController) listTargets(port int) ([]browserTarget, error) {
+	response, err := c.httpClient.Get(fmt.Sprintf("http://127.0.0.1:%d/json", port))
+	if err != nil {
+		return nil, fmt.Errorf("list browser targets: %w", err)
+	}
+	defer response.Body.Close()
+
+	targets := []browserTarget{}
+	if err := json.NewDecoder(response.Body).Decode(&targets); err != nil {
+		return nil, fmt.Errorf("decode browser targets: %w", err)
+	}
+
+	return targets, nil
+}
+
+// dropClosedTabs closes the connections to tabs of the browser on the given port that are no longer in its target list
+func (c *browserTabController) dropClosedTabs(port int, targets []browserTarget) {
+	open := make(map[string]bool, len(targets))
+	for _, target := range targets {
+		open[target.WebSocketDebuggerURL] = true
+	}
+
+	c.connectionsLock.Lock()
+	defer c.connectionsLock.Unlock()
+
+	for debuggerURL, conn := range c.connections {
+		if open[debuggerURL] {
+			continue
+		}
+
+		// other browsers' tabs are none of this list's business
+		if parsed, err := url.Parse(debuggerURL); err != nil || parsed.Port() != strconv.Itoa(port) {
+			continue
+		}
+
+		_ = conn.Close()
+		delete(c.connections, debuggerURL)
+
+		c.logger.Debugw("Dropped connection to closed tab", "debuggerURL", debuggerURL)
+	}
+}
+
+// evaluate runs a JavaScript expression in the given tab and returns its result, giving up at the deadline.
+// different tabs can be evaluated at the same time, but each one only by a single caller at a time
+func (c *browserTabController) evaluate(debuggerURL string, expression string, deadline time.Time) (json.RawMessage, error) {
+	conn, err := c.connection(debuggerURL, deadline)
+	if err != nil {
+		return nil, err
+	}
+
+	messageID := int(c.messageID.Add(1))
+	request := map[string]any{
+		"id":     messageID,
+		"method": "Runtime.evaluate",
+		"params": map[string]any{
+			"expression":    expression,
+			"returnByValue": true,
+		},
+	}
+
+	_ = conn.SetWriteDeadline(deadline)
+	if err := conn.WriteJSON(request); err != nil {
+		c.dropConnection(debuggerURL)
+		return nil, fmt.Errorf("send DevTools request: %w", err)
+	}
+
+	// the tab may also send unrelated events our way, skip past those
+	_ = conn.SetReadDeadline(deadline)
+	for {
+		var response devToolsResponse
+		if err := conn.ReadJSON(&response); err != nil {
+			c.dropConnection(debuggerURL)
+			return nil, fmt.Errorf("read DevTools response: %w", err)
+		}
+
+		if response.ID != messageID {
+			continue
+		}
+
+		if response.Error != nil {
+			return nil, fmt.Errorf("evaluate expression: %s", response.Error.Message)
+		}
+
+		return response.Result.Result.Value, nil
+	}
+}
+
+func (c *browserTabController) connection(debuggerURL string, deadline time.Time) (*websocket.Conn, error) {
+	c.connectionsLock.Lock()
+	conn, ok := c.connections[debuggerURL]
+	c.connectionsLock.Unlock()
+
+	if ok {
+		return conn, nil
+	}
+
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, debuggerURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("connect to tab: %w", err)
+	}
+
+	c.connectionsLock.Lock()
+	c.connections[debuggerURL] = conn
+	c.connectionsLock.Unlock()
+
+	return conn, nil
+}
+
+func (c *browserTabController) dropConnection(debuggerURL string) {
+	c.connectionsLock.Lock()
+	defer c.connectionsLock.Unlock()
+
+	if conn, ok := c.connections[debuggerURL]; ok {
+		_ = conn.Close()
+		delete(c.connections, debuggerURL)
+	}
+}
@@ -0,0 +1,148 @@
+package deej
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+// fakeBrowser serves a DevTools target list and answers focus queries, every tab taking answerDelay to do so
+type fakeBrowser struct {
+	server      *httptest.Server
+	answerDelay time.Duration
+
+	lock    sync.Mutex
+	tabs    []string
+	focused string
+}
+
+func newFakeBrowser(t *testing.T, answerDelay time.Duration, tabs ...string) *fakeBrowser {
+	b := &fakeBrowser{answerDelay: answerDelay, tabs: tabs}
+
+	upgrader := websocket.Upgrader{}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/json", func(w http.ResponseWriter, r *http.Request) {
+		b.lock.Lock()
+		defer b.lock.Unlock()
+
+		targets := []browserTarget{}
+		for _, tab := range b.tabs {
+			targets = append(targets, browserTarget{Type: "page", Title: tab, WebSocketDebuggerURL: b.debuggerURL(tab)})
+		}
+
+		_ = json.NewEncoder(w).Encode(targets)
+	})
+	mux.HandleFunc("/devtools/page/", func(w http.ResponseWriter, r *http.Request) {
+		tab := strings.TrimPrefix(r.URL.Path, "/devtools/page/")
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		for {
+			var request struct {
+				ID int `json:"id"`
+			}
+			if err := conn.ReadJSON(&request); err != nil {
+				return
+			}
+
+			time.Sleep(b.answerDelay)
+
+			b.lock.Lock()
+			score := 0
+			if tab == b.focused {
+				score = 3
+			}
+			b.lock.Unlock()
+
+			_ = conn.WriteJSON(map[string]any{"id": request.ID, "result": map[string]any{"result": map[string]any{"value": score}}})
+		}
+	})
+
+	b.server = httptest.NewServer(mux)
+	t.Cleanup(b.server.Close)
+
+	return b
+}
+
+func (b *fakeBrowser) debuggerURL(tab string) string {
+	return fmt.Sprintf("ws://%s/devtools/page/%s", b.server.Listener.Addr(), tab)
+}
+
+func (b *fakeBrowser) port() int {
+	return b.server.Listener.Addr().(*net.TCPAddr).Port
+}
+
+func TestFocusedTabAsksAllTabsAtOnce(t *testing.T) {
+
+	// one after another, these would take three times as long as a single request may
+	tabs := []string{"a", "b", "c", "d", "e", "f"}
+	browser := newFakeBrowser(t, browserRequestTimeout/2, tabs...)
+	browser.focused = "e"
+
+	c := newBrowserTabController(zap.NewNop().Sugar())
+	defer c.Close()
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	start := time.Now()
+	debuggerURL, err := c.focusedTab(browser.port())
+	if err != nil {
+		t.Fatalf("focusedTab() failed: %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 2*browserRequestTimeout {
+		t.Errorf("focusedTab() took %v with %d tabs", elapsed, len(tabs))
+	}
+
+	if expected := browser.debuggerURL("e"); debuggerURL != expected {
+		t.Errorf("focusedTab() = %s, expected %s", debuggerURL, expected)
+	}
+}
+
+func TestClosedTabConnectionsAreDropped(t *testing.T) {
+	browser := newFakeBrowser(t, 0, "a", "b", "c")
+	browser.focused = "a"
+
+	c := newBrowserTabController(zap.NewNop().Sugar())
+	defer c.Close()
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if _, err := c.focusedTab(browser.port()); err != nil {
+		t.Fatalf("focusedTab() failed: %v", err)
+	}
+
+	browser.lock.Lock()
+	browser.tabs = []string{"a"}
+	browser.lock.Unlock()
+
+	// past the cache, so the target list is fetched again
+	delete(c.focusedTabs, browser.port())
+
+	if _, err := c.focusedTab(browser.port()); err != nil {
+		t.Fatalf("focusedTab() failed: %v", err)
+	}
+
+	c.connectionsLock.Lock()
+	defer c.connectionsLock.Unlock()
+
+	if _, ok := c.connections[browser.debuggerURL("a")]; len(c.connections) != 1 || !ok {
+		t.Errorf("connections = %v after tabs b and c closed, expected only a", c.connections)
+	}
+}
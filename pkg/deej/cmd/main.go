@@ -16,6 +16,7 @@ var (
 
 	verbose    bool
 	configPath string
+	dryRun     bool
 )
 
 func init() {
@@ -23,6 +24,7 @@ func init() {
 	flag.BoolVar(&verbose, "v", false, "shorthand for --verbose")
 	flag.StringVar(&configPath, "config", "", "custom config file path")
 	flag.StringVar(&configPath, "c", "", "shorthand for --config")
+	flag.BoolVar(&dryRun, "dry-run", false, "use fake audio sessions instead of real ones (set DEEJ_MOCK_SESSIONS to choose them)")
 	flag.Parse()
 }
 
@@ -48,7 +50,7 @@ func main() {
 	}
 
 	// create the deej instance
-	d, err := deej.NewDeej(logger, verbose, configPath)
+	d, err := deej.NewDeej(logger, verbose, configPath, dryRun)
 	if err != nil {
 		named.Fatalw("Failed to create deej object", "error", err)
 	}
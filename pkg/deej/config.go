@@ -1,18 +1,28 @@
 package deej
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"math"
+	"net"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/nicksnyder/go-i18n/v2/i18n"
+	"github.com/spf13/cast"
 	"github.com/spf13/viper"
+	"github.com/thoas/go-funk"
 	"go.uber.org/zap"
 
 	"github.com/nik9play/deej/pkg/deej/util"
+	"github.com/nik9play/deej/pkg/icon"
 	"github.com/nik9play/deej/pkg/notify"
 )
 
@@ -29,33 +39,196 @@ type CanonicalConfig struct {
 	ConnectionInfo struct {
 		COMPort  string
 		BaudRate int
+
+		// how the board formats its lines, one of serialProtocols
+		Protocol string
+
+		// the range classic lines send slider values in, one of sliderValueRanges
+		SliderValueRange string
+
+		// only accept lines ending with a matching checksum, i.e. "512|1023*4A"
+		Checksum bool
+
+		// how long to wait before the first connection attempt, for boards that show up late at boot
+		StartupDelay time.Duration
 	}
 
-	InvertSliders bool
+	// invert_sliders is either a bool for every slider, or a map of slider index to bool
+	InvertSliders    bool
+	InvertSlidersMap map[int]bool
+
+	// names for sliders by index, only used to tell them apart in logs and the tray
+	SliderLabels map[int]string
+
+	// slider pairs acting as one stereo control, keyed by either slider's index.
+	// the pair's first slider holds the mapping, the second one only sets the right channel
+	StereoPairs map[int]stereoPair
+
+	LogVolumeChanges bool
+
+	// whether the master, mic and system sessions exist at all. off for users who only want per-app control
+	RegisterMaster bool
+
+	// lowercase process names deej.unmapped leaves alone, as if they were mapped to a slider
+	UnmappedIgnore []string
+
+	// hold back reloads that would leave every slider without a target until the file is saved again
+	ConfirmRiskyReloads bool
 
 	NoiseReductionLevel string
 
+	// per-slider noise reduction levels, for sliders noisier than the rest. sliders not in here use NoiseReductionLevel
+	NoiseReductionLevelsMap map[int]string
+
+	// weight of the previous reading in each slider's moving average, 0 turns smoothing off
+	SliderSmoothing float64
+
+	// slider positions this close to either end count as being at that end, 0 turns this off
+	SliderDeadzone float64
+
+	// how slider positions map to volume levels
+	VolumeCurve util.CurveSpec
+
+	// which notifications to show: all of them, only errors, or none at all
+	NotificationLevel string
+
+	// one of trayIconModes, or the path to a custom .ico/.png file
+	TrayIcon string
+
+	// one of logLevels, and the size in bytes the log file is rotated at (0 = never)
+	LogLevel       string
+	LogFileMaxSize int64
+
+	// spread volume changes over this long to avoid audible steps, 0 sets volumes right away
+	VolumeRamp time.Duration
+
+	// how long a 'deej.current.locked' slider has to sit still before it lets go of the app it latched onto
+	CurrentWindowLockTimeout time.Duration
+
+	// global hotkey that fades every mapped session out, and back in on a second press. empty turns it off
+	PanicHotkey string
+
+	// per-consumer policy for slider move events that pile up faster than they're handled
+	SliderMoveBackpressure map[string]string
+
+	// how many move events per second each slider may send at most, 0 for no limit
+	MaxSliderRate int
+
 	Language string
 
+	AutoRescanInterval time.Duration
+
 	AutoSearchVIDPID VIDPID
 
+	// shell commands usable with deej.exec:<name>, keyed by lowercase name. only run if AllowExecTargets is set
+	AllowExecTargets bool
+	ExecCommands     map[string]string
+
+	// what to do when a slider moves and one of its targets has no session, keyed by lowercase target.
+	// targets that aren't listed are ignored, same as with missingTargetIgnore
+	OnMissingTarget map[string]missingTargetPolicy
+
+	// actions to run when the board connects or disconnects: deej.rescan, deej.exec:<name> or a command
+	OnConnect    string
+	OnDisconnect string
+
+	// remote debugging ports of browsers usable with deej.tab:<browser>, keyed by lowercase process name
+	BrowserDebuggingPorts map[string]int
+
+	// PulseAudio sink input properties to take session keys from, first one present wins. linux-only
+	LinuxSessionKeyProperties []string
+
+	// pin master and mic to specific devices instead of the (possibly ambiguous) defaults. windows-only
+	MasterOutputDeviceID string
+	MasterInputDeviceID  string
+
 	OBSConfig struct {
 		Enabled  bool
 		Host     string
 		Port     int
 		Password string
+
+		// set when host or port can't possibly work, in which case deej doesn't try connecting
+		AddressError error
+
+		// keyed by lowercase input name
+		VolumeRanges map[string]OBSVolumeRange
+
+		// scenes to switch to as sliders move through ranges, keyed by slider index
+		SceneSwitches map[int][]OBSSceneRange
+	}
+
+	// local HTTP server for reading and setting volumes from other tools
+	HTTPAPIConfig struct {
+		Enabled bool
+		Host    string
+		Port    int
+	}
+
+	// Discord's local RPC, for muting and deafening from a slider. needs the user's own Discord application
+	DiscordConfig struct {
+		Enabled      bool
+		ClientID     string
+		ClientSecret string
+	}
+
+	// an MQTT broker to publish slider positions to, and take remote slider moves from
+	MQTTConfig struct {
+		Enabled bool
+
+		// host:port, with MQTT's default port added if it's left out
+		Broker      string
+		TopicPrefix string
+		Username    string
+		Password    string
+	}
+
+	// Voicemeeter's Remote API, for setting strip and bus gains. windows-only
+	VoicemeeterConfig struct {
+		Enabled bool
+
+		// the gains in dB the bottom and top of a slider map to
+		MinGain float64
+		MaxGain float64
 	}
 
 	logger             *zap.SugaredLogger
-	notifier           notify.Notifier
+	notifier           *filteredNotifier
 	stopWatcherChannel chan bool
 
-	reloadConsumers []chan bool
+	// closed after the config has been loaded for the first time
+	loadedChannel chan struct{}
+	loadedOnce    sync.Once
+
+	reloadConsumers     []chan bool
+	reloadConsumersLock sync.Mutex
+
+	// serializes reloads from the file watcher with programmatic config changes
+	reloadLock sync.Mutex
+
+	// set while a risky reload is being held back, so that saving the same file again applies it
+	riskyReloadPending bool
+
+	// the user config file contents last applied. viper goes back to these while a reload is held back,
+	// so nothing reading from it in the meantime picks up the held back file instead
+	appliedUserConfig []byte
 
 	userConfig     *viper.Viper
 	internalConfig *viper.Viper
 
-	configPath string
+	configPath        string
+	internalConfigDir string
+
+	// deej's directories only need checking for write access once
+	writableCheckOnce sync.Once
+
+	// keys with values deej couldn't make sense of during the last load, and the ones the user was last told about
+	configProblems         []string
+	notifiedConfigProblems []string
+
+	// targets mapped to more than one slider during the last load (i.e. "master (0, 2)"), and the ones the user was last told about
+	mappingConflicts         []string
+	notifiedMappingConflicts []string
 }
 
 const (
@@ -65,20 +238,101 @@ const (
 
 	configKeySliderMapping       = "slider_mapping"
 	configKeyInvertSliders       = "invert_sliders"
+	configKeySliderLabels        = "slider_labels"
+	configKeyStereoPairs         = "stereo_pairs"
+	configKeyLogVolumeChanges    = "log_volume_changes"
+	configKeyRegisterMaster      = "register_master"
+	configKeyUnmappedIgnore      = "unmapped_ignore"
+	configKeyConfirmRiskyReloads = "confirm_risky_reloads"
 	configKeyCOMPort             = "com_port"
 	configKeyBaudRate            = "baud_rate"
+	configKeyProtocol            = "protocol"
+	configKeySerialChecksum      = "serial_checksum"
+	configKeyStartupDelay        = "startup_delay_ms"
+	configKeySliderValueRange    = "slider_value_range"
 	configKeyNoiseReductionLevel = "noise_reduction"
+	configKeySliderSmoothing     = "slider_smoothing"
+	configKeyVolumeRamp          = "volume_ramp_ms"
+	configKeyCurrentWindowLock   = "current_window_lock_ms"
+	configKeyPanicHotkey         = "panic_hotkey"
+	configKeySliderDeadzone      = "slider_deadzone"
+	configKeyVolumeCurve         = "volume_curve"
+	configKeySliderBackpressure  = "slider_event_backpressure"
+	configKeyMaxSliderRate       = "max_slider_rate_hz"
 	configKeyLanguage            = "language"
+	configKeyAutoRescanInterval  = "auto_rescan_interval"
 	configKeyComVID              = "com_vid"
 	configKeyComPID              = "com_pid"
+	configKeyMasterOutputDevice  = "master_output_device_id"
+	configKeyLinuxSessionKey     = "linux_session_key"
+	configKeyBrowserDebugging    = "browser_debugging_ports"
+	configKeyAllowExecTargets    = "allow_exec_targets"
+	configKeyExecCommands        = "exec_commands"
+	configKeyOnConnect           = "on_connect"
+	configKeyOnMissingTarget     = "on_missing_target"
+	configKeyOnDisconnect        = "on_disconnect"
+	configKeyMasterInputDevice   = "master_input_device_id"
+	configKeyNotificationLevel   = "notifications.level"
+	configKeyTrayIcon            = "tray_icon"
+	configKeyLogLevel            = "log_level"
+	configKeyLogMaxSize          = "log_max_size_mb"
 	configKeyOBSEnabled          = "obs.enabled"
 	configKeyOBSHost             = "obs.host"
 	configKeyOBSPort             = "obs.port"
 	configKeyOBSPassword         = "obs.password"
+	configKeyOBSVolumeRanges     = "obs.volume_ranges"
+	configKeyOBSSceneSwitches    = "obs.scene_switches"
+	configKeyHTTPAPIEnabled      = "http_api.enabled"
+	configKeyHTTPAPIHost         = "http_api.host"
+	configKeyHTTPAPIPort         = "http_api.port"
+	configKeyDiscordEnabled      = "discord.enabled"
+	configKeyDiscordClientID     = "discord.client_id"
+	configKeyDiscordClientSecret = "discord.client_secret"
+	configKeyMQTTEnabled         = "mqtt.enabled"
+	configKeyMQTTBroker          = "mqtt.broker"
+	configKeyMQTTTopicPrefix     = "mqtt.topic_prefix"
+	configKeyMQTTUsername        = "mqtt.username"
+	configKeyMQTTPassword        = "mqtt.password"
+	configKeyVoicemeeterEnabled  = "voicemeeter.enabled"
+	configKeyVoicemeeterMinGain  = "voicemeeter.min_db"
+	configKeyVoicemeeterMaxGain  = "voicemeeter.max_db"
+
+	// internal config only, so Discord doesn't ask for authorization every time deej starts
+	configKeyDiscordRefreshToken = "discord_refresh_token"
+	configKeyLastSliderValues    = "last_slider_values"
 
 	defaultCOMPort  = "COM4"
 	defaultBaudRate = 9600
-	defaultLanguage = "auto"
+	defaultProtocol = serialProtocolClassic
+
+	// raw 10-bit readings, what the stock firmware sends
+	defaultSliderValueRange = sliderValueRangeRaw
+	defaultLanguage         = "auto"
+
+	// any more smoothing than this makes sliders feel sluggish
+	maxSliderSmoothing = 0.9
+
+	// a deadzone any bigger than this would swallow a good part of the slider
+	maxSliderDeadzone = 0.25
+
+	// USB devices are enumerated well within this, even on a slow boot
+	maxStartupDelay = 2 * time.Minute
+
+	// ramps longer than this make sliders feel disconnected from the volume
+	maxVolumeRamp = time.Second
+
+	// long enough to finish adjusting a volume, short enough to not forget the slider is latched
+	defaultCurrentWindowLock = 1500 * time.Millisecond
+	maxCurrentWindowLock     = time.Minute
+
+	// boards don't send lines anywhere near this fast, so a higher limit wouldn't limit anything
+	maxSliderRate = 1000
+
+	// log files bigger than this are too unwieldy to send along with a bug report anyway
+	maxLogFileSizeMB = 1024
+
+	// automatic session rescans are off unless asked for, and never more often than this
+	minAutoRescanInterval = 10 * time.Second
 
 	// ch340 chip
 	defaultVID uint64 = 0x1A86
@@ -88,10 +342,58 @@ const (
 	defaultOBSHost     = "localhost"
 	defaultOBSPort     = 4455
 	defaultOBSPassword = ""
+
+	// the HTTP API only listens locally unless told otherwise
+	defaultHTTPAPIEnabled = false
+	defaultHTTPAPIHost    = "localhost"
+	defaultHTTPAPIPort    = 7433
+
+	defaultMQTTBroker      = "localhost:" + mqttDefaultPort
+	defaultMQTTTopicPrefix = "deej"
+
+	// sliders cover Voicemeeter's whole fader by default, but stop at unity gain rather than boosting
+	defaultVoicemeeterMinGain = voicemeeterMinGainDb
+	defaultVoicemeeterMaxGain = 0.0
+
+	// policies for targets without a session, see missingTargetPolicy
+	missingTargetIgnore       = "ignore"
+	missingTargetRefresh      = "refresh"
+	missingTargetLaunchPrefix = "launch:"
+
+	// slider move backpressure policies, applied once a consumer's queue is full: block keeps every event
+	// (up to a much larger queue), the others drop the oldest or newest one. serial reads never wait either way
+	backpressureBlock      = "block"
+	backpressureDropOldest = "drop_oldest"
+	backpressureDropNewest = "drop_newest"
 )
 
+// missingTargetPolicy is what to do when a slider moves and its target has no session: nothing, rescan
+// sessions in case one was missed, or start the program behind it (launchPath)
+type missingTargetPolicy struct {
+	action     string
+	launchPath string
+}
+
+func parseMissingTargetPolicy(value string) (missingTargetPolicy, bool) {
+	value = strings.TrimSpace(value)
+
+	if strings.HasPrefix(strings.ToLower(value), missingTargetLaunchPrefix) {
+		path := strings.TrimSpace(value[len(missingTargetLaunchPrefix):])
+		return missingTargetPolicy{action: missingTargetLaunchPrefix, launchPath: path}, path != ""
+	}
+
+	switch action := strings.ToLower(value); action {
+	case missingTargetIgnore, missingTargetRefresh:
+		return missingTargetPolicy{action: action}, true
+	}
+
+	return missingTargetPolicy{}, false
+}
+
 // has to be defined as a non-constant because we're using path.Join
 
+var errRiskyReloadHeldBack = errors.New("config reload held back: no slider targets left")
+
 var defaultSliderMapping = func() *sliderMap {
 	emptyMap := newSliderMap()
 	emptyMap.set(0, []string{masterSessionName})
@@ -99,30 +401,67 @@ var defaultSliderMapping = func() *sliderMap {
 	return emptyMap
 }()
 
+// baud rates serial adapters actually run at. anything else is almost certainly a typo
+var knownBaudRates = []int{
+	300, 600, 1200, 2400, 4800, 9600, 14400, 19200, 28800, 31250, 38400, 57600,
+	74880, 115200, 230400, 250000, 460800, 500000, 921600, 1000000, 2000000,
+}
+
+var serialProtocols = []string{serialProtocolClassic, serialProtocolJSON}
+
+var sliderValueRanges = []string{sliderValueRangeRaw, sliderValueRangeNormalized}
+
+var noiseReductionLevels = []string{"low", "default", "high", "none"}
+
+var notificationLevels = []string{notificationLevelAll, notificationLevelErrors, notificationLevelNone}
+
+var trayIconModes = []string{trayIconAuto, trayIconLight, trayIconDark}
+
+// binaries make for predictable keys like "firefox", the others cover apps that don't report one
+var defaultLinuxSessionKeyProperties = []string{
+	"application.process.binary",
+	"application.id",
+	"application.name",
+	"application.process.id",
+}
+
+// the session map must see every slider position to get volumes right, while the tray only
+// ever displays the latest values, so it can safely skip stale ones
+var defaultSliderMoveBackpressure = map[string]string{
+	sliderMoveConsumerSessions: backpressureBlock,
+	sliderMoveConsumerTray:     backpressureDropOldest,
+
+	// a stalled client or broker must never hold up the sliders
+	sliderMoveConsumerWebSocket: backpressureDropOldest,
+	sliderMoveConsumerMQTT:      backpressureDropOldest,
+}
+
 // NewConfig creates a config instance for the deej object and sets up viper instances for deej's config files
 func NewConfig(logger *zap.SugaredLogger, notifier notify.Notifier, configPath string) (*CanonicalConfig, error) {
 	logger = logger.Named("config")
 
-	ex, err := os.Executable()
+	dir, err := dataDir()
 	if err != nil {
-		return nil, fmt.Errorf("get executable dir: %w", err)
+		return nil, err
 	}
 
-	// set config path to exe dir, if custom path is not provided
+	// set config path to the data dir (next to the exe unless DEEJ_DATA_DIR says otherwise), if custom path is not provided
 	if configPath == "" {
-		configPath = filepath.Join(filepath.Dir(ex), "config.yaml")
+		configPath = filepath.Join(dir, "config.yaml")
 	}
 
 	userConfigName := filepath.Base(configPath)
 	configDir := filepath.Dir(configPath)
-	internalConfigDir := filepath.Join(filepath.Dir(ex), "logs")
+	internalConfigDir := filepath.Join(dir, "logs")
 
 	cc := &CanonicalConfig{
 		logger:             logger,
-		notifier:           notifier,
+		notifier:           newFilteredNotifier(notifier, logger),
 		reloadConsumers:    []chan bool{},
 		stopWatcherChannel: make(chan bool),
+		loadedChannel:      make(chan struct{}),
 		configPath:         configPath,
+		internalConfigDir:  internalConfigDir,
 	}
 
 	// distinguish between the user-provided config (config.yaml) and the internal config (logs/preferences.yaml)
@@ -133,15 +472,47 @@ func NewConfig(logger *zap.SugaredLogger, notifier notify.Notifier, configPath s
 
 	userConfig.SetDefault(configKeySliderMapping, map[string][]string{})
 	userConfig.SetDefault(configKeyInvertSliders, false)
+	userConfig.SetDefault(configKeySliderLabels, map[string]string{})
+	userConfig.SetDefault(configKeyLogVolumeChanges, false)
+	userConfig.SetDefault(configKeyRegisterMaster, true)
+	userConfig.SetDefault(configKeyUnmappedIgnore, []string{})
+	userConfig.SetDefault(configKeyConfirmRiskyReloads, false)
+	userConfig.SetDefault(configKeyVolumeCurve, util.CurveLinear)
+	userConfig.SetDefault(configKeySliderSmoothing, 0)
+	userConfig.SetDefault(configKeyVolumeRamp, 0)
+	userConfig.SetDefault(configKeyCurrentWindowLock, defaultCurrentWindowLock.Milliseconds())
+	userConfig.SetDefault(configKeyPanicHotkey, "")
+	userConfig.SetDefault(configKeyMaxSliderRate, 0)
+	userConfig.SetDefault(configKeySliderDeadzone, 0)
 	userConfig.SetDefault(configKeyCOMPort, defaultCOMPort)
 	userConfig.SetDefault(configKeyBaudRate, defaultBaudRate)
+	userConfig.SetDefault(configKeyProtocol, defaultProtocol)
+	userConfig.SetDefault(configKeySerialChecksum, false)
+	userConfig.SetDefault(configKeyStartupDelay, 0)
+	userConfig.SetDefault(configKeySliderValueRange, defaultSliderValueRange)
 	userConfig.SetDefault(configKeyLanguage, defaultLanguage)
+	userConfig.SetDefault(configKeyAutoRescanInterval, 0)
+	userConfig.SetDefault(configKeyNotificationLevel, notificationLevelAll)
+	userConfig.SetDefault(configKeyTrayIcon, trayIconAuto)
+	userConfig.SetDefault(configKeyLogLevel, logLevelDefault)
+	userConfig.SetDefault(configKeyLogMaxSize, 0)
 	userConfig.SetDefault(configKeyComVID, defaultVID)
 	userConfig.SetDefault(configKeyComPID, defaultPID)
+	userConfig.SetDefault(configKeyAllowExecTargets, false)
+	userConfig.SetDefault(configKeyLinuxSessionKey, defaultLinuxSessionKeyProperties)
 	userConfig.SetDefault(configKeyOBSEnabled, defaultOBSEnabled)
 	userConfig.SetDefault(configKeyOBSHost, defaultOBSHost)
 	userConfig.SetDefault(configKeyOBSPort, defaultOBSPort)
 	userConfig.SetDefault(configKeyOBSPassword, defaultOBSPassword)
+	userConfig.SetDefault(configKeyHTTPAPIEnabled, defaultHTTPAPIEnabled)
+	userConfig.SetDefault(configKeyHTTPAPIHost, defaultHTTPAPIHost)
+	userConfig.SetDefault(configKeyHTTPAPIPort, defaultHTTPAPIPort)
+	userConfig.SetDefault(configKeyMQTTEnabled, false)
+	userConfig.SetDefault(configKeyMQTTBroker, defaultMQTTBroker)
+	userConfig.SetDefault(configKeyMQTTTopicPrefix, defaultMQTTTopicPrefix)
+	userConfig.SetDefault(configKeyVoicemeeterEnabled, false)
+	userConfig.SetDefault(configKeyVoicemeeterMinGain, defaultVoicemeeterMinGain)
+	userConfig.SetDefault(configKeyVoicemeeterMaxGain, defaultVoicemeeterMaxGain)
 
 	internalConfig := viper.New()
 	internalConfig.SetConfigName(internalConfigName)
@@ -164,6 +535,12 @@ func (cc *CanonicalConfig) Load(localizer *i18n.Localizer) error {
 	if !util.FileExists(cc.configPath) {
 		cc.logger.Warnw("Config file not found", "path", cc.configPath)
 
+		// the config may live in DEEJ_DATA_DIR or wherever --config points, so spell out exactly where it's expected
+		expectedPath := cc.configPath
+		if absPath, err := filepath.Abs(cc.configPath); err == nil {
+			expectedPath = absPath
+		}
+
 		configNotFoundTitle := localizer.MustLocalize(&i18n.LocalizeConfig{
 			DefaultMessage: &i18n.Message{
 				ID:    "ConfigNotFoundTitle",
@@ -173,19 +550,22 @@ func (cc *CanonicalConfig) Load(localizer *i18n.Localizer) error {
 		configNotFoundDescription := localizer.MustLocalize(&i18n.LocalizeConfig{
 			DefaultMessage: &i18n.Message{
 				ID:    "ConfigNotFoundDescription",
-				Other: "{{.FilePath}} must be in the same directory as deej. Please re-launch.",
+				Other: "Put your config at {{.FilePath}} and re-launch.",
 			},
 			TemplateData: map[string]string{
-				"FilePath": cc.configPath,
+				"FilePath": expectedPath,
 			},
 		})
-		cc.notifier.Notify(configNotFoundTitle, configNotFoundDescription)
+		cc.notifier.Notify(notificationError, configNotFoundTitle, configNotFoundDescription)
 
 		return fmt.Errorf("config file doesn't exist: %s", cc.configPath)
 	}
 
+	cc.writableCheckOnce.Do(func() { cc.checkDirsWritable(localizer) })
+
 	// load the user config
-	if err := cc.userConfig.ReadInConfig(); err != nil {
+	contents, err := cc.readUserConfig()
+	if err != nil {
 		cc.logger.Warnw("Viper failed to read user config", "error", err)
 
 		// if the error is yaml-format-related, show a sensible error. otherwise, show 'em to the logs
@@ -205,7 +585,7 @@ func (cc *CanonicalConfig) Load(localizer *i18n.Localizer) error {
 					"FilePath": cc.configPath,
 				},
 			})
-			cc.notifier.Notify(configInvalidTitle, configInvalidDescription)
+			cc.notifier.Notify(notificationError, configInvalidTitle, configInvalidDescription)
 		} else {
 			configErrorTitle := localizer.MustLocalize(&i18n.LocalizeConfig{
 				DefaultMessage: &i18n.Message{
@@ -219,7 +599,7 @@ func (cc *CanonicalConfig) Load(localizer *i18n.Localizer) error {
 					Other: "Please check deej's logs for more details.",
 				},
 			})
-			cc.notifier.Notify(configErrorTitle, configErrorDescription)
+			cc.notifier.Notify(notificationError, configErrorTitle, configErrorDescription)
 		}
 
 		return fmt.Errorf("read user config: %w", err)
@@ -230,29 +610,246 @@ func (cc *CanonicalConfig) Load(localizer *i18n.Localizer) error {
 		cc.logger.Debugw("Viper failed to read internal config", "error", err, "reminder", "this is fine")
 	}
 
+	// don't let an accidentally emptied config silently take every slider down
+	if cc.isRiskyReload() {
+		if !cc.riskyReloadPending {
+			cc.riskyReloadPending = true
+			cc.logger.Warnw("Config reload would leave all sliders without targets, keeping previous config",
+				"previousMapping", cc.SliderMapping)
+
+			riskyReloadTitle := localizer.MustLocalize(&i18n.LocalizeConfig{
+				DefaultMessage: &i18n.Message{
+					ID:    "RiskyReloadTitle",
+					Other: "Configuration change held back",
+				},
+			})
+			riskyReloadDescription := localizer.MustLocalize(&i18n.LocalizeConfig{
+				DefaultMessage: &i18n.Message{
+					ID:    "RiskyReloadDescription",
+					Other: "The new configuration has no slider targets. Save {{.FilePath}} again to apply it anyway.",
+				},
+				TemplateData: map[string]string{
+					"FilePath": cc.configPath,
+				},
+			})
+			cc.notifier.Notify(notificationError, riskyReloadTitle, riskyReloadDescription)
+
+			cc.restoreUserConfig()
+
+			return errRiskyReloadHeldBack
+		}
+
+		cc.logger.Info("Config saved again, applying previously held back reload")
+	}
+	cc.riskyReloadPending = false
+
 	// canonize the configuration with viper's helpers
 	if err := cc.populateFromVipers(); err != nil {
 		cc.logger.Warnw("Failed to populate config fields", "error", err)
 		return fmt.Errorf("populate config fields: %w", err)
 	}
 
+	cc.appliedUserConfig = contents
+
+	cc.notifyConfigProblems(localizer)
+	cc.notifyMappingConflicts(localizer)
+
+	cc.loadedOnce.Do(func() { close(cc.loadedChannel) })
+
 	cc.logger.Info("Loaded config successfully")
 	cc.logger.Infow("Config values",
 		"sliderMapping", cc.SliderMapping,
 		"connectionInfo", cc.ConnectionInfo,
-		"invertSliders", cc.InvertSliders)
+		"invertSliders", cc.InvertSliders,
+		"invertSlidersMap", cc.InvertSlidersMap,
+		"sliderLabels", cc.SliderLabels)
 
 	return nil
 }
 
+// checkDirsWritable tells the user up front when deej can't save its files, typically because it's
+// installed somewhere like Program Files. deej still runs, but settings changed from the tray won't stick
+func (cc *CanonicalConfig) checkDirsWritable(localizer *i18n.Localizer) {
+	for _, dir := range []string{filepath.Dir(cc.configPath), cc.internalConfigDir} {
+		err := util.EnsureDirWritable(dir)
+		if err == nil {
+			continue
+		}
+
+		cc.logger.Warnw("Directory isn't writable", "path", dir, "error", err)
+
+		dirNotWritableTitle := localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{
+				ID:    "DirNotWritableTitle",
+				Other: "Can't save settings!",
+			},
+		})
+		dirNotWritableDescription := localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{
+				ID:    "DirNotWritableDescription",
+				Other: "deej can't write to {{.Path}}. Move deej to a folder you own (not Program Files) and re-launch.",
+			},
+			TemplateData: map[string]string{
+				"Path": dir,
+			},
+		})
+		cc.notifier.Notify(notificationError, dirNotWritableTitle, dirNotWritableDescription)
+
+		// one notification is enough, the fix is the same either way
+		return
+	}
+}
+
+// notifyConfigProblems tells the user which keys had values deej couldn't use. the same problems aren't
+// repeated on every reload, only once they change
+func (cc *CanonicalConfig) notifyConfigProblems(localizer *i18n.Localizer) {
+	problems := cc.configProblems
+	sort.Strings(problems)
+
+	if funk.Equal(problems, cc.notifiedConfigProblems) {
+		return
+	}
+
+	cc.notifiedConfigProblems = problems
+
+	if len(problems) == 0 {
+		return
+	}
+
+	configProblemsTitle := localizer.MustLocalize(&i18n.LocalizeConfig{
+		DefaultMessage: &i18n.Message{
+			ID:    "ConfigProblemsTitle",
+			Other: "Some settings are invalid",
+		},
+	})
+	configProblemsDescription := localizer.MustLocalize(&i18n.LocalizeConfig{
+		DefaultMessage: &i18n.Message{
+			ID:    "ConfigProblemsDescription",
+			Other: "Please check {{.Keys}} in {{.FilePath}}. deej is ignoring them or using defaults for now.",
+		},
+		TemplateData: map[string]string{
+			"Keys":     strings.Join(problems, ", "),
+			"FilePath": cc.configPath,
+		},
+	})
+	cc.notifier.Notify(notificationError, configProblemsTitle, configProblemsDescription)
+}
+
+// notifyMappingConflicts tells the user about targets mapped to several sliders. like config problems,
+// the same conflicts aren't reported again on every reload
+func (cc *CanonicalConfig) notifyMappingConflicts(localizer *i18n.Localizer) {
+	conflicts := cc.mappingConflicts
+	sort.Strings(conflicts)
+
+	if funk.Equal(conflicts, cc.notifiedMappingConflicts) {
+		return
+	}
+
+	cc.notifiedMappingConflicts = conflicts
+
+	if len(conflicts) == 0 {
+		return
+	}
+
+	mappingConflictsTitle := localizer.MustLocalize(&i18n.LocalizeConfig{
+		DefaultMessage: &i18n.Message{
+			ID:    "MappingConflictsTitle",
+			Other: "Some apps are on more than one slider",
+		},
+	})
+	mappingConflictsDescription := localizer.MustLocalize(&i18n.LocalizeConfig{
+		DefaultMessage: &i18n.Message{
+			ID:    "MappingConflictsDescription",
+			Other: "{{.Conflicts}}. These sliders will override each other, consider keeping each app on a single slider.",
+		},
+		TemplateData: map[string]string{
+			"Conflicts": strings.Join(conflicts, "; "),
+		},
+	})
+	cc.notifier.Notify(notificationError, mappingConflictsTitle, mappingConflictsDescription)
+}
+
+// readUserConfig feeds the user config file to viper, after cleaning up the byte order mark and
+// CRLF line endings that Windows editors like to leave behind. it returns the contents viper got
+func (cc *CanonicalConfig) readUserConfig() ([]byte, error) {
+	contents, err := os.ReadFile(cc.configPath)
+	if err != nil {
+		return nil, fmt.Errorf("read config file: %w", err)
+	}
+
+	contents = normalizeConfigContents(contents)
+
+	if err := cc.userConfig.ReadConfig(bytes.NewReader(contents)); err != nil {
+		return nil, err
+	}
+
+	return contents, nil
+}
+
+// restoreUserConfig puts the last applied user config back into viper, after a reload was held back
+func (cc *CanonicalConfig) restoreUserConfig() {
+	if err := cc.userConfig.ReadConfig(bytes.NewReader(cc.appliedUserConfig)); err != nil {
+		cc.logger.Warnw("Failed to restore previous user config", "error", err)
+	}
+}
+
+// normalizeConfigContents strips a leading UTF-8 byte order mark and converts line endings to LF
+func normalizeConfigContents(contents []byte) []byte {
+	contents = bytes.TrimPrefix(contents, []byte("\xef\xbb\xbf"))
+	contents = bytes.ReplaceAll(contents, []byte("\r\n"), []byte("\n"))
+
+	return bytes.ReplaceAll(contents, []byte("\r"), []byte("\n"))
+}
+
+// isRiskyReload reports whether applying the freshly read config would leave every slider without a target,
+// when the currently applied one has some. only checked if the user opted into it.
+// targets that don't resolve to anything aren't checked for: whether they do depends on which apps are running,
+// so a config naming apps that aren't open yet would look exactly like a broken one
+func (cc *CanonicalConfig) isRiskyReload() bool {
+
+	// nothing to protect on first load
+	if !cc.ConfirmRiskyReloads || cc.SliderMapping == nil || cc.SliderMapping.targetCount() == 0 {
+		return false
+	}
+
+	newMapping := sliderMapFromConfigs(
+		cc.userConfig.GetStringMapStringSlice(configKeySliderMapping),
+		cc.internalConfig.GetStringMapStringSlice(configKeySliderMapping),
+	)
+
+	return newMapping.targetCount() == 0
+}
+
+// loaded returns a channel that's closed once the config has been successfully loaded for the first time
+func (cc *CanonicalConfig) loaded() <-chan struct{} {
+	return cc.loadedChannel
+}
+
 // SubscribeToChanges allows external components to receive updates when the config is reloaded
 func (cc *CanonicalConfig) SubscribeToChanges() chan bool {
 	c := make(chan bool)
+
+	cc.reloadConsumersLock.Lock()
 	cc.reloadConsumers = append(cc.reloadConsumers, c)
+	cc.reloadConsumersLock.Unlock()
 
 	return c
 }
 
+// UnsubscribeFromChanges stops delivering reload notifications to a channel from SubscribeToChanges.
+// consumers that stop listening must unsubscribe, otherwise the next reload would wait on them forever
+func (cc *CanonicalConfig) UnsubscribeFromChanges(c chan bool) {
+	cc.reloadConsumersLock.Lock()
+	defer cc.reloadConsumersLock.Unlock()
+
+	for idx, consumer := range cc.reloadConsumers {
+		if consumer == c {
+			cc.reloadConsumers = append(cc.reloadConsumers[:idx], cc.reloadConsumers[idx+1:]...)
+			return
+		}
+	}
+}
+
 // WatchConfigFileChanges starts watching for configuration file changes
 // and attempts reloading the config when they happen
 func (cc *CanonicalConfig) WatchConfigFileChanges(localizer *i18n.Localizer) {
@@ -283,26 +880,8 @@ func (cc *CanonicalConfig) WatchConfigFileChanges(localizer *i18n.Localizer) {
 				// wait a bit to let the editor actually flush the new file contents to disk
 				time.Sleep(delayBetweenEventAndReload)
 
-				if err := cc.Load(localizer); err != nil {
+				if err := cc.Reload(localizer); err != nil {
 					cc.logger.Warnw("Failed to reload config file", "error", err)
-				} else {
-					cc.logger.Info("Reloaded config successfully")
-
-					configReloadTitle := localizer.MustLocalize(&i18n.LocalizeConfig{
-						DefaultMessage: &i18n.Message{
-							ID:    "ConfigReloadTitle",
-							Other: "Configuration reloaded!",
-						},
-					})
-					configReloadDescription := localizer.MustLocalize(&i18n.LocalizeConfig{
-						DefaultMessage: &i18n.Message{
-							ID:    "ConfigReloadDescription",
-							Other: "Your changes have been applied.",
-						},
-					})
-					cc.notifier.Notify(configReloadTitle, configReloadDescription)
-
-					cc.onConfigReloaded()
 				}
 
 				// don't forget to update the time
@@ -317,12 +896,56 @@ func (cc *CanonicalConfig) WatchConfigFileChanges(localizer *i18n.Localizer) {
 	cc.userConfig.OnConfigChange(nil)
 }
 
+// Reload reads the config file again and lets everyone who's subscribed to changes know about it.
+// the file watcher does this on every save, but it can also be triggered by hand for saves the watcher misses
+func (cc *CanonicalConfig) Reload(localizer *i18n.Localizer) error {
+	cc.reloadLock.Lock()
+	err := cc.Load(localizer)
+	cc.reloadLock.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	cc.logger.Info("Reloaded config successfully")
+
+	configReloadTitle := localizer.MustLocalize(&i18n.LocalizeConfig{
+		DefaultMessage: &i18n.Message{
+			ID:    "ConfigReloadTitle",
+			Other: "Configuration reloaded!",
+		},
+	})
+	configReloadDescription := localizer.MustLocalize(&i18n.LocalizeConfig{
+		DefaultMessage: &i18n.Message{
+			ID:    "ConfigReloadDescription",
+			Other: "Your changes have been applied.",
+		},
+	})
+	cc.notifier.Notify(notificationInfo, configReloadTitle, configReloadDescription)
+
+	cc.onConfigReloaded()
+
+	return nil
+}
+
 // StopWatchingConfigFile signals our filesystem watcher to stop
 func (cc *CanonicalConfig) StopWatchingConfigFile() {
 	cc.stopWatcherChannel <- true
 }
 
 func (cc *CanonicalConfig) populateFromVipers() error {
+	cc.configProblems = []string{}
+
+	// slider indexes have to be numbers, anything else would quietly end up on slider 0
+	for sliderIdxString := range cc.userConfig.GetStringMapStringSlice(configKeySliderMapping) {
+		if _, ok := parseSliderIndex(sliderIdxString); !ok {
+			cc.logger.Warnw("Invalid slider index in slider mapping, ignoring it",
+				"key", configKeySliderMapping,
+				"invalidValue", sliderIdxString)
+
+			cc.configProblems = append(cc.configProblems, configKeySliderMapping+"."+sliderIdxString)
+		}
+	}
 
 	// merge the slider mappings from the user and internal configs
 	cc.SliderMapping = sliderMapFromConfigs(
@@ -334,40 +957,962 @@ func (cc *CanonicalConfig) populateFromVipers() error {
 	cc.ConnectionInfo.COMPort = cc.userConfig.GetString(configKeyCOMPort)
 
 	cc.ConnectionInfo.BaudRate = cc.userConfig.GetInt(configKeyBaudRate)
-	if cc.ConnectionInfo.BaudRate <= 0 {
+	if !funk.ContainsInt(knownBaudRates, cc.ConnectionInfo.BaudRate) {
 		cc.logger.Warnw("Invalid baud rate specified, using default value",
 			"key", configKeyBaudRate,
-			"invalidValue", cc.ConnectionInfo.BaudRate,
+			"invalidValue", cc.userConfig.Get(configKeyBaudRate),
 			"defaultValue", defaultBaudRate)
 
 		cc.ConnectionInfo.BaudRate = defaultBaudRate
+		cc.configProblems = append(cc.configProblems, configKeyBaudRate)
+	}
+
+	cc.ConnectionInfo.Protocol = cc.userConfig.GetString(configKeyProtocol)
+	if !funk.ContainsString(serialProtocols, cc.ConnectionInfo.Protocol) {
+		cc.logger.Warnw("Invalid serial protocol specified, using default value",
+			"key", configKeyProtocol,
+			"invalidValue", cc.ConnectionInfo.Protocol,
+			"defaultValue", defaultProtocol)
+
+		cc.ConnectionInfo.Protocol = defaultProtocol
+		cc.configProblems = append(cc.configProblems, configKeyProtocol)
+	}
+
+	cc.ConnectionInfo.Checksum = cc.userConfig.GetBool(configKeySerialChecksum)
+
+	cc.ConnectionInfo.StartupDelay = time.Duration(cc.userConfig.GetInt(configKeyStartupDelay)) * time.Millisecond
+	if cc.ConnectionInfo.StartupDelay < 0 || cc.ConnectionInfo.StartupDelay > maxStartupDelay {
+		cc.logger.Warnw("Invalid startup delay specified, connecting right away",
+			"key", configKeyStartupDelay,
+			"invalidValue", cc.userConfig.GetInt(configKeyStartupDelay),
+			"maxValue", maxStartupDelay.Milliseconds())
+
+		cc.ConnectionInfo.StartupDelay = 0
+		cc.configProblems = append(cc.configProblems, configKeyStartupDelay)
+	}
+
+	cc.ConnectionInfo.SliderValueRange = strings.TrimSpace(cc.userConfig.GetString(configKeySliderValueRange))
+	if !funk.ContainsString(sliderValueRanges, cc.ConnectionInfo.SliderValueRange) {
+		cc.logger.Warnw("Invalid slider value range specified, using default value",
+			"key", configKeySliderValueRange,
+			"invalidValue", cc.ConnectionInfo.SliderValueRange,
+			"allowedValues", sliderValueRanges,
+			"defaultValue", defaultSliderValueRange)
+
+		cc.ConnectionInfo.SliderValueRange = defaultSliderValueRange
+		cc.configProblems = append(cc.configProblems, configKeySliderValueRange)
+	}
+
+	cc.InvertSliders, cc.InvertSlidersMap = cc.parseInvertSliders()
+	cc.StereoPairs = cc.parseStereoPairs()
+	cc.SliderLabels = cc.parseSliderLabels()
+
+	// the same target on several sliders makes them fight over its volume. that's allowed, but worth a warning.
+	// the second slider of a stereo pair goes by the first one's mapping, so its own doesn't count
+	pairedSliders := map[int]bool{}
+	for _, pair := range cc.StereoPairs {
+		pairedSliders[pair.Right] = true
+	}
+
+	cc.mappingConflicts = []string{}
+	for target, sliderIdxs := range cc.SliderMapping.conflictingTargets(pairedSliders) {
+		cc.logger.Warnw("Target mapped to more than one slider, they will override each other",
+			"key", configKeySliderMapping,
+			"target", target,
+			"sliders", sliderIdxs)
+
+		sliderIdxStrings := make([]string, len(sliderIdxs))
+		for idx, sliderIdx := range sliderIdxs {
+			sliderIdxStrings[idx] = strconv.Itoa(sliderIdx)
+		}
+
+		cc.mappingConflicts = append(cc.mappingConflicts,
+			fmt.Sprintf("%s (%s)", target, strings.Join(sliderIdxStrings, ", ")))
+	}
+	cc.LogVolumeChanges = cc.userConfig.GetBool(configKeyLogVolumeChanges)
+
+	cc.UnmappedIgnore = []string{}
+	for _, processName := range cc.userConfig.GetStringSlice(configKeyUnmappedIgnore) {
+		if processName = normalizeSessionKey(strings.TrimSpace(processName)); processName != "" {
+			cc.UnmappedIgnore = append(cc.UnmappedIgnore, processName)
+		}
+	}
+
+	cc.ConfirmRiskyReloads = cc.userConfig.GetBool(configKeyConfirmRiskyReloads)
+
+	cc.RegisterMaster = cc.userConfig.GetBool(configKeyRegisterMaster)
+	if !cc.RegisterMaster {
+		cc.SliderMapping.iterate(func(sliderID int, targets []string) {
+			for _, target := range targets {
+				if funk.ContainsString([]string{masterSessionName, systemSessionName, inputSessionName}, normalizeSessionKey(target)) {
+					cc.logger.Warnw("Slider is mapped to a session that isn't registered, it won't do anything",
+						"key", configKeyRegisterMaster,
+						"slider", sliderID,
+						"target", target)
+				}
+			}
+		})
+	}
+	cc.NoiseReductionLevel, cc.NoiseReductionLevelsMap = cc.parseNoiseReductionLevels()
+
+	cc.NotificationLevel = strings.ToLower(strings.TrimSpace(cc.userConfig.GetString(configKeyNotificationLevel)))
+	if !funk.ContainsString(notificationLevels, cc.NotificationLevel) {
+		cc.logger.Warnw("Invalid notification level specified, using default value",
+			"key", configKeyNotificationLevel,
+			"invalidValue", cc.NotificationLevel,
+			"allowedValues", notificationLevels,
+			"defaultValue", notificationLevelAll)
+
+		cc.NotificationLevel = notificationLevelAll
+		cc.configProblems = append(cc.configProblems, configKeyNotificationLevel)
 	}
 
-	cc.InvertSliders = cc.userConfig.GetBool(configKeyInvertSliders)
-	cc.NoiseReductionLevel = cc.userConfig.GetString(configKeyNoiseReductionLevel)
+	cc.notifier.setLevel(cc.NotificationLevel)
+
+	// anything that isn't one of the modes is taken as a path, which has to hold an icon the tray can show
+	cc.TrayIcon = strings.TrimSpace(cc.userConfig.GetString(configKeyTrayIcon))
+	if funk.ContainsString(trayIconModes, strings.ToLower(cc.TrayIcon)) {
+		cc.TrayIcon = strings.ToLower(cc.TrayIcon)
+	} else if _, err := icon.TrayIconFromFile(cc.TrayIcon); err != nil {
+		cc.logger.Warnw("Invalid tray icon specified, using default value",
+			"key", configKeyTrayIcon,
+			"invalidValue", cc.TrayIcon,
+			"error", err,
+			"allowedValues", trayIconModes,
+			"defaultValue", trayIconAuto)
+
+		cc.TrayIcon = trayIconAuto
+		cc.configProblems = append(cc.configProblems, configKeyTrayIcon)
+	}
+
+	cc.LogLevel = strings.ToLower(strings.TrimSpace(cc.userConfig.GetString(configKeyLogLevel)))
+	if !funk.ContainsString(logLevels, cc.LogLevel) {
+		cc.logger.Warnw("Invalid log level, using default value",
+			"key", configKeyLogLevel,
+			"invalidValue", cc.LogLevel,
+			"allowedValues", logLevels)
+
+		cc.LogLevel = logLevelDefault
+		cc.configProblems = append(cc.configProblems, configKeyLogLevel)
+	}
+
+	setLogLevel(cc.LogLevel)
+
+	logMaxSizeMB := cc.userConfig.GetInt(configKeyLogMaxSize)
+	if logMaxSizeMB < 0 || logMaxSizeMB > maxLogFileSizeMB {
+		cc.logger.Warnw("Invalid log file size limit, using default value",
+			"key", configKeyLogMaxSize,
+			"invalidValue", logMaxSizeMB,
+			"maxValue", maxLogFileSizeMB,
+			"defaultValue", 0)
+
+		logMaxSizeMB = 0
+		cc.configProblems = append(cc.configProblems, configKeyLogMaxSize)
+	}
+
+	cc.LogFileMaxSize = int64(logMaxSizeMB) * 1024 * 1024
+	logFile.setMaxSize(cc.LogFileMaxSize)
+
+	cc.SliderSmoothing = cc.userConfig.GetFloat64(configKeySliderSmoothing)
+	if cc.SliderSmoothing < 0 || cc.SliderSmoothing > maxSliderSmoothing {
+		cc.logger.Warnw("Invalid slider smoothing specified, turning smoothing off",
+			"key", configKeySliderSmoothing,
+			"invalidValue", cc.SliderSmoothing,
+			"maxValue", maxSliderSmoothing)
+
+		cc.SliderSmoothing = 0
+	}
+
+	cc.SliderDeadzone = cc.userConfig.GetFloat64(configKeySliderDeadzone)
+	if cc.SliderDeadzone < 0 || cc.SliderDeadzone > maxSliderDeadzone {
+		cc.logger.Warnw("Invalid slider deadzone specified, turning it off",
+			"key", configKeySliderDeadzone,
+			"invalidValue", cc.SliderDeadzone,
+			"maxValue", maxSliderDeadzone)
+
+		cc.SliderDeadzone = 0
+	}
+
+	cc.VolumeRamp = time.Duration(cc.userConfig.GetInt(configKeyVolumeRamp)) * time.Millisecond
+	if cc.VolumeRamp < 0 || cc.VolumeRamp > maxVolumeRamp {
+		cc.logger.Warnw("Invalid volume ramp specified, turning ramping off",
+			"key", configKeyVolumeRamp,
+			"invalidValue", cc.userConfig.GetInt(configKeyVolumeRamp),
+			"maxValue", maxVolumeRamp.Milliseconds())
+
+		cc.VolumeRamp = 0
+	}
+
+	cc.CurrentWindowLockTimeout = time.Duration(cc.userConfig.GetInt(configKeyCurrentWindowLock)) * time.Millisecond
+	if cc.CurrentWindowLockTimeout <= 0 || cc.CurrentWindowLockTimeout > maxCurrentWindowLock {
+		cc.logger.Warnw("Invalid current window lock timeout specified, using default value",
+			"key", configKeyCurrentWindowLock,
+			"invalidValue", cc.userConfig.GetInt(configKeyCurrentWindowLock),
+			"defaultValue", defaultCurrentWindowLock.Milliseconds(),
+			"maxValue", maxCurrentWindowLock.Milliseconds())
+
+		cc.configProblems = append(cc.configProblems, configKeyCurrentWindowLock)
+		cc.CurrentWindowLockTimeout = defaultCurrentWindowLock
+	}
+
+	cc.PanicHotkey = ""
+	if panicHotkey := strings.TrimSpace(cc.userConfig.GetString(configKeyPanicHotkey)); panicHotkey != "" {
+		if hotkey, err := util.ParseHotkey(panicHotkey); err != nil {
+			cc.logger.Warnw("Invalid panic hotkey specified, turning it off",
+				"key", configKeyPanicHotkey,
+				"invalidValue", panicHotkey,
+				"error", err)
+
+			cc.configProblems = append(cc.configProblems, configKeyPanicHotkey)
+		} else {
+			cc.PanicHotkey = hotkey.String()
+		}
+	}
+
+	cc.MaxSliderRate = cc.userConfig.GetInt(configKeyMaxSliderRate)
+	if cc.MaxSliderRate < 0 || cc.MaxSliderRate > maxSliderRate {
+		cc.logger.Warnw("Invalid max slider rate specified, turning rate limiting off",
+			"key", configKeyMaxSliderRate,
+			"invalidValue", cc.MaxSliderRate,
+			"maxValue", maxSliderRate)
+
+		cc.MaxSliderRate = 0
+		cc.configProblems = append(cc.configProblems, configKeyMaxSliderRate)
+	}
+
+	volumeCurve, err := util.ParseCurve(cc.userConfig.GetString(configKeyVolumeCurve))
+	if err != nil {
+		cc.logger.Warnw("Invalid volume curve specified, using default value",
+			"key", configKeyVolumeCurve,
+			"error", err,
+			"defaultValue", util.CurveLinear)
+
+		volumeCurve = util.CurveSpec{Kind: util.CurveLinear}
+	}
+	cc.VolumeCurve = volumeCurve
+
+	cc.SliderMoveBackpressure = map[string]string{}
+	for consumer, policy := range cc.userConfig.GetStringMapString(configKeySliderBackpressure) {
+		policy = strings.ToLower(policy)
+
+		switch policy {
+		case backpressureBlock, backpressureDropOldest, backpressureDropNewest:
+			cc.SliderMoveBackpressure[consumer] = policy
+		default:
+			cc.logger.Warnw("Invalid slider event backpressure policy specified, using default value",
+				"key", configKeySliderBackpressure,
+				"consumer", consumer,
+				"invalidValue", policy)
+		}
+	}
 	cc.Language = cc.userConfig.GetString(configKeyLanguage)
 
+	// given in seconds, zero or less turns it off
+	cc.AutoRescanInterval = time.Duration(cc.userConfig.GetInt(configKeyAutoRescanInterval)) * time.Second
+	if cc.AutoRescanInterval > 0 && cc.AutoRescanInterval < minAutoRescanInterval {
+		cc.logger.Warnw("Auto rescan interval too short, using minimum value",
+			"key", configKeyAutoRescanInterval,
+			"invalidValue", cc.AutoRescanInterval,
+			"minimumValue", minAutoRescanInterval)
+
+		cc.AutoRescanInterval = minAutoRescanInterval
+	}
+
 	userConfigVID := cc.userConfig.GetUint64(configKeyComVID)
 	userConfigPID := cc.userConfig.GetUint64(configKeyComPID)
 
 	cc.AutoSearchVIDPID = VIDPID{VID: userConfigVID, PID: userConfigPID}
 
+	cc.BrowserDebuggingPorts = map[string]int{}
+	for browser, value := range cc.userConfig.GetStringMap(configKeyBrowserDebugging) {
+		port, err := cast.ToIntE(value)
+		if err != nil || port <= 0 || port > 65535 {
+			cc.logger.Warnw("Invalid browser debugging port specified, ignoring",
+				"key", configKeyBrowserDebugging,
+				"browser", browser,
+				"invalidValue", value)
+			continue
+		}
+
+		cc.BrowserDebuggingPorts[strings.ToLower(browser)] = port
+	}
+
+	cc.AllowExecTargets = cc.userConfig.GetBool(configKeyAllowExecTargets)
+	cc.ExecCommands = map[string]string{}
+	for name, command := range cc.userConfig.GetStringMapString(configKeyExecCommands) {
+		if _, err := parseExecCommand(name, command); err != nil {
+			cc.logger.Warnw("Invalid exec command specified, ignoring",
+				"key", configKeyExecCommands,
+				"name", name,
+				"error", err)
+			continue
+		}
+
+		cc.ExecCommands[strings.ToLower(name)] = command
+	}
+
+	cc.OnMissingTarget = map[string]missingTargetPolicy{}
+	for target, value := range cc.userConfig.GetStringMapString(configKeyOnMissingTarget) {
+		policy, ok := parseMissingTargetPolicy(value)
+		if !ok {
+			cc.logger.Warnw("Invalid missing target policy specified, ignoring",
+				"key", configKeyOnMissingTarget,
+				"target", target,
+				"invalidValue", value,
+				"allowedValues", []string{missingTargetIgnore, missingTargetRefresh, missingTargetLaunchPrefix + "<path>"})
+
+			cc.configProblems = append(cc.configProblems, configKeyOnMissingTarget+"."+target)
+			continue
+		}
+
+		if policy.launchPath != "" && !cc.AllowExecTargets {
+			cc.logger.Warnw("Missing target policy launches a program, but won't until exec targets are allowed",
+				"key", configKeyAllowExecTargets,
+				"target", target)
+		}
+
+		cc.OnMissingTarget[strings.ToLower(target)] = policy
+	}
+
+	cc.OnConnect = strings.TrimSpace(cc.userConfig.GetString(configKeyOnConnect))
+	cc.OnDisconnect = strings.TrimSpace(cc.userConfig.GetString(configKeyOnDisconnect))
+
+	if len(cc.ExecCommands) > 0 && !cc.AllowExecTargets {
+		cc.logger.Warnw("Exec commands are configured but won't run until exec targets are allowed",
+			"key", configKeyAllowExecTargets)
+	}
+
+	cc.LinuxSessionKeyProperties = []string{}
+	for _, property := range cc.userConfig.GetStringSlice(configKeyLinuxSessionKey) {
+		if property = strings.TrimSpace(property); property != "" {
+			cc.LinuxSessionKeyProperties = append(cc.LinuxSessionKeyProperties, property)
+		}
+	}
+
+	if len(cc.LinuxSessionKeyProperties) == 0 {
+		cc.logger.Warnw("No session key properties specified, using default value",
+			"key", configKeyLinuxSessionKey,
+			"defaultValue", defaultLinuxSessionKeyProperties)
+
+		cc.LinuxSessionKeyProperties = defaultLinuxSessionKeyProperties
+	}
+
+	cc.MasterOutputDeviceID = cc.userConfig.GetString(configKeyMasterOutputDevice)
+	cc.MasterInputDeviceID = cc.userConfig.GetString(configKeyMasterInputDevice)
+
 	cc.OBSConfig.Enabled = cc.userConfig.GetBool(configKeyOBSEnabled)
-	cc.OBSConfig.Host = cc.userConfig.GetString(configKeyOBSHost)
-	cc.OBSConfig.Port = cc.userConfig.GetInt(configKeyOBSPort)
+	cc.parseOBSAddress()
 	cc.OBSConfig.Password = cc.userConfig.GetString(configKeyOBSPassword)
+	cc.OBSConfig.VolumeRanges = cc.parseOBSVolumeRanges()
+	cc.OBSConfig.SceneSwitches = cc.parseOBSSceneSwitches()
+
+	cc.HTTPAPIConfig.Enabled = cc.userConfig.GetBool(configKeyHTTPAPIEnabled)
+
+	cc.HTTPAPIConfig.Host = strings.TrimSpace(cc.userConfig.GetString(configKeyHTTPAPIHost))
+	if cc.HTTPAPIConfig.Host == "" {
+		cc.HTTPAPIConfig.Host = defaultHTTPAPIHost
+	}
+
+	cc.HTTPAPIConfig.Port = cc.userConfig.GetInt(configKeyHTTPAPIPort)
+	if cc.HTTPAPIConfig.Port < 1 || cc.HTTPAPIConfig.Port > 65535 {
+		cc.logger.Warnw("Invalid HTTP API port specified, using default value",
+			"key", configKeyHTTPAPIPort,
+			"invalidValue", cc.HTTPAPIConfig.Port,
+			"defaultValue", defaultHTTPAPIPort)
+
+		cc.HTTPAPIConfig.Port = defaultHTTPAPIPort
+	}
+
+	cc.DiscordConfig.Enabled = cc.userConfig.GetBool(configKeyDiscordEnabled)
+	cc.DiscordConfig.ClientID = strings.TrimSpace(cc.userConfig.GetString(configKeyDiscordClientID))
+	cc.DiscordConfig.ClientSecret = strings.TrimSpace(cc.userConfig.GetString(configKeyDiscordClientSecret))
+
+	if cc.DiscordConfig.Enabled && (cc.DiscordConfig.ClientID == "" || cc.DiscordConfig.ClientSecret == "") {
+		cc.logger.Warnw("Discord is enabled without a client ID and secret, not connecting",
+			"keys", []string{configKeyDiscordClientID, configKeyDiscordClientSecret})
+
+		cc.DiscordConfig.Enabled = false
+	}
+
+	cc.MQTTConfig.Enabled = cc.userConfig.GetBool(configKeyMQTTEnabled)
+	cc.MQTTConfig.Broker = parseMQTTBroker(cc.userConfig.GetString(configKeyMQTTBroker))
+	cc.MQTTConfig.Username = cc.userConfig.GetString(configKeyMQTTUsername)
+	cc.MQTTConfig.Password = cc.userConfig.GetString(configKeyMQTTPassword)
+
+	// brokers can't be sent a password without a user name, so it would silently go unused
+	if cc.MQTTConfig.Password != "" && cc.MQTTConfig.Username == "" {
+		cc.logger.Warnw("MQTT password set without a username, ignoring it",
+			"key", configKeyMQTTPassword)
+
+		cc.configProblems = append(cc.configProblems, configKeyMQTTPassword)
+		cc.MQTTConfig.Password = ""
+	}
+
+	// topics can't contain wildcards, and a trailing slash would leave an empty level in every topic
+	cc.MQTTConfig.TopicPrefix = strings.Trim(strings.TrimSpace(cc.userConfig.GetString(configKeyMQTTTopicPrefix)), "/")
+	if cc.MQTTConfig.TopicPrefix == "" || strings.ContainsAny(cc.MQTTConfig.TopicPrefix, "+#") {
+		cc.logger.Warnw("Invalid MQTT topic prefix, using default value",
+			"key", configKeyMQTTTopicPrefix,
+			"invalidValue", cc.MQTTConfig.TopicPrefix,
+			"defaultValue", defaultMQTTTopicPrefix)
+
+		cc.configProblems = append(cc.configProblems, configKeyMQTTTopicPrefix)
+		cc.MQTTConfig.TopicPrefix = defaultMQTTTopicPrefix
+	}
+
+	cc.VoicemeeterConfig.Enabled = cc.userConfig.GetBool(configKeyVoicemeeterEnabled)
+	cc.VoicemeeterConfig.MinGain = cc.userConfig.GetFloat64(configKeyVoicemeeterMinGain)
+	cc.VoicemeeterConfig.MaxGain = cc.userConfig.GetFloat64(configKeyVoicemeeterMaxGain)
+
+	if cc.VoicemeeterConfig.MinGain < voicemeeterMinGainDb || cc.VoicemeeterConfig.MaxGain > voicemeeterMaxGainDb ||
+		cc.VoicemeeterConfig.MinGain >= cc.VoicemeeterConfig.MaxGain {
+
+		cc.logger.Warnw("Invalid Voicemeeter gain range, using default value",
+			"keys", []string{configKeyVoicemeeterMinGain, configKeyVoicemeeterMaxGain},
+			"invalidValue", []float64{cc.VoicemeeterConfig.MinGain, cc.VoicemeeterConfig.MaxGain},
+			"defaultValue", []float64{defaultVoicemeeterMinGain, defaultVoicemeeterMaxGain})
+
+		cc.configProblems = append(cc.configProblems, configKeyVoicemeeterMinGain)
+		cc.VoicemeeterConfig.MinGain = defaultVoicemeeterMinGain
+		cc.VoicemeeterConfig.MaxGain = defaultVoicemeeterMaxGain
+	}
 
 	cc.logger.Debugw("AutoSearchVIDPID", "val", cc.AutoSearchVIDPID)
-	cc.logger.Debugw("OBSConfig", "enabled", cc.OBSConfig.Enabled, "host", cc.OBSConfig.Host, "port", cc.OBSConfig.Port)
+	cc.logger.Debugw("OBSConfig",
+		"enabled", cc.OBSConfig.Enabled,
+		"host", cc.OBSConfig.Host,
+		"port", cc.OBSConfig.Port,
+		"addressError", cc.OBSConfig.AddressError,
+		"volumeRanges", cc.OBSConfig.VolumeRanges,
+		"sceneSwitches", cc.OBSConfig.SceneSwitches)
+	cc.logger.Debugw("HTTPAPIConfig",
+		"enabled", cc.HTTPAPIConfig.Enabled,
+		"host", cc.HTTPAPIConfig.Host,
+		"port", cc.HTTPAPIConfig.Port)
+	cc.logger.Debugw("DiscordConfig",
+		"enabled", cc.DiscordConfig.Enabled,
+		"clientID", cc.DiscordConfig.ClientID)
+	cc.logger.Debugw("MQTTConfig",
+		"enabled", cc.MQTTConfig.Enabled,
+		"broker", cc.MQTTConfig.Broker,
+		"topicPrefix", cc.MQTTConfig.TopicPrefix,
+		"username", cc.MQTTConfig.Username)
+	cc.logger.Debugw("VoicemeeterConfig",
+		"enabled", cc.VoicemeeterConfig.Enabled,
+		"minGain", cc.VoicemeeterConfig.MinGain,
+		"maxGain", cc.VoicemeeterConfig.MaxGain)
 	cc.logger.Debugw("Populated config fields from vipers")
 
 	return nil
 }
 
+// parseInvertSliders reads invert_sliders as either a single bool, or a map of slider index to bool
+func (cc *CanonicalConfig) parseInvertSliders() (bool, map[int]bool) {
+	invertMap := map[int]bool{}
+	value := cc.userConfig.Get(configKeyInvertSliders)
+
+	sliders, err := cast.ToStringMapE(value)
+	if err != nil {
+		invert, err := cast.ToBoolE(value)
+		if err != nil {
+			cc.logger.Warnw("Invalid invert sliders value specified, using default value",
+				"key", configKeyInvertSliders,
+				"invalidValue", value,
+				"defaultValue", false)
+		}
+
+		return invert, invertMap
+	}
+
+	for sliderIdxString, sliderValue := range sliders {
+		sliderIdx, err := strconv.Atoi(sliderIdxString)
+		invert, boolErr := cast.ToBoolE(sliderValue)
+		if err != nil || sliderIdx < 0 || boolErr != nil {
+			cc.logger.Warnw("Invalid per-slider invert value specified, ignoring",
+				"key", configKeyInvertSliders,
+				"slider", sliderIdxString,
+				"invalidValue", sliderValue)
+			continue
+		}
+
+		invertMap[sliderIdx] = invert
+	}
+
+	return false, invertMap
+}
+
+// parseNoiseReductionLevels reads the noise reduction level, given either for all sliders at once
+// or as a map of slider indexes to levels
+func (cc *CanonicalConfig) parseNoiseReductionLevels() (string, map[int]string) {
+	levelsMap := map[int]string{}
+	value := cc.userConfig.Get(configKeyNoiseReductionLevel)
+
+	sliders, err := cast.ToStringMapE(value)
+	if err != nil {
+		level := cast.ToString(value)
+		if level != "" && !funk.ContainsString(noiseReductionLevels, level) {
+			cc.logger.Warnw("Invalid noise reduction level specified, using default value",
+				"key", configKeyNoiseReductionLevel,
+				"invalidValue", value,
+				"allowedValues", noiseReductionLevels)
+
+			cc.configProblems = append(cc.configProblems, configKeyNoiseReductionLevel)
+			return "", levelsMap
+		}
+
+		return level, levelsMap
+	}
+
+	for sliderIdxString, sliderValue := range sliders {
+		sliderIdx, ok := parseSliderIndex(sliderIdxString)
+		level := cast.ToString(sliderValue)
+		if !ok || !funk.ContainsString(noiseReductionLevels, level) {
+			cc.logger.Warnw("Invalid per-slider noise reduction level specified, using default value",
+				"key", configKeyNoiseReductionLevel,
+				"slider", sliderIdxString,
+				"invalidValue", sliderValue,
+				"allowedValues", noiseReductionLevels)
+
+			cc.configProblems = append(cc.configProblems, configKeyNoiseReductionLevel+"."+sliderIdxString)
+			continue
+		}
+
+		levelsMap[sliderIdx] = level
+	}
+
+	return "", levelsMap
+}
+
+// SliderNoiseReductionLevel returns the noise reduction level to use for the given slider
+func (cc *CanonicalConfig) SliderNoiseReductionLevel(sliderIdx int) string {
+	if level, ok := cc.NoiseReductionLevelsMap[sliderIdx]; ok {
+		return level
+	}
+
+	return cc.NoiseReductionLevel
+}
+
+// SliderInverted reports whether the given slider's values should be flipped
+func (cc *CanonicalConfig) SliderInverted(sliderIdx int) bool {
+	if invert, ok := cc.InvertSlidersMap[sliderIdx]; ok {
+		return invert
+	}
+
+	return cc.InvertSliders
+}
+
+// parseSliderLabels reads the map of slider index to label, i.e. {0: Master, 1: Music}
+func (cc *CanonicalConfig) parseSliderLabels() map[int]string {
+	labels := map[int]string{}
+
+	for sliderIdxString, label := range cc.userConfig.GetStringMapString(configKeySliderLabels) {
+		sliderIdx, err := strconv.Atoi(sliderIdxString)
+		if err != nil || sliderIdx < 0 {
+			cc.logger.Warnw("Invalid slider label specified, ignoring",
+				"key", configKeySliderLabels,
+				"slider", sliderIdxString,
+				"invalidValue", label)
+			continue
+		}
+
+		if label = strings.TrimSpace(label); label != "" {
+			labels[sliderIdx] = label
+		}
+	}
+
+	return labels
+}
+
+// SliderLabel returns the label the user gave a slider, or an empty string if it has none
+func (cc *CanonicalConfig) SliderLabel(sliderIdx int) string {
+	return cc.SliderLabels[sliderIdx]
+}
+
+// sliderLogFields identifies a slider in structured log lines, by index and by label if it has one
+func (cc *CanonicalConfig) sliderLogFields(sliderIdx int) []interface{} {
+	if label := cc.SliderLabel(sliderIdx); label != "" {
+		return []interface{}{"slider", sliderIdx, "label", label}
+	}
+
+	return []interface{}{"slider", sliderIdx}
+}
+
+// parseStereoPairs reads the slider pairs that act as a single stereo control, i.e. [[0, 1], [2, 3]]
+func (cc *CanonicalConfig) parseStereoPairs() map[int]stereoPair {
+	pairs := map[int]stereoPair{}
+
+	for _, value := range cast.ToSlice(cc.userConfig.Get(configKeyStereoPairs)) {
+		sliders, err := cast.ToIntSliceE(value)
+		if err != nil || len(sliders) != 2 || sliders[0] < 0 || sliders[1] < 0 || sliders[0] == sliders[1] {
+			cc.logger.Warnw("Invalid stereo pair specified, ignoring",
+				"key", configKeyStereoPairs,
+				"invalidValue", value)
+			continue
+		}
+
+		pair := stereoPair{Left: sliders[0], Right: sliders[1]}
+
+		_, leftTaken := pairs[pair.Left]
+		_, rightTaken := pairs[pair.Right]
+		if leftTaken || rightTaken {
+			cc.logger.Warnw("Slider is already part of another stereo pair, ignoring",
+				"key", configKeyStereoPairs,
+				"invalidValue", value)
+			continue
+		}
+
+		if _, ok := cc.SliderMapping.get(pair.Right); ok {
+			cc.logger.Warnw("Stereo pair's right slider has its own mapping, which will be ignored",
+				"key", configKeyStereoPairs,
+				"slider", pair.Right)
+		}
+
+		pairs[pair.Left] = pair
+		pairs[pair.Right] = pair
+	}
+
+	return pairs
+}
+
+// parseOBSAddress reads the OBS host and port. missing values fall back to the defaults,
+// while values that could never be connected to are flagged in AddressError
+func (cc *CanonicalConfig) parseOBSAddress() {
+	cc.OBSConfig.AddressError = nil
+
+	cc.OBSConfig.Host = strings.TrimSpace(cc.userConfig.GetString(configKeyOBSHost))
+	if cc.OBSConfig.Host == "" {
+		cc.logger.Warnw("No OBS host specified, using default value",
+			"key", configKeyOBSHost,
+			"defaultValue", defaultOBSHost)
+
+		cc.OBSConfig.Host = defaultOBSHost
+	}
+
+	// people tend to paste the whole websocket URL, or include the port
+	if strings.Contains(cc.OBSConfig.Host, "/") || strings.ContainsAny(cc.OBSConfig.Host, " \t") ||
+		(strings.Contains(cc.OBSConfig.Host, ":") && net.ParseIP(cc.OBSConfig.Host) == nil) {
+
+		cc.OBSConfig.AddressError = fmt.Errorf("invalid host %q, expected a host name or IP address only", cc.OBSConfig.Host)
+	}
+
+	cc.OBSConfig.Port = cc.userConfig.GetInt(configKeyOBSPort)
+	if cc.OBSConfig.Port == 0 {
+		cc.logger.Warnw("No OBS port specified, using default value",
+			"key", configKeyOBSPort,
+			"defaultValue", defaultOBSPort)
+
+		cc.OBSConfig.Port = defaultOBSPort
+	}
+
+	if cc.OBSConfig.Port < 0 || cc.OBSConfig.Port > 65535 {
+		cc.OBSConfig.AddressError = fmt.Errorf("invalid port %d, expected a number between 1 and 65535", cc.OBSConfig.Port)
+	}
+
+	if cc.OBSConfig.AddressError != nil {
+		cc.logger.Warnw("Invalid OBS address specified", "error", cc.OBSConfig.AddressError)
+	}
+}
+
+// parseOBSVolumeRanges reads the per-input OBS volume ranges. each input may either specify
+// min/max as volume multipliers, or min_db/max_db as decibels
+func (cc *CanonicalConfig) parseOBSVolumeRanges() map[string]OBSVolumeRange {
+	ranges := map[string]OBSVolumeRange{}
+
+	for inputName := range cc.userConfig.GetStringMap(configKeyOBSVolumeRanges) {
+		sub := cc.userConfig.Sub(configKeyOBSVolumeRanges + "." + inputName)
+		if sub == nil {
+			cc.logger.Warnw("Invalid OBS volume range, ignoring", "input", inputName)
+			continue
+		}
+
+		var volumeRange OBSVolumeRange
+
+		if sub.IsSet("min_db") || sub.IsSet("max_db") {
+			sub.SetDefault("min_db", obsMinVolumeDb)
+			sub.SetDefault("max_db", 0)
+
+			volumeRange = OBSVolumeRange{Min: sub.GetFloat64("min_db"), Max: sub.GetFloat64("max_db"), Decibels: true}
+		} else {
+			sub.SetDefault("min", 0)
+			sub.SetDefault("max", 1)
+
+			volumeRange = OBSVolumeRange{Min: sub.GetFloat64("min"), Max: sub.GetFloat64("max")}
+		}
+
+		if volumeRange.Min > volumeRange.Max {
+			cc.logger.Warnw("OBS volume range minimum is above its maximum, ignoring",
+				"input", inputName,
+				"min", volumeRange.Min,
+				"max", volumeRange.Max)
+			continue
+		}
+
+		ranges[strings.ToLower(inputName)] = volumeRange
+	}
+
+	return ranges
+}
+
+// parseMQTTBroker turns the configured broker into host:port, accepting URLs like mqtt://broker.local as well
+func parseMQTTBroker(broker string) string {
+	broker = strings.TrimSpace(broker)
+
+	for _, scheme := range []string{"mqtt://", "tcp://"} {
+		if len(broker) > len(scheme) && strings.EqualFold(broker[:len(scheme)], scheme) {
+			broker = broker[len(scheme):]
+		}
+	}
+
+	broker = strings.TrimSuffix(broker, "/")
+	if broker == "" {
+		return defaultMQTTBroker
+	}
+
+	if _, _, err := net.SplitHostPort(broker); err != nil {
+		broker = net.JoinHostPort(strings.Trim(broker, "[]"), mqttDefaultPort)
+	}
+
+	return broker
+}
+
+// parseOBSSceneSwitches reads the scenes each slider switches OBS to. every slider has a list of
+// scenes with the min and/or max slider position (0.0 to 1.0) they cover, i.e. "BRB" up to 0.33
+func (cc *CanonicalConfig) parseOBSSceneSwitches() map[int][]OBSSceneRange {
+	switches := map[int][]OBSSceneRange{}
+
+	for sliderIdxString, value := range cc.userConfig.GetStringMap(configKeyOBSSceneSwitches) {
+		sliderIdx, ok := parseSliderIndex(sliderIdxString)
+		if !ok {
+			cc.logger.Warnw("Invalid slider index in OBS scene switches, ignoring it",
+				"key", configKeyOBSSceneSwitches,
+				"invalidValue", sliderIdxString)
+			continue
+		}
+
+		for _, entry := range cast.ToSlice(value) {
+			fields, err := cast.ToStringMapE(entry)
+			if err != nil {
+				cc.logger.Warnw("Invalid OBS scene switch, ignoring", "slider", sliderIdx, "invalidValue", entry)
+				continue
+			}
+
+			sceneRange := OBSSceneRange{
+				Scene: strings.TrimSpace(cast.ToString(fields["scene"])),
+				Min:   0,
+				Max:   1,
+			}
+
+			if minValue, ok := fields["min"]; ok {
+				sceneRange.Min = cast.ToFloat32(minValue)
+			}
+
+			if maxValue, ok := fields["max"]; ok {
+				sceneRange.Max = cast.ToFloat32(maxValue)
+			}
+
+			if sceneRange.Scene == "" || sceneRange.Min < 0 || sceneRange.Max > 1 || sceneRange.Min > sceneRange.Max {
+				cc.logger.Warnw("Invalid OBS scene switch, ignoring", "slider", sliderIdx, "invalidValue", entry)
+				continue
+			}
+
+			switches[sliderIdx] = append(switches[sliderIdx], sceneRange)
+		}
+	}
+
+	return switches
+}
+
+// sliderMoveBackpressure returns the configured backpressure policy for the named slider move consumer,
+// falling back to its built-in default (and to blocking for consumers without one)
+func (cc *CanonicalConfig) sliderMoveBackpressure(consumer string) string {
+	if policy, ok := cc.SliderMoveBackpressure[consumer]; ok {
+		return policy
+	}
+
+	if policy, ok := defaultSliderMoveBackpressure[consumer]; ok {
+		return policy
+	}
+
+	return backpressureBlock
+}
+
+// SetCOMPort changes the COM port deej connects to (or "auto" to detect it) and saves it to the config file
+func (cc *CanonicalConfig) SetCOMPort(comPort string) error {
+	if comPort == "" {
+		return fmt.Errorf("empty COM port")
+	}
+
+	return cc.applyChanges(configEdit{keys: []string{configKeyCOMPort}, value: comPort})
+}
+
+// SetBaudRate changes the serial baud rate and saves it to the config file
+func (cc *CanonicalConfig) SetBaudRate(baudRate int) error {
+	if !funk.ContainsInt(knownBaudRates, baudRate) {
+		return fmt.Errorf("invalid baud rate: %d", baudRate)
+	}
+
+	return cc.applyChanges(configEdit{keys: []string{configKeyBaudRate}, value: baudRate})
+}
+
+// SetLanguage changes deej's language ("auto" to follow the system) and saves it to the config file
+func (cc *CanonicalConfig) SetLanguage(language string) error {
+	if language == "" {
+		return fmt.Errorf("empty language")
+	}
+
+	return cc.applyChanges(configEdit{keys: []string{configKeyLanguage}, value: language})
+}
+
+// SetSliderMapping replaces the user's slider mapping and saves it to the config file.
+// sliders missing from the given mapping are removed from the config
+func (cc *CanonicalConfig) SetSliderMapping(mapping map[int][]string) error {
+	edits := []configEdit{}
+
+	for sliderIdxString := range cc.userConfig.GetStringMapStringSlice(configKeySliderMapping) {
+		sliderIdx, err := strconv.Atoi(sliderIdxString)
+		if _, ok := mapping[sliderIdx]; err != nil || !ok {
+			edits = append(edits, configEdit{keys: []string{configKeySliderMapping, sliderIdxString}})
+		}
+	}
+
+	for sliderIdx, targets := range mapping {
+		if sliderIdx < 0 {
+			return fmt.Errorf("invalid slider index: %d", sliderIdx)
+		}
+
+		// single targets are written the same way people usually write them by hand
+		var value any = targets
+		if len(targets) == 1 {
+			value = targets[0]
+		}
+
+		edits = append(edits, configEdit{keys: []string{configKeySliderMapping, strconv.Itoa(sliderIdx)}, value: value})
+	}
+
+	return cc.applyChanges(edits...)
+}
+
+// DiscordRefreshToken returns the saved Discord refresh token, if any
+func (cc *CanonicalConfig) DiscordRefreshToken() string {
+	cc.reloadLock.Lock()
+	defer cc.reloadLock.Unlock()
+
+	return cc.internalConfig.GetString(configKeyDiscordRefreshToken)
+}
+
+// SetDiscordRefreshToken saves the Discord refresh token to the internal config, or forgets it if empty
+func (cc *CanonicalConfig) SetDiscordRefreshToken(token string) error {
+	cc.reloadLock.Lock()
+	defer cc.reloadLock.Unlock()
+
+	cc.internalConfig.Set(configKeyDiscordRefreshToken, token)
+
+	return cc.writeInternalConfig()
+}
+
+// LastSliderValues returns the slider positions saved when deej last stopped, or nil if there are none worth using
+func (cc *CanonicalConfig) LastSliderValues() []float32 {
+	cc.reloadLock.Lock()
+	defer cc.reloadLock.Unlock()
+
+	values, err := cast.ToFloat64SliceE(cc.internalConfig.Get(configKeyLastSliderValues))
+	if err != nil || len(values) == 0 {
+		return nil
+	}
+
+	result := make([]float32, len(values))
+	for sliderIdx, value := range values {
+		if value < 0 || value > 1 {
+			cc.logger.Warnw("Invalid saved slider position, not restoring any",
+				"key", configKeyLastSliderValues,
+				"slider", sliderIdx,
+				"invalidValue", value)
+
+			return nil
+		}
+
+		result[sliderIdx] = float32(value)
+	}
+
+	return result
+}
+
+// SetLastSliderValues saves the given slider positions to the internal config
+func (cc *CanonicalConfig) SetLastSliderValues(values []float32) error {
+	cc.reloadLock.Lock()
+	defer cc.reloadLock.Unlock()
+
+	// float32 positions don't survive the trip through yaml exactly, don't write out their noise
+	rounded := make([]float64, len(values))
+	for sliderIdx, value := range values {
+		rounded[sliderIdx] = math.Round(float64(value)*10000) / 10000
+	}
+
+	cc.internalConfig.Set(configKeyLastSliderValues, rounded)
+
+	return cc.writeInternalConfig()
+}
+
+// writeInternalConfig saves the internal config to its file. must be called with cc.reloadLock held
+func (cc *CanonicalConfig) writeInternalConfig() error {
+	path := filepath.Join(cc.internalConfigDir, internalConfigName+"."+configType)
+	if err := cc.internalConfig.WriteConfigAs(path); err != nil {
+		cc.logger.Warnw("Failed to save internal config", "path", path, "error", err)
+		return fmt.Errorf("write internal config: %w", err)
+	}
+
+	return nil
+}
+
+// applyChanges persists the given edits to the user config file, then reloads the config from it
+// and notifies consumers just like a reload caused by editing the file would
+func (cc *CanonicalConfig) applyChanges(edits ...configEdit) error {
+	cc.reloadLock.Lock()
+
+	// the file on disk is the held back one, editing it would apply that along with the changes
+	if cc.riskyReloadPending {
+		cc.reloadLock.Unlock()
+		cc.logger.Warnw("Not saving config changes while a reload is held back", "changes", len(edits))
+		return errRiskyReloadHeldBack
+	}
+
+	if err := editConfigFile(cc.configPath, edits...); err != nil {
+		cc.reloadLock.Unlock()
+		cc.logger.Warnw("Failed to save config changes", "error", err)
+		return fmt.Errorf("save config changes: %w", err)
+	}
+
+	contents, err := cc.readUserConfig()
+	if err != nil {
+		cc.reloadLock.Unlock()
+		cc.logger.Warnw("Viper failed to read user config after saving changes", "error", err)
+		return fmt.Errorf("read user config: %w", err)
+	}
+
+	if err := cc.populateFromVipers(); err != nil {
+		cc.reloadLock.Unlock()
+		cc.logger.Warnw("Failed to populate config fields", "error", err)
+		return fmt.Errorf("populate config fields: %w", err)
+	}
+
+	cc.appliedUserConfig = contents
+
+	cc.reloadLock.Unlock()
+
+	cc.logger.Infow("Applied config changes", "changes", len(edits))
+	cc.onConfigReloaded()
+
+	return nil
+}
+
 func (cc *CanonicalConfig) onConfigReloaded() {
 	cc.logger.Debug("Notifying consumers about configuration reload")
 
-	for _, consumer := range cc.reloadConsumers {
+	cc.reloadConsumersLock.Lock()
+	consumers := append([]chan bool{}, cc.reloadConsumers...)
+	cc.reloadConsumersLock.Unlock()
+
+	for _, consumer := range consumers {
 		consumer <- true
 	}
 }
@@ -0,0 +1,57 @@
+package deej
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestRiskyReloadIsHeldBack(t *testing.T) {
+	d := newTestDeej(t, `confirm_risky_reloads: true
+notifications:
+  level: none
+slider_mapping:
+  0: master
+`)
+
+	localizer, err := d.GetSystemLocalizer()
+	if err != nil {
+		t.Fatalf("get localizer: %v", err)
+	}
+
+	emptied := []byte("confirm_risky_reloads: true\nnotifications:\n  level: none\nslider_mapping: {}\n")
+	if err := os.WriteFile(d.config.configPath, emptied, 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	if err := d.config.Load(localizer); !errors.Is(err, errRiskyReloadHeldBack) {
+		t.Fatalf("Load() = %v, expected %v", err, errRiskyReloadHeldBack)
+	}
+
+	if targets, _ := d.config.SliderMapping.get(0); len(targets) != 1 {
+		t.Errorf("slider 0 targets = %v after held back reload, expected master", targets)
+	}
+
+	// viper has to keep the previous config too, everything that reads from it directly would see the emptied one
+	if mapping := d.config.userConfig.GetStringMapStringSlice(configKeySliderMapping); len(mapping) != 1 {
+		t.Errorf("viper slider mapping = %v after held back reload, expected the previous one", mapping)
+	}
+
+	// saving changes from deej itself would have to write them into the emptied file, applying it along the way
+	if err := d.config.SetCOMPort("COM9"); !errors.Is(err, errRiskyReloadHeldBack) {
+		t.Errorf("SetCOMPort() = %v while a reload is held back, expected %v", err, errRiskyReloadHeldBack)
+	}
+
+	if contents, err := os.ReadFile(d.config.configPath); err != nil || string(contents) != string(emptied) {
+		t.Errorf("config file changed while a reload was held back: %q", contents)
+	}
+
+	// saving the same file again confirms it
+	if err := d.config.Load(localizer); err != nil {
+		t.Fatalf("second Load() = %v, expected it to apply", err)
+	}
+
+	if _, ok := d.config.SliderMapping.get(0); ok {
+		t.Error("slider 0 still mapped after confirming the reload")
+	}
+}
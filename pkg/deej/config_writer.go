@@ -0,0 +1,181 @@
+package deej
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+
+	"github.com/nik9play/deej/pkg/deej/util"
+)
+
+// the YAML encoder drops blank lines, so they're smuggled through as comments and restored afterwards
+const blankLineMarker = "#deej:blank-line"
+
+// configEdit is a single change to apply to the user's config file.
+// a nil value removes the key entirely
+type configEdit struct {
+	keys  []string
+	value any
+}
+
+// editConfigFile applies the given edits to a YAML file while keeping its comments and key order intact,
+// then atomically replaces the file with the result
+func editConfigFile(path string, edits ...configEdit) error {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config file: %w", err)
+	}
+
+	var document yaml.Node
+	if err := yaml.Unmarshal(markBlankLines(normalizeConfigContents(contents)), &document); err != nil {
+		return fmt.Errorf("parse config file: %w", err)
+	}
+
+	// an empty file has no document node yet
+	if document.Kind == 0 {
+		document = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+
+	root := document.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return fmt.Errorf("config file root is not a mapping")
+	}
+
+	for _, edit := range edits {
+		if edit.value == nil {
+			deleteYAMLValue(root, edit.keys)
+			continue
+		}
+
+		if err := setYAMLValue(root, edit.keys, edit.value); err != nil {
+			return fmt.Errorf("set %v: %w", edit.keys, err)
+		}
+	}
+
+	var buffer bytes.Buffer
+	encoder := yaml.NewEncoder(&buffer)
+	encoder.SetIndent(2)
+
+	if err := encoder.Encode(&document); err != nil {
+		return fmt.Errorf("encode config file: %w", err)
+	}
+
+	if err := encoder.Close(); err != nil {
+		return fmt.Errorf("encode config file: %w", err)
+	}
+
+	if err := util.WriteFileAtomic(path, restoreBlankLines(buffer.Bytes())); err != nil {
+		return fmt.Errorf("write config file: %w", err)
+	}
+
+	return nil
+}
+
+func markBlankLines(contents []byte) []byte {
+	lines := strings.Split(string(contents), "\n")
+
+	// leave the final newline alone
+	for idx := 0; idx < len(lines)-1; idx++ {
+		if strings.TrimSpace(lines[idx]) == "" {
+			lines[idx] = blankLineMarker
+		}
+	}
+
+	return []byte(strings.Join(lines, "\n"))
+}
+
+func restoreBlankLines(contents []byte) []byte {
+	lines := strings.Split(string(contents), "\n")
+
+	for idx, line := range lines {
+		if strings.TrimSpace(line) == blankLineMarker {
+			lines[idx] = ""
+		}
+	}
+
+	return []byte(strings.Join(lines, "\n"))
+}
+
+// setYAMLValue sets the value under the given key path, creating intermediate mappings as needed.
+// comments attached to a replaced value are carried over to the new one
+func setYAMLValue(mapping *yaml.Node, keys []string, value any) error {
+	var replacement yaml.Node
+	if err := replacement.Encode(value); err != nil {
+		return fmt.Errorf("encode value: %w", err)
+	}
+
+	for idx, key := range keys {
+		if mapping.Kind != yaml.MappingNode {
+			return fmt.Errorf("%s is not a mapping", key)
+		}
+
+		last := idx == len(keys)-1
+		existing := findYAMLValue(mapping, key)
+
+		if existing == nil {
+			existing = &yaml.Node{Kind: yaml.MappingNode}
+			mapping.Content = append(mapping.Content, newYAMLKey(key), existing)
+		}
+
+		if last {
+			replacement.HeadComment = existing.HeadComment
+			replacement.LineComment = existing.LineComment
+			replacement.FootComment = existing.FootComment
+			*existing = replacement
+
+			return nil
+		}
+
+		mapping = existing
+	}
+
+	return nil
+}
+
+// deleteYAMLValue removes the key at the end of the given key path, if it exists
+func deleteYAMLValue(mapping *yaml.Node, keys []string) {
+	for idx, key := range keys {
+		if mapping.Kind != yaml.MappingNode {
+			return
+		}
+
+		if idx < len(keys)-1 {
+			if mapping = findYAMLValue(mapping, key); mapping == nil {
+				return
+			}
+			continue
+		}
+
+		for contentIdx := 0; contentIdx+1 < len(mapping.Content); contentIdx += 2 {
+			if mapping.Content[contentIdx].Value == key {
+				mapping.Content = append(mapping.Content[:contentIdx], mapping.Content[contentIdx+2:]...)
+				return
+			}
+		}
+	}
+}
+
+func findYAMLValue(mapping *yaml.Node, key string) *yaml.Node {
+	for contentIdx := 0; contentIdx+1 < len(mapping.Content); contentIdx += 2 {
+		if mapping.Content[contentIdx].Value == key {
+			return mapping.Content[contentIdx+1]
+		}
+	}
+
+	return nil
+}
+
+func newYAMLKey(key string) *yaml.Node {
+	node := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}
+
+	// slider indexes are written as plain ints, like users do by hand
+	if _, err := strconv.Atoi(key); err == nil {
+		node.Tag = "!!int"
+	}
+
+	return node
+}
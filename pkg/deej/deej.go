@@ -6,6 +6,8 @@ import (
 	"embed"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"go.uber.org/zap"
 	"golang.org/x/text/language"
@@ -22,16 +24,51 @@ const (
 
 	// when this is set to anything, deej won't use a tray icon
 	envNoTray = "DEEJ_NO_TRAY_ICON"
+
+	// a directory to keep config.yaml and the logs folder in instead of next to the executable,
+	// for portable installs or ones in a read-only location
+	envDataDir = "DEEJ_DATA_DIR"
 )
 
+// dataDir returns the directory deej keeps config.yaml and its logs folder in: DEEJ_DATA_DIR if it's set,
+// created if it doesn't exist yet, or the executable's directory otherwise
+func dataDir() (string, error) {
+	if dir := strings.TrimSpace(os.Getenv(envDataDir)); dir != "" {
+		dir, err := filepath.Abs(dir)
+		if err != nil {
+			return "", fmt.Errorf("resolve %s: %w", envDataDir, err)
+		}
+
+		if err := util.EnsureDirExists(dir); err != nil {
+			return "", fmt.Errorf("ensure data dir exists: %w", err)
+		}
+
+		return dir, nil
+	}
+
+	ex, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("get executable dir: %w", err)
+	}
+
+	return filepath.Dir(ex), nil
+}
+
 // Deej is the main entity managing access to all sub-components
 type Deej struct {
 	logger    *zap.SugaredLogger
-	notifier  notify.Notifier
+	notifier  *filteredNotifier
 	config    *CanonicalConfig
 	serial    *SerialIO
 	sessions  *sessionMap
 	obs       *OBSClient
+	discord   *DiscordClient
+	vm        *VoicemeeterClient
+	mqtt      *MQTTClient
+	httpAPI   *httpAPI
+	panicMute *panicMute
+	hooks     *lifecycleHooks
+	metrics   *deejMetrics
 	bundle    *i18n.Bundle
 	localizer *i18n.Localizer
 
@@ -43,10 +80,15 @@ type Deej struct {
 //go:embed lang/active.*.toml
 var langFS embed.FS
 
-// NewDeej creates a Deej instance
-func NewDeej(logger *zap.SugaredLogger, verbose bool, configPath string) (*Deej, error) {
+// NewDeej creates a Deej instance. with dryRun set, it uses fake audio sessions rather than the system's
+func NewDeej(logger *zap.SugaredLogger, verbose bool, configPath string, dryRun bool) (*Deej, error) {
 	logger = logger.Named("deej")
 
+	// verbose mode is for debugging, so it logs everything no matter what the config says
+	if verbose {
+		useVerboseLogLevel()
+	}
+
 	bundle := i18n.NewBundle(language.English)
 	bundle.RegisterUnmarshalFunc("toml", toml.Unmarshal)
 	_, err := bundle.LoadMessageFileFS(langFS, "lang/active.ru.toml")
@@ -70,8 +112,9 @@ func NewDeej(logger *zap.SugaredLogger, verbose bool, configPath string) (*Deej,
 
 	d := &Deej{
 		logger:      logger,
-		notifier:    notifier,
+		notifier:    config.notifier,
 		config:      config,
+		metrics:     &deejMetrics{},
 		stopChannel: make(chan bool),
 		verbose:     verbose,
 		bundle:      bundle,
@@ -85,13 +128,23 @@ func NewDeej(logger *zap.SugaredLogger, verbose bool, configPath string) (*Deej,
 
 	d.serial = serial
 
-	sessionFinder, err := newSessionFinder(logger)
+	createSessionFinder := func() (SessionFinder, error) {
+		return newSessionFinder(logger, config)
+	}
+
+	if mockSessions, ok := mockSessionNames(dryRun); ok {
+		createSessionFinder = func() (SessionFinder, error) {
+			return newMockSessionFinder(logger, mockSessions), nil
+		}
+	}
+
+	sessionFinder, err := createSessionFinder()
 	if err != nil {
 		logger.Errorw("Failed to create SessionFinder", "error", err)
 		return nil, fmt.Errorf("create new SessionFinder: %w", err)
 	}
 
-	sessions, err := newSessionMap(d, logger, sessionFinder)
+	sessions, err := newSessionMap(d, logger, sessionFinder, createSessionFinder)
 	if err != nil {
 		logger.Errorw("Failed to create sessionMap", "error", err)
 		return nil, fmt.Errorf("create new sessionMap: %w", err)
@@ -100,6 +153,12 @@ func NewDeej(logger *zap.SugaredLogger, verbose bool, configPath string) (*Deej,
 	d.sessions = sessions
 
 	d.obs = NewOBSClient(d, logger)
+	d.discord = NewDiscordClient(d, logger)
+	d.vm = NewVoicemeeterClient(d, logger)
+	d.mqtt = NewMQTTClient(d, logger)
+	d.httpAPI = newHTTPAPI(d, logger)
+	d.panicMute = newPanicMute(d, logger)
+	d.hooks = newLifecycleHooks(d, logger)
 
 	logger.Debug("Created deej instance")
 
@@ -133,6 +192,8 @@ func (d *Deej) Initialize() error {
 		return fmt.Errorf("init session map: %w", err)
 	}
 
+	d.hooks.initialize()
+
 	// decide whether to run with/without tray
 	if _, noTraySet := os.LookupEnv(envNoTray); noTraySet {
 
@@ -201,11 +262,24 @@ func (d *Deej) run() {
 	// watch the config file for changes
 	go d.config.WatchConfigFileChanges(d.localizer)
 
+	// set sessions to where the sliders were when deej last stopped, until the board reports in
+	d.serial.RestoreState()
+
 	// connect to the arduino
 	d.serial.Start()
 
 	d.obs.Start()
 
+	d.discord.Start()
+
+	d.vm.Start()
+
+	d.mqtt.Start()
+
+	d.httpAPI.Start()
+
+	d.panicMute.Start()
+
 	// wait until stopped (gracefully)
 	<-d.stopChannel
 	d.logger.Debug("Stop channel signaled, terminating")
@@ -228,7 +302,13 @@ func (d *Deej) stop() error {
 
 	d.config.StopWatchingConfigFile()
 	d.serial.Stop()
+	d.hooks.stop()
 	d.obs.Stop()
+	d.discord.Stop()
+	d.vm.Stop()
+	d.mqtt.Stop()
+	d.httpAPI.Stop()
+	d.panicMute.Stop()
 
 	// release the session map
 	if err := d.sessions.release(); err != nil {
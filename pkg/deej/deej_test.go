@@ -0,0 +1,39 @@
+package deej
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// newTestDeej creates a dry-run deej with fake audio sessions and the given config, kept in a temporary data dir
+func newTestDeej(t testing.TB, config string) *Deej {
+	t.Helper()
+
+	dir := t.TempDir()
+	t.Setenv(envDataDir, dir)
+
+	if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(config), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	d, err := NewDeej(zap.NewNop().Sugar(), false, "", true)
+	if err != nil {
+		t.Fatalf("create deej: %v", err)
+	}
+
+	localizer, err := d.GetSystemLocalizer()
+	if err != nil {
+		t.Fatalf("get localizer: %v", err)
+	}
+
+	if err := d.config.Load(localizer); err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+
+	d.localizer = localizer
+
+	return d
+}
@@ -0,0 +1,675 @@
+package deej
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// DiscordClient talks to the Discord desktop app over its local RPC socket, to mute and deafen the user.
+// RPC is only open to applications the user owns, so they have to provide their own client ID and secret
+type DiscordClient struct {
+	deej   *Deej
+	logger *zap.SugaredLogger
+
+	// guards conn and voice
+	lock  sync.Mutex
+	conn  io.ReadWriteCloser
+	voice discordVoiceSettings
+
+	// whether voice holds Discord's actual state yet
+	voiceKnown bool
+
+	writeLock sync.Mutex
+
+	pending     map[string]chan discordPayload
+	pendingLock sync.Mutex
+	nonce       uint64
+
+	stopChannel chan struct{}
+	errChannel  chan error
+	wg          sync.WaitGroup
+
+	// config values at time of connection
+	clientIDConfig     string
+	clientSecretConfig string
+}
+
+// discordPayload is both a command sent to Discord and anything Discord sends back
+type discordPayload struct {
+	Cmd   string          `json:"cmd"`
+	Evt   string          `json:"evt,omitempty"`
+	Nonce string          `json:"nonce,omitempty"`
+	Args  any             `json:"args,omitempty"`
+	Data  json.RawMessage `json:"data,omitempty"`
+}
+
+type discordVoiceSettings struct {
+	Mute bool `json:"mute"`
+	Deaf bool `json:"deaf"`
+}
+
+type discordError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type discordToken struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// the voice settings deej can switch on and off
+const (
+	discordVoiceMute   = "mute"
+	discordVoiceDeafen = "deaf"
+)
+
+const (
+	discordRetryDelay = 5 * time.Second
+
+	// denied or broken authorizations would otherwise have Discord asking the user again every few seconds
+	discordAuthRetryDelay = time.Minute
+
+	discordCommandTimeout = 5 * time.Second
+
+	// authorizing waits on the user to click through Discord's prompt
+	discordAuthorizeTimeout = 2 * time.Minute
+
+	discordTokenURL = "https://discord.com/api/oauth2/token"
+
+	// frames are small JSON messages, anything bigger than this means we're out of sync
+	discordMaxFrameSize = 1024 * 1024
+
+	// Discord takes the first free of discord-ipc-0 through 9
+	discordIPCMaxSockets = 10
+
+	discordOpHandshake = 0
+	discordOpFrame     = 1
+	discordOpClose     = 2
+	discordOpPing      = 3
+	discordOpPong      = 4
+
+	discordCmdDispatch            = "DISPATCH"
+	discordCmdAuthorize           = "AUTHORIZE"
+	discordCmdAuthenticate        = "AUTHENTICATE"
+	discordCmdSubscribe           = "SUBSCRIBE"
+	discordCmdGetVoiceSettings    = "GET_VOICE_SETTINGS"
+	discordCmdSetVoiceSettings    = "SET_VOICE_SETTINGS"
+	discordEvtReady               = "READY"
+	discordEvtError               = "ERROR"
+	discordEvtVoiceSettingsUpdate = "VOICE_SETTINGS_UPDATE"
+)
+
+var discordScopes = []string{"rpc", "rpc.voice.read", "rpc.voice.write"}
+
+var (
+	errDiscordNotRunning = errors.New("discord isn't running")
+	errDiscordAuth       = errors.New("discord authorization failed")
+)
+
+func NewDiscordClient(deej *Deej, logger *zap.SugaredLogger) *DiscordClient {
+	logger = logger.Named("discord")
+
+	d := &DiscordClient{
+		deej:       deej,
+		logger:     logger,
+		pending:    make(map[string]chan discordPayload),
+		errChannel: make(chan error, 1),
+	}
+
+	logger.Debug("Created Discord client instance")
+
+	d.setupOnConfigReload()
+
+	return d
+}
+
+func (d *DiscordClient) Start() {
+	d.stopChannel = make(chan struct{})
+	d.logger.Info("Discord client starting")
+
+	go d.managerLoop()
+}
+
+func (d *DiscordClient) Stop() {
+	if d.stopChannel == nil {
+		return
+	}
+
+	close(d.stopChannel)
+	d.wg.Wait()
+
+	d.logger.Info("Discord client stopped")
+}
+
+func (d *DiscordClient) IsConnected() bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	return d.conn != nil && d.voiceKnown
+}
+
+// SetVoiceSetting switches self-mute or deafen on or off. nothing is sent if Discord is already in that state
+func (d *DiscordClient) SetVoiceSetting(setting string, on bool) error {
+	d.lock.Lock()
+	if d.conn == nil || !d.voiceKnown {
+		d.lock.Unlock()
+		return fmt.Errorf("not connected to Discord")
+	}
+
+	current := d.voice.Mute
+	if setting == discordVoiceDeafen {
+		current = d.voice.Deaf
+	}
+	d.lock.Unlock()
+
+	if current == on {
+		return nil
+	}
+
+	response, err := d.command(discordPayload{Cmd: discordCmdSetVoiceSettings, Args: map[string]bool{setting: on}}, discordCommandTimeout)
+	if err != nil {
+		return err
+	}
+
+	d.updateVoiceSettings(response.Data)
+	d.logger.Debugw("Set Discord voice setting", "setting", setting, "on", on)
+
+	return nil
+}
+
+func (d *DiscordClient) signalError(err error) {
+	select {
+	case d.errChannel <- err:
+	default:
+		// channel full, error already pending
+	}
+}
+
+// connect opens the IPC socket, then authorizes with Discord and reads the current voice settings
+func (d *DiscordClient) connect() error {
+	d.lock.Lock()
+	connected := d.conn != nil
+	d.lock.Unlock()
+
+	if connected {
+		return fmt.Errorf("already connected")
+	}
+
+	cfg := d.deej.config.DiscordConfig
+
+	d.logger.Debug("Attempting Discord connection")
+
+	conn, err := dialDiscordIPC()
+	if err != nil {
+		return fmt.Errorf("connect to Discord: %w", err)
+	}
+
+	// a Discord that's still starting up might accept the connection without ever answering it
+	handshakeTimer := time.AfterFunc(discordCommandTimeout, func() { conn.Close() })
+
+	if err := d.handshake(conn, cfg.ClientID); err != nil {
+		conn.Close()
+		return fmt.Errorf("discord handshake: %w", err)
+	}
+
+	if !handshakeTimer.Stop() {
+		return fmt.Errorf("discord handshake timed out")
+	}
+
+	d.lock.Lock()
+	d.conn = conn
+	d.voiceKnown = false
+	d.clientIDConfig = cfg.ClientID
+	d.clientSecretConfig = cfg.ClientSecret
+	d.lock.Unlock()
+
+	// everything from here on is request/response, so responses need to be read as they come in
+	d.wg.Add(1)
+	go d.readLoop(conn)
+
+	if err := d.authenticate(cfg.ClientID, cfg.ClientSecret); err != nil {
+		d.disconnect()
+		return err
+	}
+
+	if _, err := d.command(discordPayload{Cmd: discordCmdSubscribe, Evt: discordEvtVoiceSettingsUpdate}, discordCommandTimeout); err != nil {
+		d.logger.Debugw("Failed to subscribe to Discord voice settings updates", "error", err)
+	}
+
+	response, err := d.command(discordPayload{Cmd: discordCmdGetVoiceSettings}, discordCommandTimeout)
+	if err != nil {
+		d.disconnect()
+		return fmt.Errorf("get voice settings: %w", err)
+	}
+
+	d.updateVoiceSettings(response.Data)
+
+	d.logger.Info("Connected to Discord")
+
+	return nil
+}
+
+// handshake identifies deej to Discord, and waits for it to say it's ready
+func (d *DiscordClient) handshake(conn io.ReadWriter, clientID string) error {
+	if err := d.writeFrame(conn, discordOpHandshake, map[string]any{"v": 1, "client_id": clientID}); err != nil {
+		return err
+	}
+
+	op, payload, err := readDiscordFrame(conn)
+	if err != nil {
+		return err
+	}
+
+	if op == discordOpClose {
+		return fmt.Errorf("closed by Discord: %s", payload.Data)
+	}
+
+	if payload.Evt != discordEvtReady {
+		return fmt.Errorf("unexpected %s %s", payload.Cmd, payload.Evt)
+	}
+
+	return nil
+}
+
+// authenticate logs in with the saved refresh token if there's one, and asks the user to authorize deej otherwise
+func (d *DiscordClient) authenticate(clientID string, clientSecret string) error {
+	var token *discordToken
+
+	if refreshToken := d.deej.config.DiscordRefreshToken(); refreshToken != "" {
+		var err error
+
+		token, err = d.exchangeToken(url.Values{
+			"client_id":     {clientID},
+			"client_secret": {clientSecret},
+			"grant_type":    {"refresh_token"},
+			"refresh_token": {refreshToken},
+		})
+
+		if err != nil {
+			d.logger.Infow("Saved Discord authorization no longer works, authorizing again", "error", err)
+		}
+	}
+
+	if token == nil {
+		d.logger.Info("Asking for Discord authorization, accept the prompt in Discord to continue")
+
+		response, err := d.command(discordPayload{
+			Cmd:  discordCmdAuthorize,
+			Args: map[string]any{"client_id": clientID, "scopes": discordScopes},
+		}, discordAuthorizeTimeout)
+
+		if err != nil {
+			return fmt.Errorf("%w: authorize: %w", errDiscordAuth, err)
+		}
+
+		var authorization struct {
+			Code string `json:"code"`
+		}
+
+		if err := json.Unmarshal(response.Data, &authorization); err != nil || authorization.Code == "" {
+			return fmt.Errorf("%w: no authorization code", errDiscordAuth)
+		}
+
+		token, err = d.exchangeToken(url.Values{
+			"client_id":     {clientID},
+			"client_secret": {clientSecret},
+			"grant_type":    {"authorization_code"},
+			"code":          {authorization.Code},
+		})
+
+		if err != nil {
+			return fmt.Errorf("%w: %w", errDiscordAuth, err)
+		}
+	}
+
+	if err := d.deej.config.SetDiscordRefreshToken(token.RefreshToken); err != nil {
+		d.logger.Warnw("Failed to save Discord authorization, it will be asked for again next time", "error", err)
+	}
+
+	if _, err := d.command(discordPayload{
+		Cmd:  discordCmdAuthenticate,
+		Args: map[string]string{"access_token": token.AccessToken},
+	}, discordCommandTimeout); err != nil {
+		return fmt.Errorf("%w: authenticate: %w", errDiscordAuth, err)
+	}
+
+	return nil
+}
+
+// exchangeToken gets an access token from Discord's OAuth2 API, with either an authorization code or a refresh token
+func (d *DiscordClient) exchangeToken(form url.Values) (*discordToken, error) {
+	client := &http.Client{Timeout: discordCommandTimeout}
+
+	resp, err := client.PostForm(discordTokenURL, form)
+	if err != nil {
+		return nil, fmt.Errorf("request token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("request token: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var token discordToken
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return nil, fmt.Errorf("decode token: %w", err)
+	}
+
+	if token.AccessToken == "" {
+		return nil, errors.New("no access token in response")
+	}
+
+	return &token, nil
+}
+
+// command sends a command to Discord and waits for its response
+func (d *DiscordClient) command(request discordPayload, timeout time.Duration) (discordPayload, error) {
+	d.lock.Lock()
+	conn := d.conn
+	d.lock.Unlock()
+
+	if conn == nil {
+		return discordPayload{}, fmt.Errorf("not connected to Discord")
+	}
+
+	d.pendingLock.Lock()
+	d.nonce++
+	nonce := strconv.FormatUint(d.nonce, 10)
+	responseChannel := make(chan discordPayload, 1)
+	d.pending[nonce] = responseChannel
+	d.pendingLock.Unlock()
+
+	defer func() {
+		d.pendingLock.Lock()
+		delete(d.pending, nonce)
+		d.pendingLock.Unlock()
+	}()
+
+	request.Nonce = nonce
+	cmd := request.Cmd
+
+	if err := d.writeFrame(conn, discordOpFrame, request); err != nil {
+		d.signalError(err)
+		return discordPayload{}, fmt.Errorf("send %s: %w", cmd, err)
+	}
+
+	select {
+	case <-d.stopChannel:
+		return discordPayload{}, errors.New("stopping")
+
+	case <-time.After(timeout):
+		return discordPayload{}, fmt.Errorf("%s timed out", cmd)
+
+	case response, ok := <-responseChannel:
+		if !ok {
+			return discordPayload{}, errors.New("connection closed")
+		}
+
+		if response.Evt == discordEvtError {
+			var discordErr discordError
+			_ = json.Unmarshal(response.Data, &discordErr)
+
+			return discordPayload{}, fmt.Errorf("%s: %s (%d)", cmd, discordErr.Message, discordErr.Code)
+		}
+
+		return response, nil
+	}
+}
+
+// readLoop hands responses to whoever is waiting on them, and keeps track of voice settings changed in Discord
+func (d *DiscordClient) readLoop(conn io.ReadWriteCloser) {
+	defer d.wg.Done()
+
+	defer func() {
+		d.pendingLock.Lock()
+		defer d.pendingLock.Unlock()
+
+		for nonce, responseChannel := range d.pending {
+			close(responseChannel)
+			delete(d.pending, nonce)
+		}
+	}()
+
+	for {
+		op, payload, err := readDiscordFrame(conn)
+		if err != nil {
+			d.signalError(fmt.Errorf("read from Discord: %w", err))
+			return
+		}
+
+		switch op {
+		case discordOpClose:
+			d.signalError(fmt.Errorf("closed by Discord: %s", payload.Data))
+			return
+
+		case discordOpPing:
+			if err := d.writeFrame(conn, discordOpPong, payload.Data); err != nil {
+				d.signalError(err)
+				return
+			}
+
+		case discordOpFrame:
+			if payload.Cmd == discordCmdDispatch && payload.Evt == discordEvtVoiceSettingsUpdate {
+				d.updateVoiceSettings(payload.Data)
+				continue
+			}
+
+			d.pendingLock.Lock()
+			responseChannel, ok := d.pending[payload.Nonce]
+			d.pendingLock.Unlock()
+
+			if ok {
+				responseChannel <- payload
+			}
+		}
+	}
+}
+
+func (d *DiscordClient) updateVoiceSettings(data json.RawMessage) {
+	var settings discordVoiceSettings
+	if err := json.Unmarshal(data, &settings); err != nil {
+		d.logger.Debugw("Failed to parse Discord voice settings", "error", err)
+		return
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	d.voice = settings
+	d.voiceKnown = true
+}
+
+func (d *DiscordClient) writeFrame(w io.Writer, op uint32, value any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("marshal frame: %w", err)
+	}
+
+	frame := &bytes.Buffer{}
+	_ = binary.Write(frame, binary.LittleEndian, op)
+	_ = binary.Write(frame, binary.LittleEndian, uint32(len(data)))
+	frame.Write(data)
+
+	d.writeLock.Lock()
+	defer d.writeLock.Unlock()
+
+	if _, err := w.Write(frame.Bytes()); err != nil {
+		return fmt.Errorf("write frame: %w", err)
+	}
+
+	return nil
+}
+
+// readDiscordFrame reads one frame - a little-endian opcode and length, followed by that much JSON
+func readDiscordFrame(r io.Reader) (uint32, discordPayload, error) {
+	var header [8]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, discordPayload{}, err
+	}
+
+	op := binary.LittleEndian.Uint32(header[:4])
+	length := binary.LittleEndian.Uint32(header[4:])
+
+	if length > discordMaxFrameSize {
+		return 0, discordPayload{}, fmt.Errorf("frame too large: %d bytes", length)
+	}
+
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return 0, discordPayload{}, err
+	}
+
+	var payload discordPayload
+
+	// close and ping frames carry something other than a command, keep them around as-is
+	if op == discordOpClose || op == discordOpPing {
+		payload.Data = data
+		return op, payload, nil
+	}
+
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return 0, discordPayload{}, fmt.Errorf("decode frame: %w", err)
+	}
+
+	return op, payload, nil
+}
+
+func (d *DiscordClient) disconnect() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if d.conn == nil {
+		return
+	}
+
+	_ = d.conn.Close()
+	d.conn = nil
+	d.voiceKnown = false
+
+	d.logger.Info("Disconnected from Discord")
+}
+
+func (d *DiscordClient) managerLoop() {
+	d.wg.Add(1)
+	defer d.wg.Done()
+
+	d.logger.Info("Trying Discord connection")
+
+	for {
+		// check if Discord is enabled
+		if !d.deej.config.DiscordConfig.Enabled {
+			select {
+			case <-d.stopChannel:
+				d.logger.Debug("managerLoop: stop signal")
+				return
+			case <-time.After(discordRetryDelay):
+				continue
+			}
+		}
+
+		// attempt connection in goroutine so we can respond to stop signal
+		connectResult := make(chan error, 1)
+		go func() {
+			connectResult <- d.connect()
+		}()
+
+		// wait for connection result or stop signal
+		select {
+		case <-d.stopChannel:
+			d.logger.Debug("managerLoop: stop signal during connect")
+			// wait for connect to finish, then disconnect if it succeeded
+			if err := <-connectResult; err == nil {
+				d.disconnect()
+			}
+			return
+
+		case err := <-connectResult:
+			if err != nil {
+				retryDelay := discordRetryDelay
+
+				switch {
+				case errors.Is(err, errDiscordAuth):
+					d.logger.Warnw("Discord authorization failed, retrying later", "error", err)
+					retryDelay = discordAuthRetryDelay
+				case errors.Is(err, errDiscordNotRunning):
+					// the usual case when Discord is closed, don't bother logging it
+				default:
+					d.logger.Debugw("Discord connection error, retrying...", "error", err)
+				}
+
+				select {
+				case <-d.stopChannel:
+					d.logger.Debug("managerLoop: stop signal")
+					return
+				case <-time.After(retryDelay):
+					continue
+				}
+			}
+		}
+
+		// re-check if Discord was disabled while connecting
+		if !d.deej.config.DiscordConfig.Enabled {
+			d.logger.Debug("Discord disabled while connecting, disconnecting")
+			d.disconnect()
+			continue
+		}
+
+		// drain any stale errors from previous connection
+		select {
+		case <-d.errChannel:
+		default:
+		}
+
+		select {
+		case <-d.stopChannel:
+			d.logger.Debug("managerLoop: stop signal")
+			d.disconnect()
+			return
+
+		case err := <-d.errChannel:
+			d.logger.Warnw("Discord connection error, reconnecting...", "error", err)
+			d.disconnect()
+			time.Sleep(discordRetryDelay)
+			continue
+		}
+	}
+}
+
+func (d *DiscordClient) setupOnConfigReload() {
+	configReloadedChannel := d.deej.config.SubscribeToChanges()
+
+	go func() {
+		for {
+			<-configReloadedChannel
+
+			// only trigger reconnect if currently connected
+			if !d.IsConnected() {
+				continue
+			}
+
+			cfg := d.deej.config.DiscordConfig
+
+			if cfg.ClientID != d.clientIDConfig ||
+				cfg.ClientSecret != d.clientSecretConfig ||
+				!cfg.Enabled {
+
+				d.logger.Debug("Discord config changed, triggering reconnect")
+				d.signalError(errors.New("config changed"))
+			}
+		}
+	}()
+}
@@ -0,0 +1,43 @@
+//go:build linux || darwin
+
+package deej
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+)
+
+// Flatpak and Snap builds of Discord put their sockets in subdirectories of the usual temp dirs
+var discordIPCSubdirs = []string{"", "app/com.discordapp.Discord", "snap.discord"}
+
+// dialDiscordIPC connects to the first Discord socket that's there
+func dialDiscordIPC() (io.ReadWriteCloser, error) {
+	dirs := []string{}
+	for _, env := range []string{"XDG_RUNTIME_DIR", "TMPDIR", "TMP", "TEMP"} {
+		if dir := os.Getenv(env); dir != "" {
+			dirs = append(dirs, dir)
+		}
+	}
+
+	dirs = append(dirs, "/tmp")
+
+	for _, dir := range dirs {
+		for _, subdir := range discordIPCSubdirs {
+			for i := 0; i < discordIPCMaxSockets; i++ {
+				path := filepath.Join(dir, subdir, fmt.Sprintf("discord-ipc-%d", i))
+
+				conn, err := net.Dial("unix", path)
+				if err != nil {
+					continue
+				}
+
+				return conn, nil
+			}
+		}
+	}
+
+	return nil, errDiscordNotRunning
+}
@@ -0,0 +1,38 @@
+package deej
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// dialDiscordIPC connects to the first Discord named pipe that's there.
+// pipes are opened for overlapped I/O, so closing one interrupts a pending read
+func dialDiscordIPC() (io.ReadWriteCloser, error) {
+	for i := 0; i < discordIPCMaxSockets; i++ {
+		path := fmt.Sprintf(`\\.\pipe\discord-ipc-%d`, i)
+
+		pathPtr, err := windows.UTF16PtrFromString(path)
+		if err != nil {
+			return nil, fmt.Errorf("convert pipe path: %w", err)
+		}
+
+		handle, err := windows.CreateFile(pathPtr,
+			windows.GENERIC_READ|windows.GENERIC_WRITE,
+			0,
+			nil,
+			windows.OPEN_EXISTING,
+			windows.FILE_FLAG_OVERLAPPED,
+			0)
+
+		if err != nil {
+			continue
+		}
+
+		return os.NewFile(uintptr(handle), path), nil
+	}
+
+	return nil, errDiscordNotRunning
+}
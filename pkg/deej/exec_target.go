@@ -0,0 +1,199 @@
+package deej
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/nik9play/deej/pkg/deej/util"
+)
+
+// execTargetRunner runs the user's configured shell commands for deej.exec:<name> targets.
+// every command runs on its own worker, once the slider has settled, and never more than one
+// instance at a time - so a slider sweep doesn't turn into hundreds of processes
+type execTargetRunner struct {
+	logger *zap.SugaredLogger
+	config *CanonicalConfig
+
+	lock sync.Mutex
+
+	// pending slider values, keyed by command name. each one holds at most the latest value
+	queues map[string]chan float32
+
+	stopChannel chan struct{}
+}
+
+// the values available to command templates
+type execCommandData struct {
+	// slider position between 0 and 100
+	Percent int
+
+	// slider position between 0.0 and 1.0
+	Value float32
+
+	// the lifecycle event that triggered the command, i.e. "connect". empty for slider moves
+	Event string
+}
+
+const (
+	// how long a slider has to stay put before its command runs
+	execDebounceDelay = 250 * time.Millisecond
+
+	// commands still running after this long are killed
+	execCommandTimeout = 10 * time.Second
+)
+
+func newExecTargetRunner(logger *zap.SugaredLogger, config *CanonicalConfig) *execTargetRunner {
+	return &execTargetRunner{
+		logger:      logger.Named("exec"),
+		config:      config,
+		queues:      make(map[string]chan float32),
+		stopChannel: make(chan struct{}),
+	}
+}
+
+// Run schedules the named command to run with the given slider value
+func (r *execTargetRunner) Run(name string, volume float32) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	queue, ok := r.queues[name]
+	if !ok {
+		queue = make(chan float32, 1)
+		r.queues[name] = queue
+
+		go r.worker(name, queue)
+	}
+
+	// only the latest value is worth running the command for
+	select {
+	case <-queue:
+	default:
+	}
+
+	queue <- volume
+}
+
+// Stop shuts down all workers. commands that are already running are left to finish or time out
+func (r *execTargetRunner) Stop() {
+	close(r.stopChannel)
+}
+
+func (r *execTargetRunner) worker(name string, queue chan float32) {
+	for {
+		var volume float32
+
+		select {
+		case <-r.stopChannel:
+			return
+		case volume = <-queue:
+		}
+
+		// wait for the slider to settle, picking up any newer values meanwhile
+		debounce := time.NewTimer(execDebounceDelay)
+
+	settle:
+		for {
+			select {
+			case <-r.stopChannel:
+				debounce.Stop()
+				return
+			case volume = <-queue:
+				debounce.Reset(execDebounceDelay)
+			case <-debounce.C:
+				break settle
+			}
+		}
+
+		if err := r.execute(name, volume); err != nil {
+			r.logger.Warnw("Failed to run exec target command", "name", name, "error", err)
+		}
+	}
+}
+
+func (r *execTargetRunner) execute(name string, volume float32) error {
+
+	// the config may have changed since the slider moved
+	if !r.config.AllowExecTargets {
+		return nil
+	}
+
+	commandTemplate, ok := r.config.ExecCommands[name]
+	if !ok {
+		return fmt.Errorf("no command configured")
+	}
+
+	return r.RunCommand(name, commandTemplate, execCommandData{
+		Percent: int(math.Round(float64(volume) * 100)),
+		Value:   volume,
+	})
+}
+
+// RunCommand renders the given command template and runs it right away, waiting for it to finish or time out
+func (r *execTargetRunner) RunCommand(name string, commandTemplate string, data execCommandData) error {
+	tmpl, err := parseExecCommand(name, commandTemplate)
+	if err != nil {
+		return err
+	}
+
+	var command strings.Builder
+	if err := tmpl.Execute(&command, data); err != nil {
+		return fmt.Errorf("render command: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), execCommandTimeout)
+	defer cancel()
+
+	cmd := util.ShellCommand(ctx, command.String())
+	cmd.Env = append(os.Environ(),
+		fmt.Sprintf("DEEJ_PERCENT=%d", data.Percent),
+		fmt.Sprintf("DEEJ_VALUE=%.2f", data.Value),
+		fmt.Sprintf("DEEJ_EVENT=%s", data.Event))
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("run command: %w (output: %s)", err, strings.TrimSpace(string(output)))
+	}
+
+	r.logger.Debugw("Ran command", "name", name, "percent", data.Percent, "event", data.Event)
+
+	return nil
+}
+
+// Launch starts a program without waiting for it, i.e. a game for an on_missing_target policy.
+// unlike commands, it isn't run through a shell and keeps running after deej is done with it
+func (r *execTargetRunner) Launch(path string) error {
+	cmd := exec.Command(path)
+	cmd.Dir = filepath.Dir(path)
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("start program: %w", err)
+	}
+
+	// reap it whenever it exits
+	go func() {
+		_ = cmd.Wait()
+	}()
+
+	r.logger.Debugw("Launched program", "path", path, "pid", cmd.Process.Pid)
+
+	return nil
+}
+
+func parseExecCommand(name string, commandTemplate string) (*template.Template, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(commandTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("parse command template: %w", err)
+	}
+
+	return tmpl, nil
+}
@@ -0,0 +1,152 @@
+package deej
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// lifecycleHooks runs the user's on_connect and on_disconnect actions as the board comes and goes
+type lifecycleHooks struct {
+	deej   *Deej
+	logger *zap.SugaredLogger
+
+	// serializes hook runs and guards lastRun
+	lock    sync.Mutex
+	lastRun map[string]time.Time
+
+	stateChangeChannel chan bool
+
+	// the last state hooks ran for. only touched by the state goroutine, and by stop once that's done
+	connected bool
+
+	wg          sync.WaitGroup
+	stopChannel chan struct{}
+	doneChannel chan struct{}
+}
+
+const (
+	hookEventConnect    = "connect"
+	hookEventDisconnect = "disconnect"
+
+	// a flaky cable shouldn't set off a hook every few hundred milliseconds
+	minTimeBetweenHookRuns = 10 * time.Second
+
+	// built-in hook action that refreshes all audio sessions
+	hookActionRescan = "deej.rescan"
+)
+
+func newLifecycleHooks(deej *Deej, logger *zap.SugaredLogger) *lifecycleHooks {
+	return &lifecycleHooks{
+		deej:        deej,
+		logger:      logger.Named("hooks"),
+		lastRun:     make(map[string]time.Time),
+		stopChannel: make(chan struct{}),
+		doneChannel: make(chan struct{}),
+	}
+}
+
+func (h *lifecycleHooks) initialize() {
+	h.stateChangeChannel = h.deej.serial.SubscribeToStateChangeEvent()
+
+	go func() {
+		defer close(h.doneChannel)
+
+		for {
+			select {
+			case <-h.stopChannel:
+				return
+
+			case state := <-h.stateChangeChannel:
+				h.handleStateChange(state)
+			}
+		}
+	}()
+}
+
+// handleStateChange starts the hook for a board that just connected or disconnected
+func (h *lifecycleHooks) handleStateChange(state bool) {
+
+	// config-driven reconnects report the disconnect twice, so only act on actual transitions
+	if state == h.connected {
+		return
+	}
+	h.connected = state
+
+	event, action := hookEventDisconnect, h.deej.config.OnDisconnect
+	if state {
+		event, action = hookEventConnect, h.deej.config.OnConnect
+	}
+
+	// don't hold up the serial connection while the hook runs
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+		h.run(event, action)
+	}()
+}
+
+// stop waits for running hooks to finish. called after the serial connection is closed,
+// so that a graceful shutdown still gets to run the disconnect hook
+func (h *lifecycleHooks) stop() {
+	close(h.stopChannel)
+	<-h.doneChannel
+
+	// closing the connection just reported the disconnect, which the goroutine may have left for stopping instead
+	select {
+	case state := <-h.stateChangeChannel:
+		h.handleStateChange(state)
+	default:
+	}
+
+	h.wg.Wait()
+}
+
+func (h *lifecycleHooks) run(event string, action string) {
+	if action == "" {
+		return
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	now := time.Now()
+	if lastRun, ok := h.lastRun[event]; ok && lastRun.Add(minTimeBetweenHookRuns).After(now) {
+		h.logger.Debugw("Hook ran very recently, skipping", "event", event)
+		return
+	}
+	h.lastRun[event] = now
+
+	h.logger.Infow("Running hook", "event", event, "action", action)
+
+	if action == hookActionRescan {
+		h.deej.sessions.refreshSessions(true)
+		return
+	}
+
+	// everything else runs a command, which is only allowed alongside exec targets
+	if !h.deej.config.AllowExecTargets {
+		h.logger.Warnw("Not running hook command, exec targets aren't allowed in the config", "event", event)
+		return
+	}
+
+	name := event
+	commandTemplate := action
+
+	// named commands are shared with deej.exec:<name> targets
+	if strings.HasPrefix(strings.ToLower(action), execTargetPrefix) {
+		name = strings.ToLower(action[len(execTargetPrefix):])
+
+		var ok bool
+		if commandTemplate, ok = h.deej.config.ExecCommands[name]; !ok {
+			h.logger.Warnw("Hook refers to an unknown exec command", "event", event, "name", name)
+			return
+		}
+	}
+
+	if err := h.deej.sessions.execTargets.RunCommand(name, commandTemplate, execCommandData{Event: event}); err != nil {
+		h.logger.Warnw("Failed to run hook command", "event", event, "error", err)
+	}
+}
@@ -0,0 +1,25 @@
+package deej
+
+import "testing"
+
+func TestDisconnectHookRunsOnShutdown(t *testing.T) {
+	d := newTestDeej(t, "slider_mapping:\n  0: master\non_disconnect: deej.rescan\n")
+
+	hooks := newLifecycleHooks(d, d.logger)
+	hooks.stateChangeChannel = d.serial.SubscribeToStateChangeEvent()
+	hooks.connected = true
+
+	// the state goroutine picking the stop signal over the disconnect, which both arrive at once on shutdown
+	close(hooks.doneChannel)
+
+	d.serial.sendStateChangeEvent(false)
+	hooks.stop()
+
+	hooks.lock.Lock()
+	_, ran := hooks.lastRun[hookEventDisconnect]
+	hooks.lock.Unlock()
+
+	if !ran {
+		t.Error("disconnect hook didn't run on shutdown")
+	}
+}
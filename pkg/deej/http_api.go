@@ -0,0 +1,452 @@
+package deej
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+// httpAPI is an optional local HTTP server for reading and setting volumes from other tools,
+// i.e. a Stream Deck or home automation, with a websocket stream of slider moves for overlays.
+// it follows the config, starting and stopping as it's toggled
+type httpAPI struct {
+	deej   *Deej
+	logger *zap.SugaredLogger
+
+	// guards server and address
+	lock    sync.Mutex
+	server  *http.Server
+	address string
+
+	stopChannel chan struct{}
+	doneChannel chan struct{}
+}
+
+type httpAPISession struct {
+	Key         string  `json:"key"`
+	DisplayName string  `json:"displayName"`
+	PID         uint32  `json:"pid,omitempty"`
+	Volume      float32 `json:"volume"`
+	Muted       bool    `json:"muted"`
+}
+
+type httpAPIVolumeRequest struct {
+	Target string   `json:"target"`
+	Volume *float32 `json:"volume"`
+}
+
+type httpAPIVolumeResponse struct {
+	Target string `json:"target"`
+
+	// how many audio sessions were adjusted. always 0 for action targets (OBS, etc.)
+	Sessions int `json:"sessions"`
+}
+
+// httpAPISliderMessage is sent to live slider stream clients. "sliders" comes first with every slider's
+// current position, followed by a "move" for each slider move. "sliders" is sent again whenever
+// the board starts reporting a different number of sliders
+type httpAPISliderMessage struct {
+	Type    string              `json:"type"`
+	Sliders []httpAPISliderMove `json:"sliders,omitempty"`
+
+	*httpAPISliderMove
+}
+
+type httpAPISliderMove struct {
+	SliderID int     `json:"slider"`
+	Percent  float32 `json:"percent"`
+}
+
+// httpAPIHealth is deej's state at a glance. it's "degraded" while audio sessions can't be reached,
+// a disconnected board alone doesn't count since that's usually just the board being unplugged
+type httpAPIHealth struct {
+	Status          string     `json:"status"`
+	SerialConnected bool       `json:"serialConnected"`
+	Sessions        int        `json:"sessions"`
+	LastRefresh     *time.Time `json:"lastRefresh,omitempty"`
+	SessionError    string     `json:"sessionError,omitempty"`
+	FinderStalled   bool       `json:"finderStalled"`
+}
+
+type httpAPIError struct {
+	Error string `json:"error"`
+}
+
+const (
+	// requests are small, so anything beyond this is a mistake
+	httpAPIMaxRequestSize = 64 * 1024
+
+	httpAPIShutdownTimeout = 2 * time.Second
+
+	// clients that can't take a message within this long are dropped
+	httpAPIWriteTimeout = 5 * time.Second
+
+	httpAPIHealthOK       = "ok"
+	httpAPIHealthDegraded = "degraded"
+
+	httpAPISliderMessageSliders = "sliders"
+	httpAPISliderMessageMove    = "move"
+)
+
+// browser overlays have to be served locally to read the slider stream, see httpAPIOriginAllowed
+var httpAPIUpgrader = websocket.Upgrader{
+	CheckOrigin: httpAPIOriginAllowed,
+}
+
+// httpAPIOriginAllowed keeps web pages off the API: any site open in a browser can send requests to localhost.
+// requests without an Origin come from other tools rather than browsers, and pass. pages only do when they're
+// served by the API's own host or from this machine, i.e. a local overlay
+func httpAPIOriginAllowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	originURL, err := url.Parse(origin)
+	if err != nil || originURL.Host == "" {
+		return false
+	}
+
+	if originURL.Host == r.Host {
+		return true
+	}
+
+	hostname := originURL.Hostname()
+	ip := net.ParseIP(hostname)
+
+	return hostname == "localhost" || (ip != nil && ip.IsLoopback())
+}
+
+func newHTTPAPI(deej *Deej, logger *zap.SugaredLogger) *httpAPI {
+	return &httpAPI{
+		deej:        deej,
+		logger:      logger.Named("http_api"),
+		stopChannel: make(chan struct{}),
+		doneChannel: make(chan struct{}),
+	}
+}
+
+// Start brings the server up if it's enabled, and keeps it in line with the config from then on
+func (a *httpAPI) Start() {
+	configReloadedChannel := a.deej.config.SubscribeToChanges()
+
+	a.apply()
+
+	go func() {
+		defer close(a.doneChannel)
+		defer a.deej.config.UnsubscribeFromChanges(configReloadedChannel)
+
+		for {
+			select {
+			case <-a.stopChannel:
+				return
+			case <-configReloadedChannel:
+				a.apply()
+			}
+		}
+	}()
+}
+
+// Stop shuts the server down, if it's running
+func (a *httpAPI) Stop() {
+	close(a.stopChannel)
+	<-a.doneChannel
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.shutdown()
+}
+
+// apply starts, stops or moves the server to match the current config
+func (a *httpAPI) apply() {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	cfg := a.deej.config.HTTPAPIConfig
+
+	address := ""
+	if cfg.Enabled {
+		address = net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
+	}
+
+	if address == a.address {
+		return
+	}
+
+	a.shutdown()
+
+	if address == "" {
+		return
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		a.logger.Warnw("Failed to start HTTP API", "address", address, "error", err)
+		return
+	}
+
+	if ip := net.ParseIP(cfg.Host); cfg.Host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		a.logger.Warnw("HTTP API is reachable from other machines, anyone on the network can change volumes",
+			"address", address)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /sessions", a.handleGetSessions)
+	mux.HandleFunc("POST /volume", a.handleSetVolume)
+	mux.HandleFunc("GET /sliders", a.handleSliderStream)
+	mux.HandleFunc("GET /healthz", a.handleHealth)
+	mux.HandleFunc("GET /metrics", a.handleMetrics)
+
+	// shutting down doesn't touch hijacked websocket connections, so have their request contexts end instead
+	ctx, cancel := context.WithCancel(context.Background())
+
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+	server.RegisterOnShutdown(cancel)
+
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			a.logger.Warnw("HTTP API stopped unexpectedly", "error", err)
+		}
+	}()
+
+	a.server = server
+	a.address = address
+
+	a.logger.Infow("HTTP API listening", "address", address)
+}
+
+// shutdown stops the running server. must be called with a.lock held
+func (a *httpAPI) shutdown() {
+	if a.server == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), httpAPIShutdownTimeout)
+	defer cancel()
+
+	if err := a.server.Shutdown(ctx); err != nil {
+		a.logger.Warnw("Failed to shut down HTTP API gracefully", "error", err)
+	}
+
+	a.logger.Infow("HTTP API stopped", "address", a.address)
+
+	a.server = nil
+	a.address = ""
+}
+
+func (a *httpAPI) handleGetSessions(w http.ResponseWriter, _ *http.Request) {
+	result := []httpAPISession{}
+
+	for _, session := range a.deej.sessions.getSessions() {
+		result = append(result, httpAPISession{
+			Key:         session.Key(),
+			DisplayName: session.DisplayName(),
+			PID:         session.PID(),
+			Volume:      session.GetVolume(),
+			Muted:       session.GetMute(),
+		})
+	}
+
+	a.writeJSON(w, http.StatusOK, result)
+}
+
+func (a *httpAPI) handleSetVolume(w http.ResponseWriter, r *http.Request) {
+	if !httpAPIOriginAllowed(r) {
+		a.logger.Warnw("Rejected volume change from a web page", "origin", r.Header.Get("Origin"))
+		a.writeJSON(w, http.StatusForbidden, httpAPIError{Error: "origin not allowed"})
+		return
+	}
+
+	// browsers send plain text across origins without asking first, JSON needs their permission
+	if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mediaType != "application/json" {
+		a.writeJSON(w, http.StatusUnsupportedMediaType, httpAPIError{Error: "content type must be application/json"})
+		return
+	}
+
+	var request httpAPIVolumeRequest
+
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, httpAPIMaxRequestSize)).Decode(&request); err != nil {
+		a.writeJSON(w, http.StatusBadRequest, httpAPIError{Error: "invalid JSON body"})
+		return
+	}
+
+	if request.Target == "" {
+		a.writeJSON(w, http.StatusBadRequest, httpAPIError{Error: "target is required"})
+		return
+	}
+
+	if request.Volume == nil || *request.Volume < 0 || *request.Volume > 1 {
+		a.writeJSON(w, http.StatusBadRequest, httpAPIError{Error: "volume must be between 0.0 and 1.0"})
+		return
+	}
+
+	volume := *request.Volume
+	sessions := a.deej.sessions
+
+	// action targets (OBS, etc.) work the same as they do from a slider
+	if sessions.applySpecialTargetAction(request.Target, volume) {
+		a.writeJSON(w, http.StatusOK, httpAPIVolumeResponse{Target: request.Target})
+		return
+	}
+
+	targetSessions := sessions.sessionsForTarget(request.Target)
+	if len(targetSessions) == 0 {
+		a.writeJSON(w, http.StatusNotFound, httpAPIError{Error: "no sessions match this target"})
+		return
+	}
+
+	for _, session := range targetSessions {
+		if sessions.shouldLogVolumeChanges() {
+			a.logger.Infow("Volume change", "session", session.Key(), "from", session.GetVolume(), "to", volume)
+		}
+
+		if err := session.SetVolume(volume); err != nil {
+			a.logger.Warnw("Failed to set target session volume", "error", err)
+			a.deej.metrics.setVolumeErrors.Add(1)
+		}
+	}
+
+	a.writeJSON(w, http.StatusOK, httpAPIVolumeResponse{Target: request.Target, Sessions: len(targetSessions)})
+}
+
+// handleHealth answers 503 while degraded, so it works with monitoring that only looks at status codes
+func (a *httpAPI) handleHealth(w http.ResponseWriter, _ *http.Request) {
+	sessions := a.deej.sessions
+
+	health := httpAPIHealth{
+		Status:          httpAPIHealthOK,
+		SerialConnected: a.deej.serial.GetState(),
+		Sessions:        sessions.getSessionCount(),
+		FinderStalled:   sessions.sessionFinderStalled(),
+	}
+
+	if lastRefresh := sessions.lastRefreshSucceeded(); !lastRefresh.IsZero() {
+		health.LastRefresh = &lastRefresh
+	}
+
+	if err := sessions.sessionAcquisitionError(); err != nil {
+		health.SessionError = err.Error()
+	}
+
+	status := http.StatusOK
+	if health.SessionError != "" || health.FinderStalled {
+		health.Status = httpAPIHealthDegraded
+		status = http.StatusServiceUnavailable
+	}
+
+	a.writeJSON(w, status, health)
+}
+
+func (a *httpAPI) handleMetrics(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	if err := a.deej.metrics.writePrometheus(w, a.deej.serial.GetState(), a.deej.sessions.getSessionCount()); err != nil {
+		a.logger.Debugw("Failed to write HTTP API response", "error", err)
+	}
+}
+
+// handleSliderStream upgrades to a websocket that sends every slider's position, then each move as it happens
+func (a *httpAPI) handleSliderStream(w http.ResponseWriter, r *http.Request) {
+	conn, err := httpAPIUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		a.logger.Debugw("Failed to upgrade slider stream connection", "error", err)
+		return
+	}
+	defer conn.Close()
+
+	moveEvents := a.deej.serial.SubscribeToSliderMoveEvents(sliderMoveConsumerWebSocket)
+	defer a.deej.serial.UnsubscribeFromSliderMoveEvents(moveEvents)
+
+	sliderCountEvents := a.deej.serial.SubscribeToSliderCountChangeEvent()
+	defer a.deej.serial.UnsubscribeFromSliderCountChangeEvent(sliderCountEvents)
+
+	a.logger.Debugw("Slider stream client connected", "remoteAddr", r.RemoteAddr)
+	defer a.logger.Debugw("Slider stream client disconnected", "remoteAddr", r.RemoteAddr)
+
+	// clients don't send anything, but reading is how a closed connection is noticed
+	clientGone := make(chan struct{})
+	go func() {
+		defer close(clientGone)
+
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	if err := a.writeWebSocketJSON(conn, a.sliderSnapshot()); err != nil {
+		return
+	}
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-clientGone:
+			return
+		case event := <-moveEvents:
+			message := httpAPISliderMessage{
+				Type:              httpAPISliderMessageMove,
+				httpAPISliderMove: &httpAPISliderMove{SliderID: event.SliderID, Percent: event.PercentValue},
+			}
+
+			if err := a.writeWebSocketJSON(conn, message); err != nil {
+				return
+			}
+
+		// the client has to redraw for a different number of sliders anyway, so give it all of them
+		case <-sliderCountEvents:
+			if err := a.writeWebSocketJSON(conn, a.sliderSnapshot()); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// sliderSnapshot is a "sliders" message with every slider's current position
+func (a *httpAPI) sliderSnapshot() httpAPISliderMessage {
+	message := httpAPISliderMessage{Type: httpAPISliderMessageSliders, Sliders: []httpAPISliderMove{}}
+	for sliderID, percent := range a.deej.serial.SliderValues() {
+		message.Sliders = append(message.Sliders, httpAPISliderMove{SliderID: sliderID, Percent: percent})
+	}
+
+	return message
+}
+
+func (a *httpAPI) writeWebSocketJSON(conn *websocket.Conn, value any) error {
+	if err := conn.SetWriteDeadline(time.Now().Add(httpAPIWriteTimeout)); err != nil {
+		return err
+	}
+
+	if err := conn.WriteJSON(value); err != nil {
+		a.logger.Debugw("Failed to write to slider stream client", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+func (a *httpAPI) writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		a.logger.Debugw("Failed to write HTTP API response", "error", err)
+	}
+}
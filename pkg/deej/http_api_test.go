@@ -0,0 +1,72 @@
+package deej
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHTTPAPIOriginAllowed(t *testing.T) {
+	tests := map[string]bool{
+		"":                        true,
+		"http://localhost:3000":   true,
+		"http://127.0.0.1:8080":   true,
+		"http://[::1]":            true,
+		"http://localhost:7433":   true,
+		"http://deej.local:7433":  true,
+		"https://evil.example":    false,
+		"http://192.168.1.5:7433": false,
+		"null":                    false,
+	}
+
+	for origin, expected := range tests {
+		r := httptest.NewRequest(http.MethodGet, "http://deej.local:7433/sliders", nil)
+		if origin != "" {
+			r.Header.Set("Origin", origin)
+		}
+
+		if allowed := httpAPIOriginAllowed(r); allowed != expected {
+			t.Errorf("httpAPIOriginAllowed(%q) = %v, expected %v", origin, allowed, expected)
+		}
+	}
+}
+
+func TestHTTPAPISetVolumeRejectsWebPages(t *testing.T) {
+	d := newTestDeej(t, "slider_mapping:\n  0: master\n")
+
+	tests := []struct {
+		name        string
+		origin      string
+		contentType string
+		status      int
+	}{
+		// what a cross-origin form or fetch without a preflight looks like
+		{"cross-origin plain text", "https://evil.example", "text/plain", http.StatusForbidden},
+		{"cross-origin JSON", "https://evil.example", "application/json", http.StatusForbidden},
+		{"plain text", "", "text/plain", http.StatusUnsupportedMediaType},
+		{"no content type", "", "", http.StatusUnsupportedMediaType},
+
+		// gets past the checks, there just isn't a session for it
+		{"local tool", "", "application/json; charset=utf-8", http.StatusNotFound},
+		{"local page", "http://localhost:3000", "application/json", http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		body := strings.NewReader(`{"target": "deej-test-missing.exe", "volume": 0.5}`)
+		r := httptest.NewRequest(http.MethodPost, "http://localhost:7433/volume", body)
+		if test.origin != "" {
+			r.Header.Set("Origin", test.origin)
+		}
+		if test.contentType != "" {
+			r.Header.Set("Content-Type", test.contentType)
+		}
+
+		w := httptest.NewRecorder()
+		d.httpAPI.handleSetVolume(w, r)
+
+		if w.Code != test.status {
+			t.Errorf("%s: status = %d, expected %d", test.name, w.Code, test.status)
+		}
+	}
+}
@@ -1,9 +1,14 @@
 package deej
 
 import (
+	"errors"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/nik9play/deej/pkg/deej/util"
@@ -16,18 +21,41 @@ const (
 	buildTypeDev     = "dev"
 	buildTypeRelease = "release"
 	logFilename      = "deej-latest-run.log"
+
+	// log levels users can pick in the config, on top of whatever the build type defaults to
+	logLevelDefault = "default"
+	logLevelDebug   = "debug"
+	logLevelInfo    = "info"
+	logLevelWarn    = "warn"
+
+	// how many rotated log files are kept next to the current one, as deej-latest-run.log.1 and so on
+	logFileBackups = 3
+
+	logFileSinkScheme = "deej-log"
 )
 
+var logLevels = []string{logLevelDefault, logLevelDebug, logLevelInfo, logLevelWarn}
+
+// the logger's level can change whenever the config does, so it's shared rather than fixed at build time
+var (
+	logLevel        = zap.NewAtomicLevel()
+	buildLogLevel   zapcore.Level
+	verboseLogLevel atomic.Bool
+)
+
+// the release build's log file, which the config can set a size limit on
+var logFile = &rotatingLogFile{}
+
 // NewLogger provides a logger instance for the whole program
 func NewLogger(buildType string) (*zap.SugaredLogger, error) {
 	var loggerConfig zap.Config
 
-	ex, err := os.Executable()
+	dir, err := dataDir()
 	if err != nil {
-		return nil, fmt.Errorf("get executable dir: %w", err)
+		return nil, err
 	}
 
-	logDirectory := filepath.Join(filepath.Dir(ex), "logs")
+	logDirectory := filepath.Join(dir, "logs")
 
 	// release: info and above, log to file only (no UI)
 	if buildType == buildTypeRelease {
@@ -37,7 +65,14 @@ func NewLogger(buildType string) (*zap.SugaredLogger, error) {
 
 		loggerConfig = zap.NewProductionConfig()
 
-		loggerConfig.OutputPaths = []string{filepath.Join(logDirectory, logFilename)}
+		logFile.path = filepath.Join(logDirectory, logFilename)
+		if err := zap.RegisterSink(logFileSinkScheme, func(_ *url.URL) (zap.Sink, error) {
+			return logFile, logFile.open()
+		}); err != nil {
+			return nil, fmt.Errorf("register log file sink: %w", err)
+		}
+
+		loggerConfig.OutputPaths = []string{logFileSinkScheme + ":"}
 		loggerConfig.Encoding = "console"
 
 		// development: debug and above, log to stderr only, colorful
@@ -48,6 +83,10 @@ func NewLogger(buildType string) (*zap.SugaredLogger, error) {
 		loggerConfig.EncoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
 	}
 
+	buildLogLevel = loggerConfig.Level.Level()
+	logLevel.SetLevel(buildLogLevel)
+	loggerConfig.Level = logLevel
+
 	// all build types: make it readable
 	loggerConfig.EncoderConfig.EncodeCaller = nil
 	loggerConfig.EncoderConfig.EncodeTime = func(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
@@ -68,3 +107,146 @@ func NewLogger(buildType string) (*zap.SugaredLogger, error) {
 
 	return sugar, nil
 }
+
+// setLogLevel switches the logger to one of the configured log levels. verbose mode always logs everything
+func setLogLevel(level string) {
+	if verboseLogLevel.Load() {
+		return
+	}
+
+	switch level {
+	case logLevelDebug:
+		logLevel.SetLevel(zapcore.DebugLevel)
+	case logLevelInfo:
+		logLevel.SetLevel(zapcore.InfoLevel)
+	case logLevelWarn:
+		logLevel.SetLevel(zapcore.WarnLevel)
+	default:
+		logLevel.SetLevel(buildLogLevel)
+	}
+}
+
+// useVerboseLogLevel logs everything from now on, regardless of the config
+func useVerboseLogLevel() {
+	verboseLogLevel.Store(true)
+	logLevel.SetLevel(zapcore.DebugLevel)
+}
+
+// rotatingLogFile appends to the log file, moving it aside once it grows past a size limit.
+// without a limit it grows for as long as it's kept around, like a plain log file
+type rotatingLogFile struct {
+	path string
+
+	lock    sync.Mutex
+	file    *os.File
+	size    int64
+	maxSize int64
+}
+
+func (f *rotatingLogFile) open() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return f.openLocked()
+}
+
+func (f *rotatingLogFile) openLocked() error {
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("open log file: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("stat log file: %w", err)
+	}
+
+	f.file = file
+	f.size = info.Size()
+
+	return nil
+}
+
+// setMaxSize sets the size in bytes the log file is rotated at, or turns rotation off with 0
+func (f *rotatingLogFile) setMaxSize(maxSize int64) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.maxSize = maxSize
+}
+
+func (f *rotatingLogFile) Write(p []byte) (int, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.file == nil {
+		return 0, errors.New("log file isn't open")
+	}
+
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+
+		// there's nowhere to report a failure to, so keep logging to the full file rather than losing lines
+		_ = f.rotateLocked()
+
+		if f.file == nil {
+			return 0, errors.New("log file couldn't be reopened")
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+
+	return n, err
+}
+
+// rotateLocked shifts the backups along by one, dropping the oldest, and starts a new log file
+func (f *rotatingLogFile) rotateLocked() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+
+	for backup := logFileBackups - 1; backup > 0; backup-- {
+		_ = os.Rename(f.path+"."+strconv.Itoa(backup), f.path+"."+strconv.Itoa(backup+1))
+	}
+
+	renameErr := os.Rename(f.path, f.path+".1")
+
+	// the file has to be reopened either way
+	if err := f.openLocked(); err != nil {
+		f.file = nil
+		return err
+	}
+
+	// don't try again on every line if the file can't be moved, only once it's grown by the limit again
+	if renameErr != nil {
+		f.size = 0
+	}
+
+	return renameErr
+}
+
+func (f *rotatingLogFile) Sync() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+
+	return f.file.Sync()
+}
+
+func (f *rotatingLogFile) Close() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+
+	err := f.file.Close()
+	f.file = nil
+
+	return err
+}
@@ -0,0 +1,52 @@
+package deej
+
+import (
+	"fmt"
+	"io"
+	"sync/atomic"
+)
+
+// deejMetrics counts things worth watching on a long-running deej, exposed by the HTTP API's /metrics
+type deejMetrics struct {
+	// slider moves handled by the session map, including ones with nothing mapped
+	sliderEvents atomic.Uint64
+
+	// volume changes the audio system refused
+	setVolumeErrors atomic.Uint64
+
+	// times the serial connection dropped with an error and had to be reconnected
+	serialReconnects atomic.Uint64
+}
+
+// writePrometheus writes the counters, plus a few gauges of current state, in Prometheus' text format
+func (dm *deejMetrics) writePrometheus(w io.Writer, serialConnected bool, sessionCount int) error {
+	metrics := []struct {
+		name       string
+		help       string
+		metricType string
+		value      uint64
+	}{
+		{"deej_slider_events_total", "Slider moves handled.", "counter", dm.sliderEvents.Load()},
+		{"deej_set_volume_errors_total", "Volume changes that failed.", "counter", dm.setVolumeErrors.Load()},
+		{"deej_serial_reconnects_total", "Serial connections lost to an error.", "counter", dm.serialReconnects.Load()},
+		{"deej_serial_connected", "Whether the board is connected.", "gauge", boolToMetric(serialConnected)},
+		{"deej_sessions", "Audio sessions currently known.", "gauge", uint64(sessionCount)},
+	}
+
+	for _, metric := range metrics {
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %d\n",
+			metric.name, metric.help, metric.name, metric.metricType, metric.name, metric.value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func boolToMetric(value bool) uint64 {
+	if value {
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,255 @@
+package deej
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"go.uber.org/zap"
+)
+
+// mprisController sets the volume of media players through their MPRIS interface on the D-Bus session bus.
+// players come and go, so their names are tracked through NameOwnerChanged rather than listed on every slider move
+type mprisController struct {
+	logger *zap.SugaredLogger
+	lock   sync.Mutex
+
+	conn *dbus.Conn
+
+	// bus names of running players, i.e. "org.mpris.MediaPlayer2.spotify"
+	players map[string]bool
+
+	// don't retry connecting to the session bus on every slider move
+	lastConnectAttempt time.Time
+
+	// the active player is looked up at most this often
+	activePlayer       string
+	activePlayerLookup time.Time
+}
+
+const (
+	mprisBusNamePrefix   = "org.mpris.MediaPlayer2."
+	mprisObjectPath      = "/org/mpris/MediaPlayer2"
+	mprisPlayerInterface = "org.mpris.MediaPlayer2.Player"
+
+	mprisReconnectDelay   = 5 * time.Second
+	mprisActiveCacheTime  = 350 * time.Millisecond
+	mprisPlaybackPlaying  = "Playing"
+	mprisSignalBufferSize = 16
+)
+
+var errNoMPRISPlayer = errors.New("no MPRIS player found")
+
+func newMPRISController(logger *zap.SugaredLogger) *mprisController {
+	return &mprisController{
+		logger:  logger.Named("mpris"),
+		players: make(map[string]bool),
+	}
+}
+
+// SetVolume sets the volume of every player whose name starts with the given one, or of the active player if it's empty
+func (c *mprisController) SetVolume(player string, volume float32) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if err := c.ensureConnected(); err != nil {
+		return err
+	}
+
+	var busNames []string
+	if player == "" {
+		busName, err := c.findActivePlayer()
+		if err != nil {
+			return err
+		}
+
+		busNames = []string{busName}
+	} else {
+		busNames = c.matchPlayers(strings.ToLower(player))
+	}
+
+	if len(busNames) == 0 {
+		return errNoMPRISPlayer
+	}
+
+	for _, busName := range busNames {
+		object := c.conn.Object(busName, mprisObjectPath)
+
+		if err := object.SetProperty(mprisPlayerInterface+".Volume", dbus.MakeVariant(float64(volume))); err != nil {
+			c.logger.Debugw("Failed to set MPRIS player volume", "player", busName, "error", err)
+			c.dropPlayerOnError(busName, err)
+
+			continue
+		}
+	}
+
+	return nil
+}
+
+func (c *mprisController) Close() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.conn != nil {
+		c.conn.Close()
+		c.conn = nil
+	}
+}
+
+// ensureConnected (re)connects to the session bus if needed, and starts tracking players. must be called with c.lock held
+func (c *mprisController) ensureConnected() error {
+	if c.conn != nil && c.conn.Connected() {
+		return nil
+	}
+
+	if time.Since(c.lastConnectAttempt) < mprisReconnectDelay {
+		return errors.New("not connected to D-Bus session bus")
+	}
+
+	c.lastConnectAttempt = time.Now()
+	c.conn = nil
+	c.players = make(map[string]bool)
+	c.activePlayer = ""
+
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return fmt.Errorf("connect to D-Bus session bus: %w", err)
+	}
+
+	if err := conn.AddMatchSignal(
+		dbus.WithMatchInterface("org.freedesktop.DBus"),
+		dbus.WithMatchMember("NameOwnerChanged"),
+		dbus.WithMatchArg0Namespace(strings.TrimSuffix(mprisBusNamePrefix, ".")),
+	); err != nil {
+		conn.Close()
+		return fmt.Errorf("watch MPRIS players: %w", err)
+	}
+
+	signals := make(chan *dbus.Signal, mprisSignalBufferSize)
+	conn.Signal(signals)
+
+	var names []string
+	if err := conn.BusObject().Call("org.freedesktop.DBus.ListNames", 0).Store(&names); err != nil {
+		conn.Close()
+		return fmt.Errorf("list bus names: %w", err)
+	}
+
+	for _, name := range names {
+		if strings.HasPrefix(name, mprisBusNamePrefix) {
+			c.players[name] = true
+		}
+	}
+
+	c.conn = conn
+	go c.watchPlayers(conn, signals)
+
+	c.logger.Debugw("Connected to D-Bus session bus", "players", len(c.players))
+
+	return nil
+}
+
+// watchPlayers keeps the player list up to date until the connection is closed
+func (c *mprisController) watchPlayers(conn *dbus.Conn, signals chan *dbus.Signal) {
+	defer conn.RemoveSignal(signals)
+
+	for {
+		select {
+		case <-conn.Context().Done():
+			c.logger.Debug("D-Bus session bus connection closed")
+			return
+
+		case signal := <-signals:
+			var name, oldOwner, newOwner string
+			if err := dbus.Store(signal.Body, &name, &oldOwner, &newOwner); err != nil {
+				continue
+			}
+
+			if !strings.HasPrefix(name, mprisBusNamePrefix) {
+				continue
+			}
+
+			c.lock.Lock()
+			if c.conn == conn {
+				if newOwner == "" {
+					c.logger.Debugw("MPRIS player disappeared", "player", name)
+					delete(c.players, name)
+				} else {
+					c.logger.Debugw("MPRIS player appeared", "player", name)
+					c.players[name] = true
+				}
+
+				c.activePlayer = ""
+			}
+			c.lock.Unlock()
+		}
+	}
+}
+
+// matchPlayers returns the players whose name (without the MPRIS prefix) starts with the given lowercase one.
+// players with more than one instance add a suffix, i.e. "vlc.instance1234". must be called with c.lock held
+func (c *mprisController) matchPlayers(player string) []string {
+	result := []string{}
+
+	for busName := range c.players {
+		if strings.HasPrefix(strings.ToLower(strings.TrimPrefix(busName, mprisBusNamePrefix)), player) {
+			result = append(result, busName)
+		}
+	}
+
+	return result
+}
+
+// findActivePlayer returns the first playing player, falling back to the first one there is.
+// must be called with c.lock held
+func (c *mprisController) findActivePlayer() (string, error) {
+	if c.activePlayer != "" && time.Since(c.activePlayerLookup) < mprisActiveCacheTime {
+		return c.activePlayer, nil
+	}
+
+	busNames := make([]string, 0, len(c.players))
+	for busName := range c.players {
+		busNames = append(busNames, busName)
+	}
+
+	if len(busNames) == 0 {
+		return "", errNoMPRISPlayer
+	}
+
+	sort.Strings(busNames)
+
+	active := busNames[0]
+	for _, busName := range busNames {
+		status, err := c.conn.Object(busName, mprisObjectPath).GetProperty(mprisPlayerInterface + ".PlaybackStatus")
+		if err != nil {
+			c.dropPlayerOnError(busName, err)
+			continue
+		}
+
+		if value, ok := status.Value().(string); ok && value == mprisPlaybackPlaying {
+			active = busName
+			break
+		}
+	}
+
+	c.activePlayer = active
+	c.activePlayerLookup = time.Now()
+
+	return active, nil
+}
+
+// dropPlayerOnError forgets a player whose bus name went away without us hearing about it.
+// must be called with c.lock held
+func (c *mprisController) dropPlayerOnError(busName string, err error) {
+	var dbusErr dbus.Error
+	if errors.As(err, &dbusErr) && dbusErr.Name == "org.freedesktop.DBus.Error.ServiceUnknown" {
+		delete(c.players, busName)
+
+		if c.activePlayer == busName {
+			c.activePlayer = ""
+		}
+	}
+}
@@ -0,0 +1,22 @@
+//go:build !linux
+
+package deej
+
+import (
+	"errors"
+
+	"go.uber.org/zap"
+)
+
+// mprisController does nothing outside of Linux, MPRIS is a D-Bus interface
+type mprisController struct{}
+
+func newMPRISController(_ *zap.SugaredLogger) *mprisController {
+	return &mprisController{}
+}
+
+func (c *mprisController) SetVolume(_ string, _ float32) error {
+	return errors.New("MPRIS is only available on Linux")
+}
+
+func (c *mprisController) Close() {}
@@ -0,0 +1,640 @@
+package deej
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// MQTTClient publishes slider positions to an MQTT broker for home automation, i.e. Home Assistant,
+// and takes remote slider positions from it. deej only needs a tiny part of MQTT 3.1.1 (QoS 0
+// publishing and subscribing), so it speaks the protocol itself rather than pulling in a library
+type MQTTClient struct {
+	deej   *Deej
+	logger *zap.SugaredLogger
+
+	// guards conn
+	lock sync.Mutex
+	conn net.Conn
+
+	writeLock sync.Mutex
+
+	// identifies deej to the broker for as long as it runs
+	clientID string
+
+	stopChannel chan struct{}
+	errChannel  chan error
+	wg          sync.WaitGroup
+
+	// config values at time of connection
+	brokerConfig      string
+	topicPrefixConfig string
+	usernameConfig    string
+	passwordConfig    string
+}
+
+const (
+	mqttRetryDelay = 5 * time.Second
+
+	mqttConnectTimeout = 5 * time.Second
+
+	// the broker drops deej if it hears nothing for this long, so it pings halfway through
+	mqttKeepAlive    = 30 * time.Second
+	mqttPingInterval = mqttKeepAlive / 2
+
+	// MQTT's unencrypted default port
+	mqttDefaultPort = "1883"
+
+	mqttPacketConnect     = 0x10
+	mqttPacketConnAck     = 0x20
+	mqttPacketPublish     = 0x30
+	mqttPacketSubscribe   = 0x82
+	mqttPacketSubAck      = 0x90
+	mqttPacketPingReq     = 0xC0
+	mqttPacketDisconnect  = 0xE0
+	mqttPublishRetainFlag = 0x01
+
+	mqttConnectCleanSession = 0x02
+	mqttConnectPassword     = 0x40
+	mqttConnectUsername     = 0x80
+
+	// messages are slider positions, anything bigger than this means we're out of sync
+	mqttMaxPacketSize = 64 * 1024
+
+	// topics are <prefix>/slider/<id>, and <prefix>/slider/<id>/set to move a slider remotely
+	mqttSliderTopic    = "slider"
+	mqttSetTopicSuffix = "set"
+)
+
+var errMQTTConnectionRefused = errors.New("mqtt broker refused the connection")
+
+func NewMQTTClient(deej *Deej, logger *zap.SugaredLogger) *MQTTClient {
+	logger = logger.Named("mqtt")
+
+	c := &MQTTClient{
+		deej:       deej,
+		logger:     logger,
+		clientID:   "deej-" + strings.ReplaceAll(uuid.NewString(), "-", "")[:8],
+		errChannel: make(chan error, 1),
+	}
+
+	logger.Debug("Created MQTT client instance")
+
+	c.setupOnConfigReload()
+
+	return c
+}
+
+func (c *MQTTClient) Start() {
+	c.stopChannel = make(chan struct{})
+	c.logger.Info("MQTT client starting")
+
+	go c.managerLoop()
+	go c.publishSliderMoves()
+}
+
+func (c *MQTTClient) Stop() {
+	if c.stopChannel == nil {
+		return
+	}
+
+	close(c.stopChannel)
+	c.wg.Wait()
+
+	c.logger.Info("MQTT client stopped")
+}
+
+func (c *MQTTClient) IsConnected() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.conn != nil
+}
+
+func (c *MQTTClient) signalError(err error) {
+	select {
+	case c.errChannel <- err:
+	default:
+		// channel full, error already pending
+	}
+}
+
+// connectionFailed signals an error with the given connection, unless deej has already moved on from it
+func (c *MQTTClient) connectionFailed(conn net.Conn, err error) {
+	c.lock.Lock()
+	current := c.conn == conn
+	c.lock.Unlock()
+
+	if current {
+		c.signalError(err)
+	}
+}
+
+// connect opens a session with the broker, subscribes to remote slider moves and publishes every slider's position
+func (c *MQTTClient) connect() error {
+	if c.IsConnected() {
+		return fmt.Errorf("already connected")
+	}
+
+	cfg := c.deej.config.MQTTConfig
+
+	c.logger.Debugw("Attempting MQTT connection", "broker", cfg.Broker)
+
+	conn, err := net.DialTimeout("tcp", cfg.Broker, mqttConnectTimeout)
+	if err != nil {
+		return fmt.Errorf("connect to MQTT broker: %w", err)
+	}
+
+	// a broker that accepts the connection but never answers shouldn't leave deej waiting forever
+	if err := conn.SetDeadline(time.Now().Add(mqttConnectTimeout)); err != nil {
+		conn.Close()
+		return fmt.Errorf("set MQTT connect deadline: %w", err)
+	}
+
+	reader := bufio.NewReader(conn)
+
+	if err := c.handshake(conn, reader, cfg.Username, cfg.Password); err != nil {
+		conn.Close()
+		return err
+	}
+
+	setTopic := fmt.Sprintf("%s/%s/+/%s", cfg.TopicPrefix, mqttSliderTopic, mqttSetTopicSuffix)
+
+	if err := c.subscribe(conn, reader, setTopic); err != nil {
+		conn.Close()
+		return fmt.Errorf("subscribe to %s: %w", setTopic, err)
+	}
+
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		conn.Close()
+		return fmt.Errorf("clear MQTT connect deadline: %w", err)
+	}
+
+	c.lock.Lock()
+	c.conn = conn
+	c.brokerConfig = cfg.Broker
+	c.topicPrefixConfig = cfg.TopicPrefix
+	c.usernameConfig = cfg.Username
+	c.passwordConfig = cfg.Password
+	c.lock.Unlock()
+
+	c.wg.Add(2)
+	go c.readLoop(conn, reader, cfg.TopicPrefix)
+	go c.pingLoop(conn)
+
+	c.logger.Infow("Connected to MQTT broker", "broker", cfg.Broker)
+
+	// retained positions might be stale from before deej was restarted
+	for sliderID, percent := range c.deej.serial.SliderValues() {
+		c.publishSliderPosition(SliderMoveEvent{SliderID: sliderID, PercentValue: percent})
+	}
+
+	return nil
+}
+
+// handshake sends CONNECT and waits for the broker to accept it
+func (c *MQTTClient) handshake(conn net.Conn, reader *bufio.Reader, username string, password string) error {
+	var body bytes.Buffer
+
+	writeMQTTString(&body, "MQTT")
+	body.WriteByte(4) // protocol level 3.1.1
+
+	flags := byte(mqttConnectCleanSession)
+	if username != "" {
+		flags |= mqttConnectUsername
+	}
+
+	// MQTT 3.1.1 only allows a password together with a user name
+	if username != "" && password != "" {
+		flags |= mqttConnectPassword
+	}
+
+	body.WriteByte(flags)
+	_ = binary.Write(&body, binary.BigEndian, uint16(mqttKeepAlive/time.Second))
+
+	writeMQTTString(&body, c.clientID)
+	if flags&mqttConnectUsername != 0 {
+		writeMQTTString(&body, username)
+	}
+	if flags&mqttConnectPassword != 0 {
+		writeMQTTString(&body, password)
+	}
+
+	if err := c.writePacket(conn, mqttPacketConnect, body.Bytes()); err != nil {
+		return fmt.Errorf("send MQTT connect: %w", err)
+	}
+
+	packetType, payload, err := readMQTTPacket(reader)
+	if err != nil {
+		return fmt.Errorf("read MQTT connect response: %w", err)
+	}
+
+	if packetType != mqttPacketConnAck || len(payload) != 2 {
+		return fmt.Errorf("unexpected MQTT packet %#x while connecting", packetType)
+	}
+
+	// 4 and 5 are bad credentials and not authorized, the rest mean the broker won't take deej at all
+	if payload[1] != 0 {
+		return fmt.Errorf("%w: return code %d", errMQTTConnectionRefused, payload[1])
+	}
+
+	return nil
+}
+
+// subscribe asks for messages on a topic filter at QoS 0, and waits for the broker to confirm
+func (c *MQTTClient) subscribe(conn net.Conn, reader *bufio.Reader, topicFilter string) error {
+	var body bytes.Buffer
+
+	_ = binary.Write(&body, binary.BigEndian, uint16(1)) // packet ID, deej only ever has one subscription
+	writeMQTTString(&body, topicFilter)
+	body.WriteByte(0) // QoS 0
+
+	if err := c.writePacket(conn, mqttPacketSubscribe, body.Bytes()); err != nil {
+		return err
+	}
+
+	// brokers may send retained messages for the new subscription before confirming it
+	packetType, payload, err := readMQTTPacket(reader)
+	for err == nil && packetType&0xF0 == mqttPacketPublish {
+		packetType, payload, err = readMQTTPacket(reader)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if packetType != mqttPacketSubAck || len(payload) != 3 {
+		return fmt.Errorf("unexpected MQTT packet %#x while subscribing", packetType)
+	}
+
+	if payload[2] == 0x80 {
+		return errors.New("broker refused the subscription")
+	}
+
+	return nil
+}
+
+func (c *MQTTClient) disconnect() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.conn == nil {
+		return
+	}
+
+	// closing the connection ends the read and ping loops
+	_ = c.writePacket(c.conn, mqttPacketDisconnect, nil)
+	_ = c.conn.Close()
+	c.conn = nil
+
+	c.logger.Info("Disconnected from MQTT broker")
+}
+
+// readLoop handles everything the broker sends, until the connection closes
+func (c *MQTTClient) readLoop(conn net.Conn, reader *bufio.Reader, topicPrefix string) {
+	defer c.wg.Done()
+
+	for {
+		// the broker answers every ping, so hearing nothing for a whole keep-alive means it's gone
+		if err := conn.SetReadDeadline(time.Now().Add(mqttKeepAlive)); err != nil {
+			c.connectionFailed(conn, err)
+			return
+		}
+
+		packetType, payload, err := readMQTTPacket(reader)
+		if err != nil {
+			c.connectionFailed(conn, fmt.Errorf("read from MQTT broker: %w", err))
+			return
+		}
+
+		if packetType&0xF0 == mqttPacketPublish {
+			c.handlePublish(packetType, payload, topicPrefix)
+		}
+	}
+}
+
+// pingLoop keeps the connection alive while there are no slider moves to publish
+func (c *MQTTClient) pingLoop(conn net.Conn) {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(mqttPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stopChannel:
+			return
+		case <-ticker.C:
+			if err := c.writePacket(conn, mqttPacketPingReq, nil); err != nil {
+				c.connectionFailed(conn, fmt.Errorf("ping MQTT broker: %w", err))
+				return
+			}
+		}
+	}
+}
+
+// handlePublish moves a slider remotely when a position arrives on its set topic, routing it through
+// the session map just like the board's own moves
+func (c *MQTTClient) handlePublish(packetType byte, payload []byte, topicPrefix string) {
+	topic, rest, ok := readMQTTString(payload)
+	if !ok {
+		return
+	}
+
+	// deej only subscribes at QoS 0, but brokers may still include a packet ID in theory
+	if packetType&0x06 != 0 {
+		if len(rest) < 2 {
+			return
+		}
+		rest = rest[2:]
+	}
+
+	// retained set messages were meant for some earlier moment, not for whenever deej happens to connect
+	if packetType&mqttPublishRetainFlag != 0 {
+		c.logger.Debugw("Ignoring retained remote slider move", "topic", topic)
+		return
+	}
+
+	sliderIDString, ok := strings.CutPrefix(topic, topicPrefix+"/"+mqttSliderTopic+"/")
+	if !ok {
+		return
+	}
+
+	sliderIDString, ok = strings.CutSuffix(sliderIDString, "/"+mqttSetTopicSuffix)
+	if !ok {
+		return
+	}
+
+	sliderID, err := strconv.Atoi(sliderIDString)
+	if err != nil || sliderID < 0 {
+		c.logger.Debugw("Ignoring remote slider move for an invalid slider", "topic", topic)
+		return
+	}
+
+	percent, err := strconv.ParseFloat(strings.TrimSpace(string(rest)), 32)
+	if err != nil || percent < 0 || percent > 1 {
+		c.logger.Debugw("Ignoring remote slider move with an invalid position", "topic", topic, "payload", string(rest))
+		return
+	}
+
+	event := SliderMoveEvent{SliderID: sliderID, PercentValue: float32(percent)}
+
+	c.logger.Debugw("Remote slider move", "slider", sliderID, "percent", percent)
+
+	c.deej.sessions.handleSliderMoveEvent(event)
+	c.publishSliderPosition(event)
+}
+
+// publishSliderMoves sends every slider move to the broker while connected
+func (c *MQTTClient) publishSliderMoves() {
+	moveEvents := c.deej.serial.SubscribeToSliderMoveEvents(sliderMoveConsumerMQTT)
+	defer c.deej.serial.UnsubscribeFromSliderMoveEvents(moveEvents)
+
+	for {
+		select {
+		case <-c.stopChannel:
+			return
+		case event := <-moveEvents:
+			c.publishSliderPosition(event)
+		}
+	}
+}
+
+// publishSliderPosition sends a slider's position (0.0 to 1.0) as a retained message, so subscribers
+// that come along later still get it. does nothing while disconnected
+func (c *MQTTClient) publishSliderPosition(event SliderMoveEvent) {
+	c.lock.Lock()
+	conn := c.conn
+	topicPrefix := c.topicPrefixConfig
+	c.lock.Unlock()
+
+	if conn == nil {
+		return
+	}
+
+	var body bytes.Buffer
+
+	writeMQTTString(&body, fmt.Sprintf("%s/%s/%d", topicPrefix, mqttSliderTopic, event.SliderID))
+	body.WriteString(strconv.FormatFloat(float64(event.PercentValue), 'f', 2, 32))
+
+	if err := c.writePacket(conn, mqttPacketPublish|mqttPublishRetainFlag, body.Bytes()); err != nil {
+		c.connectionFailed(conn, fmt.Errorf("publish slider position: %w", err))
+	}
+}
+
+// writePacket sends a single MQTT packet. writes come from slider moves, pings and remote moves at once
+func (c *MQTTClient) writePacket(conn net.Conn, packetType byte, body []byte) error {
+	var packet bytes.Buffer
+
+	packet.WriteByte(packetType)
+
+	// the remaining length is a variable length integer, 7 bits at a time
+	length := len(body)
+	for {
+		digit := byte(length % 128)
+		length /= 128
+
+		if length > 0 {
+			digit |= 0x80
+		}
+
+		packet.WriteByte(digit)
+
+		if length == 0 {
+			break
+		}
+	}
+
+	packet.Write(body)
+
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	// a broker that stopped reading mustn't hold up slider moves
+	if err := conn.SetWriteDeadline(time.Now().Add(mqttConnectTimeout)); err != nil {
+		return err
+	}
+
+	_, err := conn.Write(packet.Bytes())
+
+	return err
+}
+
+// readMQTTPacket reads a single MQTT packet, returning its first byte (type and flags) and what follows the header
+func readMQTTPacket(reader *bufio.Reader) (byte, []byte, error) {
+	packetType, err := reader.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	length := 0
+	for multiplier := 1; ; multiplier *= 128 {
+		digit, err := reader.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		length += int(digit&0x7F) * multiplier
+
+		if digit&0x80 == 0 {
+			break
+		}
+
+		if multiplier > 128*128*128 {
+			return 0, nil, errors.New("malformed MQTT packet length")
+		}
+	}
+
+	if length > mqttMaxPacketSize {
+		return 0, nil, fmt.Errorf("MQTT packet too large (%d bytes)", length)
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		return 0, nil, err
+	}
+
+	return packetType, payload, nil
+}
+
+// writeMQTTString writes a length-prefixed UTF-8 string, the way MQTT encodes topics, client IDs and credentials
+func writeMQTTString(buf *bytes.Buffer, s string) {
+	_ = binary.Write(buf, binary.BigEndian, uint16(len(s)))
+	buf.WriteString(s)
+}
+
+// readMQTTString reads a length-prefixed string, returning it along with whatever comes after it
+func readMQTTString(data []byte) (string, []byte, bool) {
+	if len(data) < 2 {
+		return "", nil, false
+	}
+
+	length := int(binary.BigEndian.Uint16(data))
+	if len(data) < 2+length {
+		return "", nil, false
+	}
+
+	return string(data[2 : 2+length]), data[2+length:], true
+}
+
+func (c *MQTTClient) managerLoop() {
+	c.wg.Add(1)
+	defer c.wg.Done()
+
+	for {
+		// check if MQTT is enabled
+		if !c.deej.config.MQTTConfig.Enabled {
+			select {
+			case <-c.stopChannel:
+				c.logger.Debug("managerLoop: stop signal")
+				return
+			case <-time.After(mqttRetryDelay):
+				continue
+			}
+		}
+
+		// attempt connection in goroutine so we can respond to stop signal
+		connectResult := make(chan error, 1)
+		go func() {
+			connectResult <- c.connect()
+		}()
+
+		// wait for connection result or stop signal
+		select {
+		case <-c.stopChannel:
+			c.logger.Debug("managerLoop: stop signal during connect")
+			// wait for connect to finish, then disconnect if it succeeded
+			if err := <-connectResult; err == nil {
+				c.disconnect()
+			}
+			return
+
+		case err := <-connectResult:
+			if err != nil {
+				if errors.Is(err, errMQTTConnectionRefused) {
+					c.logger.Warnw("MQTT broker refused the connection, check the credentials", "error", err)
+				} else {
+					c.logger.Debugw("MQTT connection error, retrying...", "error", err)
+				}
+
+				select {
+				case <-c.stopChannel:
+					c.logger.Debug("managerLoop: stop signal")
+					return
+				case <-time.After(mqttRetryDelay):
+					continue
+				}
+			}
+		}
+
+		// re-check if MQTT was disabled while connecting
+		if !c.deej.config.MQTTConfig.Enabled {
+			c.logger.Debug("MQTT disabled while connecting, disconnecting")
+			c.disconnect()
+			continue
+		}
+
+		// drain any stale errors from previous connection
+		select {
+		case <-c.errChannel:
+		default:
+		}
+
+		select {
+		case <-c.stopChannel:
+			c.logger.Debug("managerLoop: stop signal")
+			c.disconnect()
+			return
+
+		case err := <-c.errChannel:
+			c.logger.Warnw("MQTT connection error, reconnecting...", "error", err)
+			c.disconnect()
+			time.Sleep(mqttRetryDelay)
+			continue
+		}
+	}
+}
+
+func (c *MQTTClient) setupOnConfigReload() {
+	configReloadedChannel := c.deej.config.SubscribeToChanges()
+
+	go func() {
+		for {
+			<-configReloadedChannel
+
+			if c.needsReconnect() {
+				c.logger.Debug("MQTT config changed, triggering reconnect")
+				c.signalError(errors.New("config changed"))
+			}
+		}
+	}()
+}
+
+// needsReconnect tells whether the reloaded config no longer matches the current connection
+func (c *MQTTClient) needsReconnect() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.conn == nil {
+		return false
+	}
+
+	cfg := c.deej.config.MQTTConfig
+
+	return !cfg.Enabled ||
+		cfg.Broker != c.brokerConfig ||
+		cfg.TopicPrefix != c.topicPrefixConfig ||
+		cfg.Username != c.usernameConfig ||
+		cfg.Password != c.passwordConfig
+}
@@ -0,0 +1,69 @@
+package deej
+
+import (
+	"bufio"
+	"net"
+	"slices"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestMQTTHandshakeCredentialFlags(t *testing.T) {
+	cases := []struct {
+		name     string
+		username string
+		password string
+		flags    byte
+	}{
+		{"no credentials", "", "", mqttConnectCleanSession},
+		{"username only", "deej", "", mqttConnectCleanSession | mqttConnectUsername},
+		{"username and password", "deej", "secret", mqttConnectCleanSession | mqttConnectUsername | mqttConnectPassword},
+		{"password only", "", "secret", mqttConnectCleanSession},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, broker := net.Pipe()
+			defer client.Close()
+			defer broker.Close()
+
+			c := &MQTTClient{logger: zap.NewNop().Sugar(), clientID: "deej-test"}
+
+			connect := make(chan []byte, 1)
+			go func() {
+				_, payload, err := readMQTTPacket(bufio.NewReader(broker))
+				if err != nil {
+					close(connect)
+					return
+				}
+
+				connect <- payload
+				_, _ = broker.Write([]byte{mqttPacketConnAck, 2, 0, 0})
+			}()
+
+			if err := c.handshake(client, bufio.NewReader(client), tc.username, tc.password); err != nil {
+				t.Fatalf("handshake() failed: %v", err)
+			}
+
+			payload := <-connect
+
+			// protocol name "MQTT" with its length, then the protocol level, then the flags
+			if flags := payload[7]; flags != tc.flags {
+				t.Errorf("connect flags = %#x, expected %#x", flags, tc.flags)
+			}
+		})
+	}
+}
+
+func TestMQTTPasswordWithoutUsernameIsConfigProblem(t *testing.T) {
+	d := newTestDeej(t, "mqtt:\n  password: secret\n")
+
+	if d.config.MQTTConfig.Password != "" {
+		t.Errorf("Password = %q, expected it to be dropped without a username", d.config.MQTTConfig.Password)
+	}
+
+	if !slices.Contains(d.config.configProblems, configKeyMQTTPassword) {
+		t.Errorf("configProblems = %v, expected %s", d.config.configProblems, configKeyMQTTPassword)
+	}
+}
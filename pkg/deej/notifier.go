@@ -0,0 +1,64 @@
+package deej
+
+import (
+	"sync/atomic"
+
+	"go.uber.org/zap"
+
+	"github.com/nik9play/deej/pkg/notify"
+)
+
+// notificationSeverity tells the notifier how important a notification is, so the configured level can filter it
+type notificationSeverity int
+
+const (
+
+	// things going as expected, i.e. connecting to the board or reloading the config
+	notificationInfo notificationSeverity = iota
+
+	// things the user probably needs to do something about, i.e. an invalid config
+	notificationError
+)
+
+// the notification levels users can choose from
+const (
+	notificationLevelAll    = "all"
+	notificationLevelErrors = "errors"
+	notificationLevelNone   = "none"
+)
+
+// filteredNotifier wraps the toast notifier, dropping whatever the configured notification level doesn't want shown
+type filteredNotifier struct {
+	notifier notify.Notifier
+	logger   *zap.SugaredLogger
+
+	// one of the notification levels. notifications sent before the config is loaded all get through
+	level atomic.Value
+}
+
+func newFilteredNotifier(notifier notify.Notifier, logger *zap.SugaredLogger) *filteredNotifier {
+	fn := &filteredNotifier{
+		notifier: notifier,
+		logger:   logger.Named("notifier"),
+	}
+
+	fn.level.Store(notificationLevelAll)
+
+	return fn
+}
+
+func (fn *filteredNotifier) setLevel(level string) {
+	fn.level.Store(level)
+}
+
+// Notify shows a notification, unless the configured level is set to leave out ones of this severity
+func (fn *filteredNotifier) Notify(severity notificationSeverity, title string, message string) {
+	level := fn.level.Load().(string)
+
+	if level == notificationLevelNone || (level == notificationLevelErrors && severity < notificationError) {
+		fn.logger.Debugw("Not showing notification due to the notification level", "level", level, "title", title)
+		return
+	}
+
+	fn.notifier.Notify(title, message)
+}
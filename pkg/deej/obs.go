@@ -3,11 +3,18 @@ package deej
 import (
 	"errors"
 	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/andreykaipov/goobs"
+	"github.com/andreykaipov/goobs/api/requests/filters"
 	"github.com/andreykaipov/goobs/api/requests/inputs"
+	"github.com/andreykaipov/goobs/api/requests/scenes"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
 	"go.uber.org/zap"
 )
 
@@ -26,12 +33,57 @@ type OBSClient struct {
 	hostConfig     string
 	portConfig     int
 	passwordConfig string
+
+	// the last invalid address the user was notified about, so they're only told once
+	notifiedAddressError string
+}
+
+// OBSVolumeRange maps a slider's 0-100% onto a range of OBS input volumes,
+// given either as volume multipliers or as decibels
+type OBSVolumeRange struct {
+	Min      float64
+	Max      float64
+	Decibels bool
 }
 
 const (
 	obsRetryDelay = 5 * time.Second
+
+	// the bounds OBS accepts for input volumes
+	obsMinVolumeMul = 0.0
+	obsMaxVolumeMul = 20.0
+	obsMinVolumeDb  = -100.0
+	obsMaxVolumeDb  = 26.0
 )
 
+// OBSSceneRange switches OBS to a scene while its slider is positioned between Min and Max (0.0 to 1.0)
+type OBSSceneRange struct {
+	Scene string
+	Min   float32
+	Max   float32
+}
+
+// without a configured range, sliders map to 0.0-1.0 (silence to unity gain)
+var defaultOBSVolumeRange = OBSVolumeRange{Min: 0, Max: 1}
+
+// multiplier converts a slider value between 0.0 and 1.0 into an OBS volume multiplier within this range
+func (r OBSVolumeRange) multiplier(volume float32) float64 {
+	value := r.Min + float64(volume)*(r.Max-r.Min)
+
+	if r.Decibels {
+		value = math.Max(obsMinVolumeDb, math.Min(obsMaxVolumeDb, value))
+
+		// the bottom of the dB scale is treated as silence, rather than as a barely audible signal
+		if volume == 0 || value <= obsMinVolumeDb {
+			return obsMinVolumeMul
+		}
+
+		value = math.Pow(10, value/20)
+	}
+
+	return math.Max(obsMinVolumeMul, math.Min(obsMaxVolumeMul, value))
+}
+
 func NewOBSClient(deej *Deej, logger *zap.SugaredLogger) *OBSClient {
 	logger = logger.Named("obs")
 
@@ -81,7 +133,12 @@ func (o *OBSClient) SetInputVolume(inputName string, volume float32) error {
 		return fmt.Errorf("not connected to OBS")
 	}
 
-	vol := float64(volume)
+	volumeRange, ok := o.deej.config.OBSConfig.VolumeRanges[strings.ToLower(inputName)]
+	if !ok {
+		volumeRange = defaultOBSVolumeRange
+	}
+
+	vol := volumeRange.multiplier(volume)
 	_, err := o.client.Inputs.SetInputVolume(&inputs.SetInputVolumeParams{
 		InputName:      &inputName,
 		InputVolumeMul: &vol,
@@ -91,7 +148,98 @@ func (o *OBSClient) SetInputVolume(inputName string, volume float32) error {
 		return err
 	}
 
-	o.logger.Debugw("Set OBS input volume", "input", inputName, "volume", volume)
+	o.logger.Debugw("Set OBS input volume", "input", inputName, "volume", volume, "multiplier", vol)
+
+	return nil
+}
+
+// SetInputMute mutes or unmutes an OBS input, leaving its volume as it is
+func (o *OBSClient) SetInputMute(inputName string, muted bool) error {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	if o.client == nil {
+		return fmt.Errorf("not connected to OBS")
+	}
+
+	_, err := o.client.Inputs.SetInputMute(&inputs.SetInputMuteParams{
+		InputName:  &inputName,
+		InputMuted: &muted,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	o.logger.Debugw("Set OBS input mute state", "input", inputName, "muted", muted)
+
+	return nil
+}
+
+// GetInputMute returns whether an OBS input is muted
+func (o *OBSClient) GetInputMute(inputName string) (bool, error) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	if o.client == nil {
+		return false, fmt.Errorf("not connected to OBS")
+	}
+
+	resp, err := o.client.Inputs.GetInputMute(&inputs.GetInputMuteParams{
+		InputName: &inputName,
+	})
+
+	if err != nil {
+		return false, err
+	}
+
+	return resp.InputMuted, nil
+}
+
+// SetSourceFilterSetting changes a single setting of a filter on an OBS source, leaving its other settings as they are
+func (o *OBSClient) SetSourceFilterSetting(sourceName string, filterName string, setting string, value float64) error {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	if o.client == nil {
+		return fmt.Errorf("not connected to OBS")
+	}
+
+	overlay := true
+	_, err := o.client.Filters.SetSourceFilterSettings(&filters.SetSourceFilterSettingsParams{
+		SourceName:     &sourceName,
+		FilterName:     &filterName,
+		FilterSettings: map[string]any{setting: value},
+		Overlay:        &overlay,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	o.logger.Debugw("Set OBS filter setting", "source", sourceName, "filter", filterName, "setting", setting, "value", value)
+
+	return nil
+}
+
+// SetCurrentScene switches OBS's program output to the named scene
+func (o *OBSClient) SetCurrentScene(sceneName string) error {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	if o.client == nil {
+		return fmt.Errorf("not connected to OBS")
+	}
+
+	_, err := o.client.Scenes.SetCurrentProgramScene(&scenes.SetCurrentProgramSceneParams{
+		SceneName: &sceneName,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	o.logger.Debugw("Switched OBS scene", "scene", sceneName)
 
 	return nil
 }
@@ -132,7 +280,7 @@ func (o *OBSClient) connect() error {
 	}
 
 	cfg := o.deej.config.OBSConfig
-	address := fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
+	address := net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
 
 	o.logger.Debugw("Attempting OBS connection", "address", address)
 
@@ -181,8 +329,8 @@ func (o *OBSClient) managerLoop() {
 	)
 
 	for {
-		// check if OBS is enabled
-		if !o.deej.config.OBSConfig.Enabled {
+		// check if OBS is enabled, and that there's any point in trying to connect
+		if !o.deej.config.OBSConfig.Enabled || !o.checkAddress() {
 			select {
 			case <-o.stopChannel:
 				o.logger.Debug("managerLoop: stop signal")
@@ -253,6 +401,38 @@ func (o *OBSClient) managerLoop() {
 	}
 }
 
+// checkAddress reports whether the configured OBS address is usable, letting the user know once if it isn't
+func (o *OBSClient) checkAddress() bool {
+	addressError := o.deej.config.OBSConfig.AddressError
+	if addressError == nil {
+		o.notifiedAddressError = ""
+		return true
+	}
+
+	if addressError.Error() == o.notifiedAddressError {
+		return false
+	}
+
+	o.notifiedAddressError = addressError.Error()
+	o.logger.Warnw("Not connecting to OBS, address is invalid", "error", addressError)
+
+	title := o.deej.localizer.MustLocalize(&i18n.LocalizeConfig{
+		DefaultMessage: &i18n.Message{
+			ID:    "OBSInvalidAddressTitle",
+			Other: "Invalid OBS address",
+		},
+	})
+	description := o.deej.localizer.MustLocalize(&i18n.LocalizeConfig{
+		DefaultMessage: &i18n.Message{
+			ID:    "OBSInvalidAddressDescription",
+			Other: "Please check obs.host and obs.port in the config. Host should be a name or IP only, e.g. localhost.",
+		},
+	})
+	o.deej.notifier.Notify(notificationError, title, description)
+
+	return false
+}
+
 func (o *OBSClient) eventLoop() {
 	o.wg.Add(1)
 	defer o.wg.Done()
@@ -286,21 +466,30 @@ func (o *OBSClient) setupOnConfigReload() {
 		for {
 			<-configReloadedChannel
 
-			// only trigger reconnect if currently connected
-			if !o.IsConnected() {
-				continue
-			}
-
-			cfg := o.deej.config.OBSConfig
-
-			if cfg.Host != o.hostConfig ||
-				cfg.Port != o.portConfig ||
-				cfg.Password != o.passwordConfig ||
-				!cfg.Enabled {
-
+			if o.needsReconnect() {
 				o.logger.Debug("OBS config changed, triggering reconnect")
 				o.signalError(errors.New("config changed"))
 			}
 		}
 	}()
 }
+
+// needsReconnect tells whether the reloaded config no longer matches the current connection, either
+// because it points somewhere else now or because OBS was turned off. edits to anything else, like
+// volume ranges, apply without dropping the connection
+func (o *OBSClient) needsReconnect() bool {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	// nothing to reconnect while disconnected, the manager loop picks up the new config on its next attempt
+	if o.client == nil {
+		return false
+	}
+
+	cfg := o.deej.config.OBSConfig
+
+	return !cfg.Enabled ||
+		cfg.Host != o.hostConfig ||
+		cfg.Port != o.portConfig ||
+		cfg.Password != o.passwordConfig
+}
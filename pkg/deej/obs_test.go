@@ -0,0 +1,61 @@
+package deej
+
+import (
+	"testing"
+
+	"github.com/andreykaipov/goobs"
+)
+
+func TestOBSNeedsReconnect(t *testing.T) {
+	d := newTestDeej(t, `slider_mapping:
+  0: master
+  1: deej.obs:Mic/Aux
+obs:
+  enabled: true
+  host: localhost
+  port: 4455
+  password: secret
+`)
+
+	connected := d.config.OBSConfig
+
+	// stands in for a live connection made with the config above, needsReconnect only looks at whether there is one
+	d.obs.client = &goobs.Client{}
+	d.obs.hostConfig = connected.Host
+	d.obs.portConfig = connected.Port
+	d.obs.passwordConfig = connected.Password
+
+	tests := []struct {
+		name      string
+		edit      func()
+		reconnect bool
+	}{
+		{"unchanged", func() {}, false},
+		{"volume range edited", func() {
+			d.config.OBSConfig.VolumeRanges = map[string]OBSVolumeRange{"mic/aux": {Min: 0, Max: 2}}
+		}, false},
+		{"port changed", func() { d.config.OBSConfig.Port = 4456 }, true},
+		{"password changed", func() { d.config.OBSConfig.Password = "hunter2" }, true},
+		{"disabled", func() { d.config.OBSConfig.Enabled = false }, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			d.config.OBSConfig = connected
+			test.edit()
+
+			if reconnect := d.obs.needsReconnect(); reconnect != test.reconnect {
+				t.Errorf("needsReconnect() = %v, expected %v", reconnect, test.reconnect)
+			}
+		})
+	}
+
+	t.Run("disconnected", func(t *testing.T) {
+		d.obs.client = nil
+		d.config.OBSConfig.Enabled = false
+
+		if d.obs.needsReconnect() {
+			t.Error("needsReconnect() = true without a connection")
+		}
+	})
+}
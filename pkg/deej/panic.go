@@ -41,12 +41,12 @@ func (d *Deej) recoverFromPanic() {
 	// if we got here, we're recovering from a panic!
 	now := time.Now()
 
-	ex, err := os.Executable()
+	dir, err := dataDir()
 	if err != nil {
-		panic(fmt.Errorf("get executable dir: %w", err))
+		panic(fmt.Errorf("get data dir: %w", err))
 	}
 
-	logDirectory := filepath.Join(filepath.Dir(ex), "logs")
+	logDirectory := filepath.Join(dir, "logs")
 
 	// that would suck
 	if err := util.EnsureDirExists(logDirectory); err != nil {
@@ -65,7 +65,7 @@ func (d *Deej) recoverFromPanic() {
 		"crashlogPath", crashlogPath,
 		"error", r)
 
-	d.notifier.Notify("Unexpected crash occurred...",
+	d.notifier.Notify(notificationError, "Unexpected crash occurred...",
 		fmt.Sprintf("More details in %s", crashlogPath))
 
 	// bye :(
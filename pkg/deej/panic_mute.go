@@ -0,0 +1,172 @@
+package deej
+
+import (
+	"sync"
+	"time"
+
+	"github.com/nik9play/deej/pkg/deej/util"
+	"go.uber.org/zap"
+)
+
+// panicMute fades every mapped session out on a global hotkey (for when someone walks in), and brings them back
+// to where they were on the next press. it works off the slider mapping alone, the board doesn't need to be there
+type panicMute struct {
+	deej   *Deej
+	logger *zap.SugaredLogger
+
+	// guards hotkey and unregister
+	lock       sync.Mutex
+	hotkey     string
+	unregister func()
+
+	// guards muted and savedVolumes, and is held for a whole toggle so quick presses don't step on each other
+	toggleLock   sync.Mutex
+	muted        bool
+	savedVolumes map[string]float32
+
+	stopChannel chan struct{}
+	doneChannel chan struct{}
+}
+
+// quick enough to count as instant, slow enough not to pop
+const panicMuteFade = 300 * time.Millisecond
+
+func newPanicMute(deej *Deej, logger *zap.SugaredLogger) *panicMute {
+	return &panicMute{
+		deej:        deej,
+		logger:      logger.Named("panic_mute"),
+		stopChannel: make(chan struct{}),
+		doneChannel: make(chan struct{}),
+	}
+}
+
+// Start registers the hotkey if one is set, and keeps it in line with the config from then on
+func (p *panicMute) Start() {
+	configReloadedChannel := p.deej.config.SubscribeToChanges()
+
+	p.apply()
+
+	go func() {
+		defer close(p.doneChannel)
+		defer p.deej.config.UnsubscribeFromChanges(configReloadedChannel)
+
+		for {
+			select {
+			case <-p.stopChannel:
+				return
+			case <-configReloadedChannel:
+				p.apply()
+			}
+		}
+	}()
+}
+
+// Stop lets go of the hotkey. sessions that are still muted stay that way
+func (p *panicMute) Stop() {
+	close(p.stopChannel)
+	<-p.doneChannel
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.release()
+}
+
+// apply registers, unregisters or swaps the hotkey to match the current config
+func (p *panicMute) apply() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	hotkeyString := p.deej.config.PanicHotkey
+	if hotkeyString == p.hotkey {
+		return
+	}
+
+	p.release()
+
+	if hotkeyString == "" {
+		return
+	}
+
+	// already validated by the config, so this can't fail
+	hotkey, _ := util.ParseHotkey(hotkeyString)
+
+	unregister, err := util.RegisterHotkey(hotkey, p.toggle)
+	if err != nil {
+		p.logger.Warnw("Failed to register panic hotkey", "hotkey", hotkeyString, "error", err)
+		return
+	}
+
+	p.hotkey = hotkeyString
+	p.unregister = unregister
+
+	p.logger.Infow("Registered panic hotkey", "hotkey", hotkeyString)
+}
+
+// release unregisters the hotkey, if there is one. needs lock held
+func (p *panicMute) release() {
+	if p.unregister == nil {
+		return
+	}
+
+	p.unregister()
+
+	p.logger.Debugw("Unregistered panic hotkey", "hotkey", p.hotkey)
+
+	p.hotkey = ""
+	p.unregister = nil
+}
+
+func (p *panicMute) toggle() {
+	p.toggleLock.Lock()
+	defer p.toggleLock.Unlock()
+
+	if p.muted {
+		p.restore()
+	} else {
+		p.mute()
+	}
+
+	p.muted = !p.muted
+}
+
+// mute remembers the volume of every session the sliders control, then fades them all to zero
+func (p *panicMute) mute() {
+	sessions := p.deej.sessions.mappedSessions()
+	p.savedVolumes = make(map[string]float32, len(sessions))
+
+	for _, session := range sessions {
+
+		// a slider ramp that's still going would otherwise undo the fade, and leave us remembering half a move
+		p.deej.sessions.cancelVolumeRamp(session)
+
+		volume := session.GetVolume()
+		p.savedVolumes[session.ID()] = volume
+
+		p.deej.sessions.rampSessionVolume(session, volume, 0, panicMuteFade)
+	}
+
+	p.logger.Infow("Panic mute on", "sessions", len(sessions))
+}
+
+// restore fades every muted session that's still around back to its old volume.
+// sessions that only showed up since are left alone, the sliders will get to them
+func (p *panicMute) restore() {
+	restored := 0
+
+	for _, session := range p.deej.sessions.getSessions() {
+		volume, ok := p.savedVolumes[session.ID()]
+		if !ok {
+			continue
+		}
+
+		p.deej.sessions.cancelVolumeRamp(session)
+		p.deej.sessions.rampSessionVolume(session, session.GetVolume(), volume, panicMuteFade)
+
+		restored++
+	}
+
+	p.logger.Infow("Panic mute off", "sessions", restored)
+
+	p.savedVolumes = nil
+}
@@ -2,12 +2,17 @@ package deej
 
 import (
 	"bufio"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io/fs"
+	"math"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"go.bug.st/serial"
@@ -34,15 +39,87 @@ type SerialIO struct {
 	port        serial.Port
 	mode        serial.Mode
 
+	// set while the connection is being renewed after a config change
+	reconnecting atomic.Bool
+
+	// whether the startup delay was already waited out, so restarts don't wait again. only touched by managerLoop
+	startupDelayDone bool
+
+	// guards lastKnownNumSliders, which is also reset from the config reload goroutine
+	sliderCountLock     sync.Mutex
 	lastKnownNumSliders int
+	// the slider count consumers were last told about, kept apart so that
+	// rediscovering the same sliders after a config reload doesn't notify them again
+	reportedNumSliders  int
 	currentSliderValues []int
 
-	sliderMoveConsumers  []chan SliderMoveEvent
+	// smoothed raw value of every slider, or -1 until its first reading
+	filteredSliderValues []float64
+
+	// the latest position of every slider as sent in move events, also guarded by sliderCountLock
+	currentSliderPercents []float32
+
+	// how many slider positions were restored from the previous run, until the board reports in, and when
+	restoredNumSliders int
+	restoredAt         time.Time
+
+	// last reported state of every button the board has sent, keyed by button ID
+	currentButtonStates map[int]bool
+
+	// with max_slider_rate_hz set, when each slider last had a move event delivered, and the
+	// latest move of sliders that moved again too soon. those go out once their slider's time is up
+	sliderRateLock       sync.Mutex
+	lastSliderMoveSent   map[int]time.Time
+	pendingSliderMoves   map[int]SliderMoveEvent
+	pendingSliderMoveJob *time.Timer
+
+	// consumers can come and go while serial reads are delivering events to them
+	sliderMoveConsumersLock sync.Mutex
+	sliderMoveConsumers     []*sliderMoveConsumer
+
 	stateChangeConsumers []chan bool
+	buttonConsumers      []chan ButtonEvent
+
+	// slider count consumers can come and go too, i.e. HTTP API clients
+	sliderCountConsumersLock sync.Mutex
+	sliderCountConsumers     []chan int
 }
 
+// sliderMoveConsumer is a single subscriber to slider move events. each one gets its own queue and a goroutine
+// handing events over from it, so neither a slow consumer nor one that stopped reading holds up serial reads
+// or the other consumers. the consumer's backpressure policy decides what happens once its queue fills up
+type sliderMoveConsumer struct {
+	name string
+
+	// what the consumer reads from. unbuffered, so everything waiting is in queue where the policy can see it
+	ch chan SliderMoveEvent
+
+	// guards queue and overflowWarned
+	lock           sync.Mutex
+	queue          []SliderMoveEvent
+	overflowWarned bool
+
+	wake chan struct{}
+	stop chan struct{}
+}
+
+const (
+	// connection retries start out this far apart, and wait twice as long after every failure
+	serialRetryMinDelay = 2 * time.Second
+
+	// ...but never longer than this, so a board that's plugged back in is picked up within a minute
+	serialRetryMaxDelay = time.Minute
+
+	// a connection that drops sooner than this is part of the same flapping streak as the one before it
+	serialStableConnection = 30 * time.Second
+
+	// restored positions still go out to sessions that show up this long after the restore
+	restoredValuesReplayWindow = time.Minute
+)
+
 var ErrNoSerialPorts = errors.New("no serial ports found")
 var ErrAutoPortNotFound = errors.New("can't autodetect com port")
+var ErrPortNotFound = errors.New("com port doesn't exist")
 
 // var allowedVIDPIDs = []VIDPID{{0x1A86, 0x7523}}
 
@@ -52,7 +129,57 @@ type SliderMoveEvent struct {
 	PercentValue float32
 }
 
-var expectedLinePattern = regexp.MustCompile(`^\d{1,4}(\|\d{1,4})*\r\n$`)
+// ButtonEvent represents a single button press or release captured by deej
+type ButtonEvent struct {
+	ButtonID int
+	Pressed  bool
+}
+
+// slider values come first, optionally followed by button states, i.e. "500|300|b0:1|b1:0".
+// lines end with CRLF (Serial.println) or a bare LF, depending on the firmware
+var expectedLinePattern = regexp.MustCompile(`^\d{1,4}(\|\d{1,4})*(\|b\d{1,2}:[01])*\r?\n$`)
+
+// the same, for boards that send slider positions already normalized between 0.0 and 1.0, i.e. "0.52|0.31|b0:1"
+var expectedNormalizedLinePattern = regexp.MustCompile(`^\d(\.\d+)?(\|\d(\.\d+)?)*(\|b\d{1,2}:[01])*\r?\n$`)
+
+// with serial_checksum on, lines end with "*" and a two digit hex XOR of everything before it, NMEA-style
+var checksummedLinePattern = regexp.MustCompile(`^(.*)\*([0-9A-Fa-f]{2})\r?\n$`)
+
+const (
+	// how many slider move events each consumer can fall behind by before its backpressure policy kicks in
+	sliderMoveQueueSize = 64
+
+	// consumers with the block policy get every move, up to this many behind. going past it means
+	// the consumer stopped reading altogether, and its queue mustn't grow forever
+	sliderMoveQueueMaxSize = 4096
+
+	// how many button events each consumer can fall behind by before serial reads wait for it
+	buttonEventQueueSize = 16
+
+	// marks a button state within a serial line, i.e. "b0:1"
+	buttonTokenPrefix = "b"
+
+	// line formats boards can use: pipe-delimited raw readings, or json with normalized positions
+	serialProtocolClassic = "classic"
+	serialProtocolJSON    = "json"
+
+	// ranges slider values in classic lines can come in: raw readings, or positions the board normalized itself
+	sliderValueRangeRaw        = "0-1023"
+	sliderValueRangeNormalized = "0.0-1.0"
+
+	// well-known slider move consumer names, used to pick a backpressure policy from the config
+	sliderMoveConsumerSessions = "sessions"
+	sliderMoveConsumerTray     = "tray"
+
+	// raw readings this close to either end skip smoothing, so sliders still reach 0% and 100%
+	sliderSmoothingEdge = 10
+
+	// shared by every client of the HTTP API's live slider stream
+	sliderMoveConsumerWebSocket = "websocket"
+
+	// publishes slider positions to the MQTT broker
+	sliderMoveConsumerMQTT = "mqtt"
+)
 
 // NewSerialIO creates a SerialIO instance that uses the provided deej
 // instance's connection info to establish communications with the arduino chip
@@ -64,8 +191,13 @@ func NewSerialIO(deej *Deej, logger *zap.SugaredLogger) (*SerialIO, error) {
 		logger:               logger,
 		port:                 nil,
 		errChannel:           make(chan error, 1),
-		sliderMoveConsumers:  []chan SliderMoveEvent{},
+		sliderMoveConsumers:  []*sliderMoveConsumer{},
 		stateChangeConsumers: []chan bool{},
+		sliderCountConsumers: []chan int{},
+		buttonConsumers:      []chan ButtonEvent{},
+		currentButtonStates:  make(map[int]bool),
+		lastSliderMoveSent:   make(map[int]time.Time),
+		pendingSliderMoves:   make(map[int]SliderMoveEvent),
 	}
 
 	logger.Debug("Created serial i/o instance")
@@ -142,8 +274,15 @@ func (sio *SerialIO) connect() error {
 	port, err := serial.Open(sio.comPortToUse, &sio.mode)
 
 	if err != nil {
-		// might need a user notification here, TBD
 		sio.logger.Debugw("Failed to open serial connection", "error", err)
+
+		// a port that doesn't exist at all is most likely a typo, unlike one that's busy or unplugged for a moment.
+		// windows says so with a port error, elsewhere the device file is simply missing
+		var portErr *serial.PortError
+		if (errors.As(err, &portErr) && portErr.Code() == serial.PortNotFound) || errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("open serial connection: %w: %w", ErrPortNotFound, err)
+		}
+
 		return fmt.Errorf("open serial connection: %w", err)
 	}
 
@@ -161,39 +300,267 @@ func (sio *SerialIO) connect() error {
 	return nil
 }
 
+// notifyPortNotFound lets the user know the configured port doesn't exist, along with the ones that do
+func (sio *SerialIO) notifyPortNotFound() {
+	availablePorts := availablePortNames()
+
+	sio.logger.Warnw("Configured COM port doesn't exist, set com_port to auto or use one of the available ports",
+		"port", sio.comPortToUse,
+		"availablePorts", availablePorts)
+
+	title := sio.deej.localizer.MustLocalize(&i18n.LocalizeConfig{
+		DefaultMessage: &i18n.Message{
+			ID:    "ComPortNotFoundNotificationTitle",
+			Other: "{{.ComPort}} doesn't exist",
+		},
+		TemplateData: map[string]string{
+			"ComPort": sio.comPortToUse,
+		},
+	})
+
+	var description string
+	if len(availablePorts) == 0 {
+		description = sio.deej.localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{
+				ID:    "ComPortNotFoundNoPortsDescription",
+				Other: "No serial ports were found. Check that the board is plugged in, or set com_port to auto.",
+			},
+		})
+	} else {
+		description = sio.deej.localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{
+				ID:    "ComPortNotFoundDescription",
+				Other: "Set com_port to auto to find the board automatically, or use one of these ports: {{.Ports}}",
+			},
+			TemplateData: map[string]string{
+				"Ports": strings.Join(availablePorts, ", "),
+			},
+		})
+	}
+
+	sio.deej.notifier.Notify(notificationError, title, description)
+}
+
+// availablePortNames lists the serial ports that exist right now, along with the USB IDs of USB ones
+func availablePortNames() []string {
+	ports, err := enumerator.GetDetailedPortsList()
+	if err != nil {
+		return nil
+	}
+
+	names := make([]string, 0, len(ports))
+	for _, port := range ports {
+		if port.IsUSB {
+			names = append(names, fmt.Sprintf("%s (USB %s:%s)", port.Name, port.VID, port.PID))
+		} else {
+			names = append(names, port.Name)
+		}
+	}
+
+	return names
+}
+
 func (sio *SerialIO) GetState() bool {
 	return sio.port != nil
 }
 
+// IsReconnecting returns true while the serial connection is being renewed due to changed connection parameters
+func (sio *SerialIO) IsReconnecting() bool {
+	return sio.reconnecting.Load()
+}
+
 // Start attempts to connect to our arduino chip
 func (sio *SerialIO) Start() {
 	sio.stopChannel = make(chan struct{})
 	sio.logger.Info("Serial starting")
 
+	// a read error from the previous connection's read loop mustn't tear down the next one
+	select {
+	case <-sio.errChannel:
+	default:
+	}
+
+	sio.wg.Add(1)
 	go sio.managerLoop()
 }
 
-// Stop signals us to shut down our serial connection, if one is active
+// Stop signals us to shut down our serial connection, if one is active.
+// it returns once the port is closed and all serial goroutines have finished
 func (sio *SerialIO) Stop() {
 	close(sio.stopChannel)
 
 	// Wait for all goroutines to finish
 	sio.wg.Wait()
 
+	sio.saveState()
+
 	sio.logger.Info("Serial stopped")
 }
 
-// SubscribeToSliderMoveEvents returns an unbuffered channel that receives
-// a sliderMoveEvent struct every time a slider moves
-func (sio *SerialIO) SubscribeToSliderMoveEvents() chan SliderMoveEvent {
-	ch := make(chan SliderMoveEvent)
-	sio.sliderMoveConsumers = append(sio.sliderMoveConsumers, ch)
+// saveState remembers every slider's latest position in the internal config, for RestoreState to pick up on the next run
+func (sio *SerialIO) saveState() {
+	sio.sliderCountLock.Lock()
+	values := slices.Clone(sio.currentSliderPercents)
+	sio.sliderCountLock.Unlock()
 
-	return ch
+	// nothing was read from the board, keep whatever the previous run saved
+	if len(values) == 0 {
+		return
+	}
+
+	if err := sio.deej.config.SetLastSliderValues(values); err != nil {
+		sio.logger.Warnw("Failed to save slider positions", "error", err)
+		return
+	}
+
+	sio.logger.Debugw("Saved slider positions", "values", values)
+}
+
+// RestoreState sends the slider positions saved by the previous run to all consumers, so sessions match the
+// sliders from the start rather than once each one is moved. it does nothing once the board has reported in,
+// as its own values are more current - and those replace the restored ones as soon as it does
+func (sio *SerialIO) RestoreState() {
+	values := sio.deej.config.LastSliderValues()
+	if len(values) == 0 {
+		return
+	}
+
+	sio.sliderCountLock.Lock()
+	if len(sio.currentSliderPercents) > 0 {
+		sio.sliderCountLock.Unlock()
+		return
+	}
+
+	sio.currentSliderPercents = slices.Clone(values)
+	sio.restoredNumSliders = len(values)
+	sio.restoredAt = time.Now()
+	sio.sliderCountLock.Unlock()
+
+	sio.logger.Infow("Restoring slider positions from the previous run", "values", values)
+
+	moveEvents := make([]SliderMoveEvent, len(values))
+	for sliderIdx, percent := range values {
+		moveEvents[sliderIdx] = SliderMoveEvent{SliderID: sliderIdx, PercentValue: percent}
+	}
+
+	sio.sendSliderMoveEvents(sio.logger, moveEvents)
+}
+
+// RestoredValues returns the slider positions RestoreState sent, for sessions that only showed up after it did.
+// session finders enumerate in the background, so that's most of them. ok is false once the board has reported
+// in, or once restoredValuesReplayWindow has passed - past that, new sessions are left to the sliders
+func (sio *SerialIO) RestoredValues() ([]float32, bool) {
+	sio.sliderCountLock.Lock()
+	defer sio.sliderCountLock.Unlock()
+
+	if sio.restoredNumSliders == 0 || time.Since(sio.restoredAt) > restoredValuesReplayWindow {
+		return nil, false
+	}
+
+	return slices.Clone(sio.currentSliderPercents), true
+}
+
+// restart renews the serial connection, starting again only after the previous port has been fully released
+func (sio *SerialIO) restart() {
+	sio.reconnecting.Store(true)
+	sio.sendStateChangeEvent(false)
+
+	sio.Stop()
+
+	sio.reconnecting.Store(false)
+	sio.Start()
 }
 
+// SubscribeToSliderMoveEvents returns a channel that receives a sliderMoveEvent struct every time
+// a slider moves. name identifies the consumer when choosing its backpressure policy
+func (sio *SerialIO) SubscribeToSliderMoveEvents(name string) chan SliderMoveEvent {
+	consumer := &sliderMoveConsumer{
+		name: name,
+		ch:   make(chan SliderMoveEvent),
+		wake: make(chan struct{}, 1),
+		stop: make(chan struct{}),
+	}
+
+	go consumer.deliver()
+
+	sio.sliderMoveConsumersLock.Lock()
+	defer sio.sliderMoveConsumersLock.Unlock()
+
+	sio.sliderMoveConsumers = append(sio.sliderMoveConsumers, consumer)
+
+	return consumer.ch
+}
+
+// UnsubscribeFromSliderMoveEvents stops delivering slider move events to the given channel
+func (sio *SerialIO) UnsubscribeFromSliderMoveEvents(ch chan SliderMoveEvent) {
+	sio.sliderMoveConsumersLock.Lock()
+	defer sio.sliderMoveConsumersLock.Unlock()
+
+	for idx, consumer := range sio.sliderMoveConsumers {
+		if consumer.ch == ch {
+			close(consumer.stop)
+			sio.sliderMoveConsumers = append(sio.sliderMoveConsumers[:idx:idx], sio.sliderMoveConsumers[idx+1:]...)
+			return
+		}
+	}
+}
+
+// sliderMoveConsumer returns the subscriber reading from the given channel, or nil if there's none
+func (sio *SerialIO) sliderMoveConsumer(ch chan SliderMoveEvent) *sliderMoveConsumer {
+	sio.sliderMoveConsumersLock.Lock()
+	defer sio.sliderMoveConsumersLock.Unlock()
+
+	for _, consumer := range sio.sliderMoveConsumers {
+		if consumer.ch == ch {
+			return consumer
+		}
+	}
+
+	return nil
+}
+
+// SliderValues returns the latest position of every slider, between 0.0 and 1.0
+func (sio *SerialIO) SliderValues() []float32 {
+	sio.sliderCountLock.Lock()
+	defer sio.sliderCountLock.Unlock()
+
+	values := make([]float32, len(sio.currentSliderPercents))
+	copy(values, sio.currentSliderPercents)
+
+	return values
+}
+
+// ReplayCurrentValues queues every slider's latest position for the given subscriber channel as move events,
+// so consumers that subscribe late don't have to wait for the next physical move. does nothing until
+// sliders have been detected. the consumer's backpressure policy applies like it does to real moves
+func (sio *SerialIO) ReplayCurrentValues(ch chan SliderMoveEvent) {
+	consumer := sio.sliderMoveConsumer(ch)
+	if consumer == nil {
+		return
+	}
+
+	sio.sliderCountLock.Lock()
+	if sio.lastKnownNumSliders == 0 {
+		sio.sliderCountLock.Unlock()
+		return
+	}
+
+	values := make([]float32, len(sio.currentSliderPercents))
+	copy(values, sio.currentSliderPercents)
+	sio.sliderCountLock.Unlock()
+
+	policy := sio.deej.config.sliderMoveBackpressure(consumer.name)
+
+	for sliderIdx, percent := range values {
+		sio.enqueueSliderMoveEvent(sio.logger, consumer, policy, SliderMoveEvent{SliderID: sliderIdx, PercentValue: percent})
+	}
+}
+
+// SubscribeToStateChangeEvent returns a channel that receives the connection state whenever it changes.
+// like slider counts, only the latest state is kept for consumers that haven't caught up, so a consumer
+// that's already gone (i.e. the tray during shutdown) can't keep the serial connection from closing
 func (sio *SerialIO) SubscribeToStateChangeEvent() chan bool {
-	ch := make(chan bool)
+	ch := make(chan bool, 1)
 	sio.stateChangeConsumers = append(sio.stateChangeConsumers, ch)
 
 	return ch
@@ -201,10 +568,81 @@ func (sio *SerialIO) SubscribeToStateChangeEvent() chan bool {
 
 func (sio *SerialIO) sendStateChangeEvent(state bool) {
 	for _, consumer := range sio.stateChangeConsumers {
-		consumer <- state
+
+		// replace a stale state that hasn't been picked up yet
+		select {
+		case <-consumer:
+		default:
+		}
+
+		// restarts and the manager loop can both report at once, in which case the other state got in first
+		select {
+		case consumer <- state:
+		default:
+		}
+	}
+}
+
+// SliderCount returns the number of sliders reported by the board, or 0 if it hasn't sent any values yet
+func (sio *SerialIO) SliderCount() int {
+	sio.sliderCountLock.Lock()
+	defer sio.sliderCountLock.Unlock()
+
+	return sio.lastKnownNumSliders
+}
+
+// SubscribeToSliderCountChangeEvent returns a channel that receives the new slider count
+// whenever the board starts reporting a different number of sliders. only the latest count
+// is kept for consumers that haven't caught up, so this never holds up serial reads
+func (sio *SerialIO) SubscribeToSliderCountChangeEvent() chan int {
+	ch := make(chan int, 1)
+
+	sio.sliderCountConsumersLock.Lock()
+	sio.sliderCountConsumers = append(sio.sliderCountConsumers, ch)
+	sio.sliderCountConsumersLock.Unlock()
+
+	return ch
+}
+
+// UnsubscribeFromSliderCountChangeEvent stops delivering slider count changes to a channel
+// from SubscribeToSliderCountChangeEvent
+func (sio *SerialIO) UnsubscribeFromSliderCountChangeEvent(ch chan int) {
+	sio.sliderCountConsumersLock.Lock()
+	defer sio.sliderCountConsumersLock.Unlock()
+
+	for idx, consumer := range sio.sliderCountConsumers {
+		if consumer == ch {
+			sio.sliderCountConsumers = append(sio.sliderCountConsumers[:idx:idx], sio.sliderCountConsumers[idx+1:]...)
+			return
+		}
 	}
 }
 
+func (sio *SerialIO) sendSliderCountChangeEvent(count int) {
+	sio.sliderCountConsumersLock.Lock()
+	defer sio.sliderCountConsumersLock.Unlock()
+
+	for _, consumer := range sio.sliderCountConsumers {
+
+		// replace a stale count that hasn't been picked up yet
+		select {
+		case <-consumer:
+		default:
+		}
+
+		consumer <- count
+	}
+}
+
+// SubscribeToButtonEvents returns a buffered channel that receives a ButtonEvent
+// every time a button on the board is pressed or released
+func (sio *SerialIO) SubscribeToButtonEvents() chan ButtonEvent {
+	ch := make(chan ButtonEvent, buttonEventQueueSize)
+	sio.buttonConsumers = append(sio.buttonConsumers, ch)
+
+	return ch
+}
+
 func (sio *SerialIO) setupOnConfigReload() {
 	configReloadedChannel := sio.deej.config.SubscribeToChanges()
 
@@ -212,19 +650,16 @@ func (sio *SerialIO) setupOnConfigReload() {
 		for {
 			<-configReloadedChannel
 
+			sio.sliderCountLock.Lock()
 			sio.lastKnownNumSliders = 0
+			sio.sliderCountLock.Unlock()
 
 			// if connection params have changed, attempt to stop and start the connection
 			if sio.deej.config.ConnectionInfo.COMPort != sio.comPortConfig ||
 				sio.deej.config.ConnectionInfo.BaudRate != sio.baudRateConfig {
 
 				sio.logger.Info("Detected change in connection parameters, attempting to renew connection")
-				sio.Stop()
-
-				// let the connection close
-				time.Sleep(2 * time.Second)
-
-				sio.Start()
+				sio.restart()
 			}
 		}
 	}()
@@ -232,31 +667,80 @@ func (sio *SerialIO) setupOnConfigReload() {
 
 // manages serial connection and retries
 func (sio *SerialIO) managerLoop() {
-	sio.wg.Add(1)
 	defer sio.wg.Done()
 
+	// give a board that's slow to show up at boot a head start, rather than failing to find it and backing off
+	if delay := sio.deej.config.ConnectionInfo.StartupDelay; delay > 0 && !sio.startupDelayDone {
+		sio.logger.Infow("Waiting before the first serial connection", "delay", delay)
+
+		select {
+		case <-sio.stopChannel:
+			sio.logger.Debug("managerLoop: stop signal")
+			return
+		case <-time.After(delay):
+		}
+	}
+
+	sio.startupDelayDone = true
+
 	sio.logger.Infow("Trying serial connection",
 		"port", sio.deej.config.ConnectionInfo.COMPort,
 		"vid", fmt.Sprintf("%X", sio.deej.config.AutoSearchVIDPID.VID),
 		"pid", fmt.Sprintf("%X", sio.deej.config.AutoSearchVIDPID.PID),
 	)
 
+	retryDelay := serialRetryMinDelay
+
+	// the disconnected notification is only shown once per streak of disconnects, i.e. a flaky USB cable.
+	// a streak ends once a connection stays up for serialStableConnection
+	disconnectNotified := false
+
+	// the port a "port doesn't exist" notification was last shown for, so it's shown again only once the config changes
+	portNotFoundNotified := ""
+
+	// waits before the next connection attempt, backing off further every time.
+	// returns false if deej is stopped in the meantime
+	waitForRetry := func() bool {
+		timer := time.NewTimer(retryDelay)
+		defer timer.Stop()
+
+		retryDelay = min(retryDelay*2, serialRetryMaxDelay)
+
+		select {
+		case <-sio.stopChannel:
+			sio.logger.Debug("managerLoop: stop signal")
+			return false
+		case <-timer.C:
+			return true
+		}
+	}
+
 	for {
 		err := sio.connect()
 		if err != nil {
-			sio.logger.Debugw("Serial connection error. Trying again...", "err", err)
+			sio.logger.Debugw("Serial connection error. Trying again...", "err", err, "retryIn", retryDelay)
 
-			select {
-			case <-sio.stopChannel:
-				sio.logger.Debug("managerLoop: stop signal")
+			if errors.Is(err, ErrPortNotFound) && portNotFoundNotified != sio.comPortToUse {
+				portNotFoundNotified = sio.comPortToUse
+				sio.notifyPortNotFound()
+			}
+
+			if !waitForRetry() {
 				return
-			case <-time.After(2 * time.Second):
-				continue
 			}
+
+			continue
 		}
 
+		retryDelay = serialRetryMinDelay
+		portNotFoundNotified = ""
+		connectedAt := time.Now()
+
 		sio.sendStateChangeEvent(true)
 
+		// a fresh connection may come with different firmware, don't carry over stale button states
+		sio.currentButtonStates = make(map[int]bool)
+
 		namedLogger := sio.logger.Named(strings.ToLower(sio.comPortToUse))
 		namedLogger.Infow("Connected")
 
@@ -275,8 +759,9 @@ func (sio *SerialIO) managerLoop() {
 				Other: "Succesfully connected to deej.",
 			},
 		})
-		sio.deej.notifier.Notify(connectedTitle, connectedDescription)
+		sio.deej.notifier.Notify(notificationInfo, connectedTitle, connectedDescription)
 
+		sio.wg.Add(1)
 		go sio.readLoop(namedLogger)
 
 		select {
@@ -284,6 +769,12 @@ func (sio *SerialIO) managerLoop() {
 			sio.logger.Warnw("Read line error", "err", err)
 			sio.logger.Warn("Closing serial port")
 
+			sio.deej.metrics.serialReconnects.Add(1)
+
+			if time.Since(connectedAt) >= serialStableConnection {
+				disconnectNotified = false
+			}
+
 			disconnectedTitle := sio.deej.localizer.MustLocalize(&i18n.LocalizeConfig{
 				DefaultMessage: &i18n.Message{
 					ID:    "ComPortDisconnectedNotificationTitle",
@@ -299,10 +790,17 @@ func (sio *SerialIO) managerLoop() {
 					Other: "Trying to reconnect.",
 				},
 			})
-			sio.deej.notifier.Notify(disconnectedTitle, disconnectedDescription)
+			if !disconnectNotified {
+				sio.deej.notifier.Notify(notificationInfo, disconnectedTitle, disconnectedDescription)
+				disconnectNotified = true
+			}
 
 			_ = sio.closePort()
-			time.Sleep(2 * time.Second)
+
+			if !waitForRetry() {
+				return
+			}
+
 			continue
 
 		case <-sio.stopChannel:
@@ -314,7 +812,6 @@ func (sio *SerialIO) managerLoop() {
 }
 
 func (sio *SerialIO) readLoop(logger *zap.SugaredLogger) {
-	sio.wg.Add(1)
 	defer sio.wg.Done()
 
 	reader := bufio.NewReader(sio.port)
@@ -350,45 +847,217 @@ func (sio *SerialIO) closePort() error {
 }
 
 func (sio *SerialIO) handleLine(logger *zap.SugaredLogger, line string) {
-	// this function receives an unsanitized line which is guaranteed to end with LF,
-	// but most lines will end with CRLF. it may also have garbage instead of
-	// deej-formatted values, so we must check for that! just ignore bad ones
-	if !expectedLinePattern.MatchString(line) {
+	var sliderValues []int
+	var buttonEvents []ButtonEvent
+	var ok bool
+
+	// a line mangled by electrical noise can still look valid, so a checksum is the only way to catch it
+	if sio.deej.config.ConnectionInfo.Checksum {
+		if line, ok = verifyLineChecksum(line); !ok {
+			logger.Debugw("Got line with a missing or wrong checksum from serial, ignoring", "line", line)
+			return
+		}
+	}
+
+	if sio.deej.config.ConnectionInfo.Protocol == serialProtocolJSON {
+		sliderValues, buttonEvents, ok = parseJSONLine(logger, line)
+	} else {
+		normalized := sio.deej.config.ConnectionInfo.SliderValueRange == sliderValueRangeNormalized
+		sliderValues, buttonEvents, ok = parseClassicLine(logger, line, normalized)
+	}
+
+	if !ok {
 		return
 	}
 
-	// trim the suffix
-	line = strings.TrimSuffix(line, "\r\n")
+	sio.handleSliderValues(logger, sliderValues)
+	sio.handleButtonEvents(logger, buttonEvents)
+}
+
+// verifyLineChecksum checks the checksum at the end of a line, and returns the line without it
+func verifyLineChecksum(line string) (string, bool) {
+	match := checksummedLinePattern.FindStringSubmatch(line)
+	if match == nil {
+		return line, false
+	}
+
+	content := match[1]
+	expected, _ := strconv.ParseUint(match[2], 16, 8)
+
+	var checksum byte
+	for idx := 0; idx < len(content); idx++ {
+		checksum ^= content[idx]
+	}
+
+	if checksum != byte(expected) {
+		return line, false
+	}
+
+	// the parsers still expect a line ending
+	return content + "\n", true
+}
 
-	// split on pipe (|), this gives a slice of numerical strings between "0" and "1023"
+// parseClassicLine reads slider values and button states from a pipe-delimited line, i.e. "500|300|b0:1".
+// this function receives an unsanitized line which is guaranteed to end with LF,
+// but most lines will end with CRLF. it may also have garbage instead of
+// deej-formatted values, so we must check for that! just ignore bad ones.
+// with normalized set, slider values are positions between 0.0 and 1.0 rather than raw readings, and are brought
+// to the same 0-1023 scale raw readings use, so smoothing, noise reduction and so on apply alike
+func parseClassicLine(logger *zap.SugaredLogger, line string, normalized bool) ([]int, []ButtonEvent, bool) {
+	linePattern := expectedLinePattern
+	if normalized {
+		linePattern = expectedNormalizedLinePattern
+	}
+
+	if !linePattern.MatchString(line) {
+		return nil, nil, false
+	}
+
+	// trim the suffix, whichever one it is
+	line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+
+	// split on pipe (|), this gives a slice of numerical strings between "0" and "1023",
+	// followed by any button states. the line pattern guarantees buttons only come after sliders
 	splitLine := strings.Split(line, "|")
-	numSliders := len(splitLine)
+
+	buttonEvents := []ButtonEvent{}
+	for idx, token := range splitLine {
+		if strings.HasPrefix(token, buttonTokenPrefix) {
+			buttonEvents = parseButtonTokens(splitLine[idx:])
+			splitLine = splitLine[:idx]
+			break
+		}
+	}
+
+	// convert string values to integers ("1023" -> 1023). the line pattern allows up to 4 digits, so a glitched
+	// reading can still be way out of range - turns out the first line could come out dirty sometimes
+	// (i.e. "4558|925|41|643|220"), and so can any slider's value. ignore the whole line if that happens,
+	// rather than letting a slider jump past 100% for a moment
+	sliderValues := make([]int, len(splitLine))
+	for sliderIdx, stringValue := range splitLine {
+		if normalized {
+			position, _ := strconv.ParseFloat(stringValue, 64)
+			if position > 1 {
+				logger.Debugw("Got malformed line from serial, ignoring", "line", line, "slider", sliderIdx, "value", position)
+				return nil, nil, false
+			}
+
+			sliderValues[sliderIdx] = int(math.Round(position * 1023))
+			continue
+		}
+
+		number, _ := strconv.Atoi(stringValue)
+		if number > 1023 {
+			logger.Debugw("Got malformed line from serial, ignoring", "line", line, "slider", sliderIdx, "value", number)
+			return nil, nil, false
+		}
+
+		sliderValues[sliderIdx] = number
+	}
+
+	return sliderValues, buttonEvents, true
+}
+
+// parseButtonTokens turns button tokens into button events. "b3:1" -> button 3, pressed.
+// the line pattern already made sure these are well-formed
+func parseButtonTokens(tokens []string) []ButtonEvent {
+	buttonEvents := make([]ButtonEvent, len(tokens))
+	for idx, token := range tokens {
+		buttonIDString, state, _ := strings.Cut(strings.TrimPrefix(token, buttonTokenPrefix), ":")
+		buttonID, _ := strconv.Atoi(buttonIDString)
+
+		buttonEvents[idx] = ButtonEvent{ButtonID: buttonID, Pressed: state == "1"}
+	}
+
+	return buttonEvents
+}
+
+// jsonLine is a line sent by boards using the json protocol, i.e. {"sliders":[0.5,0.3],"buttons":[true]}
+type jsonLine struct {
+	// slider positions, already normalized between 0.0 and 1.0
+	Sliders []float64 `json:"sliders"`
+
+	// button states, by button ID
+	Buttons []bool `json:"buttons"`
+}
+
+// parseJSONLine reads slider values and button states from a json line. slider positions are
+// brought to the same 0-1023 scale classic lines use, so smoothing, noise reduction and so on apply alike
+func parseJSONLine(logger *zap.SugaredLogger, line string) ([]int, []ButtonEvent, bool) {
+	var parsed jsonLine
+	if err := json.Unmarshal([]byte(line), &parsed); err != nil || len(parsed.Sliders) == 0 {
+		return nil, nil, false
+	}
+
+	sliderValues := make([]int, len(parsed.Sliders))
+	for sliderIdx, position := range parsed.Sliders {
+		if position < 0 || position > 1 {
+			logger.Debugw("Got malformed line from serial, ignoring", "line", line, "slider", sliderIdx, "value", position)
+			return nil, nil, false
+		}
+
+		sliderValues[sliderIdx] = int(math.Round(position * 1023))
+	}
+
+	buttonEvents := make([]ButtonEvent, len(parsed.Buttons))
+	for buttonID, pressed := range parsed.Buttons {
+		buttonEvents[buttonID] = ButtonEvent{ButtonID: buttonID, Pressed: pressed}
+	}
+
+	return sliderValues, buttonEvents, true
+}
+
+// handleSliderValues sends slider move events for every slider whose raw value (0-1023) changed enough
+func (sio *SerialIO) handleSliderValues(logger *zap.SugaredLogger, sliderValues []int) {
+	numSliders := len(sliderValues)
 
 	// update our slider count, if needed - this will send slider move events for all
-	if numSliders != sio.lastKnownNumSliders {
+	sio.sliderCountLock.Lock()
+	countChanged := numSliders != sio.lastKnownNumSliders
+	sio.lastKnownNumSliders = numSliders
+
+	countReported := numSliders != sio.reportedNumSliders
+	sio.reportedNumSliders = numSliders
+
+	restoredNumSliders := sio.restoredNumSliders
+	sio.restoredNumSliders = 0
+	sio.sliderCountLock.Unlock()
+
+	// positions restored for a different set of sliders belonged to other sliders, or none at all
+	if restoredNumSliders != 0 && restoredNumSliders != numSliders {
+		logger.Infow("Slider count changed since the previous run, replacing restored positions",
+			"restored", restoredNumSliders,
+			"detected", numSliders)
+	}
+
+	if countChanged {
 		logger.Infow("Detected sliders", "amount", numSliders)
-		sio.lastKnownNumSliders = numSliders
 		sio.currentSliderValues = make([]int, numSliders)
 
+		sio.sliderCountLock.Lock()
+		sio.currentSliderPercents = make([]float32, numSliders)
+		sio.sliderCountLock.Unlock()
+
 		// reset everything to be an impossible value to force the slider move event later
 		for idx := range sio.currentSliderValues {
 			sio.currentSliderValues[idx] = -1023
 		}
+
+		// start smoothing over from each slider's next reading, rather than lagging behind old values
+		sio.filteredSliderValues = make([]float64, numSliders)
+		for idx := range sio.filteredSliderValues {
+			sio.filteredSliderValues[idx] = -1
+		}
+	}
+
+	if countReported {
+		sio.sendSliderCountChangeEvent(numSliders)
 	}
 
 	// for each slider:
 	moveEvents := []SliderMoveEvent{}
-	for sliderIdx, stringValue := range splitLine {
-
-		// convert string values to integers ("1023" -> 1023)
-		number, _ := strconv.Atoi(stringValue)
-
-		// turns out the first line could come out dirty sometimes (i.e. "4558|925|41|643|220")
-		// so let's check the first number for correctness just in case
-		if sliderIdx == 0 && number > 1023 {
-			logger.Debugw("Got malformed line from serial, ignoring", "line", line)
-			return
-		}
+	for sliderIdx, number := range sliderValues {
+		number = sio.smoothSliderValue(sliderIdx, number)
 
 		// map the value from raw to a "dirty" float between 0 and 1 (e.g. 0.15451...)
 		dirtyFloat := float32(number) / 1023.0
@@ -396,13 +1065,19 @@ func (sio *SerialIO) handleLine(logger *zap.SugaredLogger, line string) {
 		// normalize it to an actual volume scalar between 0.0 and 1.0 with 2 points of precision
 		normalizedScalar := util.NormalizeScalar(dirtyFloat)
 
-		// if sliders are inverted, take the complement of 1.0
-		if sio.deej.config.InvertSliders {
+		// if this slider is inverted, take the complement of 1.0
+		if sio.deej.config.SliderInverted(sliderIdx) {
 			normalizedScalar = 1 - normalizedScalar
 		}
 
+		// snap positions close to either end onto that end
+		normalizedScalar = util.ApplyDeadzone(normalizedScalar, sio.deej.config.SliderDeadzone)
+
+		// map the slider position onto the configured volume curve
+		normalizedScalar = util.ApplyCurve(normalizedScalar, sio.deej.config.VolumeCurve)
+
 		// check if it changes the desired state (could just be a jumpy raw slider value)
-		if util.SignificantlyDifferent(sio.currentSliderValues[sliderIdx], number, sio.deej.config.NoiseReductionLevel) {
+		if util.SignificantlyDifferent(sio.currentSliderValues[sliderIdx], number, sio.deej.config.SliderNoiseReductionLevel(sliderIdx)) {
 
 			// if it does, update the saved value and create a move event
 			sio.currentSliderValues[sliderIdx] = number
@@ -413,17 +1088,243 @@ func (sio *SerialIO) handleLine(logger *zap.SugaredLogger, line string) {
 			})
 
 			if sio.deej.Verbose() {
-				logger.Debugw("Slider moved", "event", moveEvents[len(moveEvents)-1])
+				logger.Debugw("Slider moved",
+					append(sio.deej.config.sliderLogFields(sliderIdx), "event", moveEvents[len(moveEvents)-1])...)
 			}
 		}
 	}
 
 	// deliver move events if there are any, towards all potential consumers
 	if len(moveEvents) > 0 {
-		for _, consumer := range sio.sliderMoveConsumers {
-			for _, moveEvent := range moveEvents {
-				consumer <- moveEvent
+		sio.sliderCountLock.Lock()
+		for _, moveEvent := range moveEvents {
+			sio.currentSliderPercents[moveEvent.SliderID] = moveEvent.PercentValue
+		}
+		sio.sliderCountLock.Unlock()
+
+		sio.rateLimitSliderMoveEvents(logger, moveEvents)
+	}
+}
+
+// rateLimitSliderMoveEvents delivers move events right away, unless their slider already had one
+// delivered within the configured rate. those are held back, replacing any move held back before them,
+// and delivered once the slider's time is up - so the position a slider settles on always gets through
+func (sio *SerialIO) rateLimitSliderMoveEvents(logger *zap.SugaredLogger, moveEvents []SliderMoveEvent) {
+	rate := sio.deej.config.MaxSliderRate
+	if rate == 0 {
+		sio.sendSliderMoveEvents(logger, moveEvents)
+		return
+	}
+
+	interval := time.Second / time.Duration(rate)
+	now := time.Now()
+	ready := []SliderMoveEvent{}
+
+	sio.sliderRateLock.Lock()
+	for _, moveEvent := range moveEvents {
+		sinceLastSent := now.Sub(sio.lastSliderMoveSent[moveEvent.SliderID])
+
+		if sinceLastSent >= interval {
+			sio.lastSliderMoveSent[moveEvent.SliderID] = now
+			delete(sio.pendingSliderMoves, moveEvent.SliderID)
+			ready = append(ready, moveEvent)
+			continue
+		}
+
+		sio.pendingSliderMoves[moveEvent.SliderID] = moveEvent
+
+		// moves held back for other sliders go out along with this one
+		if sio.pendingSliderMoveJob == nil {
+			sio.pendingSliderMoveJob = time.AfterFunc(interval-sinceLastSent, func() {
+				sio.sendPendingSliderMoveEvents(logger)
+			})
+		}
+	}
+	sio.sliderRateLock.Unlock()
+
+	if len(ready) > 0 {
+		sio.sendSliderMoveEvents(logger, ready)
+	}
+}
+
+// sendPendingSliderMoveEvents delivers every move event held back by the rate limit
+func (sio *SerialIO) sendPendingSliderMoveEvents(logger *zap.SugaredLogger) {
+	now := time.Now()
+
+	sio.sliderRateLock.Lock()
+	pending := make([]SliderMoveEvent, 0, len(sio.pendingSliderMoves))
+	for sliderIdx, moveEvent := range sio.pendingSliderMoves {
+		sio.lastSliderMoveSent[sliderIdx] = now
+		pending = append(pending, moveEvent)
+	}
+
+	clear(sio.pendingSliderMoves)
+	sio.pendingSliderMoveJob = nil
+	sio.sliderRateLock.Unlock()
+
+	if len(pending) > 0 {
+		sio.sendSliderMoveEvents(logger, pending)
+	}
+}
+
+// sendSliderMoveEvents delivers the given move events to every consumer, according to its backpressure policy
+func (sio *SerialIO) sendSliderMoveEvents(logger *zap.SugaredLogger, moveEvents []SliderMoveEvent) {
+
+	// (un)subscribing can carry on while events are queued
+	sio.sliderMoveConsumersLock.Lock()
+	consumers := slices.Clone(sio.sliderMoveConsumers)
+	sio.sliderMoveConsumersLock.Unlock()
+
+	for _, consumer := range consumers {
+		policy := sio.deej.config.sliderMoveBackpressure(consumer.name)
+
+		for _, moveEvent := range moveEvents {
+			sio.enqueueSliderMoveEvent(logger, consumer, policy, moveEvent)
+		}
+	}
+}
+
+// handleButtonEvents sends on every button event whose state differs from the last line's
+func (sio *SerialIO) handleButtonEvents(logger *zap.SugaredLogger, events []ButtonEvent) {
+	for _, event := range events {
+
+		// buttons not seen before count as released
+		if sio.currentButtonStates[event.ButtonID] == event.Pressed {
+			continue
+		}
+		sio.currentButtonStates[event.ButtonID] = event.Pressed
+
+		if sio.deej.Verbose() {
+			logger.Debugw("Button state changed", "event", event)
+		}
+
+		// same as with slider moves, a consumer that stopped reading mustn't keep Stop from returning
+		for _, consumer := range sio.buttonConsumers {
+			select {
+			case consumer <- event:
+			case <-sio.stopChannel:
+				return
+			}
+		}
+	}
+}
+
+// smoothSliderValue runs a raw slider reading through an exponential moving average, if enabled in the config
+func (sio *SerialIO) smoothSliderValue(sliderIdx int, number int) int {
+	smoothing := sio.deej.config.SliderSmoothing
+	previous := sio.filteredSliderValues[sliderIdx]
+
+	filtered := float64(number)
+	if smoothing > 0 && previous >= 0 && number >= sliderSmoothingEdge && number <= 1023-sliderSmoothingEdge {
+		filtered = smoothing*previous + (1-smoothing)*float64(number)
+	}
+
+	sio.filteredSliderValues[sliderIdx] = filtered
+
+	return int(math.Round(filtered))
+}
+
+// enqueueSliderMoveEvent places a move event on a consumer's queue, applying the given
+// backpressure policy if that consumer hasn't kept up with previous events. it never waits for the consumer
+func (sio *SerialIO) enqueueSliderMoveEvent(
+	logger *zap.SugaredLogger,
+	consumer *sliderMoveConsumer,
+	policy string,
+	event SliderMoveEvent,
+) {
+	dropped, ok := consumer.enqueue(event, policy)
+	if !ok {
+		return
+	}
+
+	if policy == backpressureBlock {
+		if consumer.warnOverflow() {
+			logger.Warnw("Slider move consumer stopped keeping up, dropping its oldest events",
+				"consumer", consumer.name,
+				"queued", sliderMoveQueueMaxSize)
+		}
+
+		return
+	}
+
+	if sio.deej.Verbose() {
+		logger.Debugw("Slider move queue full, dropping event", "consumer", consumer.name, "policy", policy, "event", dropped)
+	}
+}
+
+// enqueue adds an event to the queue and wakes up deliver. if the queue is full, the policy decides which event
+// is dropped: the new one with drop_newest, otherwise the oldest one. returns the dropped event, if any
+func (c *sliderMoveConsumer) enqueue(event SliderMoveEvent, policy string) (SliderMoveEvent, bool) {
+	c.lock.Lock()
+
+	limit := sliderMoveQueueSize
+	if policy == backpressureBlock {
+		limit = sliderMoveQueueMaxSize
+	}
+
+	dropped, ok := SliderMoveEvent{}, false
+
+	if len(c.queue) >= limit {
+		if policy == backpressureDropNewest {
+			c.lock.Unlock()
+			return event, true
+		}
+
+		dropped, ok = c.queue[0], true
+		c.queue = c.queue[1:]
+	}
+
+	c.queue = append(c.queue, event)
+	c.lock.Unlock()
+
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+
+	return dropped, ok
+}
+
+// warnOverflow tells whether an overflowing block queue should be warned about, which it should only
+// once per streak: the consumer catching up with its whole queue starts a new one
+func (c *sliderMoveConsumer) warnOverflow() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.overflowWarned {
+		return false
+	}
+
+	c.overflowWarned = true
+
+	return true
+}
+
+// deliver hands queued events over to the consumer one at a time, until it unsubscribes
+func (c *sliderMoveConsumer) deliver() {
+	for {
+		c.lock.Lock()
+
+		if len(c.queue) == 0 {
+			c.overflowWarned = false
+			c.lock.Unlock()
+
+			select {
+			case <-c.wake:
+				continue
+			case <-c.stop:
+				return
 			}
 		}
+
+		event := c.queue[0]
+		c.queue = c.queue[1:]
+		c.lock.Unlock()
+
+		select {
+		case c.ch <- event:
+		case <-c.stop:
+			return
+		}
 	}
 }
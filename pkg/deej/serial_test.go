@@ -0,0 +1,140 @@
+package deej
+
+import (
+	"testing"
+	"time"
+)
+
+// stopping has to finish well within this, or something is waiting on a consumer that's gone
+const serialStopTimeout = 5 * time.Second
+
+func TestButtonEventsDontBlockStop(t *testing.T) {
+	d := newTestDeej(t, "slider_mapping:\n  0: master\n")
+	sio := d.serial
+
+	// subscribed, but never read from
+	sio.SubscribeToButtonEvents()
+	sio.stopChannel = make(chan struct{})
+
+	events := make([]ButtonEvent, buttonEventQueueSize*2)
+	for idx := range events {
+		events[idx] = ButtonEvent{ButtonID: idx, Pressed: true}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		sio.handleButtonEvents(sio.logger, events)
+	}()
+
+	close(sio.stopChannel)
+
+	select {
+	case <-done:
+	case <-time.After(serialStopTimeout):
+		t.Fatal("button delivery kept waiting on a consumer that stopped reading")
+	}
+}
+
+func TestSerialStartStopRapidly(t *testing.T) {
+	d := newTestDeej(t, "slider_mapping:\n  0: master\ncom_port: deej-test-missing-port\n")
+
+	// nobody reads state changes or slider moves here, like the tray during shutdown
+	d.serial.SubscribeToStateChangeEvent()
+	d.serial.SubscribeToSliderMoveEvents(sliderMoveConsumerTray)
+
+	for range 20 {
+		d.serial.Start()
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			d.serial.Stop()
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(serialStopTimeout):
+			t.Fatal("Stop didn't return")
+		}
+	}
+}
+
+func TestSlowSliderMoveConsumerDoesntHoldUpOthers(t *testing.T) {
+	d := newTestDeej(t, "slider_mapping:\n  0: master\n")
+	sio := d.serial
+
+	// never read from, with the policy that keeps every event
+	sio.SubscribeToSliderMoveEvents(sliderMoveConsumerSessions)
+	tray := sio.SubscribeToSliderMoveEvents(sliderMoveConsumerTray)
+
+	sent := make(chan struct{})
+	go func() {
+		defer close(sent)
+
+		for idx := range sliderMoveQueueSize * 4 {
+			sio.sendSliderMoveEvents(sio.logger, []SliderMoveEvent{{SliderID: 0, PercentValue: float32(idx)}})
+		}
+	}()
+
+	select {
+	case <-sent:
+	case <-time.After(serialStopTimeout):
+		t.Fatal("sending slider moves waited on a consumer that stopped reading")
+	}
+
+	// drop_oldest keeps the latest moves, so the last one sent has to come through
+	last := float32(sliderMoveQueueSize*4 - 1)
+	timeout := time.After(serialStopTimeout)
+
+	for {
+		select {
+		case event := <-tray:
+			if event.PercentValue == last {
+				return
+			}
+		case <-timeout:
+			t.Fatal("the tray never got the latest slider move")
+		}
+	}
+}
+
+func TestSliderMoveBackpressurePolicies(t *testing.T) {
+	events := func(count int) []SliderMoveEvent {
+		result := make([]SliderMoveEvent, count)
+		for idx := range result {
+			result[idx] = SliderMoveEvent{PercentValue: float32(idx)}
+		}
+
+		return result
+	}
+
+	tests := []struct {
+		policy string
+		sent   int
+		first  float32
+		last   float32
+		queued int
+	}{
+		{backpressureBlock, sliderMoveQueueSize * 2, 0, sliderMoveQueueSize*2 - 1, sliderMoveQueueSize * 2},
+		{backpressureBlock, sliderMoveQueueMaxSize + 1, 1, sliderMoveQueueMaxSize, sliderMoveQueueMaxSize},
+		{backpressureDropOldest, sliderMoveQueueSize + 10, 10, sliderMoveQueueSize + 9, sliderMoveQueueSize},
+		{backpressureDropNewest, sliderMoveQueueSize + 10, 0, sliderMoveQueueSize - 1, sliderMoveQueueSize},
+	}
+
+	for _, test := range tests {
+		consumer := &sliderMoveConsumer{wake: make(chan struct{}, 1)}
+
+		for _, event := range events(test.sent) {
+			consumer.enqueue(event, test.policy)
+		}
+
+		if len(consumer.queue) != test.queued ||
+			consumer.queue[0].PercentValue != test.first ||
+			consumer.queue[len(consumer.queue)-1].PercentValue != test.last {
+			t.Errorf("%s with %d events: queued %d from %v to %v, expected %d from %v to %v",
+				test.policy, test.sent, len(consumer.queue), consumer.queue[0].PercentValue,
+				consumer.queue[len(consumer.queue)-1].PercentValue, test.queued, test.first, test.last)
+		}
+	}
+}
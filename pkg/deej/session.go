@@ -2,6 +2,7 @@ package deej
 
 import (
 	"strings"
+	"sync"
 
 	"go.uber.org/zap"
 )
@@ -11,14 +12,53 @@ type Session interface {
 	GetVolume() float32
 	SetVolume(v float32) error
 
-	// TODO: future mute support
-	// GetMute() bool
-	// SetMute(m bool) error
+	// GetMute and SetMute control the session's mute flag, which is separate from its volume level -
+	// unmuting brings back whatever volume the session had before
+	GetMute() bool
+	SetMute(m bool) error
 
+	// Key returns the name targets are matched against. keys are always lowercase - for process sessions
+	// as well as devices - so targets can be written in any case
 	Key() string
+
+	// DisplayName returns a human-friendly name for listing the session, i.e. "Spotify" rather than "spotify.exe".
+	// only "deej.title:" targets are matched against it, everything else goes by Key
+	DisplayName() string
+
+	// PID returns the ID of the process owning this session, or 0 if it isn't owned by a single process
+	PID() uint32
+
+	// ID returns a handle that tells this session apart from every other one, even ones with the same key.
+	// it doesn't change for as long as the session exists, so it's what sessions are removed by
+	ID() string
+
 	Release()
 }
 
+// stereoSession is implemented by sessions whose left and right channel volumes can be set separately,
+// which is what sliders configured as a stereo pair use
+type stereoSession interface {
+	SetStereoVolume(left float32, right float32) error
+}
+
+// deviceSession is implemented by sessions controlling a whole audio device,
+// which is how "deej.allmaster" finds every output device
+type deviceSession interface {
+	IsOutputDevice() bool
+}
+
+// aliasedSession is implemented by sessions that go by more than one name, i.e. a sandboxed app on linux whose
+// binary is only a wrapper. targets matching any of these alternate keys control the session as well
+type aliasedSession interface {
+	AlternateKeys() []string
+}
+
+// channelSession is implemented by sessions that can set a single channel's volume on its own,
+// which is what "deej.channel:" targets use
+type channelSession interface {
+	SetChannelVolume(channel int, v float32) error
+}
+
 const (
 
 	// ideally these would share a common ground in baseSession
@@ -34,11 +74,21 @@ type baseSession struct {
 	system bool
 	master bool
 
+	// owning process, 0 for system and device sessions
+	pid uint32
+
+	// used by ID(), needs to be set by child
+	id string
+
 	// used by Key(), needs to be set by child
 	name string
 
 	// used by String(), needs to be set by child
 	humanReadableDesc string
+
+	// used by DisplayName(), may be set by child and can change during the session's lifetime
+	displayName     string
+	displayNameLock sync.Mutex
 }
 
 func (s *baseSession) Key() string {
@@ -46,9 +96,52 @@ func (s *baseSession) Key() string {
 		return systemSessionName
 	}
 
-	if s.master {
-		return strings.ToLower(s.name) // could be master or mic, or any device's friendly name
+	// for master sessions this could be master or mic, or any device's friendly name
+	return normalizeSessionKey(s.name)
+}
+
+// normalizeSessionKey brings a session key or target to the form sessions are stored under in the session map
+func normalizeSessionKey(key string) string {
+	return strings.ToLower(key)
+}
+
+func (s *baseSession) PID() uint32 {
+	return s.pid
+}
+
+func (s *baseSession) ID() string {
+	return s.id
+}
+
+func (s *baseSession) DisplayName() string {
+	s.displayNameLock.Lock()
+	defer s.displayNameLock.Unlock()
+
+	if s.displayName == "" {
+		return s.name
+	}
+
+	return s.displayName
+}
+
+func (s *baseSession) setDisplayName(displayName string) {
+	s.displayNameLock.Lock()
+	defer s.displayNameLock.Unlock()
+
+	s.displayName = displayName
+}
+
+// stereoChannelVolume picks the volume of a single channel when balancing left and right separately.
+// the first two channels are left and right, any others (center, surround) get the average of both,
+// and so do mono streams
+func stereoChannelVolume(channel int, channelCount int, left float32, right float32) float32 {
+	if channelCount < 2 || channel > 1 {
+		return (left + right) / 2
+	}
+
+	if channel == 0 {
+		return left
 	}
 
-	return strings.ToLower(s.name)
+	return right
 }
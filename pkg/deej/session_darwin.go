@@ -0,0 +1,198 @@
+package deej
+
+/*
+#cgo LDFLAGS: -framework CoreAudio -framework AudioToolbox
+
+#include <CoreAudio/CoreAudio.h>
+#include <AudioToolbox/AudioServices.h>
+
+static OSStatus deejGetDefaultDevice(int isOutput, AudioObjectID *device) {
+	AudioObjectPropertyAddress address = {
+		isOutput ? kAudioHardwarePropertyDefaultOutputDevice : kAudioHardwarePropertyDefaultInputDevice,
+		kAudioObjectPropertyScopeGlobal,
+		kAudioObjectPropertyElementMain,
+	};
+
+	UInt32 size = sizeof(AudioObjectID);
+	return AudioObjectGetPropertyData(kAudioObjectSystemObject, &address, 0, NULL, &size, device);
+}
+
+// the virtual main volume covers every channel of the device, but not every device has one.
+// those that don't usually still have a main element volume, so fall back to that
+static AudioObjectPropertyAddress deejVolumeAddress(AudioObjectID device, int isOutput) {
+	AudioObjectPropertyAddress address = {
+		kAudioHardwareServiceDeviceProperty_VirtualMainVolume,
+		isOutput ? kAudioDevicePropertyScopeOutput : kAudioDevicePropertyScopeInput,
+		kAudioObjectPropertyElementMain,
+	};
+
+	if (!AudioObjectHasProperty(device, &address)) {
+		address.mSelector = kAudioDevicePropertyVolumeScalar;
+	}
+
+	return address;
+}
+
+static OSStatus deejGetVolume(AudioObjectID device, int isOutput, Float32 *volume) {
+	AudioObjectPropertyAddress address = deejVolumeAddress(device, isOutput);
+
+	UInt32 size = sizeof(Float32);
+	return AudioObjectGetPropertyData(device, &address, 0, NULL, &size, volume);
+}
+
+static OSStatus deejSetVolume(AudioObjectID device, int isOutput, Float32 volume) {
+	AudioObjectPropertyAddress address = deejVolumeAddress(device, isOutput);
+
+	return AudioObjectSetPropertyData(device, &address, 0, NULL, sizeof(Float32), &volume);
+}
+
+static OSStatus deejGetMute(AudioObjectID device, int isOutput, UInt32 *mute) {
+	AudioObjectPropertyAddress address = {
+		kAudioDevicePropertyMute,
+		isOutput ? kAudioDevicePropertyScopeOutput : kAudioDevicePropertyScopeInput,
+		kAudioObjectPropertyElementMain,
+	};
+
+	UInt32 size = sizeof(UInt32);
+	return AudioObjectGetPropertyData(device, &address, 0, NULL, &size, mute);
+}
+
+static OSStatus deejSetMute(AudioObjectID device, int isOutput, UInt32 mute) {
+	AudioObjectPropertyAddress address = {
+		kAudioDevicePropertyMute,
+		isOutput ? kAudioDevicePropertyScopeOutput : kAudioDevicePropertyScopeInput,
+		kAudioObjectPropertyElementMain,
+	};
+
+	return AudioObjectSetPropertyData(device, &address, 0, NULL, sizeof(UInt32), &mute);
+}
+*/
+import "C"
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+// masterSession controls a whole CoreAudio device - the default output device for "master",
+// or the default input device for "mic"
+type masterSession struct {
+	baseSession
+
+	deviceID uint32
+	isOutput bool
+}
+
+func newMasterSession(logger *zap.SugaredLogger, deviceID uint32, isOutput bool) *masterSession {
+	s := &masterSession{
+		deviceID: deviceID,
+		isOutput: isOutput,
+	}
+
+	var key string
+	if isOutput {
+		key = masterSessionName
+	} else {
+		key = inputSessionName
+	}
+
+	s.logger = logger.Named(key)
+	s.master = true
+	s.name = key
+	s.humanReadableDesc = key
+	s.id = fmt.Sprintf("%s|%d", key, deviceID)
+
+	s.logger.Debugw(sessionCreationLogMessage, "session", s)
+
+	return s
+}
+
+func (s *masterSession) GetVolume() float32 {
+	var level C.Float32
+
+	if status := C.deejGetVolume(C.AudioObjectID(s.deviceID), s.scope(), &level); status != 0 {
+		s.logger.Warnw("Failed to get session volume", "status", int32(status))
+	}
+
+	return float32(level)
+}
+
+func (s *masterSession) SetVolume(v float32) error {
+	if status := C.deejSetVolume(C.AudioObjectID(s.deviceID), s.scope(), C.Float32(v)); status != 0 {
+		s.logger.Warnw("Failed to set session volume",
+			"status", int32(status),
+			"volume", v)
+
+		return fmt.Errorf("adjust session volume: OSStatus %d", int32(status))
+	}
+
+	s.logger.Debugw("Adjusting session volume", "to", fmt.Sprintf("%.2f", v))
+
+	return nil
+}
+
+func (s *masterSession) GetMute() bool {
+	var muted C.UInt32
+
+	if status := C.deejGetMute(C.AudioObjectID(s.deviceID), s.scope(), &muted); status != 0 {
+		s.logger.Warnw("Failed to get session mute state", "status", int32(status))
+	}
+
+	return muted != 0
+}
+
+func (s *masterSession) SetMute(m bool) error {
+	var muted C.UInt32
+	if m {
+		muted = 1
+	}
+
+	if status := C.deejSetMute(C.AudioObjectID(s.deviceID), s.scope(), muted); status != 0 {
+		s.logger.Warnw("Failed to set session mute state",
+			"status", int32(status),
+			"mute", m)
+
+		return fmt.Errorf("adjust session mute state: OSStatus %d", int32(status))
+	}
+
+	s.logger.Debugw("Adjusting session mute state", "to", m)
+
+	return nil
+}
+
+func (s *masterSession) Release() {
+	s.logger.Debug("Releasing audio session")
+}
+
+func (s *masterSession) String() string {
+	return fmt.Sprintf(sessionStringFormat, s.humanReadableDesc, s.GetVolume())
+}
+
+func (s *masterSession) scope() C.int {
+	if s.isOutput {
+		return 1
+	}
+
+	return 0
+}
+
+// getDefaultDeviceID returns the current default output or input device
+func getDefaultDeviceID(isOutput bool) (uint32, error) {
+	var device C.AudioObjectID
+
+	var output C.int
+	if isOutput {
+		output = 1
+	}
+
+	if status := C.deejGetDefaultDevice(output, &device); status != 0 {
+		return 0, fmt.Errorf("get default device: OSStatus %d", int32(status))
+	}
+
+	if device == C.kAudioObjectUnknown {
+		return 0, fmt.Errorf("no default device")
+	}
+
+	return uint32(device), nil
+}
@@ -1,18 +1,41 @@
 package deej
 
+import (
+	"errors"
+	"time"
+)
+
+// rescans taking longer than this are given up on, which usually means the audio engine is stuck
+const rescanTimeout = 10 * time.Second
+
+var errRescanTimeout = errors.New("timed out waiting for session rescan")
+
 // SessionFinder represents an entity that can find all current audio sessions
 type SessionFinder interface {
 	// SubscribeToSessionEvents returns a channel that emits session add/remove events
 	SubscribeToSessionEvents() <-chan SessionEvent
 
+	// Rescan drops every known session and acquires them again from scratch.
+	// removal and re-addition are both reported through the session event channel.
+	// it returns once the rescan is done, or with errRescanTimeout if that takes unreasonably long
+	Rescan() error
+
 	Release() error
 }
 
+// defaultDeviceSetter is implemented by session finders that can change the system's default output device
+type defaultDeviceSetter interface {
+	SetDefaultOutputDevice(name string) error
+}
+
 // SessionEvent represents a session add/remove event
 type SessionEvent struct {
 	Type      SessionEventType
 	Session   Session
 	SessionID string
+
+	// the session's new volume, for SessionEventVolumeChanged
+	Volume float32
 }
 
 // SessionEventType indicates whether a session was added or removed
@@ -23,4 +46,12 @@ const (
 	SessionEventAdded SessionEventType = iota
 	// SessionEventRemoved indicates a session was removed/disconnected
 	SessionEventRemoved
+	// SessionEventExclusiveModeStarted indicates another app took exclusive control of a device,
+	// so its volume can't be controlled until it lets go
+	SessionEventExclusiveModeStarted
+	// SessionEventExclusiveModeEnded indicates a device is no longer held in exclusive mode
+	SessionEventExclusiveModeEnded
+	// SessionEventVolumeChanged indicates something other than deej changed a session's volume.
+	// only reported on windows, and only for app sessions
+	SessionEventVolumeChanged
 )
@@ -0,0 +1,189 @@
+package deej
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	sessionEventChanSize = 100
+
+	// CoreAudio can notify about default device changes, but only through callbacks into Go.
+	// checking two device IDs every so often is a lot simpler and just as good for our purposes
+	defaultDevicePollInterval = 2 * time.Second
+)
+
+// caSessionFinder provides the master and mic sessions on macOS. CoreAudio has no per-app volume,
+// so process targets don't match anything here
+type caSessionFinder struct {
+	logger        *zap.SugaredLogger
+	sessionLogger *zap.SugaredLogger
+
+	config *CanonicalConfig
+
+	mu        sync.Mutex
+	masterOut *masterSession
+	masterIn  *masterSession
+
+	// whether master and mic sessions are created at all. guarded by mu
+	registerMaster bool
+
+	sessionEvents chan SessionEvent
+	stopCh        chan struct{}
+}
+
+func newSessionFinder(logger *zap.SugaredLogger, config *CanonicalConfig) (SessionFinder, error) {
+	sf := &caSessionFinder{
+		logger:         logger.Named("session_finder"),
+		sessionLogger:  logger.Named("sessions"),
+		config:         config,
+		registerMaster: true,
+		sessionEvents:  make(chan SessionEvent, sessionEventChanSize),
+		stopCh:         make(chan struct{}),
+	}
+
+	sf.logger.Info("Per-app volume isn't available on macOS, only master and mic can be controlled")
+
+	sf.refreshMasterSessions()
+
+	go sf.watch()
+
+	sf.logger.Debug("Created CoreAudio session finder")
+	return sf, nil
+}
+
+// watch follows the default devices, as well as config changes to whether master sessions exist
+func (sf *caSessionFinder) watch() {
+	configReloadedChannel := sf.config.SubscribeToChanges()
+	defer sf.config.UnsubscribeFromChanges(configReloadedChannel)
+
+	ticker := time.NewTicker(defaultDevicePollInterval)
+	defer ticker.Stop()
+
+	// sessions found before the config is first loaded use the defaults, so check it once it is
+	loaded := sf.config.loaded()
+
+	for {
+		select {
+		case <-sf.stopCh:
+			return
+		case <-loaded:
+			loaded = nil
+			sf.applyConfig()
+		case <-configReloadedChannel:
+			sf.applyConfig()
+		case <-ticker.C:
+			sf.refreshChangedDevices()
+		}
+	}
+}
+
+func (sf *caSessionFinder) applyConfig() {
+	sf.mu.Lock()
+	changed := sf.config.RegisterMaster != sf.registerMaster
+	sf.registerMaster = sf.config.RegisterMaster
+	sf.mu.Unlock()
+
+	if changed {
+		sf.logger.Infow("Master session registration changed, rescanning sessions", "registerMaster", sf.config.RegisterMaster)
+		sf.Rescan()
+	}
+}
+
+// refreshChangedDevices re-creates master sessions whose default device has changed
+func (sf *caSessionFinder) refreshChangedDevices() {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+
+	if !sf.registerMaster {
+		return
+	}
+
+	sf.refreshMasterSession(&sf.masterOut, true, false)
+	sf.refreshMasterSession(&sf.masterIn, false, false)
+}
+
+func (sf *caSessionFinder) refreshMasterSessions() {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+
+	if !sf.registerMaster {
+		return
+	}
+
+	sf.refreshMasterSession(&sf.masterOut, true, true)
+	sf.refreshMasterSession(&sf.masterIn, false, true)
+}
+
+// refreshMasterSession points the given master session at the current default device,
+// re-creating it if the device changed or force is set. must be called with sf.mu held
+func (sf *caSessionFinder) refreshMasterSession(session **masterSession, isOutput bool, force bool) {
+	deviceID, err := getDefaultDeviceID(isOutput)
+	if err != nil {
+		sf.logger.Debugw("Failed to get default device", "isOutput", isOutput, "error", err)
+		deviceID = 0
+	}
+
+	old := *session
+	if !force && ((old == nil && deviceID == 0) || (old != nil && old.deviceID == deviceID)) {
+		return
+	}
+
+	if old != nil {
+		sf.emitEvent(SessionEvent{Type: SessionEventRemoved, Session: old})
+		old.Release()
+		*session = nil
+	}
+
+	if deviceID == 0 {
+		return
+	}
+
+	*session = newMasterSession(sf.sessionLogger, deviceID, isOutput)
+	sf.emitEvent(SessionEvent{Type: SessionEventAdded, Session: *session})
+}
+
+// releaseSessions drops both master sessions. must be called with sf.mu held
+func (sf *caSessionFinder) releaseSessions() {
+	for _, session := range []**masterSession{&sf.masterOut, &sf.masterIn} {
+		if *session == nil {
+			continue
+		}
+
+		sf.emitEvent(SessionEvent{Type: SessionEventRemoved, Session: *session})
+		(*session).Release()
+		*session = nil
+	}
+}
+
+func (sf *caSessionFinder) emitEvent(event SessionEvent) {
+	select {
+	case sf.sessionEvents <- event:
+	default:
+	}
+}
+
+func (sf *caSessionFinder) SubscribeToSessionEvents() <-chan SessionEvent {
+	return sf.sessionEvents
+}
+
+func (sf *caSessionFinder) Rescan() error {
+	sf.logger.Debug("Rescanning sessions")
+
+	sf.mu.Lock()
+	sf.releaseSessions()
+	sf.mu.Unlock()
+
+	sf.refreshMasterSessions()
+
+	return nil
+}
+
+func (sf *caSessionFinder) Release() error {
+	close(sf.stopCh)
+
+	sf.logger.Debug("Released CoreAudio session finder")
+	return nil
+}
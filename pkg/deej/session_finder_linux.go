@@ -3,6 +3,10 @@ package deej
 import (
 	"fmt"
 	"net"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -13,12 +17,27 @@ import (
 const (
 	sessionEventChanSize = 100
 	reconnectDelay       = 2 * time.Second
+
+	// as a session key property, this one stands for the name of the process rather than its ID
+	processIDProperty = "application.process.id"
+
+	// PulseAudio sends server events in bursts while switching default devices, so the master sessions
+	// are only recreated once things have been quiet for this long
+	masterRefreshDebounce = 250 * time.Millisecond
 )
 
 type paSessionFinder struct {
 	logger        *zap.SugaredLogger
 	sessionLogger *zap.SugaredLogger
 
+	config *CanonicalConfig
+
+	// sink input properties to take the session key from, first one present wins. guarded by mu
+	sessionKeyProperties []string
+
+	// whether master and mic sessions are created at all. guarded by mu
+	registerMaster bool
+
 	mu           sync.RWMutex
 	client       *proto.Client
 	conn         net.Conn
@@ -33,18 +52,25 @@ type paSessionFinder struct {
 	sessionEvents chan SessionEvent
 	reconnectCh   chan struct{}
 	stopCh        chan struct{}
+
+	// pending master refresh from server events, pushed back by every new one
+	masterRefreshLock  sync.Mutex
+	masterRefreshTimer *time.Timer
 }
 
-func newSessionFinder(logger *zap.SugaredLogger) (SessionFinder, error) {
+func newSessionFinder(logger *zap.SugaredLogger, config *CanonicalConfig) (SessionFinder, error) {
 	sf := &paSessionFinder{
-		logger:        logger.Named("session_finder"),
-		sessionLogger: logger.Named("sessions"),
-		sinkInputs:    make(map[uint32]*paSession),
-		namedSinks:    make(map[uint32]*masterSession),
-		namedSources:  make(map[uint32]*masterSession),
-		sessionEvents: make(chan SessionEvent, sessionEventChanSize),
-		reconnectCh:   make(chan struct{}, 1),
-		stopCh:        make(chan struct{}),
+		logger:               logger.Named("session_finder"),
+		sessionLogger:        logger.Named("sessions"),
+		config:               config,
+		sessionKeyProperties: defaultLinuxSessionKeyProperties,
+		registerMaster:       true,
+		sinkInputs:           make(map[uint32]*paSession),
+		namedSinks:           make(map[uint32]*masterSession),
+		namedSources:         make(map[uint32]*masterSession),
+		sessionEvents:        make(chan SessionEvent, sessionEventChanSize),
+		reconnectCh:          make(chan struct{}, 1),
+		stopCh:               make(chan struct{}),
 	}
 
 	if err := sf.connect(); err != nil {
@@ -52,6 +78,7 @@ func newSessionFinder(logger *zap.SugaredLogger) (SessionFinder, error) {
 	}
 
 	go sf.connectionManager()
+	go sf.watchConfig()
 
 	sf.logger.Debug("Created event-driven PA session finder")
 	return sf, nil
@@ -68,6 +95,49 @@ func (sf *paSessionFinder) connectionManager() {
 	}
 }
 
+// watchConfig re-creates all sessions whenever the config changes how their keys are picked or
+// whether master sessions exist. sessions found before the config is first loaded use the defaults,
+// so this also covers startup
+func (sf *paSessionFinder) watchConfig() {
+	configReloadedChannel := sf.config.SubscribeToChanges()
+	defer sf.config.UnsubscribeFromChanges(configReloadedChannel)
+
+	select {
+	case <-sf.stopCh:
+		return
+	case <-sf.config.loaded():
+	}
+
+	for {
+		properties := sf.config.LinuxSessionKeyProperties
+		registerMaster := sf.config.RegisterMaster
+
+		sf.mu.Lock()
+		propertiesChanged := !slices.Equal(properties, sf.sessionKeyProperties)
+		registerMasterChanged := registerMaster != sf.registerMaster
+		sf.sessionKeyProperties = properties
+		sf.registerMaster = registerMaster
+		sf.mu.Unlock()
+
+		if propertiesChanged {
+			sf.logger.Infow("Session key properties changed, rescanning sessions", "properties", properties)
+		}
+		if registerMasterChanged {
+			sf.logger.Infow("Master session registration changed, rescanning sessions", "registerMaster", registerMaster)
+		}
+
+		if propertiesChanged || registerMasterChanged {
+			sf.Rescan()
+		}
+
+		select {
+		case <-sf.stopCh:
+			return
+		case <-configReloadedChannel:
+		}
+	}
+}
+
 func (sf *paSessionFinder) handleReconnect() {
 	sf.clearSessions()
 
@@ -90,6 +160,20 @@ func (sf *paSessionFinder) handleReconnect() {
 }
 
 func (sf *paSessionFinder) clearSessions() {
+	sf.releaseSessions()
+
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+
+	if sf.conn != nil {
+		sf.conn.Close()
+		sf.conn = nil
+	}
+	sf.client = nil
+}
+
+// releaseSessions drops every tracked session while keeping the PulseAudio connection intact
+func (sf *paSessionFinder) releaseSessions() {
 	sf.mu.Lock()
 	defer sf.mu.Unlock()
 
@@ -121,12 +205,6 @@ func (sf *paSessionFinder) clearSessions() {
 		sf.masterSource.Release()
 		sf.masterSource = nil
 	}
-
-	if sf.conn != nil {
-		sf.conn.Close()
-		sf.conn = nil
-	}
-	sf.client = nil
 }
 
 func (sf *paSessionFinder) connect() error {
@@ -177,7 +255,7 @@ func (sf *paSessionFinder) onPulseEvent(msg any) {
 		case proto.EventSinkSinkInput:
 			go sf.handleSinkInputEvent(v.Event.GetType(), v.Index)
 		case proto.EventServer:
-			go sf.refreshMaster()
+			sf.scheduleMasterRefresh()
 		case proto.EventSink:
 			go sf.handleSinkEvent(v.Event.GetType(), v.Index)
 		case proto.EventSource:
@@ -198,6 +276,26 @@ func (sf *paSessionFinder) handleSinkInputEvent(eventType proto.SubscriptionEven
 	}
 }
 
+// scheduleMasterRefresh refreshes the master sessions once server events stop coming in
+func (sf *paSessionFinder) scheduleMasterRefresh() {
+	sf.masterRefreshLock.Lock()
+	defer sf.masterRefreshLock.Unlock()
+
+	if sf.masterRefreshTimer != nil && sf.masterRefreshTimer.Stop() {
+		sf.logger.Debug("Server event during pending master refresh, pushing it back")
+	}
+
+	sf.masterRefreshTimer = time.AfterFunc(masterRefreshDebounce, func() {
+		select {
+		case <-sf.stopCh:
+			return
+		default:
+		}
+
+		sf.refreshMaster()
+	})
+}
+
 func (sf *paSessionFinder) refreshMaster() {
 	sf.refreshMasterSink()
 	sf.refreshMasterSource()
@@ -206,8 +304,9 @@ func (sf *paSessionFinder) refreshMaster() {
 func (sf *paSessionFinder) refreshMasterSink() {
 	sf.mu.RLock()
 	client := sf.client
+	registerMaster := sf.registerMaster
 	sf.mu.RUnlock()
-	if client == nil {
+	if client == nil || !registerMaster {
 		return
 	}
 
@@ -219,21 +318,28 @@ func (sf *paSessionFinder) refreshMasterSink() {
 
 	sf.mu.Lock()
 	old := sf.masterSink
-	sf.masterSink = newMasterSession(sf.sessionLogger, sf.client, reply.SinkIndex, reply.Channels, true)
+	if old.sameStream(sf.client, reply.SinkIndex, reply.Channels) {
+		sf.mu.Unlock()
+		return
+	}
+
+	master := newMasterSession(sf.sessionLogger, sf.client, reply.SinkIndex, reply.Channels, true)
+	sf.masterSink = master
 	sf.mu.Unlock()
 
 	if old != nil {
 		sf.emitEvent(SessionEvent{Type: SessionEventRemoved, Session: old})
 		old.Release()
 	}
-	sf.emitEvent(SessionEvent{Type: SessionEventAdded, Session: sf.masterSink})
+	sf.emitEvent(SessionEvent{Type: SessionEventAdded, Session: master})
 }
 
 func (sf *paSessionFinder) refreshMasterSource() {
 	sf.mu.RLock()
 	client := sf.client
+	registerMaster := sf.registerMaster
 	sf.mu.RUnlock()
-	if client == nil {
+	if client == nil || !registerMaster {
 		return
 	}
 
@@ -245,14 +351,20 @@ func (sf *paSessionFinder) refreshMasterSource() {
 
 	sf.mu.Lock()
 	old := sf.masterSource
-	sf.masterSource = newMasterSession(sf.sessionLogger, sf.client, reply.SourceIndex, reply.Channels, false)
+	if old.sameStream(sf.client, reply.SourceIndex, reply.Channels) {
+		sf.mu.Unlock()
+		return
+	}
+
+	master := newMasterSession(sf.sessionLogger, sf.client, reply.SourceIndex, reply.Channels, false)
+	sf.masterSource = master
 	sf.mu.Unlock()
 
 	if old != nil {
 		sf.emitEvent(SessionEvent{Type: SessionEventRemoved, Session: old})
 		old.Release()
 	}
-	sf.emitEvent(SessionEvent{Type: SessionEventAdded, Session: sf.masterSource})
+	sf.emitEvent(SessionEvent{Type: SessionEventAdded, Session: master})
 }
 
 func (sf *paSessionFinder) enumerateExistingSessions() {
@@ -292,29 +404,91 @@ func (sf *paSessionFinder) addSinkInput(index uint32) {
 }
 
 func (sf *paSessionFinder) addSinkInputFromInfo(info *proto.GetSinkInputInfoReply) {
-	// Try application.process.binary, then application.id, then application.name
-	name, ok := info.Properties["application.process.binary"]
-	if !ok {
-		name, ok = info.Properties["application.id"]
-		if !ok {
-			name, ok = info.Properties["application.name"]
-			if !ok {
-				return
-			}
-		}
-	}
-
 	sf.mu.Lock()
 	if _, exists := sf.sinkInputs[info.SinkInputIndex]; exists {
 		sf.mu.Unlock()
 		return
 	}
-	session := newPASession(sf.sessionLogger, sf.client, info.SinkInputIndex, info.Channels, name.String())
+
+	// the first of the configured properties that this sink input has names it, the rest are alternate keys
+	names := sinkInputNames(info, sf.sessionKeyProperties)
+
+	if len(names) == 0 {
+		sf.mu.Unlock()
+		sf.logger.Debugw("Sink input has none of the session key properties, ignoring",
+			"index", info.SinkInputIndex,
+			"properties", sf.sessionKeyProperties)
+		return
+	}
+
+	name := names[0]
+
+	session := newPASession(sf.sessionLogger, sf.client, info.SinkInputIndex, info.Channels, name, sinkInputPID(info))
+	session.alternateKeys = names[1:]
+	if displayName, ok := info.Properties["application.name"]; ok {
+		session.setDisplayName(displayName.String())
+	}
 	sf.sinkInputs[info.SinkInputIndex] = session
 	sf.mu.Unlock()
 
 	sf.emitEvent(SessionEvent{Type: SessionEventAdded, Session: session})
-	sf.logger.Debugw("Added session", "index", info.SinkInputIndex, "name", name.String())
+	sf.logger.Debugw("Added session", "index", info.SinkInputIndex, "name", name, "alternateKeys", session.alternateKeys)
+}
+
+// sinkInputNames returns the values of the given properties that a sink input has, in the same order and without
+// duplicates. sandboxed apps (Flatpak, Snap) often only have the sandbox's wrapper as their binary, so the other
+// properties are what tells them apart
+func sinkInputNames(info *proto.GetSinkInputInfoReply, properties []string) []string {
+	names := []string{}
+
+	for _, property := range properties {
+		value, ok := info.Properties[property]
+		if !ok {
+			continue
+		}
+
+		name := value.String()
+		if property == processIDProperty {
+			name = processName(sinkInputPID(info))
+		}
+
+		if name == "" || slices.ContainsFunc(names, func(existing string) bool { return strings.EqualFold(existing, name) }) {
+			continue
+		}
+
+		names = append(names, name)
+	}
+
+	return names
+}
+
+// processName returns the name the kernel knows a process by, or nothing if it can't be read
+func processName(pid uint32) string {
+	if pid == 0 {
+		return ""
+	}
+
+	comm, err := os.ReadFile(fmt.Sprintf("/proc/%d/comm", pid))
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(comm))
+}
+
+// sinkInputPID returns the ID of the process playing a sink input, or 0 if PulseAudio doesn't know it
+func sinkInputPID(info *proto.GetSinkInputInfoReply) uint32 {
+	prop, ok := info.Properties["application.process.id"]
+	if !ok {
+		return 0
+	}
+
+	pid, err := strconv.ParseUint(prop.String(), 10, 32)
+	if err != nil {
+		return 0
+	}
+
+	return uint32(pid)
 }
 
 func (sf *paSessionFinder) removeSinkInput(index uint32) {
@@ -517,9 +691,26 @@ func (sf *paSessionFinder) SubscribeToSessionEvents() <-chan SessionEvent {
 	return sf.sessionEvents
 }
 
+func (sf *paSessionFinder) Rescan() error {
+	sf.logger.Debug("Rescanning sessions")
+
+	sf.releaseSessions()
+	sf.refreshMaster()
+	sf.enumerateExistingSessions()
+	sf.enumerateExistingDevices()
+
+	return nil
+}
+
 func (sf *paSessionFinder) Release() error {
 	close(sf.stopCh)
 
+	sf.masterRefreshLock.Lock()
+	if sf.masterRefreshTimer != nil {
+		sf.masterRefreshTimer.Stop()
+	}
+	sf.masterRefreshLock.Unlock()
+
 	sf.mu.Lock()
 	conn := sf.conn
 	sf.mu.Unlock()
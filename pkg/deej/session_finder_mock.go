@@ -0,0 +1,218 @@
+package deej
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// setting this to a comma-separated list of session names, i.e. "master,mic,spotify.exe", makes deej
+// use fake sessions with those names instead of the system's real ones
+const mockSessionsEnvVar = "DEEJ_MOCK_SESSIONS"
+
+// the sessions a dry run gets when DEEJ_MOCK_SESSIONS doesn't say otherwise
+var defaultMockSessions = []string{masterSessionName, inputSessionName, systemSessionName, "chrome.exe", "spotify.exe", "discord.exe"}
+
+// mockSessionFinder provides fake sessions that remember their volume and nothing else.
+// it's for running deej without any audio hardware - during development, or on CI
+type mockSessionFinder struct {
+	logger        *zap.SugaredLogger
+	sessionLogger *zap.SugaredLogger
+
+	lock     sync.Mutex
+	names    []string
+	sessions map[string]*mockSession
+	nextPID  uint32
+
+	sessionEvents chan SessionEvent
+
+	// set once the finder is released, guarded by lock
+	released bool
+}
+
+// mockSession is a fake audio session. volume changes are logged, as that's the only way to see them
+type mockSession struct {
+	baseSession
+
+	lock   sync.Mutex
+	volume float32
+	mute   bool
+}
+
+// mockSessionNames returns the session names to fake, if deej should use fake sessions at all
+func mockSessionNames(dryRun bool) ([]string, bool) {
+	if value := os.Getenv(mockSessionsEnvVar); value != "" {
+		names := []string{}
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+
+		return names, true
+	}
+
+	return defaultMockSessions, dryRun
+}
+
+func newMockSessionFinder(logger *zap.SugaredLogger, names []string) *mockSessionFinder {
+	sf := &mockSessionFinder{
+		logger:        logger.Named("session_finder"),
+		sessionLogger: logger.Named("sessions"),
+		names:         names,
+		sessions:      make(map[string]*mockSession),
+		nextPID:       1000,
+		sessionEvents: make(chan SessionEvent, sessionEventChanSize),
+	}
+
+	sf.logger.Infow("Using fake audio sessions, volume changes only show up in the log", "sessions", names)
+
+	for _, name := range names {
+		sf.AddSession(name)
+	}
+
+	sf.logger.Debug("Created mock session finder")
+	return sf
+}
+
+// AddSession creates a fake session and reports it like a real one appearing would be.
+// "master", "mic" and "system" get the same kind of session they'd have for real
+func (sf *mockSessionFinder) AddSession(name string) Session {
+	sf.lock.Lock()
+	defer sf.lock.Unlock()
+
+	key := normalizeSessionKey(name)
+	if session, ok := sf.sessions[key]; ok {
+		return session
+	}
+
+	session := &mockSession{volume: 1}
+	session.logger = sf.sessionLogger.Named(key)
+
+	// fake sessions are one per name, and dropped by name too
+	session.id = "mock|" + key
+	session.name = name
+	session.humanReadableDesc = name
+
+	switch key {
+	case masterSessionName, inputSessionName:
+		session.master = true
+	case systemSessionName:
+		session.system = true
+	default:
+		session.pid = sf.nextPID
+		sf.nextPID++
+	}
+
+	session.logger.Debugw(sessionCreationLogMessage, "session", session)
+
+	sf.sessions[key] = session
+	sf.emitEvent(SessionEvent{Type: SessionEventAdded, Session: session})
+
+	return session
+}
+
+// RemoveSession drops a fake session and reports it like a real one going away would be
+func (sf *mockSessionFinder) RemoveSession(name string) {
+	sf.lock.Lock()
+	defer sf.lock.Unlock()
+
+	key := normalizeSessionKey(name)
+
+	session, ok := sf.sessions[key]
+	if !ok {
+		return
+	}
+
+	delete(sf.sessions, key)
+	sf.emitEvent(SessionEvent{Type: SessionEventRemoved, Session: session})
+	session.Release()
+}
+
+func (sf *mockSessionFinder) emitEvent(event SessionEvent) {
+	select {
+	case sf.sessionEvents <- event:
+	default:
+		sf.logger.Warnw("Session event channel full, dropping event", "type", event.Type)
+	}
+}
+
+func (sf *mockSessionFinder) SubscribeToSessionEvents() <-chan SessionEvent {
+	return sf.sessionEvents
+}
+
+// Rescan drops every fake session and creates the configured ones again, with their volumes reset
+func (sf *mockSessionFinder) Rescan() error {
+	sf.logger.Debug("Rescanning sessions")
+
+	sf.lock.Lock()
+	keys := make([]string, 0, len(sf.sessions))
+	for key := range sf.sessions {
+		keys = append(keys, key)
+	}
+	sf.lock.Unlock()
+
+	for _, key := range keys {
+		sf.RemoveSession(key)
+	}
+
+	for _, name := range sf.names {
+		sf.AddSession(name)
+	}
+
+	return nil
+}
+
+func (sf *mockSessionFinder) Release() error {
+	sf.lock.Lock()
+	sf.released = true
+	sf.lock.Unlock()
+
+	sf.logger.Debug("Released mock session finder")
+	return nil
+}
+
+func (s *mockSession) GetVolume() float32 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.volume
+}
+
+func (s *mockSession) SetVolume(v float32) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.volume = v
+	s.logger.Infow("Adjusting session volume", "to", fmt.Sprintf("%.2f", v))
+
+	return nil
+}
+
+func (s *mockSession) GetMute() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.mute
+}
+
+func (s *mockSession) SetMute(m bool) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.mute = m
+	s.logger.Infow("Adjusting session mute state", "to", m)
+
+	return nil
+}
+
+func (s *mockSession) Release() {
+	s.logger.Debug("Releasing audio session")
+}
+
+func (s *mockSession) String() string {
+	return fmt.Sprintf(sessionStringFormat, s.humanReadableDesc, s.GetVolume())
+}
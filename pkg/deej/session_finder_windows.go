@@ -16,12 +16,14 @@ import (
 	wca "github.com/moutend/go-wca/pkg/wca"
 	"go.uber.org/zap"
 
+	"github.com/nik9play/deej/pkg/deej/util"
 	"github.com/nik9play/deej/pkg/win"
 )
 
 type wcaSessionFinder struct {
 	logger        *zap.SugaredLogger
 	sessionLogger *zap.SugaredLogger
+	config        *CanonicalConfig
 
 	eventCtx *ole.GUID // needed for some session actions to successfully notify other audio consumers
 
@@ -42,6 +44,22 @@ type wcaSessionFinder struct {
 	masterOutID string
 	masterInID  string
 
+	// the device backing masterOut, so it can follow that device into exclusive mode
+	masterOutDeviceID string
+
+	// whether every device also gets a session of its own, see initializeAllDeviceManagers
+	deviceSessions bool
+
+	// the pinned device IDs masterOut and masterIn were created with, empty when following the defaults
+	masterOutPinnedID string
+	masterInPinnedID  string
+
+	// whether master, mic and system sessions were last created or skipped, per the config
+	masterRegistered bool
+
+	// devices currently held in exclusive mode by another app
+	exclusiveDevices map[string]bool
+
 	// per-device session managers (persistent)
 	deviceManagers map[string]*deviceSessionManager
 
@@ -88,24 +106,38 @@ const (
 
 	// buffer size for the device work channel
 	deviceWorkChanSize = 50
+
+	// AUDCLNT_S_NO_SINGLE_PROCESS, returned by GetProcessId for sessions with more than one process
+	audclntSNoSingleProcess = 0x0889000D
 )
 
-func newSessionFinder(logger *zap.SugaredLogger) (SessionFinder, error) {
+func newSessionFinder(logger *zap.SugaredLogger, config *CanonicalConfig) (SessionFinder, error) {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	sf := &wcaSessionFinder{
 		logger:           logger.Named("session_finder"),
 		sessionLogger:    logger.Named("sessions"),
+		config:           config,
 		eventCtx:         ole.NewGUID(myteriousGUID),
 		deviceManagers:   make(map[string]*deviceSessionManager),
 		trackedSessions:  make(map[string]*trackedSession),
+		exclusiveDevices: make(map[string]bool),
 		sessionEventChan: make(chan SessionEvent, sessionEventChanSize),
 		workChan:         make(chan func(), deviceWorkChanSize),
 		workerCtx:        ctx,
 		workerCancel:     cancel,
 	}
 
-	sf.logger.Debug("Created WCA session finder instance")
+	// a finder is created again on every audio engine restart, so this is where callbacks leaking would show
+	if count := win.CallbackCount(); count > win.MaxCallbacks {
+		sf.logger.Errorw("Created more syscall callbacks than expected, something isn't reusing them",
+			"callbacks", count,
+			"maxCallbacks", win.MaxCallbacks)
+	}
+
+	sf.logger.Debugw("Created WCA session finder instance", "callbacks", win.CallbackCount())
+
+	sf.setupOnConfigReload()
 
 	go sf.sessionFinderWorker(ctx)
 
@@ -182,6 +214,18 @@ func (sf *wcaSessionFinder) sessionFinderWorker(ctx context.Context) {
 		sf.logger.Errorw("Failed to initialize device enumerator", "error", err)
 		return
 	}
+	if util.RemoteSession() {
+		sf.logger.Warn("Running in a remote desktop session, default devices and per-app volume may be limited")
+	}
+
+	// which devices to track and which ones master sessions use both depend on the config, so wait for it
+	select {
+	case <-ctx.Done():
+		sf.cleanup()
+		return
+	case <-sf.config.loaded():
+	}
+
 	// Initialize all device managers and register for session notifications
 	if err := sf.initializeAllDeviceManagers(); err != nil {
 		sf.logger.Warnw("Failed to initialize device managers", "error", err)
@@ -236,9 +280,21 @@ func (sf *wcaSessionFinder) initializeDeviceEnumerator() error {
 }
 
 func (sf *wcaSessionFinder) initializeAllDeviceManagers() error {
+	sf.deviceSessions = sf.needsDeviceSessions()
+
+	// fast path: app sessions can live on any output device, so those are always enumerated. but unless the
+	// config targets devices, skip input devices (they have nothing else) and each device's own session
+	flow := uint32(wca.EAll)
+	if !sf.deviceSessions {
+		sf.logger.Debug("No device targets mapped, only tracking app sessions on output devices")
+		flow = wca.ERender
+	}
+
 	var deviceCollection *wca.IMMDeviceCollection
 
-	if err := sf.mmDeviceEnumerator.EnumAudioEndpoints(wca.EAll, wca.DEVICE_STATE_ACTIVE, &deviceCollection); err != nil {
+	// machines can start without any audio device (headless ones, or drivers that are still loading).
+	// that's not an error, devices are picked up by handleDeviceAdded once they show up
+	if err := sf.mmDeviceEnumerator.EnumAudioEndpoints(flow, wca.DEVICE_STATE_ACTIVE, &deviceCollection); err != nil {
 		return fmt.Errorf("enumerate audio endpoints: %w", err)
 	}
 	defer deviceCollection.Release()
@@ -264,7 +320,40 @@ func (sf *wcaSessionFinder) initializeAllDeviceManagers() error {
 	return nil
 }
 
+// needsDeviceSessions returns true if any slider targets a specific device by its name, or all output
+// devices at once - the only cases where devices need sessions of their own
+func (sf *wcaSessionFinder) needsDeviceSessions() bool {
+	if sf.config.SliderMapping == nil {
+		return true
+	}
+
+	found := false
+
+	sf.config.SliderMapping.iterate(func(_ int, targets []string) {
+		for _, target := range targets {
+			if deviceSessionKeyPattern.MatchString(target) || strings.EqualFold(target, specialTargetTransformPrefix+specialTargetAllMaster) {
+				found = true
+				return
+			}
+		}
+	})
+
+	return found
+}
+
+// rebuildDeviceManagers drops all device managers and creates them again, for when what's tracked
+// for each device changes
+func (sf *wcaSessionFinder) rebuildDeviceManagers() {
+	sf.removeAllDeviceManagers()
+
+	if err := sf.initializeAllDeviceManagers(); err != nil {
+		sf.logger.Warnw("Failed to initialize device managers", "error", err)
+	}
+}
+
 func (sf *wcaSessionFinder) initializeMasterSessions() {
+	sf.masterRegistered = sf.config.RegisterMaster
+
 	sf.refreshMasterOutput()
 	sf.refreshMasterInput()
 }
@@ -278,11 +367,14 @@ func (sf *wcaSessionFinder) emitSessionEvent(event SessionEvent) {
 }
 
 // dispatchWork sends fn to the worker goroutine for execution on the COM-initialized thread.
-func (sf *wcaSessionFinder) dispatchWork(fn func()) {
+// dispatchWork queues work for the COM worker, returning false if it had to be dropped
+func (sf *wcaSessionFinder) dispatchWork(fn func()) bool {
 	select {
 	case sf.workChan <- fn:
+		return true
 	default:
 		sf.logger.Warn("Device work channel full, dropping device event")
+		return false
 	}
 }
 
@@ -317,6 +409,12 @@ func (sf *wcaSessionFinder) createDeviceManager(device *wca.IMMDevice) error {
 
 	isOutput := dataFlow == wca.ERender
 
+	// without device sessions, an input device has nothing worth tracking
+	if !isOutput && !sf.deviceSessions {
+		device.Release()
+		return nil
+	}
+
 	// Activate IAudioSessionManager2
 	var sessionManager *wca.IAudioSessionManager2
 	if err := mmdActivateWorkaround(device, wca.IID_IAudioSessionManager2, wca.CLSCTX_ALL, nil, &sessionManager); err != nil {
@@ -330,14 +428,16 @@ func (sf *wcaSessionFinder) createDeviceManager(device *wca.IMMDevice) error {
 		isOutput:       isOutput,
 	}
 
-	// Create device master session
-	deviceMasterSession, err := sf.createDeviceMasterSession(device)
-	if err != nil {
-		sf.logger.Warnw("Failed to create device master session", "deviceID", deviceIDStr, "error", err)
-	} else {
-		dm.masterSession = deviceMasterSession
-		// Emit event for device master session
-		sf.emitSessionEvent(SessionEvent{Type: SessionEventAdded, Session: deviceMasterSession, SessionID: "device_" + deviceIDStr})
+	// Create device master session, unless nothing targets it
+	if sf.deviceSessions {
+		deviceMasterSession, err := sf.createDeviceMasterSession(device, isOutput)
+		if err != nil {
+			sf.logger.Warnw("Failed to create device master session", "deviceID", deviceIDStr, "error", err)
+		} else {
+			dm.masterSession = deviceMasterSession
+			// Emit event for device master session
+			sf.emitSessionEvent(SessionEvent{Type: SessionEventAdded, Session: deviceMasterSession, SessionID: "device_" + deviceIDStr})
+		}
 	}
 
 	// Only register for session notifications on output devices (they have process sessions)
@@ -356,7 +456,9 @@ func (sf *wcaSessionFinder) createDeviceManager(device *wca.IMMDevice) error {
 			sf.logger.Debugw("Registered session notification for device", "deviceID", deviceIDStr)
 		}
 
-		// Enumerate existing sessions on this device
+		// Enumerate existing sessions on this device. besides picking up sessions that already exist, this
+		// is required for the notification above to work: windows only starts sending OnSessionCreated
+		// once the session enumerator has been requested, so this has to happen after registering
 		sf.enumerateDeviceSessions(dm)
 	}
 
@@ -364,6 +466,11 @@ func (sf *wcaSessionFinder) createDeviceManager(device *wca.IMMDevice) error {
 	sf.deviceManagers[deviceIDStr] = dm
 	sf.mu.Unlock()
 
+	// device IDs are what the config uses to pin master and mic, so make them easy to find
+	if dm.masterSession != nil {
+		sf.logger.Infow("Found audio device", "name", dm.masterSession.Key(), "deviceID", deviceIDStr, "isOutput", isOutput)
+	}
+
 	sf.logger.Debugw("Created device manager", "deviceID", deviceIDStr, "isOutput", isOutput)
 
 	return nil
@@ -421,15 +528,18 @@ func (sf *wcaSessionFinder) addSessionFromControl(deviceID string, audioSessionC
 	}
 	audioSessionControl2 := (*wca.IAudioSessionControl2)(unsafe.Pointer(dispatch))
 
-	// Get PID
-	var pid uint32
-	if err := audioSessionControl2.GetProcessId(&pid); err != nil {
-		isSystemSoundsErr := audioSessionControl2.IsSystemSoundsSession()
-		if isSystemSoundsErr != nil && !strings.Contains(err.Error(), "143196173") {
-			audioSessionControl2.Release()
-			audioSessionControl.Release()
-			return fmt.Errorf("get process ID: %w", err)
-		}
+	system, pid, err := sessionProcess(audioSessionControl2.IsSystemSoundsSession, audioSessionControl2.GetProcessId)
+	if err != nil {
+		audioSessionControl2.Release()
+		audioSessionControl.Release()
+		return err
+	}
+
+	// system sounds go along with master and mic, which the user may not want
+	if system && !sf.config.RegisterMaster {
+		audioSessionControl2.Release()
+		audioSessionControl.Release()
+		return nil
 	}
 
 	// Query ISimpleAudioVolume
@@ -442,7 +552,7 @@ func (sf *wcaSessionFinder) addSessionFromControl(deviceID string, audioSessionC
 	simpleAudioVolume := (*wca.ISimpleAudioVolume)(unsafe.Pointer(dispatch))
 
 	// Create session
-	session, err := newWCASession(sf.sessionLogger, audioSessionControl2, simpleAudioVolume, pid, sf.eventCtx)
+	session, err := newWCASession(sf.sessionLogger, audioSessionControl2, simpleAudioVolume, pid, system, sf.eventCtx)
 	if err != nil {
 		audioSessionControl2.Release()
 		simpleAudioVolume.Release()
@@ -456,6 +566,10 @@ func (sf *wcaSessionFinder) addSessionFromControl(deviceID string, audioSessionC
 
 	sessionID := fmt.Sprintf("%s_%s_%d", deviceID, session.Key(), pid)
 
+	session.onExpired = func() {
+		sf.dispatchWork(func() { sf.removeSession(sessionID) })
+	}
+
 	// Register session events callback
 	eventsCallback := win.IAudioSessionEventsCallback{
 		OnStateChanged: func(newState uint32) error {
@@ -465,9 +579,43 @@ func (sf *wcaSessionFinder) addSessionFromControl(deviceID string, audioSessionC
 			}
 			return nil
 		},
+		OnSimpleVolumeChanged: func(_ float32, _ bool, eventContext *ole.GUID) error {
+
+			// deej's own changes carry its event context, only changes from elsewhere are interesting
+			if eventContext != nil && ole.IsEqualGUID(eventContext, sf.eventCtx) {
+				return nil
+			}
+
+			// dragging a volume around sends plenty of these, so leave room in the work
+			// and event channels for the ones that matter more
+			if len(sf.workChan) >= deviceWorkChanSize/2 {
+				return nil
+			}
+
+			// the volume passed in is a float, which windows passes in a register go callbacks
+			// can't see, so ask the session for it instead
+			sf.dispatchWork(func() {
+				if len(sf.sessionEventChan) < sessionEventChanSize/2 {
+					sf.emitSessionEvent(SessionEvent{Type: SessionEventVolumeChanged, Session: session, SessionID: sessionID, Volume: session.GetVolume()})
+				}
+			})
+
+			return nil
+		},
+		OnDisplayNameChanged: func(newDisplayName string, _ *ole.GUID) error {
+			sf.logger.Debugw("Session display name changed", "sessionID", sessionID, "displayName", newDisplayName)
+			session.updateDisplayName(newDisplayName)
+			return nil
+		},
 		OnSessionDisconnected: func(disconnectReason uint32) error {
 			sf.logger.Debugw("Session disconnected", "sessionID", sessionID, "reason", disconnectReason)
-			sf.dispatchWork(func() { sf.removeSession(sessionID) })
+			sf.dispatchWork(func() {
+				sf.removeSession(sessionID)
+
+				if disconnectReason == win.DisconnectReasonExclusiveModeOverride {
+					sf.handleExclusiveModeStarted(deviceID)
+				}
+			})
 			return nil
 		},
 	}
@@ -497,9 +645,82 @@ func (sf *wcaSessionFinder) addSessionFromControl(deviceID string, audioSessionC
 	sf.emitSessionEvent(SessionEvent{Type: SessionEventAdded, Session: session, SessionID: sessionID})
 
 	sf.logger.Debugw("Added tracked session", "sessionID", sessionID, "key", session.Key())
+
+	// shared-mode sessions only show up again once exclusive mode is over
+	sf.handleExclusiveModeEnded(deviceID)
+
 	return nil
 }
 
+// sessionProcess tells whether a session is the system sounds one, and which process it belongs to otherwise.
+// system sounds are recognized by asking directly rather than by their PID, which is only 0 for them as long as
+// GetProcessId happens to succeed. IsSystemSoundsSession returns S_FALSE for every other session
+func sessionProcess(isSystemSoundsSession func() error, getProcessID func(*uint32) error) (bool, uint32, error) {
+	if isSystemSoundsSession() == nil {
+		return true, 0, nil
+	}
+
+	// sessions shared by several processes report AUDCLNT_S_NO_SINGLE_PROCESS
+	// along with their first process, which is good enough for us
+	var pid uint32
+	if err := getProcessID(&pid); err != nil {
+		var oleErr *ole.OleError
+		if !errors.As(err, &oleErr) || oleErr.Code() != audclntSNoSingleProcess {
+			return false, 0, fmt.Errorf("get process ID: %w", err)
+		}
+	}
+
+	return false, pid, nil
+}
+
+// handleExclusiveModeStarted marks the master sessions backed by the given device as unavailable
+// after another app took it over in exclusive mode
+func (sf *wcaSessionFinder) handleExclusiveModeStarted(deviceID string) {
+	sf.mu.Lock()
+	if sf.exclusiveDevices[deviceID] {
+		sf.mu.Unlock()
+		return
+	}
+	sf.exclusiveDevices[deviceID] = true
+	deviceMaster := sf.setDeviceExclusiveMode(deviceID, true)
+	sf.mu.Unlock()
+
+	sf.logger.Infow("Device taken over in exclusive mode", "deviceID", deviceID)
+	sf.emitSessionEvent(SessionEvent{Type: SessionEventExclusiveModeStarted, Session: deviceMaster, SessionID: "device_" + deviceID})
+}
+
+// handleExclusiveModeEnded restores volume control for a device previously held in exclusive mode
+func (sf *wcaSessionFinder) handleExclusiveModeEnded(deviceID string) {
+	sf.mu.Lock()
+	if !sf.exclusiveDevices[deviceID] {
+		sf.mu.Unlock()
+		return
+	}
+	delete(sf.exclusiveDevices, deviceID)
+	deviceMaster := sf.setDeviceExclusiveMode(deviceID, false)
+	sf.mu.Unlock()
+
+	sf.logger.Infow("Device no longer in exclusive mode", "deviceID", deviceID)
+	sf.emitSessionEvent(SessionEvent{Type: SessionEventExclusiveModeEnded, Session: deviceMaster, SessionID: "device_" + deviceID})
+}
+
+// setDeviceExclusiveMode flags every master session backed by the given device and returns
+// that device's own master session, if any. must be called with sf.mu held
+func (sf *wcaSessionFinder) setDeviceExclusiveMode(deviceID string, exclusive bool) Session {
+	if sf.masterOut != nil && sf.masterOutDeviceID == deviceID {
+		sf.masterOut.setExclusiveMode(exclusive)
+	}
+
+	dm, ok := sf.deviceManagers[deviceID]
+	if !ok || dm.masterSession == nil {
+		return nil
+	}
+
+	dm.masterSession.setExclusiveMode(exclusive)
+
+	return dm.masterSession
+}
+
 func (sf *wcaSessionFinder) removeSession(sessionID string) {
 	sf.mu.Lock()
 	tracked, exists := sf.trackedSessions[sessionID]
@@ -532,6 +753,7 @@ func (sf *wcaSessionFinder) removeDeviceManager(deviceID string) {
 		return
 	}
 	delete(sf.deviceManagers, deviceID)
+	delete(sf.exclusiveDevices, deviceID)
 	sf.mu.Unlock()
 
 	// Remove all sessions associated with this device
@@ -569,7 +791,7 @@ func (sf *wcaSessionFinder) removeDeviceManager(deviceID string) {
 	sf.logger.Debugw("Removed device manager", "deviceID", deviceID)
 }
 
-func (sf *wcaSessionFinder) cleanup() {
+func (sf *wcaSessionFinder) removeAllDeviceManagers() {
 	sf.mu.Lock()
 	deviceIDs := make([]string, 0, len(sf.deviceManagers))
 	for id := range sf.deviceManagers {
@@ -580,13 +802,17 @@ func (sf *wcaSessionFinder) cleanup() {
 	for _, id := range deviceIDs {
 		sf.removeDeviceManager(id)
 	}
+}
+
+func (sf *wcaSessionFinder) cleanup() {
+	sf.removeAllDeviceManagers()
 
 	if sf.mmDeviceEnumerator != nil {
 		sf.mmDeviceEnumerator.Release()
 	}
 }
 
-func (sf *wcaSessionFinder) createDeviceMasterSession(device *wca.IMMDevice) (*masterSession, error) {
+func (sf *wcaSessionFinder) createDeviceMasterSession(device *wca.IMMDevice, isOutput bool) (*masterSession, error) {
 	// Get device properties for friendly name
 	var propertyStore *wca.IPropertyStore
 	if err := device.OpenPropertyStore(wca.STGM_READ, &propertyStore); err != nil {
@@ -605,17 +831,22 @@ func (sf *wcaSessionFinder) createDeviceMasterSession(device *wca.IMMDevice) (*m
 	}
 	endpointFriendlyName := value.String()
 
-	return sf.getMasterSession(device, endpointFriendlyName, fmt.Sprintf(deviceSessionFormat, endpointDescription))
+	return sf.getMasterSession(device, endpointFriendlyName, fmt.Sprintf(deviceSessionFormat, endpointDescription), isOutput)
 }
 
-func (sf *wcaSessionFinder) getMasterSession(mmDevice *wca.IMMDevice, key string, loggerKey string) (*masterSession, error) {
+func (sf *wcaSessionFinder) getMasterSession(mmDevice *wca.IMMDevice, key string, loggerKey string, isOutput bool) (*masterSession, error) {
+	var deviceID string
+	if err := mmDevice.GetId(&deviceID); err != nil {
+		return nil, fmt.Errorf("get device id: %w", err)
+	}
+
 	var audioEndpointVolume *wca.IAudioEndpointVolume
 
 	if err := mmdActivateWorkaround(mmDevice, wca.IID_IAudioEndpointVolume, wca.CLSCTX_ALL, nil, &audioEndpointVolume); err != nil {
 		return nil, fmt.Errorf("activate AudioEndpointVolume: %w", err)
 	}
 
-	master, err := newMasterSession(sf.sessionLogger, audioEndpointVolume, sf.eventCtx, key, loggerKey)
+	master, err := newMasterSession(sf.sessionLogger, audioEndpointVolume, sf.eventCtx, key, loggerKey, deviceID, isOutput)
 	if err != nil {
 		audioEndpointVolume.Release()
 		return nil, fmt.Errorf("create master session: %w", err)
@@ -629,10 +860,126 @@ func (sf *wcaSessionFinder) SubscribeToSessionEvents() <-chan SessionEvent {
 	return sf.sessionEventChan
 }
 
+// Rescan drops all device managers and their sessions, then enumerates everything again
+func (sf *wcaSessionFinder) Rescan() error {
+	done := make(chan struct{})
+
+	if !sf.dispatchWork(func() {
+		defer close(done)
+
+		sf.logger.Debug("Rescanning sessions")
+
+		sf.rebuildDeviceManagers()
+
+		// refreshing also releases the previous master sessions
+		sf.initializeMasterSessions()
+	}) {
+		return errors.New("session finder is too busy to rescan")
+	}
+
+	select {
+	case <-done:
+		return nil
+	case <-time.After(rescanTimeout):
+		return errRescanTimeout
+	}
+}
+
+// SetDefaultOutputDevice makes the active output device with the given friendly name the system's default
+func (sf *wcaSessionFinder) SetDefaultOutputDevice(name string) error {
+	result := make(chan error, 1)
+
+	if !sf.dispatchWork(func() {
+		result <- sf.setDefaultOutputDevice(name)
+	}) {
+		return errors.New("session finder is too busy to set the default device")
+	}
+
+	select {
+	case err := <-result:
+		return err
+	case <-time.After(rescanTimeout):
+		return errors.New("timed out waiting for the default device to be set")
+	}
+}
+
+// setDefaultOutputDevice does the actual work of SetDefaultOutputDevice, on the COM worker
+func (sf *wcaSessionFinder) setDefaultOutputDevice(name string) error {
+	var deviceCollection *wca.IMMDeviceCollection
+
+	if err := sf.mmDeviceEnumerator.EnumAudioEndpoints(wca.ERender, wca.DEVICE_STATE_ACTIVE, &deviceCollection); err != nil {
+		return fmt.Errorf("enumerate audio endpoints: %w", err)
+	}
+	defer deviceCollection.Release()
+
+	var deviceCount uint32
+	if err := deviceCollection.GetCount(&deviceCount); err != nil {
+		return fmt.Errorf("get device count: %w", err)
+	}
+
+	for i := uint32(0); i < deviceCount; i++ {
+		var device *wca.IMMDevice
+		if err := deviceCollection.Item(i, &device); err != nil {
+			sf.logger.Warnw("Failed to get device from collection", "index", i, "error", err)
+			continue
+		}
+
+		friendlyName, err := deviceFriendlyName(device)
+		if err != nil || !strings.EqualFold(friendlyName, name) {
+			device.Release()
+			continue
+		}
+
+		var deviceID string
+		err = device.GetId(&deviceID)
+		device.Release()
+
+		if err != nil {
+			return fmt.Errorf("get device ID: %w", err)
+		}
+
+		if err := win.SetDefaultDevice(deviceID); err != nil {
+			return fmt.Errorf("set default device: %w", err)
+		}
+
+		sf.logger.Infow("Set default output device", "name", friendlyName, "deviceID", deviceID)
+
+		return nil
+	}
+
+	return fmt.Errorf("no active output device named %q", name)
+}
+
+func deviceFriendlyName(device *wca.IMMDevice) (string, error) {
+	var propertyStore *wca.IPropertyStore
+	if err := device.OpenPropertyStore(wca.STGM_READ, &propertyStore); err != nil {
+		return "", fmt.Errorf("open property store: %w", err)
+	}
+	defer propertyStore.Release()
+
+	value := &wca.PROPVARIANT{}
+	if err := propertyStore.GetValue(&wca.PKEY_Device_FriendlyName, value); err != nil {
+		return "", fmt.Errorf("get friendly name: %w", err)
+	}
+
+	return value.String(), nil
+}
+
 func (sf *wcaSessionFinder) Release() error {
 	sf.workerCancel()
 
 	if sf.mmDeviceEnumerator != nil {
+
+		// the enumerator holds a reference to the notification client until it's unregistered, which would
+		// otherwise keep a dead finder's callbacks around every time the finder gets restarted
+		if sf.mmNotificationClient != nil {
+			if err := win.UnregisterEndpointNotificationCallback(sf.mmDeviceEnumerator, sf.mmNotificationClient); err != nil {
+				sf.logger.Warnw("Failed to unregister endpoint notification callback", "error", err)
+			} else {
+				sf.logger.Debugw("Unregistered endpoint notification callback", "refCount", sf.mmNotificationClient.RefCount())
+			}
+		}
+
 		sf.mmDeviceEnumerator.Release()
 	}
 
@@ -681,18 +1028,25 @@ func (sf *wcaSessionFinder) refreshMasterOutput() {
 		sf.masterOut.Release()
 		sf.masterOut = nil
 		sf.masterOutID = ""
+		sf.masterOutDeviceID = ""
 	}
 
-	// Get new default output device
-	var mmOutDevice *wca.IMMDevice
-	if err := sf.mmDeviceEnumerator.GetDefaultAudioEndpoint(wca.ERender, wca.EConsole, &mmOutDevice); err != nil {
+	// Get new default (or pinned) output device
+	sf.masterOutPinnedID = sf.config.MasterOutputDeviceID
+
+	if !sf.config.RegisterMaster {
+		return
+	}
+
+	mmOutDevice, err := sf.getMasterDevice(wca.ERender, sf.masterOutPinnedID)
+	if err != nil {
 		sf.logger.Warnw("Failed to get new default output endpoint", "error", err)
 		return
 	}
 	defer mmOutDevice.Release()
 
 	// Create new master output session
-	masterOut, err := sf.getMasterSession(mmOutDevice, masterSessionName, masterSessionName)
+	masterOut, err := sf.getMasterSession(mmOutDevice, masterSessionName, masterSessionName, true)
 	if err != nil {
 		sf.logger.Warnw("Failed to create new master output session", "error", err)
 		return
@@ -701,6 +1055,12 @@ func (sf *wcaSessionFinder) refreshMasterOutput() {
 	sf.masterOut = masterOut
 	sf.masterOutID = "master_output"
 
+	if err := mmOutDevice.GetId(&sf.masterOutDeviceID); err != nil {
+		sf.logger.Debugw("Failed to get default output device ID", "error", err)
+	} else if sf.exclusiveDevices[sf.masterOutDeviceID] {
+		masterOut.setExclusiveMode(true)
+	}
+
 	sf.emitSessionEvent(SessionEvent{Type: SessionEventAdded, Session: masterOut, SessionID: sf.masterOutID})
 
 	sf.logger.Debug("Refreshed master output session for new default device")
@@ -721,16 +1081,22 @@ func (sf *wcaSessionFinder) refreshMasterInput() {
 		sf.masterInID = ""
 	}
 
-	// Get new default input device
-	var mmInDevice *wca.IMMDevice
-	if err := sf.mmDeviceEnumerator.GetDefaultAudioEndpoint(wca.ECapture, wca.EConsole, &mmInDevice); err != nil {
-		sf.logger.Debug("No default input device available after change")
+	// Get new default (or pinned) input device
+	sf.masterInPinnedID = sf.config.MasterInputDeviceID
+
+	if !sf.config.RegisterMaster {
+		return
+	}
+
+	mmInDevice, err := sf.getMasterDevice(wca.ECapture, sf.masterInPinnedID)
+	if err != nil {
+		sf.logger.Debugw("No default input device available after change", "error", err)
 		return
 	}
 	defer mmInDevice.Release()
 
 	// Create new master input session
-	masterIn, err := sf.getMasterSession(mmInDevice, inputSessionName, inputSessionName)
+	masterIn, err := sf.getMasterSession(mmInDevice, inputSessionName, inputSessionName, false)
 	if err != nil {
 		sf.logger.Warnw("Failed to create new master input session", "error", err)
 		return
@@ -744,6 +1110,69 @@ func (sf *wcaSessionFinder) refreshMasterInput() {
 	sf.logger.Debug("Refreshed master input session for new default device")
 }
 
+// getMasterDevice returns the device with the given ID if one is pinned, or the default device for the given flow.
+// a pinned device that can't be found falls back to the default one
+func (sf *wcaSessionFinder) getMasterDevice(flow uint32, pinnedDeviceID string) (*wca.IMMDevice, error) {
+	var mmDevice *wca.IMMDevice
+
+	if pinnedDeviceID != "" {
+		err := win.GetDevice(sf.mmDeviceEnumerator, pinnedDeviceID, &mmDevice)
+		if err == nil {
+			return mmDevice, nil
+		}
+
+		sf.logger.Warnw("Failed to get pinned device, using default device instead", "deviceID", pinnedDeviceID, "error", err)
+	}
+
+	if err := sf.mmDeviceEnumerator.GetDefaultAudioEndpoint(flow, wca.EConsole, &mmDevice); err != nil {
+		return nil, fmt.Errorf("get default audio endpoint: %w", err)
+	}
+
+	return mmDevice, nil
+}
+
+// setupOnConfigReload recreates the master sessions whenever the devices they're pinned to change,
+// or they're turned on or off
+func (sf *wcaSessionFinder) setupOnConfigReload() {
+	configReloadedChannel := sf.config.SubscribeToChanges()
+
+	go func() {
+		defer sf.config.UnsubscribeFromChanges(configReloadedChannel)
+
+		for {
+			select {
+			case <-sf.workerCtx.Done():
+				return
+			case <-configReloadedChannel:
+			}
+
+			sf.dispatchWork(func() {
+				if sf.config.MasterOutputDeviceID != sf.masterOutPinnedID {
+					sf.logger.Debugw("Pinned output device changed", "deviceID", sf.config.MasterOutputDeviceID)
+					sf.refreshMasterOutput()
+				}
+
+				if sf.needsDeviceSessions() != sf.deviceSessions {
+					sf.logger.Debugw("Device targets changed, rebuilding device managers", "deviceSessions", !sf.deviceSessions)
+					sf.rebuildDeviceManagers()
+				}
+
+				if sf.config.MasterInputDeviceID != sf.masterInPinnedID {
+					sf.logger.Debugw("Pinned input device changed", "deviceID", sf.config.MasterInputDeviceID)
+					sf.refreshMasterInput()
+				}
+
+				// the system sounds session lives among the device's sessions, so those need rebuilding too
+				if sf.config.RegisterMaster != sf.masterRegistered {
+					sf.logger.Debugw("Master session registration changed", "registerMaster", sf.config.RegisterMaster)
+					sf.rebuildDeviceManagers()
+					sf.initializeMasterSessions()
+				}
+			})
+		}
+	}()
+}
+
 func (sf *wcaSessionFinder) deviceAddedCallback(pwstrDeviceID string) error {
 	sf.logger.Debugw("Device added", "deviceID", pwstrDeviceID)
 	sf.dispatchWork(func() {
@@ -763,6 +1192,16 @@ func (sf *wcaSessionFinder) handleDeviceAdded(pwstrDeviceID string) {
 		sf.logger.Warnw("Failed to create device manager for added device", "deviceID", pwstrDeviceID, "error", err)
 		device.Release()
 	}
+
+	// there was no output device at all until now, and a default device change to go with this one isn't a given
+	sf.mu.RLock()
+	noMasterOutput := sf.masterOut == nil
+	sf.mu.RUnlock()
+
+	if noMasterOutput {
+		sf.logger.Infow("Audio device became available, picking up the default output device", "deviceID", pwstrDeviceID)
+		sf.refreshMasterOutput()
+	}
 }
 
 func (sf *wcaSessionFinder) deviceRemovedCallback(pwstrDeviceID string) error {
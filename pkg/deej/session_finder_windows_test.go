@@ -0,0 +1,114 @@
+package deej
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"testing"
+
+	ole "github.com/go-ole/go-ole"
+	"go.uber.org/zap"
+)
+
+func TestSessionProcess(t *testing.T) {
+	const sFalse = 1
+
+	tests := []struct {
+		name          string
+		systemSounds  error
+		pid           uint32
+		pidErr        error
+		expectSystem  bool
+		expectPID     uint32
+		expectFailure bool
+	}{
+		{"system sounds", nil, 0, nil, true, 0, false},
+
+		// whatever GetProcessId would have said, asking for system sounds decides
+		{"system sounds with a PID", nil, 1234, nil, true, 0, false},
+		{"system sounds without a PID", nil, 0, errors.New("no process"), true, 0, false},
+
+		{"app", ole.NewError(sFalse), 1234, nil, false, 1234, false},
+		{"app shared by processes", ole.NewError(sFalse), 1234, ole.NewError(audclntSNoSingleProcess), false, 1234, false},
+		{"app without a PID", ole.NewError(sFalse), 0, ole.NewError(0x80004005), false, 0, true},
+	}
+
+	for _, test := range tests {
+		system, pid, err := sessionProcess(
+			func() error { return test.systemSounds },
+			func(pid *uint32) error {
+				*pid = test.pid
+				return test.pidErr
+			},
+		)
+
+		if (err != nil) != test.expectFailure {
+			t.Errorf("%s: err = %v, expected failure: %v", test.name, err, test.expectFailure)
+			continue
+		}
+
+		if system != test.expectSystem || pid != test.expectPID {
+			t.Errorf("%s: system = %v, pid = %d, expected %v and %d", test.name, system, pid, test.expectSystem, test.expectPID)
+		}
+
+		// system sounds go by "system" no matter the PID, so a slider mapped to it finds them
+		if session := (&baseSession{system: system, pid: pid, name: "svchost.exe"}); system && session.Key() != systemSessionName {
+			t.Errorf("%s: key = %q, expected %q", test.name, session.Key(), systemSessionName)
+		}
+	}
+}
+
+// BenchmarkInitializeDeviceManagers compares a full device refresh with and without device targets mapped.
+// it needs a working audio stack, and is skipped without one
+func BenchmarkInitializeDeviceManagers(b *testing.B) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	if err := ole.CoInitializeEx(0, ole.COINIT_MULTITHREADED); err != nil {
+		b.Skipf("initialize COM: %v", err)
+	}
+	defer ole.CoUninitialize()
+
+	for _, bench := range []struct {
+		name   string
+		config string
+	}{
+		{"AppTargets", "slider_mapping:\n  0: master\n  1: spotify.exe\n  2: deej.unmapped\n"},
+		{"DeviceTargets", "slider_mapping:\n  0: master\n  1: spotify.exe\n  2: deej.allmaster\n"},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			d := newTestDeej(b, bench.config)
+
+			sf := &wcaSessionFinder{
+				logger:           zap.NewNop().Sugar(),
+				sessionLogger:    zap.NewNop().Sugar(),
+				config:           d.config,
+				eventCtx:         ole.NewGUID(myteriousGUID),
+				deviceManagers:   make(map[string]*deviceSessionManager),
+				trackedSessions:  make(map[string]*trackedSession),
+				exclusiveDevices: make(map[string]bool),
+				sessionEventChan: make(chan SessionEvent, sessionEventChanSize),
+				workChan:         make(chan func(), deviceWorkChanSize),
+				workerCtx:        context.Background(),
+			}
+
+			if err := sf.initializeDeviceEnumerator(); err != nil {
+				b.Skipf("initialize device enumerator: %v", err)
+			}
+			defer sf.cleanup()
+
+			for b.Loop() {
+				if err := sf.initializeAllDeviceManagers(); err != nil {
+					b.Fatalf("initialize device managers: %v", err)
+				}
+
+				sf.removeAllDeviceManagers()
+
+				// nothing reads the events here, don't let them pile up
+				for len(sf.sessionEventChan) > 0 {
+					<-sf.sessionEventChan
+				}
+			}
+		})
+	}
+}
@@ -16,6 +16,9 @@ type paSession struct {
 
 	processName string
 
+	// names from the other session key properties, see AlternateKeys
+	alternateKeys []string
+
 	client *proto.Client
 
 	sinkInputIndex    uint32
@@ -38,6 +41,7 @@ func newPASession(
 	sinkInputIndex uint32,
 	sinkInputChannels byte,
 	processName string,
+	pid uint32,
 ) *paSession {
 
 	s := &paSession{
@@ -47,6 +51,8 @@ func newPASession(
 	}
 
 	s.processName = processName
+	s.pid = pid
+	s.id = fmt.Sprintf("sink-input|%d", sinkInputIndex)
 	s.name = processName
 	s.humanReadableDesc = processName
 
@@ -57,6 +63,11 @@ func newPASession(
 	return s
 }
 
+// AlternateKeys returns the session's names from the session key properties other than the one it's keyed by
+func (s *paSession) AlternateKeys() []string {
+	return s.alternateKeys
+}
+
 func newMasterSession(
 	logger *zap.SugaredLogger,
 	client *proto.Client,
@@ -74,6 +85,12 @@ func newMasterSession(
 	return newNamedMasterSession(logger, client, streamIndex, streamChannels, isOutput, key)
 }
 
+// sameStream tells whether the session already controls the given stream over the given connection, in which
+// case there's nothing to gain from replacing it. a nil session controls nothing
+func (s *masterSession) sameStream(client *proto.Client, streamIndex uint32, streamChannels byte) bool {
+	return s != nil && s.client == client && s.streamIndex == streamIndex && s.streamChannels == streamChannels
+}
+
 func newNamedMasterSession(
 	logger *zap.SugaredLogger,
 	client *proto.Client,
@@ -94,11 +111,22 @@ func newNamedMasterSession(
 	s.name = name
 	s.humanReadableDesc = name
 
+	// "master" and the sink's own named session control the same sink, so the name is part of it
+	if isOutput {
+		s.id = fmt.Sprintf("%s|sink|%d", name, streamIndex)
+	} else {
+		s.id = fmt.Sprintf("%s|source|%d", name, streamIndex)
+	}
+
 	s.logger.Debugw(sessionCreationLogMessage, "session", s)
 
 	return s
 }
 
+func (s *masterSession) IsOutputDevice() bool {
+	return s.isOutput
+}
+
 func (s *paSession) GetVolume() float32 {
 	request := proto.GetSinkInputInfo{
 		SinkInputIndex: s.sinkInputIndex,
@@ -115,22 +143,67 @@ func (s *paSession) GetVolume() float32 {
 }
 
 func (s *paSession) SetVolume(v float32) error {
-	volumes := createChannelVolumes(s.sinkInputChannels, v)
-	request := proto.SetSinkInputVolume{
+	if err := s.setChannelVolumes(createChannelVolumes(s.sinkInputChannels, v)); err != nil {
+		s.logger.Warnw("Failed to set session volume", "error", err)
+		return fmt.Errorf("adjust session volume: %w", err)
+	}
+
+	s.logger.Debugw("Adjusting session volume", "to", fmt.Sprintf("%.2f", v))
+
+	return nil
+}
+
+func (s *paSession) GetMute() bool {
+	request := proto.GetSinkInputInfo{
 		SinkInputIndex: s.sinkInputIndex,
-		ChannelVolumes: volumes,
+	}
+	reply := proto.GetSinkInputInfoReply{}
+
+	if err := s.client.Request(&request, &reply); err != nil {
+		s.logger.Warnw("Failed to get session mute state", "error", err)
+	}
+
+	return reply.Muted
+}
+
+func (s *paSession) SetMute(m bool) error {
+	request := proto.SetSinkInputMute{
+		SinkInputIndex: s.sinkInputIndex,
+		Mute:           m,
 	}
 
 	if err := s.client.Request(&request, nil); err != nil {
-		s.logger.Warnw("Failed to set session volume", "error", err)
-		return fmt.Errorf("adjust session volume: %w", err)
+		s.logger.Warnw("Failed to set session mute state", "error", err)
+		return fmt.Errorf("adjust session mute state: %w", err)
 	}
 
-	s.logger.Debugw("Adjusting session volume", "to", fmt.Sprintf("%.2f", v))
+	s.logger.Debugw("Adjusting session mute state", "to", m)
+
+	return nil
+}
+
+func (s *paSession) SetStereoVolume(left float32, right float32) error {
+	if err := s.setChannelVolumes(createStereoChannelVolumes(s.sinkInputChannels, left, right)); err != nil {
+		s.logger.Warnw("Failed to set session stereo volume", "error", err)
+		return fmt.Errorf("adjust session stereo volume: %w", err)
+	}
+
+	s.logger.Debugw("Adjusting session stereo volume",
+		"left", fmt.Sprintf("%.2f", left),
+		"right", fmt.Sprintf("%.2f", right))
 
 	return nil
 }
 
+func (s *paSession) setChannelVolumes(volumes proto.ChannelVolumes) error {
+	request := proto.SetSinkInputVolume{
+		SinkInputIndex: s.sinkInputIndex,
+		ChannelVolumes: volumes,
+	}
+
+	return s.client.Request(&request, nil)
+}
+
 func (s *paSession) Release() {
 	s.logger.Debug("Releasing audio session")
 }
@@ -140,8 +213,30 @@ func (s *paSession) String() string {
 }
 
 func (s *masterSession) GetVolume() float32 {
-	var level float32
+	volumes, err := s.getChannelVolumes()
+	if err != nil {
+		s.logger.Warnw("Failed to get session volume", "error", err)
+		return 0
+	}
+
+	return parseChannelVolumes(volumes)
+}
+
+func (s *masterSession) SetVolume(v float32) error {
+	if err := s.setChannelVolumes(createChannelVolumes(s.streamChannels, v)); err != nil {
+		s.logger.Warnw("Failed to set session volume",
+			"error", err,
+			"volume", v)
+
+		return fmt.Errorf("adjust session volume: %w", err)
+	}
+
+	s.logger.Debugw("Adjusting session volume", "to", fmt.Sprintf("%.2f", v))
+
+	return nil
+}
 
+func (s *masterSession) GetMute() bool {
 	if s.isOutput {
 		request := proto.GetSinkInfo{
 			SinkIndex: s.streamIndex,
@@ -149,33 +244,128 @@ func (s *masterSession) GetVolume() float32 {
 		reply := proto.GetSinkInfoReply{}
 
 		if err := s.client.Request(&request, &reply); err != nil {
-			s.logger.Warnw("Failed to get session volume", "error", err)
-			return 0
+			s.logger.Warnw("Failed to get session mute state", "error", err)
+			return false
 		}
 
-		level = parseChannelVolumes(reply.ChannelVolumes)
+		return reply.Mute
+	}
+
+	request := proto.GetSourceInfo{
+		SourceIndex: s.streamIndex,
+	}
+	reply := proto.GetSourceInfoReply{}
+
+	if err := s.client.Request(&request, &reply); err != nil {
+		s.logger.Warnw("Failed to get session mute state", "error", err)
+		return false
+	}
+
+	return reply.Mute
+}
+
+func (s *masterSession) SetMute(m bool) error {
+	var request proto.RequestArgs
+
+	if s.isOutput {
+		request = &proto.SetSinkMute{
+			SinkIndex: s.streamIndex,
+			Mute:      m,
+		}
 	} else {
-		request := proto.GetSourceInfo{
+		request = &proto.SetSourceMute{
 			SourceIndex: s.streamIndex,
+			Mute:        m,
+		}
+	}
+
+	if err := s.client.Request(request, nil); err != nil {
+		s.logger.Warnw("Failed to set session mute state",
+			"error", err,
+			"mute", m)
+
+		return fmt.Errorf("adjust session mute state: %w", err)
+	}
+
+	s.logger.Debugw("Adjusting session mute state", "to", m)
+
+	return nil
+}
+
+func (s *masterSession) SetStereoVolume(left float32, right float32) error {
+	if err := s.setChannelVolumes(createStereoChannelVolumes(s.streamChannels, left, right)); err != nil {
+		s.logger.Warnw("Failed to set session stereo volume",
+			"error", err,
+			"left", left,
+			"right", right)
+
+		return fmt.Errorf("adjust session stereo volume: %w", err)
+	}
+
+	s.logger.Debugw("Adjusting session stereo volume",
+		"left", fmt.Sprintf("%.2f", left),
+		"right", fmt.Sprintf("%.2f", right))
+
+	return nil
+}
+
+// SetChannelVolume sets one channel of the sink or source, leaving the others as they are
+func (s *masterSession) SetChannelVolume(channel int, v float32) error {
+	volumes, err := s.getChannelVolumes()
+	if err != nil {
+		s.logger.Warnw("Failed to get session channel volumes", "error", err)
+		return fmt.Errorf("get session channel volumes: %w", err)
+	}
+
+	if channel < 0 || channel >= len(volumes) {
+		return fmt.Errorf("no channel %d, session has %d", channel, len(volumes))
+	}
+
+	volumes[channel] = uint32(v * maxVolume)
+
+	if err := s.setChannelVolumes(volumes); err != nil {
+		s.logger.Warnw("Failed to set session channel volume",
+			"error", err,
+			"channel", channel,
+			"volume", v)
+
+		return fmt.Errorf("adjust session channel volume: %w", err)
+	}
+
+	s.logger.Debugw("Adjusting session channel volume", "channel", channel, "to", fmt.Sprintf("%.2f", v))
+
+	return nil
+}
+
+func (s *masterSession) getChannelVolumes() (proto.ChannelVolumes, error) {
+	if s.isOutput {
+		request := proto.GetSinkInfo{
+			SinkIndex: s.streamIndex,
 		}
-		reply := proto.GetSourceInfoReply{}
+		reply := proto.GetSinkInfoReply{}
 
 		if err := s.client.Request(&request, &reply); err != nil {
-			s.logger.Warnw("Failed to get session volume", "error", err)
-			return 0
+			return nil, err
 		}
 
-		level = parseChannelVolumes(reply.ChannelVolumes)
+		return reply.ChannelVolumes, nil
 	}
 
-	return level
+	request := proto.GetSourceInfo{
+		SourceIndex: s.streamIndex,
+	}
+	reply := proto.GetSourceInfoReply{}
+
+	if err := s.client.Request(&request, &reply); err != nil {
+		return nil, err
+	}
+
+	return reply.ChannelVolumes, nil
 }
 
-func (s *masterSession) SetVolume(v float32) error {
+func (s *masterSession) setChannelVolumes(volumes proto.ChannelVolumes) error {
 	var request proto.RequestArgs
 
-	volumes := createChannelVolumes(s.streamChannels, v)
-
 	if s.isOutput {
 		request = &proto.SetSinkVolume{
 			SinkIndex:      s.streamIndex,
@@ -188,17 +378,7 @@ func (s *masterSession) SetVolume(v float32) error {
 		}
 	}
 
-	if err := s.client.Request(request, nil); err != nil {
-		s.logger.Warnw("Failed to set session volume",
-			"error", err,
-			"volume", v)
-
-		return fmt.Errorf("adjust session volume: %w", err)
-	}
-
-	s.logger.Debugw("Adjusting session volume", "to", fmt.Sprintf("%.2f", v))
-
-	return nil
+	return s.client.Request(request, nil)
 }
 
 func (s *masterSession) Release() {
@@ -219,6 +399,17 @@ func createChannelVolumes(channels byte, volume float32) []uint32 {
 	return volumes
 }
 
+// createStereoChannelVolumes balances left and right channels separately, see stereoChannelVolume
+func createStereoChannelVolumes(channels byte, left float32, right float32) []uint32 {
+	volumes := make([]uint32, channels)
+
+	for i := range volumes {
+		volumes[i] = uint32(stereoChannelVolume(i, int(channels), left, right) * maxVolume)
+	}
+
+	return volumes
+}
+
 func parseChannelVolumes(volumes []uint32) float32 {
 	var level uint32
 
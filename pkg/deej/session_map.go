@@ -2,10 +2,16 @@ package deej
 
 import (
 	"fmt"
+	"math"
 	"regexp"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/nicksnyder/go-i18n/v2/i18n"
 	"github.com/nik9play/deej/pkg/deej/util"
 	"github.com/thoas/go-funk"
 	"go.uber.org/zap"
@@ -18,12 +24,86 @@ type sessionMap struct {
 	m    map[string][]Session
 	lock sync.Locker
 
+	// sessions by their alternate keys (see aliasedSession), which only get looks at. kept apart from m
+	// so everything iterating m still sees every session once. guarded by lock
+	aliases map[string][]Session
+
 	sessionFinder SessionFinder
 
+	// creates the finder restartSessionFinder swaps in, of the same kind deej started out with
+	createSessionFinder func() (SessionFinder, error)
+
+	// closed to stop handling events from the current session finder, once it's being replaced
+	sessionEventsStop chan struct{}
+
+	// serializes session finder restarts
+	finderRestartLock sync.Mutex
+
+	browserTabs *browserTabController
+	mpris       *mprisController
+	execTargets *execTargetRunner
+
+	// in-flight volume ramps by session ID, so a newer slider move can take over from an older one
+	volumeRamps    map[string]*volumeRamp
+	volumeRampLock sync.Mutex
+
+	// compiled patterns of the config's regex targets, rebuilt whenever it's reloaded.
+	// patterns that don't compile are kept as nil so they're only warned about once
+	regexTargets     map[string]*regexp.Regexp
+	regexTargetsLock sync.Mutex
+
+	// sessions no slider is mapped to, for 'deej.unmapped'. only touched while holding lock, since
+	// session events append and remove from it while slider moves read it
 	unmappedSessions []Session
 
+	// last known position of every slider that's part of a stereo pair
+	stereoSliderValues map[int]float32
+
+	// the OBS scene each slider with scene switches last switched to
+	obsScenes map[int]string
+
+	// the apps each 'deej.current.locked' slider latched onto
+	currentWindowLocks     map[int]*currentWindowLock
+	currentWindowLocksLock sync.Mutex
+
+	// which end of the slider every key and setdefault target was last at, so they only act on arriving there
+	targetEnds     map[string]int
+	targetEndsLock sync.Mutex
+
+	// when each on_missing_target program was last launched, keyed by path
+	missingTargetLaunches     map[string]time.Time
+	missingTargetLaunchesLock sync.Mutex
+
+	lastSessionRefresh time.Time
+
+	// when a refresh last went through without an error, for the HTTP API's health check
+	lastSuccessfulRefresh time.Time
+	autoRescanStop        chan struct{}
+
+	// consecutive refreshes that were slow or timed out, and whether that's been reported as a stall
+	slowRefreshes int
+	finderStalled bool
+
 	// channel for notifying about session count changes
 	sessionCountChangeChan chan struct{}
+
+	// channel for notifying about the session finder stalling or recovering
+	finderStallChangeChan chan struct{}
+
+	// why sessions couldn't be acquired the last time deej tried, or nil if that went fine
+	sessionError           error
+	sessionErrorChangeChan chan struct{}
+
+	// subscribers to volume changes made outside of deej
+	volumeChangeConsumers     []chan VolumeChangeEvent
+	volumeChangeConsumersLock sync.Mutex
+}
+
+// VolumeChangeEvent reports a session's volume being changed by something other than deej,
+// i.e. the windows volume mixer or the app itself
+type VolumeChangeEvent struct {
+	Key    string
+	Volume float32
 }
 
 const (
@@ -38,29 +118,173 @@ const (
 	// obs targets are handled directly via OBS WebSocket API
 	obsTargetPrefix = "deej.obs:"
 
+	// mutes an OBS input while the slider is all the way down, i.e. "deej.obs.mute:Mic/Aux"
+	obsMuteTargetPrefix = "deej.obs.mute:"
+
+	// sets a setting of a filter on an OBS source to the slider's position (0.0 to 1.0),
+	// i.e. "deej.obs.filter:Webcam:Color Correction:opacity"
+	obsFilterTargetPrefix = "deej.obs.filter:"
+
 	// targets the currently active window (Windows-only, experimental)
 	specialTargetCurrentWindow = "current"
 
 	// targets the currently active fullscreen window (Windows-only, experimental)
 	specialTargetCurrentFullscreenWindow = "current.fullscreen"
 
+	// targets the window that was active when the slider started moving, and keeps targeting it until the
+	// slider sits still for current_window_lock_ms. alt-tabbing mid-adjustment doesn't take the slider along
+	specialTargetCurrentWindowLocked = "current.locked"
+
 	// targets all currently unmapped sessions (experimental)
 	specialTargetAllUnmapped = "unmapped"
+
+	// targets the master volume of every output device at once, i.e. speakers and a headset together
+	specialTargetAllMaster = "allmaster"
+
+	// targets the sessions of a single process by its ID, i.e. "deej.pid:1234"
+	pidTargetPrefix = "deej.pid:"
+
+	// targets media in a browser's focused tab, i.e. "deej.tab:chrome.exe" (experimental).
+	// falls back to the whole browser's session if the tab can't be reached
+	browserTabTargetPrefix    = "deej.tab:"
+	browserTabTransformPrefix = "tab:"
+
+	// targets the active MPRIS media player, or players by name with "deej.mpris:spotify" (Linux-only)
+	mprisTarget       = "deej.mpris"
+	mprisTargetPrefix = "deej.mpris:"
+
+	// mutes its target when the slider is all the way down rather than setting its volume, i.e. "deej.mute:spotify.exe".
+	// the target keeps its volume level, so it comes back at the same volume once unmuted
+	muteTargetPrefix = "deej.mute:"
+
+	// slider positions at or below this count as all the way down for mute targets
+	muteTargetThreshold = 0.02
+
+	// self-mute and deafen in Discord while the slider is all the way down (requires discord.enabled)
+	discordMuteTarget   = "deej.discord.mute"
+	discordDeafenTarget = "deej.discord.deafen"
+
+	// set the gain of a Voicemeeter input strip by index or output bus by name, i.e. "deej.vm.strip:0" or
+	// "deej.vm.bus:A1" (requires voicemeeter.enabled, Windows-only)
+	voicemeeterStripTargetPrefix = "deej.vm.strip:"
+	voicemeeterBusTargetPrefix   = "deej.vm.bus:"
+
+	// sets its target to the slider's position plus a fixed offset, i.e. "deej.offset:discord.exe:+0.2"
+	// keeps discord 20% louder than the slider's other targets
+	offsetTargetPrefix = "deej.offset:"
+
+	// squeezes the slider's whole travel into part of its target's volume range, i.e. "deej.range:spotify.exe:0.05:0.7"
+	// keeps spotify between 5% and 70%. applied after inversion, so an inverted slider's top end still gives the max
+	rangeTargetPrefix = "deej.range:"
+
+	// sets a single channel of its target, i.e. "deej.channel:master:0" for the left channel of master.
+	// only master and mic support this
+	channelTargetPrefix = "deej.channel:"
+
+	// targets every session whose name matches a regular expression, i.e. "deej.regex:^(chrome|msedge|brave).*\.exe$".
+	// matching ignores case
+	regexTargetPrefix = "deej.regex:"
+
+	// targets sessions by the name their app gives them rather than by executable, i.e. "deej.title:Slack"
+	// to tell apart two apps that both run as electron.exe. falls back to the executable if no session has that name
+	titleTargetPrefix = "deej.title:"
+
+	// runs a shell command from the config's exec_commands, i.e. "deej.exec:lamp". off unless allow_exec_targets is set
+	execTargetPrefix = "deej.exec:"
+
+	// presses a media key when the slider is pushed all the way up, i.e. "deej.key:medianext", or one key for
+	// either end, i.e. "deej.key:mediaprev:medianext". the slider has to leave that end before it presses the key again
+	keyTargetPrefix = "deej.key:"
+
+	// targets a Store app by its package name or family name, i.e. "deej.uwp:Microsoft.ZuneMusic" (Windows-only).
+	// their sessions carry these as alternate keys, as the executable doesn't always identify them
+	uwpTargetPrefix = "deej.uwp:"
+
+	// makes a device the default output device when the slider is pushed all the way up,
+	// i.e. "deej.setdefault:Headphones (Realtek Audio)" (Windows-only)
+	setDefaultTargetPrefix = "deej.setdefault:"
+
+	// don't let non-forced refreshes hammer the session finder
+	minTimeBetweenSessionRefreshes = time.Second * 5
+
+	// refreshes slower than this count towards considering the session finder stalled
+	slowSessionRefreshThreshold = time.Second * 3
+
+	// how many slow refreshes in a row it takes to consider the session finder stalled
+	slowSessionRefreshesUntilStalled = 3
+
+	// how many sessions get their volume read and set at once. each one is a COM round trip on windows,
+	// which adds up for targets like a browser with dozens of sessions
+	maxParallelVolumeChanges = 8
+
+	// programs launched for a missing target usually take a while to open an audio session,
+	// keep moving the slider meanwhile from starting them over and over
+	minTimeBetweenMissingTargetLaunches = 30 * time.Second
+
+	// volume ramps take a step this often
+	volumeRampStepInterval = 10 * time.Millisecond
+
+	// a slider has to be this far into a scene's range before switching OBS over to it,
+	// so jitter around a boundary doesn't flip back and forth between two scenes
+	obsSceneSwitchMargin = 0.03
+
+	// how many volume changes made outside of deej each subscriber can fall behind by
+	volumeChangeQueueSize = 16
 )
 
+// sliderSessionVolume is a session a slider currently controls, and the volume that session is actually at
+type sliderSessionVolume struct {
+	sliderID int
+	key      string
+	volume   float32
+}
+
+// currentWindowLock is what a 'deej.current.locked' slider latched onto, and when it last moved
+type currentWindowLock struct {
+	processNames []string
+	lastMove     time.Time
+}
+
+// volumeRamp is a volume change being spread out over time
+type volumeRamp struct {
+	cancel chan struct{}
+	done   chan struct{}
+}
+
 // this matches friendly device names (on Windows), e.g. "Headphones (Realtek Audio)"
 var deviceSessionKeyPattern = regexp.MustCompile(`^.+ \(.+\)$`)
 
-func newSessionMap(deej *Deej, logger *zap.SugaredLogger, sessionFinder SessionFinder) (*sessionMap, error) {
+func newSessionMap(
+	deej *Deej,
+	logger *zap.SugaredLogger,
+	sessionFinder SessionFinder,
+	createSessionFinder func() (SessionFinder, error),
+) (*sessionMap, error) {
 	logger = logger.Named("sessions")
 
 	m := &sessionMap{
 		deej:                   deej,
 		logger:                 logger,
 		m:                      make(map[string][]Session),
+		aliases:                make(map[string][]Session),
+		stereoSliderValues:     make(map[int]float32),
+		targetEnds:             make(map[string]int),
+		missingTargetLaunches:  make(map[string]time.Time),
+		obsScenes:              make(map[int]string),
+		currentWindowLocks:     make(map[int]*currentWindowLock),
 		lock:                   &sync.Mutex{},
 		sessionFinder:          sessionFinder,
+		createSessionFinder:    createSessionFinder,
+		browserTabs:            newBrowserTabController(logger),
+		mpris:                  newMPRISController(logger),
+		volumeRamps:            make(map[string]*volumeRamp),
+		regexTargets:           make(map[string]*regexp.Regexp),
+		execTargets:            newExecTargetRunner(logger, deej.config),
 		sessionCountChangeChan: make(chan struct{}, 1),
+		finderStallChangeChan:  make(chan struct{}, 1),
+		sessionErrorChangeChan: make(chan struct{}, 1),
+		autoRescanStop:         make(chan struct{}),
+		sessionEventsStop:      make(chan struct{}),
 	}
 
 	logger.Debug("Created session map instance")
@@ -80,217 +304,1799 @@ func (m *sessionMap) notifySessionCountChange() {
 	}
 }
 
-func (m *sessionMap) initialize() error {
-	m.setupOnSliderMove()
-	m.setupOnSessionEvents(m.sessionFinder)
-	return nil
-}
+func (m *sessionMap) initialize() error {
+	m.setupOnSliderMove()
+	m.setupOnSliderCountChange()
+	m.setupOnSessionEvents(m.sessionFinder, m.sessionEventsStop)
+	m.setupAutoRescan()
+	m.setupRegexTargets()
+	m.setupUnmappedSessions()
+	m.warnIfRemoteSession()
+	return nil
+}
+
+// warnIfRemoteSession lets the user know that volume control might not work as expected over remote desktop
+func (m *sessionMap) warnIfRemoteSession() {
+	if !util.RemoteSession() {
+		return
+	}
+
+	m.logger.Warn("Running in a remote desktop session, per-app volume control may be limited")
+
+	title := m.deej.localizer.MustLocalize(&i18n.LocalizeConfig{
+		DefaultMessage: &i18n.Message{
+			ID:    "RemoteSessionTitle",
+			Other: "Running in a remote desktop session",
+		},
+	})
+	description := m.deej.localizer.MustLocalize(&i18n.LocalizeConfig{
+		DefaultMessage: &i18n.Message{
+			ID:    "RemoteSessionDescription",
+			Other: "Per-app volume control may be limited. You can pin master and mic to specific devices in the config.",
+		},
+	})
+	m.deej.notifier.Notify(notificationError, title, description)
+}
+
+func (m *sessionMap) release() error {
+	close(m.autoRescanStop)
+	m.cancelAllVolumeRamps()
+	m.browserTabs.Close()
+	m.mpris.Close()
+	m.execTargets.Stop()
+
+	if err := m.currentSessionFinder().Release(); err != nil {
+		m.logger.Warnw("Failed to release session finder during session map release", "error", err)
+		return fmt.Errorf("release session finder during release: %w", err)
+	}
+
+	return nil
+}
+
+// setupAutoRescan periodically forces a full session refresh, as a safety net for environments where
+// session events are unreliable. the interval follows the config and is off by default
+func (m *sessionMap) setupAutoRescan() {
+	configReloadedChannel := m.deej.config.SubscribeToChanges()
+
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+
+		resetTicker := func() {
+			ticker.Stop()
+
+			if interval := m.deej.config.AutoRescanInterval; interval > 0 {
+				m.logger.Debugw("Scheduling automatic session rescans", "interval", interval)
+				ticker.Reset(interval)
+			}
+		}
+		resetTicker()
+
+		for {
+			select {
+			case <-m.autoRescanStop:
+				return
+			case <-configReloadedChannel:
+				resetTicker()
+			case <-ticker.C:
+				m.logger.Debug("Automatic session rescan")
+				m.refreshSessions(true)
+			}
+		}
+	}()
+}
+
+// setupRegexTargets compiles the config's regex targets now and again whenever the config is reloaded
+func (m *sessionMap) setupRegexTargets() {
+	configReloadedChannel := m.deej.config.SubscribeToChanges()
+	m.compileRegexTargets()
+
+	go func() {
+		for {
+			select {
+			case <-m.autoRescanStop:
+				return
+			case <-configReloadedChannel:
+				m.compileRegexTargets()
+			}
+		}
+	}()
+}
+
+// setupUnmappedSessions works out again which sessions are unmapped whenever the config is reloaded,
+// as both the slider mapping and unmapped_ignore may have changed
+func (m *sessionMap) setupUnmappedSessions() {
+	configReloadedChannel := m.deej.config.SubscribeToChanges()
+
+	go func() {
+		for {
+			select {
+			case <-m.autoRescanStop:
+				return
+			case <-configReloadedChannel:
+				m.rebuildUnmappedSessions()
+			}
+		}
+	}()
+}
+
+func (m *sessionMap) rebuildUnmappedSessions() {
+	m.lock.Lock()
+	sessions := []Session{}
+	for _, keySessions := range m.m {
+		sessions = append(sessions, keySessions...)
+	}
+	m.lock.Unlock()
+
+	unmapped := []Session{}
+	for _, session := range sessions {
+		if !m.sessionMapped(session) {
+			unmapped = append(unmapped, session)
+		}
+	}
+
+	m.lock.Lock()
+	m.unmappedSessions = unmapped
+	m.lock.Unlock()
+
+	m.logger.Debugw("Rebuilt unmapped sessions", "count", len(unmapped))
+}
+
+// compileRegexTargets replaces the compiled regex target cache with the patterns currently in the config
+func (m *sessionMap) compileRegexTargets() {
+	regexTargets := make(map[string]*regexp.Regexp)
+
+	m.deej.config.SliderMapping.iterate(func(sliderIdx int, targets []string) {
+		for _, target := range targets {
+			pattern, ok := parseRegexTarget(target)
+			if !ok {
+				continue
+			}
+
+			if _, seen := regexTargets[pattern]; seen {
+				continue
+			}
+
+			compiled, err := regexp.Compile("(?i)" + pattern)
+			if err != nil {
+				m.logger.Warnw("Invalid regex target, skipping", "slider", sliderIdx, "pattern", pattern, "error", err)
+			}
+
+			// invalid patterns are stored as nil so they don't get compiled again on every slider move
+			regexTargets[pattern] = compiled
+		}
+	})
+
+	m.regexTargetsLock.Lock()
+	m.regexTargets = regexTargets
+	m.regexTargetsLock.Unlock()
+}
+
+// regexTarget returns the compiled pattern of a regex target, compiling it if the cache doesn't know it yet.
+// returns nil for patterns that don't compile
+func (m *sessionMap) regexTarget(pattern string) *regexp.Regexp {
+	m.regexTargetsLock.Lock()
+	defer m.regexTargetsLock.Unlock()
+
+	if compiled, ok := m.regexTargets[pattern]; ok {
+		return compiled
+	}
+
+	compiled, err := regexp.Compile("(?i)" + pattern)
+	if err != nil {
+		m.logger.Warnw("Invalid regex target, skipping", "pattern", pattern, "error", err)
+	}
+
+	m.regexTargets[pattern] = compiled
+
+	return compiled
+}
+
+// refreshSessions clears all known sessions and has the session finder acquire them again.
+// unless forced, this is skipped if another refresh happened very recently
+func (m *sessionMap) refreshSessions(force bool) {
+	m.lock.Lock()
+	now := time.Now()
+	if !force && m.lastSessionRefresh.Add(minTimeBetweenSessionRefreshes).After(now) {
+		m.lock.Unlock()
+		return
+	}
+	m.lastSessionRefresh = now
+	m.lock.Unlock()
+
+	m.logger.Debug("Refreshing sessions")
+
+	start := time.Now()
+	err := m.currentSessionFinder().Rescan()
+	elapsed := time.Since(start)
+
+	if err != nil {
+		m.logger.Warnw("Failed to refresh sessions", "error", err, "elapsed", elapsed)
+	} else {
+		m.logger.Debugw("Refreshed sessions", "elapsed", elapsed)
+	}
+
+	if err == nil {
+		m.lock.Lock()
+		m.lastSuccessfulRefresh = time.Now()
+		m.lock.Unlock()
+	}
+
+	m.trackRefresh(err == nil && elapsed < slowSessionRefreshThreshold)
+	m.setSessionError(err)
+}
+
+// lastRefreshSucceeded returns when sessions were last refreshed without an error, zero if they never were
+func (m *sessionMap) lastRefreshSucceeded() time.Time {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return m.lastSuccessfulRefresh
+}
+
+// SubscribeToSessionErrors returns a channel that's signaled whenever sessionAcquisitionError changes
+func (m *sessionMap) SubscribeToSessionErrors() <-chan struct{} {
+	return m.sessionErrorChangeChan
+}
+
+// sessionAcquisitionError returns why sessions couldn't be acquired the last time, or nil if they could
+func (m *sessionMap) sessionAcquisitionError() error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return m.sessionError
+}
+
+// setSessionError records the outcome of the latest attempt at acquiring sessions, and lets
+// subscribers know when that goes from failing to working or the other way around
+func (m *sessionMap) setSessionError(err error) {
+	m.lock.Lock()
+	changed := (err == nil) != (m.sessionError == nil)
+	m.sessionError = err
+	m.lock.Unlock()
+
+	if !changed {
+		return
+	}
+
+	select {
+	case m.sessionErrorChangeChan <- struct{}{}:
+	default:
+	}
+}
+
+// SubscribeToFinderStallChange returns a channel that's signaled whenever sessionFinderStalled changes
+func (m *sessionMap) SubscribeToFinderStallChange() <-chan struct{} {
+	return m.finderStallChangeChan
+}
+
+// sessionFinderStalled reports whether the session finder has repeatedly failed to keep up with refreshes
+func (m *sessionMap) sessionFinderStalled() bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return m.finderStalled
+}
+
+func (m *sessionMap) currentSessionFinder() SessionFinder {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return m.sessionFinder
+}
+
+// trackRefresh keeps count of slow refreshes, and lets the user know once it looks like the
+// session finder got stuck. a single healthy refresh clears that state again
+func (m *sessionMap) trackRefresh(healthy bool) {
+	m.lock.Lock()
+
+	wasStalled := m.finderStalled
+	if healthy {
+		m.slowRefreshes = 0
+		m.finderStalled = false
+	} else {
+		m.slowRefreshes++
+		m.finderStalled = m.slowRefreshes >= slowSessionRefreshesUntilStalled
+	}
+
+	stalled := m.finderStalled
+	m.lock.Unlock()
+
+	if stalled == wasStalled {
+		return
+	}
+
+	select {
+	case m.finderStallChangeChan <- struct{}{}:
+	default:
+	}
+
+	if !stalled {
+		m.logger.Info("Session finder recovered")
+		return
+	}
+
+	m.logger.Warnw("Session finder looks stalled", "slowRefreshes", slowSessionRefreshesUntilStalled)
+
+	title := m.deej.localizer.MustLocalize(&i18n.LocalizeConfig{
+		DefaultMessage: &i18n.Message{
+			ID:    "SessionFinderStalledTitle",
+			Other: "Audio engine not responding",
+		},
+	})
+	description := m.deej.localizer.MustLocalize(&i18n.LocalizeConfig{
+		DefaultMessage: &i18n.Message{
+			ID:    "SessionFinderStalledDescription",
+			Other: "Volume control may not work. You can restart the audio engine from the tray menu.",
+		},
+	})
+	m.deej.notifier.Notify(notificationError, title, description)
+}
+
+// restartSessionFinder tears down the session finder and creates a fresh one in its place,
+// recovering from an audio engine that got stuck without restarting deej
+func (m *sessionMap) restartSessionFinder() error {
+	m.finderRestartLock.Lock()
+	defer m.finderRestartLock.Unlock()
+
+	m.logger.Info("Restarting session finder")
+
+	// only let go of the old finder once there's a new one, so a failed restart leaves things as they were
+	sessionFinder, err := m.createSessionFinder()
+	if err != nil {
+		m.logger.Errorw("Failed to create session finder during restart", "error", err)
+		m.setSessionError(err)
+		return fmt.Errorf("create new SessionFinder: %w", err)
+	}
+
+	// events from the old finder are no longer of interest, its sessions are dropped all at once below
+	close(m.sessionEventsStop)
+
+	if err := m.currentSessionFinder().Release(); err != nil {
+		m.logger.Warnw("Failed to release session finder during restart", "error", err)
+	}
+
+	m.lock.Lock()
+	m.m = make(map[string][]Session)
+	m.aliases = make(map[string][]Session)
+	m.unmappedSessions = nil
+	m.lock.Unlock()
+
+	m.notifySessionCountChange()
+
+	m.lock.Lock()
+	m.sessionFinder = sessionFinder
+	m.sessionEventsStop = make(chan struct{})
+	sessionEventsStop := m.sessionEventsStop
+	m.lock.Unlock()
+
+	m.setupOnSessionEvents(sessionFinder, sessionEventsStop)
+
+	// the new finder starts out healthy
+	m.trackRefresh(true)
+	m.setSessionError(nil)
+
+	m.logger.Info("Restarted session finder")
+
+	return nil
+}
+
+func (m *sessionMap) setupOnSliderMove() {
+	sliderEventsChannel := m.deej.serial.SubscribeToSliderMoveEvents(sliderMoveConsumerSessions)
+
+	go func() {
+		for {
+			event := <-sliderEventsChannel
+			m.handleSliderMoveEvent(event)
+		}
+	}()
+}
+
+func (m *sessionMap) setupOnSliderCountChange() {
+	sliderCountChannel := m.deej.serial.SubscribeToSliderCountChangeEvent()
+
+	go func() {
+		for {
+			count := <-sliderCountChannel
+			m.warnAboutUnreachableSliders(count)
+		}
+	}()
+}
+
+// warnAboutUnreachableSliders logs mappings for slider indexes the board doesn't have,
+// which usually means the config was written for a different number of sliders
+func (m *sessionMap) warnAboutUnreachableSliders(count int) {
+	m.deej.config.SliderMapping.iterate(func(sliderIdx int, targets []string) {
+		if sliderIdx >= count {
+			m.logger.Warnw("Slider mapping refers to a slider the board doesn't have",
+				"slider", sliderIdx,
+				"sliderCount", count,
+				"targets", targets)
+		}
+	})
+}
+
+func (m *sessionMap) setupOnSessionEvents(finder SessionFinder, stop <-chan struct{}) {
+	sessionEventsChan := finder.SubscribeToSessionEvents()
+
+	go func() {
+		for {
+			var event SessionEvent
+			var ok bool
+
+			select {
+			case <-stop:
+				return
+			case event, ok = <-sessionEventsChan:
+				if !ok {
+					return
+				}
+			}
+
+			switch event.Type {
+			case SessionEventAdded:
+				m.handleSessionAdded(event)
+			case SessionEventRemoved:
+				m.handleSessionRemoved(event)
+			case SessionEventExclusiveModeStarted, SessionEventExclusiveModeEnded:
+				m.handleExclusiveModeChange(event)
+			case SessionEventVolumeChanged:
+				m.handleVolumeChanged(event)
+			}
+		}
+	}()
+}
+
+func (m *sessionMap) handleSessionAdded(event SessionEvent) {
+	m.logger.Debugw("Session added event received", "session", event.Session)
+
+	// Add to the main map
+	m.add(event.Session)
+
+	// Track as unmapped if applicable
+	if !m.sessionMapped(event.Session) {
+		m.logger.Debugw("Tracking unmapped session from event", "session", event.Session)
+		m.lock.Lock()
+		m.unmappedSessions = append(m.unmappedSessions, event.Session)
+		m.lock.Unlock()
+	} else {
+		m.applyRestoredValues(event.Session)
+	}
+
+	m.notifySessionCountChange()
+}
+
+// applyRestoredValues sets a session that showed up after the previous run's slider positions were restored
+// to the position of every slider that controls it, as if those had only just been sent
+func (m *sessionMap) applyRestoredValues(session Session) {
+	values, ok := m.deej.serial.RestoredValues()
+	if !ok {
+		return
+	}
+
+	for sliderID, value := range values {
+		event := SliderMoveEvent{SliderID: sliderID, PercentValue: value}
+
+		// the left slider of a pair sets both channels, the right one has no mapping of its own
+		if pair, ok := m.deej.config.StereoPairs[sliderID]; ok {
+			if sliderID == pair.Left && m.sliderControls(sliderID, session) {
+				m.handleStereoSliderMoveEvent(event, pair)
+			}
+
+			continue
+		}
+
+		targets, ok := m.deej.config.SliderMapping.get(sliderID)
+		if !ok {
+			continue
+		}
+
+		for _, target := range targets {
+			if !containsSession(m.controlledSessions(sliderID, target), session) {
+				continue
+			}
+
+			// wrapping targets transform the value themselves, and only for everything they wrap at once
+			if unwrapTarget(target) != target {
+				m.targetSessions(sliderID, []string{target}, value)
+				continue
+			}
+
+			m.setSessionsVolume(event, []Session{session})
+		}
+	}
+}
+
+// sliderControls reports whether any of a slider's targets controls the given session
+func (m *sessionMap) sliderControls(sliderID int, session Session) bool {
+	targets, ok := m.deej.config.SliderMapping.get(sliderID)
+	if !ok {
+		return false
+	}
+
+	for _, target := range targets {
+		if containsSession(m.controlledSessions(sliderID, target), session) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// containsSession reports whether sessions includes the given one
+func containsSession(sessions []Session, session Session) bool {
+	return slices.ContainsFunc(sessions, func(s Session) bool { return s.ID() == session.ID() })
+}
+
+func (m *sessionMap) handleSessionRemoved(event SessionEvent) {
+	if event.Session == nil {
+		return
+	}
+
+	m.logger.Debugw("Session removed event received", "key", event.Session.Key())
+
+	// Remove from the main map
+	m.removeSession(event.Session)
+
+	// Remove from unmapped sessions if present
+	m.lock.Lock()
+	for i, unmapped := range m.unmappedSessions {
+		if unmapped.ID() == event.Session.ID() {
+			m.unmappedSessions = append(m.unmappedSessions[:i], m.unmappedSessions[i+1:]...)
+			break
+		}
+	}
+	m.lock.Unlock()
+
+	m.notifySessionCountChange()
+}
+
+// SubscribeToVolumeChanges returns a channel that receives session volume changes made outside of deej.
+// it only keeps a few changes for subscribers that fall behind, dropping the rest
+func (m *sessionMap) SubscribeToVolumeChanges() <-chan VolumeChangeEvent {
+	ch := make(chan VolumeChangeEvent, volumeChangeQueueSize)
+
+	m.volumeChangeConsumersLock.Lock()
+	m.volumeChangeConsumers = append(m.volumeChangeConsumers, ch)
+	m.volumeChangeConsumersLock.Unlock()
+
+	return ch
+}
+
+func (m *sessionMap) handleVolumeChanged(event SessionEvent) {
+	if event.Session == nil {
+		return
+	}
+
+	volumeChange := VolumeChangeEvent{Key: event.Session.Key(), Volume: event.Volume}
+
+	if m.deej.Verbose() {
+		m.logger.Debugw("Session volume changed outside of deej", "event", volumeChange)
+	}
+
+	m.volumeChangeConsumersLock.Lock()
+	defer m.volumeChangeConsumersLock.Unlock()
+
+	for _, consumer := range m.volumeChangeConsumers {
+		select {
+		case consumer <- volumeChange:
+		default:
+		}
+	}
+}
+
+// handleExclusiveModeChange lets the user know why a device's volume can't be controlled at the moment,
+// and when it can be again
+func (m *sessionMap) handleExclusiveModeChange(event SessionEvent) {
+	deviceName := event.SessionID
+	if event.Session != nil {
+		deviceName = event.Session.Key()
+	}
+
+	if event.Type == SessionEventExclusiveModeStarted {
+		m.logger.Infow("Device volume control unavailable due to exclusive mode", "device", deviceName)
+
+		title := m.deej.localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{
+				ID:    "ExclusiveModeStartedTitle",
+				Other: "{{.Device}} is in exclusive mode",
+			},
+			TemplateData: map[string]string{
+				"Device": deviceName,
+			},
+		})
+		description := m.deej.localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{
+				ID:    "ExclusiveModeStartedDescription",
+				Other: "Another app took exclusive control of this device. Volume control will resume once it's released.",
+			},
+		})
+		m.deej.notifier.Notify(notificationInfo, title, description)
+
+		return
+	}
+
+	m.logger.Infow("Device volume control restored after exclusive mode", "device", deviceName)
+
+	title := m.deej.localizer.MustLocalize(&i18n.LocalizeConfig{
+		DefaultMessage: &i18n.Message{
+			ID:    "ExclusiveModeEndedTitle",
+			Other: "{{.Device}} is available again",
+		},
+		TemplateData: map[string]string{
+			"Device": deviceName,
+		},
+	})
+	description := m.deej.localizer.MustLocalize(&i18n.LocalizeConfig{
+		DefaultMessage: &i18n.Message{
+			ID:    "ExclusiveModeEndedDescription",
+			Other: "Volume control has been restored.",
+		},
+	})
+	m.deej.notifier.Notify(notificationInfo, title, description)
+}
+
+// removeSession removes a specific session from the map. sessions are matched by ID, as the one
+// passed in isn't necessarily the same instance the map got when the session was added
+func (m *sessionMap) removeSession(session Session) {
+	m.cancelVolumeRamp(session)
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	key := normalizeSessionKey(session.Key())
+	sessions, ok := m.m[key]
+	if !ok {
+		return
+	}
+
+	// Find and remove the specific session
+	for i, s := range sessions {
+		if s.ID() == session.ID() {
+			m.m[key] = append(sessions[:i], sessions[i+1:]...)
+			break
+		}
+	}
+
+	// Remove the key entirely if no sessions left
+	if len(m.m[key]) == 0 {
+		delete(m.m, key)
+	}
+
+	for _, alias := range sessionAlternateKeys(session) {
+		m.aliases[alias] = slices.DeleteFunc(m.aliases[alias], func(s Session) bool { return s.ID() == session.ID() })
+
+		if len(m.aliases[alias]) == 0 {
+			delete(m.aliases, alias)
+		}
+	}
+}
+
+// sessionAlternateKeys returns the normalized alternate keys of a session, if it has any
+func sessionAlternateKeys(session Session) []string {
+	aliased, ok := session.(aliasedSession)
+	if !ok {
+		return nil
+	}
+
+	keys := []string{}
+	for _, alias := range aliased.AlternateKeys() {
+		if alias = normalizeSessionKey(alias); alias != session.Key() && !slices.Contains(keys, alias) {
+			keys = append(keys, alias)
+		}
+	}
+
+	return keys
+}
+
+// returns true if a session is not currently mapped to any slider, false otherwise
+// special sessions (master, system, mic) and device-specific sessions always count as mapped,
+// even when absent from the config. this makes sense for every current feature that uses "unmapped sessions"
+func (m *sessionMap) sessionMapped(session Session) bool {
+
+	// count master/system/mic as mapped, unless the user opted out of them
+	if m.deej.config.RegisterMaster && funk.ContainsString([]string{masterSessionName, systemSessionName, inputSessionName}, session.Key()) {
+		return true
+	}
+
+	// count device sessions as mapped
+	if deviceSessionKeyPattern.MatchString(session.Key()) {
+		return true
+	}
+
+	// and apps the user never wants deej.unmapped to pick up
+	for _, processName := range m.deej.config.UnmappedIgnore {
+		if processName == session.Key() || slices.Contains(sessionAlternateKeys(session), processName) {
+			return true
+		}
+	}
+
+	matchFound := false
+
+	// look through the actual mappings
+	m.deej.config.SliderMapping.iterate(func(_ int, targets []string) {
+		for _, target := range targets {
+
+			// mute, offset, range, channel and tab targets map whatever they wrap
+			target = unwrapTarget(target)
+
+			// sessions matching a regex target are mapped as well
+			if pattern, ok := parseRegexTarget(target); ok {
+				if compiled := m.regexTarget(pattern); compiled != nil && compiled.MatchString(session.Key()) {
+					matchFound = true
+					return
+				}
+				continue
+			}
+
+			// so are sessions matching a title target
+			if title, ok := parseTitleTarget(target); ok {
+				if strings.EqualFold(session.DisplayName(), title) || normalizeSessionKey(title) == session.Key() {
+					matchFound = true
+					return
+				}
+				continue
+			}
+
+			// processes bound by their ID are mapped as well
+			if pid, ok := parsePIDTarget(target); ok {
+				if session.PID() == pid {
+					matchFound = true
+					return
+				}
+				continue
+			}
+
+			// and Store apps bound by their package
+			if uwpKey, ok := parseUWPTarget(target); ok {
+				if slices.Contains(sessionAlternateKeys(session), uwpKey) {
+					matchFound = true
+					return
+				}
+				continue
+			}
+
+			// ignore special transforms, whatever case they're written in
+			if m.targetHasSpecialTransform(normalizeSessionKey(target)) {
+				continue
+			}
+
+			// safe to assume this has a single element because we made sure there's no special transform
+			target = m.resolveTarget(target)[0]
+
+			if target == session.Key() || slices.Contains(sessionAlternateKeys(session), target) {
+				matchFound = true
+				return
+			}
+		}
+	})
+
+	return matchFound
+}
+
+func (m *sessionMap) handleSliderMoveEvent(event SliderMoveEvent) {
+	m.deej.metrics.sliderEvents.Add(1)
+
+	// scene switches work independently of the slider's mapping, it doesn't even need one
+	if sceneRanges, ok := m.deej.config.OBSConfig.SceneSwitches[event.SliderID]; ok {
+		m.handleOBSSceneSwitch(event, sceneRanges)
+	}
+
+	// sliders in a stereo pair only make sense together
+	if pair, ok := m.deej.config.StereoPairs[event.SliderID]; ok {
+		m.handleStereoSliderMoveEvent(event, pair)
+		return
+	}
+
+	// get the targets mapped to this slider from the config
+	targets, ok := m.deej.config.SliderMapping.get(event.SliderID)
+
+	// if slider not found in config, silently ignore
+	if !ok {
+		return
+	}
+
+	m.setSessionsVolume(event, m.targetSessions(event.SliderID, targets, event.PercentValue))
+}
+
+// handleStereoSliderMoveEvent sets the left and right channels of the pair's targets to the positions
+// of its two sliders. until both have reported in, both channels follow the one that did
+func (m *sessionMap) handleStereoSliderMoveEvent(event SliderMoveEvent, pair stereoPair) {
+	m.lock.Lock()
+	m.stereoSliderValues[event.SliderID] = event.PercentValue
+
+	left, leftKnown := m.stereoSliderValues[pair.Left]
+	right, rightKnown := m.stereoSliderValues[pair.Right]
+	m.lock.Unlock()
+
+	if !leftKnown {
+		left = right
+	}
+	if !rightKnown {
+		right = left
+	}
+
+	targets, ok := m.deej.config.SliderMapping.get(pair.Left)
+	if !ok {
+		return
+	}
+
+	// anything that doesn't have separate channels gets the average of both sides
+	averageEvent := SliderMoveEvent{SliderID: pair.Left, PercentValue: (left + right) / 2}
+
+	for _, session := range m.targetSessions(pair.Left, targets, averageEvent.PercentValue) {
+		stereo, ok := session.(stereoSession)
+		if !ok {
+			m.setSessionsVolume(averageEvent, []Session{session})
+			continue
+		}
+
+		if m.shouldLogVolumeChanges() {
+			m.logger.Infow("Stereo volume change",
+				"sliders", pair,
+				"session", session.Key(),
+				"left", left,
+				"right", right)
+		}
+
+		if err := stereo.SetStereoVolume(left, right); err != nil {
+			m.logger.Warnw("Failed to set target session stereo volume", "error", err)
+			m.deej.metrics.setVolumeErrors.Add(1)
+		}
+	}
+}
+
+// targetSessions returns the sessions matching the given targets. targets controlling something
+// other than audio sessions (OBS, etc.) are set to the given volume right away instead
+func (m *sessionMap) targetSessions(sliderID int, targets []string, volume float32) []Session {
+	result := []Session{}
+
+	// for each possible target for this slider...
+	for _, target := range targets {
+
+		// handle special action targets (OBS, etc.) that don't map to audio sessions
+		if m.applySpecialTargetAction(target, volume) {
+			continue
+		}
+
+		// locked current window targets depend on the slider's own history, not just the target
+		if normalizeSessionKey(target) == specialTargetTransformPrefix+specialTargetCurrentWindowLocked {
+			for _, processName := range m.lockedCurrentWindow(sliderID) {
+				sessions, _ := m.get(processName)
+				result = append(result, sessions...)
+			}
+
+			continue
+		}
+
+		sessions := m.sessionsForTarget(target)
+		if len(sessions) == 0 {
+			m.handleMissingTarget(target)
+		}
+
+		result = append(result, sessions...)
+	}
+
+	return result
+}
+
+// handleMissingTarget applies the target's on_missing_target policy, if it has one
+func (m *sessionMap) handleMissingTarget(target string) {
+	policy, ok := m.deej.config.OnMissingTarget[strings.ToLower(target)]
+	if !ok {
+		return
+	}
+
+	switch policy.action {
+	case missingTargetRefresh:
+
+		// refreshes are rate limited on their own, and take a while, so don't wait for them
+		go m.refreshSessions(false)
+
+	case missingTargetLaunchPrefix:
+		if !m.deej.config.AllowExecTargets {
+			return
+		}
+
+		// give the program time to start and open its audio session before launching another copy
+		m.missingTargetLaunchesLock.Lock()
+		lastLaunch, launched := m.missingTargetLaunches[policy.launchPath]
+		if launched && time.Since(lastLaunch) < minTimeBetweenMissingTargetLaunches {
+			m.missingTargetLaunchesLock.Unlock()
+			return
+		}
+		m.missingTargetLaunches[policy.launchPath] = time.Now()
+		m.missingTargetLaunchesLock.Unlock()
+
+		m.logger.Infow("Target has no session, launching its program", "target", target, "path", policy.launchPath)
+
+		if err := m.execTargets.Launch(policy.launchPath); err != nil {
+			m.logger.Warnw("Failed to launch program for missing target", "target", target, "path", policy.launchPath, "error", err)
+		}
+	}
+}
+
+// lockedCurrentWindow returns the processes a 'deej.current.locked' slider is latched onto. a slider that
+// sat still for longer than the lock timeout lets go, and latches onto whatever window is active now
+func (m *sessionMap) lockedCurrentWindow(sliderID int) []string {
+	m.currentWindowLocksLock.Lock()
+	defer m.currentWindowLocksLock.Unlock()
+
+	now := time.Now()
+
+	if lock, ok := m.currentWindowLocks[sliderID]; ok && now.Sub(lock.lastMove) <= m.deej.config.CurrentWindowLockTimeout {
+		lock.lastMove = now
+		return lock.processNames
+	}
+
+	processNames := m.applyTargetTransform(specialTargetCurrentWindow)
+
+	// nothing to latch onto (or no way to tell what's active), try again on the next move
+	if len(processNames) == 0 {
+		delete(m.currentWindowLocks, sliderID)
+		return nil
+	}
+
+	m.currentWindowLocks[sliderID] = &currentWindowLock{processNames: processNames, lastMove: now}
+	m.logger.Debugw("Slider latched onto current window",
+		append(m.deej.config.sliderLogFields(sliderID), "processNames", processNames)...)
+
+	return processNames
+}
+
+// sliderSessionVolumes returns every session each slider currently controls, ordered by slider. sessions behind
+// mute, offset and range targets count as controlled, while action targets (OBS, keys, etc.) aren't sessions at all
+func (m *sessionMap) sliderSessionVolumes() []sliderSessionVolume {
+	mapping := map[int][]string{}
+	m.deej.config.SliderMapping.iterate(func(sliderID int, targets []string) {
+		mapping[sliderID] = targets
+	})
+
+	sliderIDs := make([]int, 0, len(mapping))
+	for sliderID := range mapping {
+		sliderIDs = append(sliderIDs, sliderID)
+	}
+	sort.Ints(sliderIDs)
+
+	result := []sliderSessionVolume{}
+
+	for _, sliderID := range sliderIDs {
+		seen := map[Session]bool{}
+
+		for _, target := range mapping[sliderID] {
+			for _, session := range m.controlledSessions(sliderID, target) {
+				if seen[session] {
+					continue
+				}
+				seen[session] = true
+
+				result = append(result, sliderSessionVolume{sliderID: sliderID, key: session.Key(), volume: session.GetVolume()})
+			}
+		}
+	}
+
+	return result
+}
+
+// mappedSessions returns every session any slider currently controls, each one only once
+func (m *sessionMap) mappedSessions() []Session {
+	result := []Session{}
+	seen := map[string]bool{}
+
+	m.deej.config.SliderMapping.iterate(func(sliderID int, targets []string) {
+		for _, target := range targets {
+			for _, session := range m.controlledSessions(sliderID, target) {
+				if seen[session.ID()] {
+					continue
+				}
+				seen[session.ID()] = true
+
+				result = append(result, session)
+			}
+		}
+	})
+
+	return result
+}
+
+// controlledSessions returns the sessions a slider's target controls, without doing anything to them
+func (m *sessionMap) controlledSessions(sliderID int, target string) []Session {
+	target = unwrapTarget(target)
+
+	// only look at what the slider already latched onto, rather than latching onto something new
+	if strings.ToLower(target) == specialTargetTransformPrefix+specialTargetCurrentWindowLocked {
+		m.currentWindowLocksLock.Lock()
+		var processNames []string
+		if lock, ok := m.currentWindowLocks[sliderID]; ok && time.Since(lock.lastMove) <= m.deej.config.CurrentWindowLockTimeout {
+			processNames = lock.processNames
+		}
+		m.currentWindowLocksLock.Unlock()
+
+		result := []Session{}
+		for _, processName := range processNames {
+			sessions, _ := m.get(processName)
+			result = append(result, sessions...)
+		}
+
+		return result
+	}
+
+	// action targets don't resolve to any sessions, so there's no need to tell them apart here
+	return m.sessionsForTarget(target)
+}
+
+// sessionsForTarget returns the audio sessions a single (non-action) target refers to
+func (m *sessionMap) sessionsForTarget(target string) []Session {
+
+	// process ID targets match individual sessions rather than session keys
+	if pid, ok := parsePIDTarget(target); ok {
+		return m.getByPID(pid)
+	}
+
+	// regex targets are matched against every session key, before resolving would lowercase the pattern
+	if pattern, ok := parseRegexTarget(target); ok {
+		return m.getByPattern(m.regexTarget(pattern))
+	}
+
+	// title targets match the session's display name, and only fall back to the executable if nothing has that name
+	if title, ok := parseTitleTarget(target); ok {
+		if sessions := m.getByDisplayName(title); len(sessions) > 0 {
+			return sessions
+		}
+
+		sessions, _ := m.get(title)
+		return sessions
+	}
+
+	// package targets are looked up as they are, they'd otherwise be taken for a special transform
+	if uwpKey, ok := parseUWPTarget(target); ok {
+		sessions, _ := m.get(uwpKey)
+		return sessions
+	}
+
+	result := []Session{}
+
+	// resolve the target name by cleaning it up and applying any special transformations.
+	// depending on the transformation applied, this can result in more than one target name
+	resolvedTargets := m.resolveTarget(target)
+
+	// for each resolved target...
+	for _, resolvedTarget := range resolvedTargets {
+
+		// check the map for matching sessions
+		sessions, ok := m.get(resolvedTarget)
+
+		// no sessions matching this target - move on
+		if !ok {
+			continue
+		}
+
+		result = append(result, sessions...)
+	}
+
+	return result
+}
+
+// setSessionsVolume iterates all given sessions and adjusts the volume of each one
+func (m *sessionMap) setSessionsVolume(event SliderMoveEvent, sessions []Session) {
+	errs := forEachSessionInParallel(sessions, func(session Session) error {
+		oldVolume := session.GetVolume()
+		skipped := oldVolume == event.PercentValue
+
+		if m.shouldLogVolumeChanges() {
+			m.logger.Infow("Volume change", append(m.deej.config.sliderLogFields(event.SliderID),
+				"session", session.Key(),
+				"from", oldVolume,
+				"to", event.PercentValue,
+				"skipped", skipped)...)
+		}
+
+		// whatever volume an older move was ramping towards isn't wanted anymore
+		m.cancelVolumeRamp(session)
+
+		if skipped {
+			return nil
+		}
+
+		if ramp := m.deej.config.VolumeRamp; ramp > 0 {
+			m.rampSessionVolume(session, oldVolume, event.PercentValue, ramp)
+			return nil
+		}
+
+		return session.SetVolume(event.PercentValue)
+	})
+
+	for _, err := range errs {
+		m.logger.Warnw("Failed to set target session volume", "error", err)
+		m.deej.metrics.setVolumeErrors.Add(1)
+	}
+}
+
+// forEachSessionInParallel runs fn for every session, a few at a time, and returns whatever errors that gave.
+// each call is a round-trip to the audio system, which adds up for apps with many sessions (i.e. browsers)
+func forEachSessionInParallel(sessions []Session, fn func(Session) error) []error {
+
+	// not worth spinning up goroutines for the usual single session
+	if len(sessions) == 1 {
+		if err := fn(sessions[0]); err != nil {
+			return []error{err}
+		}
+
+		return nil
+	}
+
+	var (
+		wg      sync.WaitGroup
+		errLock sync.Mutex
+		errs    []error
+	)
+
+	slots := make(chan struct{}, maxParallelVolumeChanges)
+
+	for _, session := range sessions {
+		wg.Add(1)
+		slots <- struct{}{}
+
+		go func(session Session) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+
+			if err := fn(session); err != nil {
+				errLock.Lock()
+				errs = append(errs, fmt.Errorf("%s: %w", session.Key(), err))
+				errLock.Unlock()
+			}
+		}(session)
+	}
+
+	wg.Wait()
+
+	return errs
+}
+
+// rampSessionVolume moves a session's volume towards the given one in small steps over the given duration
+func (m *sessionMap) rampSessionVolume(session Session, from float32, to float32, duration time.Duration) {
+	ramp := &volumeRamp{
+		cancel: make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+
+	m.volumeRampLock.Lock()
+	m.volumeRamps[session.ID()] = ramp
+	m.volumeRampLock.Unlock()
+
+	steps := max(int(duration/volumeRampStepInterval), 1)
+
+	go func() {
+		defer close(ramp.done)
+
+		defer func() {
+			m.volumeRampLock.Lock()
+			defer m.volumeRampLock.Unlock()
+
+			if m.volumeRamps[session.ID()] == ramp {
+				delete(m.volumeRamps, session.ID())
+			}
+		}()
+
+		ticker := time.NewTicker(duration / time.Duration(steps))
+		defer ticker.Stop()
+
+		for step := 1; ; step++ {
+			volume := from + (to-from)*float32(step)/float32(steps)
+
+			if err := session.SetVolume(volume); err != nil {
+				m.logger.Warnw("Failed to set target session volume", "error", err)
+				m.deej.metrics.setVolumeErrors.Add(1)
+				return
+			}
+
+			if step == steps {
+				return
+			}
+
+			select {
+			case <-ramp.cancel:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// cancelVolumeRamp stops a session's in-flight volume ramp, if it has one, and waits for it to let go of the session
+func (m *sessionMap) cancelVolumeRamp(session Session) {
+	m.cancelVolumeRampByID(session.ID())
+}
+
+func (m *sessionMap) cancelVolumeRampByID(sessionID string) {
+	m.volumeRampLock.Lock()
+	ramp, ok := m.volumeRamps[sessionID]
+	delete(m.volumeRamps, sessionID)
+	m.volumeRampLock.Unlock()
+
+	if !ok {
+		return
+	}
+
+	close(ramp.cancel)
+	<-ramp.done
+}
+
+func (m *sessionMap) cancelAllVolumeRamps() {
+	m.volumeRampLock.Lock()
+	sessionIDs := make([]string, 0, len(m.volumeRamps))
+	for sessionID := range m.volumeRamps {
+		sessionIDs = append(sessionIDs, sessionID)
+	}
+	m.volumeRampLock.Unlock()
+
+	for _, sessionID := range sessionIDs {
+		m.cancelVolumeRampByID(sessionID)
+	}
+}
+
+// parsePIDTarget extracts the process ID from a "deej.pid:<number>" target
+func parsePIDTarget(target string) (uint32, bool) {
+	if !strings.HasPrefix(strings.ToLower(target), pidTargetPrefix) {
+		return 0, false
+	}
+
+	pid, err := strconv.ParseUint(strings.TrimSpace(target[len(pidTargetPrefix):]), 10, 32)
+	if err != nil || pid == 0 {
+		return 0, false
+	}
+
+	return uint32(pid), true
+}
+
+// parseOBSFilterTarget splits the "<source>:<filter>:<setting>" part of an OBS filter target. the source ends
+// at the first colon and the setting starts after the last one, so only filter names can contain colons
+func parseOBSFilterTarget(sourceFilterAndSetting string) (string, string, string, bool) {
+	sourceEnd := strings.Index(sourceFilterAndSetting, ":")
+	settingStart := strings.LastIndex(sourceFilterAndSetting, ":")
+	if sourceEnd == -1 || sourceEnd == settingStart {
+		return "", "", "", false
+	}
+
+	sourceName := strings.TrimSpace(sourceFilterAndSetting[:sourceEnd])
+	filterName := strings.TrimSpace(sourceFilterAndSetting[sourceEnd+1 : settingStart])
+	setting := strings.TrimSpace(sourceFilterAndSetting[settingStart+1:])
+
+	if sourceName == "" || filterName == "" || setting == "" {
+		return "", "", "", false
+	}
+
+	return sourceName, filterName, setting, true
+}
+
+// parseRegexTarget returns the pattern of a "deej.regex:<pattern>" target, in the case it was written in
+func parseRegexTarget(target string) (string, bool) {
+	if !strings.HasPrefix(strings.ToLower(target), regexTargetPrefix) {
+		return "", false
+	}
+
+	pattern := strings.TrimSpace(target[len(regexTargetPrefix):])
+	if pattern == "" {
+		return "", false
+	}
+
+	return pattern, true
+}
+
+// parseTitleTarget returns the display name a "deej.title:<name>" target is looking for
+func parseTitleTarget(target string) (string, bool) {
+	if !strings.HasPrefix(strings.ToLower(target), titleTargetPrefix) {
+		return "", false
+	}
+
+	title := strings.TrimSpace(target[len(titleTargetPrefix):])
+	if title == "" {
+		return "", false
+	}
+
+	return title, true
+}
+
+// parseUWPTarget returns the normalized key a "deej.uwp:<package>" target looks up sessions by
+func parseUWPTarget(target string) (string, bool) {
+	if !strings.HasPrefix(strings.ToLower(target), uwpTargetPrefix) {
+		return "", false
+	}
+
+	packageName := strings.TrimSpace(target[len(uwpTargetPrefix):])
+	if packageName == "" {
+		return "", false
+	}
+
+	return normalizeSessionKey(uwpTargetPrefix + packageName), true
+}
+
+// shouldLogVolumeChanges reports whether every individual volume change should be logged,
+// either because deej runs in verbose mode or because the user asked for it in the config
+func (m *sessionMap) shouldLogVolumeChanges() bool {
+	return m.deej.Verbose() || m.deej.config.LogVolumeChanges
+}
+
+// applySpecialTargetAction handles targets that control external systems rather than audio sessions
+// (e.g. OBS or Discord).
+// Returns true if the target was handled, false if it should be treated as a normal audio target.
+func (m *sessionMap) applySpecialTargetAction(target string, volume float32) bool {
+	switch {
+	case strings.HasPrefix(strings.ToLower(target), obsTargetPrefix):
+		inputName := target[len(obsTargetPrefix):]
+		m.handleOBSTarget(inputName, volume)
+		return true
+
+	case strings.HasPrefix(strings.ToLower(target), obsMuteTargetPrefix):
+		inputName := target[len(obsMuteTargetPrefix):]
+		m.handleOBSMuteTarget(inputName, volume)
+		return true
+
+	case strings.HasPrefix(strings.ToLower(target), obsFilterTargetPrefix):
+		m.handleOBSFilterTarget(target[len(obsFilterTargetPrefix):], volume)
+		return true
+
+	case strings.HasPrefix(strings.ToLower(target), browserTabTargetPrefix):
+		browser := target[len(browserTabTargetPrefix):]
+		return m.handleBrowserTabTarget(browser, volume)
+
+	case strings.HasPrefix(strings.ToLower(target), execTargetPrefix):
+		name := target[len(execTargetPrefix):]
+		m.handleExecTarget(name, volume)
+		return true
+
+	case strings.HasPrefix(strings.ToLower(target), muteTargetPrefix):
+		m.handleMuteTarget(target[len(muteTargetPrefix):], volume)
+		return true
+
+	case strings.HasPrefix(strings.ToLower(target), keyTargetPrefix):
+		m.handleKeyTarget(strings.ToLower(target[len(keyTargetPrefix):]), volume)
+		return true
+
+	case strings.HasPrefix(strings.ToLower(target), setDefaultTargetPrefix):
+		m.handleSetDefaultTarget(strings.TrimSpace(target[len(setDefaultTargetPrefix):]), volume)
+		return true
+
+	case strings.HasPrefix(strings.ToLower(target), offsetTargetPrefix):
+		m.handleOffsetTarget(target[len(offsetTargetPrefix):], volume)
+		return true
+
+	case strings.HasPrefix(strings.ToLower(target), rangeTargetPrefix):
+		m.handleRangeTarget(target[len(rangeTargetPrefix):], volume)
+		return true
+
+	case strings.HasPrefix(strings.ToLower(target), channelTargetPrefix):
+		m.handleChannelTarget(target[len(channelTargetPrefix):], volume)
+		return true
+
+	case strings.EqualFold(target, mprisTarget):
+		m.handleMPRISTarget("", volume)
+		return true
+
+	case strings.HasPrefix(strings.ToLower(target), mprisTargetPrefix):
+		m.handleMPRISTarget(strings.TrimSpace(target[len(mprisTargetPrefix):]), volume)
+		return true
+
+	case strings.EqualFold(target, discordMuteTarget):
+		m.handleDiscordTarget(discordVoiceMute, volume)
+		return true
+
+	case strings.EqualFold(target, discordDeafenTarget):
+		m.handleDiscordTarget(discordVoiceDeafen, volume)
+		return true
+
+	case strings.HasPrefix(strings.ToLower(target), voicemeeterStripTargetPrefix):
+		m.handleVoicemeeterTarget(m.deej.vm.SetStripGain, target[len(voicemeeterStripTargetPrefix):], volume)
+		return true
+
+	case strings.HasPrefix(strings.ToLower(target), voicemeeterBusTargetPrefix):
+		m.handleVoicemeeterTarget(m.deej.vm.SetBusGain, target[len(voicemeeterBusTargetPrefix):], volume)
+		return true
+	}
+
+	return false
+}
+
+// handleBrowserTabTarget adjusts the focused tab of a browser with remote debugging enabled.
+// returns false if that's not possible, so the target can fall back to the browser's own session
+func (m *sessionMap) handleBrowserTabTarget(browser string, volume float32) bool {
+	port, ok := m.deej.config.BrowserDebuggingPorts[strings.ToLower(browser)]
+	if !ok {
+		return false
+	}
+
+	if err := m.browserTabs.SetFocusedTabVolume(port, volume); err != nil {
+		m.logger.Debugw("Failed to set browser tab volume, falling back to browser session", "browser", browser, "error", err)
+		return false
+	}
+
+	return true
+}
+
+func (m *sessionMap) handleMPRISTarget(player string, volume float32) {
+	if err := m.mpris.SetVolume(player, volume); err != nil {
+		m.logger.Debugw("Failed to set MPRIS player volume", "player", player, "error", err)
+	}
+}
+
+func (m *sessionMap) handleExecTarget(name string, volume float32) {
+	if !m.deej.config.AllowExecTargets {
+		m.logger.Debugw("Ignoring exec target, exec targets aren't allowed in the config", "name", name)
+		return
+	}
+
+	m.execTargets.Run(strings.ToLower(name), volume)
+}
+
+// handleMuteTarget mutes the target's sessions while the slider is all the way down, and unmutes them otherwise
+func (m *sessionMap) handleMuteTarget(target string, volume float32) {
+	muted := volume <= muteTargetThreshold
+
+	for _, session := range m.sessionsForTarget(target) {
+		if session.GetMute() == muted {
+			continue
+		}
+
+		if m.shouldLogVolumeChanges() {
+			m.logger.Infow("Mute change", "session", session.Key(), "muted", muted)
+		}
+
+		if err := session.SetMute(muted); err != nil {
+			m.logger.Warnw("Failed to set target session mute state", "error", err)
+		}
+	}
+}
+
+// handleKeyTarget presses the media key for whichever end of the slider it just got to, given either
+// as "<key>" for the top end only or as "<bottom key>:<top key>"
+func (m *sessionMap) handleKeyTarget(keys string, volume float32) {
+	bottomKey, topKey, ok := strings.Cut(keys, ":")
+	if !ok {
+		bottomKey, topKey = "", keys
+	}
+
+	end := m.arrivedAtEnd(keyTargetPrefix+keys, volume)
+	if end == 0 {
+		return
+	}
+
+	key := topKey
+	if end == -1 {
+		key = bottomKey
+	}
+
+	if key == "" {
+		return
+	}
+
+	if m.shouldLogVolumeChanges() {
+		m.logger.Infow("Pressing media key", "key", key)
+	}
+
+	if err := util.PressMediaKey(key); err != nil {
+		m.logger.Warnw("Failed to press media key", "target", keyTargetPrefix+keys, "error", err)
+	}
+}
+
+// arrivedAtEnd tells which end of the slider a target just arrived at: -1 for the bottom, 1 for the top,
+// or 0 if it's in between or was already there. the first position is only remembered, so a slider
+// that's already at an end when deej starts doesn't trigger anything
+func (m *sessionMap) arrivedAtEnd(target string, volume float32) int {
+	end := 0
+	if volume <= muteTargetThreshold {
+		end = -1
+	} else if volume >= 1-muteTargetThreshold {
+		end = 1
+	}
 
-func (m *sessionMap) release() error {
-	if err := m.sessionFinder.Release(); err != nil {
-		m.logger.Warnw("Failed to release session finder during session map release", "error", err)
-		return fmt.Errorf("release session finder during release: %w", err)
+	m.targetEndsLock.Lock()
+	previousEnd, seen := m.targetEnds[target]
+	m.targetEnds[target] = end
+	m.targetEndsLock.Unlock()
+
+	if !seen || end == previousEnd {
+		return 0
 	}
 
-	return nil
+	return end
 }
 
-func (m *sessionMap) setupOnSliderMove() {
-	sliderEventsChannel := m.deej.serial.SubscribeToSliderMoveEvents()
+func (m *sessionMap) handleSetDefaultTarget(deviceName string, volume float32) {
+	if m.arrivedAtEnd(setDefaultTargetPrefix+strings.ToLower(deviceName), volume) != 1 {
+		return
+	}
+
+	setter, ok := m.currentSessionFinder().(defaultDeviceSetter)
+	if !ok {
+		m.logger.Warnw("Setting the default device isn't supported on this platform", "target", setDefaultTargetPrefix+deviceName)
+		return
+	}
 
+	// talking to the audio engine can take a moment, don't hold up other sliders meanwhile
 	go func() {
-		for {
-			event := <-sliderEventsChannel
-			m.handleSliderMoveEvent(event)
+		if err := setter.SetDefaultOutputDevice(deviceName); err != nil {
+			m.logger.Warnw("Failed to set default output device", "device", deviceName, "error", err)
 		}
 	}()
 }
 
-func (m *sessionMap) setupOnSessionEvents(finder SessionFinder) {
-	sessionEventsChan := finder.SubscribeToSessionEvents()
+// handleOffsetTarget sets the sessions of an "<target>:<offset>" target to the slider's position plus the offset.
+// the offset is applied to the slider rather than the session's current volume, so it doesn't add up with every move
+func (m *sessionMap) handleOffsetTarget(targetAndOffset string, volume float32) {
+	target, offset, ok := parseOffsetTarget(targetAndOffset)
+	if !ok {
+		m.logger.Debugw("Ignoring invalid offset target", "target", offsetTargetPrefix+targetAndOffset)
+		return
+	}
 
-	go func() {
-		for event := range sessionEventsChan {
-			switch event.Type {
-			case SessionEventAdded:
-				m.handleSessionAdded(event)
-			case SessionEventRemoved:
-				m.handleSessionRemoved(event)
-			}
+	offsetVolume := float32(math.Max(0, math.Min(1, float64(volume)+offset)))
+
+	for _, session := range m.sessionsForTarget(target) {
+		if m.shouldLogVolumeChanges() {
+			m.logger.Infow("Volume change", "session", session.Key(), "from", session.GetVolume(), "to", offsetVolume,
+				"offset", offset)
 		}
-	}()
-}
 
-func (m *sessionMap) handleSessionAdded(event SessionEvent) {
-	m.logger.Debugw("Session added event received", "session", event.Session)
+		if err := session.SetVolume(offsetVolume); err != nil {
+			m.logger.Warnw("Failed to set target session volume", "error", err)
+			m.deej.metrics.setVolumeErrors.Add(1)
+		}
+	}
+}
 
-	// Add to the main map
-	m.add(event.Session)
+// parseOffsetTarget splits "discord.exe:+0.2" into its target and an offset between -1.0 and 1.0
+func parseOffsetTarget(targetAndOffset string) (string, float64, bool) {
+	separatorIdx := strings.LastIndex(targetAndOffset, ":")
+	if separatorIdx <= 0 {
+		return "", 0, false
+	}
 
-	// Track as unmapped if applicable
-	if !m.sessionMapped(event.Session) {
-		m.logger.Debugw("Tracking unmapped session from event", "session", event.Session)
-		m.lock.Lock()
-		m.unmappedSessions = append(m.unmappedSessions, event.Session)
-		m.lock.Unlock()
+	offset, err := strconv.ParseFloat(strings.TrimSpace(targetAndOffset[separatorIdx+1:]), 64)
+	if err != nil || offset < -1 || offset > 1 {
+		return "", 0, false
 	}
 
-	m.notifySessionCountChange()
+	return strings.TrimSpace(targetAndOffset[:separatorIdx]), offset, true
 }
 
-func (m *sessionMap) handleSessionRemoved(event SessionEvent) {
-	if event.Session == nil {
+// handleRangeTarget maps the slider's position onto the volume range of a "<target>:<min>:<max>" target
+func (m *sessionMap) handleRangeTarget(targetAndRange string, volume float32) {
+	target, minVolume, maxVolume, ok := parseRangeTarget(targetAndRange)
+	if !ok {
+		m.logger.Debugw("Ignoring invalid range target", "target", rangeTargetPrefix+targetAndRange)
 		return
 	}
 
-	m.logger.Debugw("Session removed event received", "key", event.Session.Key())
+	rangeVolume := util.NormalizeScalar(float32(minVolume + float64(volume)*(maxVolume-minVolume)))
 
-	// Remove from the main map
-	m.removeSession(event.Session)
+	for _, session := range m.sessionsForTarget(target) {
+		if m.shouldLogVolumeChanges() {
+			m.logger.Infow("Volume change", "session", session.Key(), "from", session.GetVolume(), "to", rangeVolume,
+				"min", minVolume, "max", maxVolume)
+		}
 
-	// Remove from unmapped sessions if present
-	m.lock.Lock()
-	for i, unmapped := range m.unmappedSessions {
-		if unmapped == event.Session {
-			m.unmappedSessions = append(m.unmappedSessions[:i], m.unmappedSessions[i+1:]...)
-			break
+		if err := session.SetVolume(rangeVolume); err != nil {
+			m.logger.Warnw("Failed to set target session volume", "error", err)
+			m.deej.metrics.setVolumeErrors.Add(1)
 		}
 	}
-	m.lock.Unlock()
-
-	m.notifySessionCountChange()
 }
 
-// removeSession removes a specific session from the map
-func (m *sessionMap) removeSession(session Session) {
-	m.lock.Lock()
-	defer m.lock.Unlock()
+// parseRangeTarget splits "spotify.exe:0.05:0.7" into its target and the volumes the slider's ends map to.
+// both are between 0.0 and 1.0, and a min above max turns the slider around
+func parseRangeTarget(targetAndRange string) (string, float64, float64, bool) {
+	maxSeparatorIdx := strings.LastIndex(targetAndRange, ":")
+	if maxSeparatorIdx <= 0 {
+		return "", 0, 0, false
+	}
 
-	key := session.Key()
-	sessions, ok := m.m[key]
+	minSeparatorIdx := strings.LastIndex(targetAndRange[:maxSeparatorIdx], ":")
+	if minSeparatorIdx <= 0 {
+		return "", 0, 0, false
+	}
+
+	minVolume, minErr := strconv.ParseFloat(strings.TrimSpace(targetAndRange[minSeparatorIdx+1:maxSeparatorIdx]), 64)
+	maxVolume, maxErr := strconv.ParseFloat(strings.TrimSpace(targetAndRange[maxSeparatorIdx+1:]), 64)
+	if minErr != nil || maxErr != nil || minVolume < 0 || minVolume > 1 || maxVolume < 0 || maxVolume > 1 {
+		return "", 0, 0, false
+	}
+
+	return strings.TrimSpace(targetAndRange[:minSeparatorIdx]), minVolume, maxVolume, true
+}
+
+// handleChannelTarget sets a single channel of the sessions of a "<target>:<channel>" target
+func (m *sessionMap) handleChannelTarget(targetAndChannel string, volume float32) {
+	target, channel, ok := parseChannelTarget(targetAndChannel)
 	if !ok {
+		m.logger.Debugw("Ignoring invalid channel target", "target", channelTargetPrefix+targetAndChannel)
 		return
 	}
 
-	// Find and remove the specific session
-	for i, s := range sessions {
-		if s == session {
-			m.m[key] = append(sessions[:i], sessions[i+1:]...)
-			break
+	for _, session := range m.sessionsForTarget(target) {
+		channelSession, ok := session.(channelSession)
+		if !ok {
+			m.logger.Debugw("Session doesn't support setting channel volumes", "session", session.Key())
+			continue
 		}
-	}
 
-	// Remove the key entirely if no sessions left
-	if len(m.m[key]) == 0 {
-		delete(m.m, key)
+		if m.shouldLogVolumeChanges() {
+			m.logger.Infow("Channel volume change", "session", session.Key(), "channel", channel, "to", volume)
+		}
+
+		if err := channelSession.SetChannelVolume(channel, volume); err != nil {
+			m.logger.Debugw("Failed to set target session channel volume", "channel", channel, "error", err)
+		}
 	}
 }
 
-// returns true if a session is not currently mapped to any slider, false otherwise
-// special sessions (master, system, mic) and device-specific sessions always count as mapped,
-// even when absent from the config. this makes sense for every current feature that uses "unmapped sessions"
-func (m *sessionMap) sessionMapped(session Session) bool {
-
-	// count master/system/mic as mapped
-	if funk.ContainsString([]string{masterSessionName, systemSessionName, inputSessionName}, session.Key()) {
-		return true
+// parseChannelTarget splits a "<target>:<channel>" target into its target and channel index
+func parseChannelTarget(targetAndChannel string) (string, int, bool) {
+	separatorIdx := strings.LastIndex(targetAndChannel, ":")
+	if separatorIdx <= 0 {
+		return "", 0, false
 	}
 
-	// count device sessions as mapped
-	if deviceSessionKeyPattern.MatchString(session.Key()) {
-		return true
+	channel, err := strconv.Atoi(strings.TrimSpace(targetAndChannel[separatorIdx+1:]))
+	if err != nil || channel < 0 {
+		return "", 0, false
 	}
 
-	matchFound := false
+	return strings.TrimSpace(targetAndChannel[:separatorIdx]), channel, true
+}
 
-	// look through the actual mappings
-	m.deej.config.SliderMapping.iterate(func(_ int, targets []string) {
-		for _, target := range targets {
+// unwrapTarget returns the target whose sessions a wrapping target acts on: the one inside a mute, offset, range
+// or channel target, or the browser a tab target falls back to. wrappers can be nested, and anything else
+// (including a wrapper that doesn't parse) comes back as it is
+func unwrapTarget(target string) string {
+	for {
+		lowerTarget := strings.ToLower(target)
+		unwrapped := target
 
-			// ignore special transforms
-			if m.targetHasSpecialTransform(target) {
-				continue
+		switch {
+		case strings.HasPrefix(lowerTarget, muteTargetPrefix):
+			unwrapped = target[len(muteTargetPrefix):]
+
+		case strings.HasPrefix(lowerTarget, browserTabTargetPrefix):
+			unwrapped = target[len(browserTabTargetPrefix):]
+
+		case strings.HasPrefix(lowerTarget, offsetTargetPrefix):
+			if offsetTarget, _, ok := parseOffsetTarget(target[len(offsetTargetPrefix):]); ok {
+				unwrapped = offsetTarget
 			}
 
-			// safe to assume this has a single element because we made sure there's no special transform
-			target = m.resolveTarget(target)[0]
+		case strings.HasPrefix(lowerTarget, rangeTargetPrefix):
+			if rangeTarget, _, _, ok := parseRangeTarget(target[len(rangeTargetPrefix):]); ok {
+				unwrapped = rangeTarget
+			}
 
-			if target == session.Key() {
-				matchFound = true
-				return
+		case strings.HasPrefix(lowerTarget, channelTargetPrefix):
+			if channelTarget, _, ok := parseChannelTarget(target[len(channelTargetPrefix):]); ok {
+				unwrapped = channelTarget
 			}
 		}
-	})
 
-	return matchFound
+		if unwrapped == target {
+			return target
+		}
+
+		target = unwrapped
+	}
 }
 
-func (m *sessionMap) handleSliderMoveEvent(event SliderMoveEvent) {
+func (m *sessionMap) handleOBSTarget(inputName string, volume float32) {
+	if m.deej.obs == nil || !m.deej.obs.IsConnected() {
+		return
+	}
 
-	// get the targets mapped to this slider from the config
-	targets, ok := m.deej.config.SliderMapping.get(event.SliderID)
+	if err := m.deej.obs.SetInputVolume(inputName, volume); err != nil {
+		m.logger.Debugw("Failed to set OBS input volume", "input", inputName, "error", err)
+	}
+}
 
-	// if slider not found in config, silently ignore
+// handleOBSMuteTarget mutes an OBS input while the slider is all the way down, and unmutes it otherwise.
+// OBS is only asked to change the input's state when it differs, so moving the slider around doesn't flood it
+func (m *sessionMap) handleOBSMuteTarget(inputName string, volume float32) {
+	if m.deej.obs == nil || !m.deej.obs.IsConnected() {
+		return
+	}
+
+	mute := volume <= muteTargetThreshold
+
+	if muted, err := m.deej.obs.GetInputMute(inputName); err == nil && muted == mute {
+		return
+	}
+
+	if err := m.deej.obs.SetInputMute(inputName, mute); err != nil {
+		m.logger.Debugw("Failed to set OBS input mute state", "input", inputName, "error", err)
+	}
+}
+
+// handleOBSFilterTarget sets a filter setting given as "<source>:<filter>:<setting>" to the slider's position
+func (m *sessionMap) handleOBSFilterTarget(sourceFilterAndSetting string, volume float32) {
+	if m.deej.obs == nil || !m.deej.obs.IsConnected() {
+		return
+	}
+
+	sourceName, filterName, setting, ok := parseOBSFilterTarget(sourceFilterAndSetting)
 	if !ok {
+		m.logger.Debugw("Ignoring invalid OBS filter target", "target", obsFilterTargetPrefix+sourceFilterAndSetting)
 		return
 	}
 
-	// for each possible target for this slider...
-	for _, target := range targets {
+	if err := m.deej.obs.SetSourceFilterSetting(sourceName, filterName, setting, float64(volume)); err != nil {
+		m.logger.Debugw("Failed to set OBS filter setting",
+			"source", sourceName,
+			"filter", filterName,
+			"setting", setting,
+			"error", err)
+	}
+}
 
-		// handle special action targets (OBS, etc.) that don't map to audio sessions
-		if m.applySpecialTargetAction(target, event.PercentValue) {
+// handleOBSSceneSwitch switches OBS to the scene whose range the slider moved into. the slider needs to be
+// obsSceneSwitchMargin past the range's edges, unless that edge is the end of the slider
+func (m *sessionMap) handleOBSSceneSwitch(event SliderMoveEvent, sceneRanges []OBSSceneRange) {
+	if m.deej.obs == nil || !m.deej.obs.IsConnected() {
+		return
+	}
+
+	m.lock.Lock()
+	currentScene := m.obsScenes[event.SliderID]
+	m.lock.Unlock()
+
+	for _, sceneRange := range sceneRanges {
+		low, high := sceneRange.Min, sceneRange.Max
+		if low > 0 {
+			low += obsSceneSwitchMargin
+		}
+		if high < 1 {
+			high -= obsSceneSwitchMargin
+		}
+
+		if event.PercentValue < low || event.PercentValue > high {
 			continue
 		}
 
-		// resolve the target name by cleaning it up and applying any special transformations.
-		// depending on the transformation applied, this can result in more than one target name
-		resolvedTargets := m.resolveTarget(target)
+		if sceneRange.Scene == currentScene {
+			return
+		}
 
-		// for each resolved target...
-		for _, resolvedTarget := range resolvedTargets {
+		if err := m.deej.obs.SetCurrentScene(sceneRange.Scene); err != nil {
+			m.logger.Debugw("Failed to switch OBS scene", "scene", sceneRange.Scene, "error", err)
+			return
+		}
 
-			// check the map for matching sessions
-			sessions, ok := m.get(resolvedTarget)
+		m.logger.Infow("Switched OBS scene", append(m.deej.config.sliderLogFields(event.SliderID), "scene", sceneRange.Scene)...)
 
-			// no sessions matching this target - move on
-			if !ok {
-				continue
-			}
+		m.lock.Lock()
+		m.obsScenes[event.SliderID] = sceneRange.Scene
+		m.lock.Unlock()
 
-			// iterate all matching sessions and adjust the volume of each one
-			for _, session := range sessions {
-				if session.GetVolume() != event.PercentValue {
-					if err := session.SetVolume(event.PercentValue); err != nil {
-						m.logger.Warnw("Failed to set target session volume", "error", err)
-					}
-				}
-			}
-		}
+		return
 	}
 }
 
-// applySpecialTargetAction handles targets that control external systems rather than audio sessions
-// (e.g. OBS, and potentially Discord or others in the future).
-// Returns true if the target was handled, false if it should be treated as a normal audio target.
-func (m *sessionMap) applySpecialTargetAction(target string, volume float32) bool {
-	switch {
-	case strings.HasPrefix(strings.ToLower(target), obsTargetPrefix):
-		inputName := target[len(obsTargetPrefix):]
-		m.handleOBSTarget(inputName, volume)
-		return true
+// handleDiscordTarget turns a Discord voice setting on while the slider is all the way down, and off otherwise
+func (m *sessionMap) handleDiscordTarget(setting string, volume float32) {
+	if m.deej.discord == nil || !m.deej.discord.IsConnected() {
+		return
 	}
 
-	return false
+	if err := m.deej.discord.SetVoiceSetting(setting, volume <= muteTargetThreshold); err != nil {
+		m.logger.Debugw("Failed to set Discord voice setting", "setting", setting, "error", err)
+	}
 }
 
-func (m *sessionMap) handleOBSTarget(inputName string, volume float32) {
-	if m.deej.obs == nil || !m.deej.obs.IsConnected() {
+// handleVoicemeeterTarget sets the gain of a Voicemeeter strip or bus, doing nothing while Voicemeeter isn't running
+func (m *sessionMap) handleVoicemeeterTarget(setGain func(string, float32) error, name string, volume float32) {
+	if m.deej.vm == nil || !m.deej.vm.IsConnected() {
 		return
 	}
 
-	if err := m.deej.obs.SetInputVolume(inputName, volume); err != nil {
-		m.logger.Debugw("Failed to set OBS input volume", "input", inputName, "error", err)
+	if err := setGain(name, volume); err != nil {
+		m.logger.Debugw("Failed to set Voicemeeter gain", "name", name, "error", err)
 	}
 }
 
@@ -301,7 +2107,7 @@ func (m *sessionMap) targetHasSpecialTransform(target string) bool {
 func (m *sessionMap) resolveTarget(target string) []string {
 
 	// start by ignoring the case
-	target = strings.ToLower(target)
+	target = normalizeSessionKey(target)
 
 	// look for any special targets first, by examining the prefix
 	if m.targetHasSpecialTransform(target) {
@@ -314,6 +2120,11 @@ func (m *sessionMap) resolveTarget(target string) []string {
 func (m *sessionMap) applyTargetTransform(specialTargetName string) []string {
 	checkFullscreen := false
 
+	// browser tab targets that couldn't reach a tab control the whole browser instead
+	if strings.HasPrefix(specialTargetName, browserTabTransformPrefix) {
+		return []string{strings.TrimPrefix(specialTargetName, browserTabTransformPrefix)}
+	}
+
 	// select the transformation based on its name
 	switch specialTargetName {
 
@@ -339,12 +2150,30 @@ func (m *sessionMap) applyTargetTransform(specialTargetName string) []string {
 		// remove dupes
 		return funk.UniqString(currentWindowProcessNames)
 
+	// get every output device, and master in case the default device has no session of its own
+	case specialTargetAllMaster:
+		targetKeys := []string{masterSessionName}
+
+		m.lock.Lock()
+		for key, sessions := range m.m {
+			for _, session := range sessions {
+				if device, ok := session.(deviceSession); ok && device.IsOutputDevice() && !funk.ContainsString(targetKeys, key) {
+					targetKeys = append(targetKeys, key)
+				}
+			}
+		}
+		m.lock.Unlock()
+
+		return targetKeys
+
 	// get currently unmapped sessions
 	case specialTargetAllUnmapped:
+		m.lock.Lock()
 		targetKeys := make([]string, len(m.unmappedSessions))
 		for sessionIdx, session := range m.unmappedSessions {
 			targetKeys[sessionIdx] = session.Key()
 		}
+		m.lock.Unlock()
 
 		return targetKeys
 	}
@@ -356,7 +2185,7 @@ func (m *sessionMap) add(value Session) {
 	m.lock.Lock()
 	defer m.lock.Unlock()
 
-	key := value.Key()
+	key := normalizeSessionKey(value.Key())
 
 	existing, ok := m.m[key]
 	if !ok {
@@ -364,16 +2193,86 @@ func (m *sessionMap) add(value Session) {
 	} else {
 		m.m[key] = append(existing, value)
 	}
+
+	for _, alias := range sessionAlternateKeys(value) {
+		m.aliases[alias] = append(m.aliases[alias], value)
+	}
 }
 
+// get returns the sessions stored under the given key, which may be in any case,
+// along with the sessions that have it as an alternate key
 func (m *sessionMap) get(key string) ([]Session, bool) {
 	m.lock.Lock()
 	defer m.lock.Unlock()
 
+	key = normalizeSessionKey(key)
+
 	value, ok := m.m[key]
+
+	if aliased, aliasOk := m.aliases[key]; aliasOk {
+		value = append(slices.Clone(value), aliased...)
+		ok = true
+	}
+
 	return value, ok
 }
 
+// getByPattern returns the sessions of every key the given pattern matches
+func (m *sessionMap) getByPattern(pattern *regexp.Regexp) []Session {
+	result := []Session{}
+
+	if pattern == nil {
+		return result
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	for key, sessions := range m.m {
+		if pattern.MatchString(key) {
+			result = append(result, sessions...)
+		}
+	}
+
+	return result
+}
+
+// getByDisplayName returns the sessions whose display name is the given one, ignoring case
+func (m *sessionMap) getByDisplayName(displayName string) []Session {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	result := []Session{}
+
+	for _, sessions := range m.m {
+		for _, session := range sessions {
+			if strings.EqualFold(session.DisplayName(), displayName) {
+				result = append(result, session)
+			}
+		}
+	}
+
+	return result
+}
+
+// getByPID returns all sessions owned by the given process. once it exits, its sessions
+// are removed from the map and this simply comes back empty
+func (m *sessionMap) getByPID(pid uint32) []Session {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	result := []Session{}
+	for _, sessions := range m.m {
+		for _, session := range sessions {
+			if session.PID() == pid {
+				result = append(result, session)
+			}
+		}
+	}
+
+	return result
+}
+
 func (m *sessionMap) getSessionCount() int {
 	m.lock.Lock()
 	defer m.lock.Unlock()
@@ -386,6 +2285,44 @@ func (m *sessionMap) getSessionCount() int {
 	return count
 }
 
+// getSessions returns every known session, ordered by key
+func (m *sessionMap) getSessions() []Session {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	keys := make([]string, 0, len(m.m))
+	for key := range m.m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	sessions := []Session{}
+	for _, key := range keys {
+		sessions = append(sessions, m.m[key]...)
+	}
+
+	return sessions
+}
+
+// getSessionDisplayNames returns the sorted, de-duplicated display names of all current sessions
+func (m *sessionMap) getSessionDisplayNames() []string {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	names := []string{}
+	for _, sessions := range m.m {
+		for _, session := range sessions {
+			if name := session.DisplayName(); !funk.ContainsString(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
 func (m *sessionMap) String() string {
 	return fmt.Sprintf("<%d audio sessions>", m.getSessionCount())
 }
@@ -0,0 +1,197 @@
+package deej
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestUnwrapTarget(t *testing.T) {
+	tests := map[string]string{
+		"spotify.exe":                            "spotify.exe",
+		"deej.mute:spotify.exe":                  "spotify.exe",
+		"deej.offset:discord.exe:+0.2":           "discord.exe",
+		"deej.range:spotify.exe:0.05:0.7":        "spotify.exe",
+		"deej.channel:master:0":                  "master",
+		"deej.tab:chrome.exe":                    "chrome.exe",
+		"Deej.Mute:deej.offset:Discord.exe:-0.1": "Discord.exe",
+		"deej.mute:deej.range:deej.pid:1234:0:1": "deej.pid:1234",
+		"deej.offset:discord.exe:not-an-offset":  "deej.offset:discord.exe:not-an-offset",
+		"deej.current":                           "deej.current",
+		"deej.obs:Mic/Aux":                       "deej.obs:Mic/Aux",
+	}
+
+	for target, expected := range tests {
+		if unwrapped := unwrapTarget(target); unwrapped != expected {
+			t.Errorf("unwrapTarget(%q) = %q, expected %q", target, unwrapped, expected)
+		}
+	}
+}
+
+func TestSessionMappedThroughWrappingTargets(t *testing.T) {
+	d := newTestDeej(t, `slider_mapping:
+  0: deej.offset:spotify.exe:+0.2
+  1: deej.tab:chrome.exe
+  2: deej.channel:discord.exe:0
+  3: deej.unmapped
+`)
+
+	finder := d.sessions.currentSessionFinder().(*mockSessionFinder)
+
+	for name, mapped := range map[string]bool{
+		"spotify.exe": true,
+		"chrome.exe":  true,
+		"discord.exe": true,
+		"steam.exe":   false,
+	} {
+		if got := d.sessions.sessionMapped(finder.AddSession(name)); got != mapped {
+			t.Errorf("sessionMapped(%s) = %v, expected %v", name, got, mapped)
+		}
+	}
+}
+
+func TestRestoredValuesReachLateSessions(t *testing.T) {
+	d := newTestDeej(t, `slider_mapping:
+  0: steam.exe
+  1: deej.offset:vlc.exe:-0.25
+  2: deej.unmapped
+`)
+
+	if err := d.config.SetLastSliderValues([]float32{0.4, 0.75, 0.1}); err != nil {
+		t.Fatalf("SetLastSliderValues: %v", err)
+	}
+
+	// nothing has been enumerated yet when the positions go out, like on startup
+	d.serial.RestoreState()
+
+	finder := d.sessions.currentSessionFinder().(*mockSessionFinder)
+
+	for name, expected := range map[string]float32{
+		"steam.exe":   0.4,
+		"vlc.exe":     0.5,
+		"notepad.exe": 1,
+	} {
+		session := finder.AddSession(name)
+		d.sessions.handleSessionAdded(SessionEvent{Type: SessionEventAdded, Session: session})
+
+		if volume := session.GetVolume(); math.Abs(float64(volume-expected)) > 0.001 {
+			t.Errorf("%s volume = %v, expected %v", name, volume, expected)
+		}
+	}
+}
+
+func TestSessionLookupIgnoresCase(t *testing.T) {
+	d := newTestDeej(t, `slider_mapping:
+  0: SPOTIFY.EXE
+  1: headphones (usb audio)
+`)
+
+	finder := d.sessions.currentSessionFinder().(*mockSessionFinder)
+
+	process := finder.AddSession("Spotify.exe")
+
+	device := finder.AddSession("Headphones (USB Audio)")
+	device.(*mockSession).master = true
+
+	for _, session := range []Session{process, device} {
+		d.sessions.add(session)
+	}
+
+	for key, expected := range map[string]Session{
+		"spotify.exe":            process,
+		"SpOtIfY.eXe":            process,
+		"Headphones (USB Audio)": device,
+		"HEADPHONES (usb audio)": device,
+	} {
+		sessions, ok := d.sessions.get(key)
+		if !ok || len(sessions) != 1 || sessions[0].ID() != expected.ID() {
+			t.Errorf("get(%q) = %v, expected %v", key, sessions, expected)
+		}
+	}
+
+	for _, session := range []Session{process, device} {
+		if !d.sessions.sessionMapped(session) {
+			t.Errorf("sessionMapped(%s) = false, expected true", session.Key())
+		}
+	}
+}
+
+func TestFailedSessionFinderRestartKeepsOldFinder(t *testing.T) {
+	d := newTestDeej(t, "slider_mapping:\n  0: master\n")
+
+	oldFinder := d.sessions.currentSessionFinder().(*mockSessionFinder)
+	createSessionFinder := d.sessions.createSessionFinder
+
+	d.sessions.createSessionFinder = func() (SessionFinder, error) {
+		return nil, errors.New("audio engine unavailable")
+	}
+
+	// clicking restart again after a failure used to close the same stop channel twice
+	for range 2 {
+		if err := d.sessions.restartSessionFinder(); err == nil {
+			t.Fatal("restartSessionFinder() succeeded without a session finder")
+		}
+	}
+
+	if d.sessions.currentSessionFinder() != oldFinder {
+		t.Error("failed restart replaced the session finder")
+	}
+
+	oldFinder.lock.Lock()
+	released := oldFinder.released
+	oldFinder.lock.Unlock()
+
+	if released {
+		t.Error("failed restart released the session finder that's still in use")
+	}
+
+	d.sessions.createSessionFinder = createSessionFinder
+
+	if err := d.sessions.restartSessionFinder(); err != nil {
+		t.Fatalf("restartSessionFinder() = %v once a finder can be created again", err)
+	}
+
+	if d.sessions.currentSessionFinder() == oldFinder {
+		t.Error("restart kept the old session finder")
+	}
+}
+
+// slowSession takes a while to report its volume, like a session behind a busy audio engine
+type slowSession struct {
+	*mockSession
+}
+
+const slowSessionDelay = 50 * time.Millisecond
+
+func (s slowSession) GetVolume() float32 {
+	time.Sleep(slowSessionDelay)
+	return s.mockSession.GetVolume()
+}
+
+func TestSetSessionsVolumeReadsInParallel(t *testing.T) {
+	d := newTestDeej(t, "slider_mapping:\n  0: master\n")
+
+	finder := d.sessions.currentSessionFinder().(*mockSessionFinder)
+
+	sessions := []Session{}
+	for i := range 4 * maxParallelVolumeChanges {
+		sessions = append(sessions, slowSession{finder.AddSession(fmt.Sprintf("tab%d.exe", i)).(*mockSession)})
+	}
+
+	start := time.Now()
+	d.sessions.setSessionsVolume(SliderMoveEvent{SliderID: 0, PercentValue: 0.3}, sessions)
+	elapsed := time.Since(start)
+
+	// one after another, reading every volume would take the whole delay per session
+	if sequential := time.Duration(len(sessions)) * slowSessionDelay; elapsed >= sequential/2 {
+		t.Errorf("setting %d sessions took %v, reading them one at a time takes %v", len(sessions), elapsed, sequential)
+	}
+
+	for _, session := range sessions {
+		if volume := session.(slowSession).mockSession.GetVolume(); volume != 0.3 {
+			t.Errorf("%s volume = %v, expected 0.3", session.Key(), volume)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package deej
+
+import "testing"
+
+func TestSessionKeysAreLowercase(t *testing.T) {
+	tests := []struct {
+		name     string
+		session  *baseSession
+		expected string
+	}{
+		{"process", &baseSession{name: "Spotify.exe"}, "spotify.exe"},
+		{"device", &baseSession{master: true, name: "Speakers (Realtek(R) Audio)"}, "speakers (realtek(r) audio)"},
+		{"master", &baseSession{master: true, name: masterSessionName}, masterSessionName},
+		{"system", &baseSession{system: true, name: "ignored"}, systemSessionName},
+	}
+
+	for _, test := range tests {
+		if key := test.session.Key(); key != test.expected {
+			t.Errorf("%s session Key() = %q, expected %q", test.name, key, test.expected)
+		}
+	}
+}
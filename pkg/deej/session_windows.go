@@ -4,26 +4,36 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"sync/atomic"
+	"unsafe"
 
 	ole "github.com/go-ole/go-ole"
 	ps "github.com/mitchellh/go-ps"
 	wca "github.com/moutend/go-wca/pkg/wca"
 	"go.uber.org/zap"
+
+	"github.com/nik9play/deej/pkg/win"
 )
 
 var errNoSuchProcess = errors.New("no such process")
-var errRefreshSessions = errors.New("trigger session refresh")
+var errSessionExpired = errors.New("session expired")
 
 type wcaSession struct {
 	baseSession
 
-	pid         uint32
 	processName string
 
+	// "deej.uwp:" keys for the package of a Store app, by name and by family name
+	alternateKeys []string
+
 	control *wca.IAudioSessionControl2
 	volume  *wca.ISimpleAudioVolume
 
 	eventCtx *ole.GUID
+
+	// set by the session finder, to drop the session as soon as a volume change finds it expired
+	// rather than waiting for its expiry event, which may still be on its way or never come
+	onExpired func()
 }
 
 type masterSession struct {
@@ -32,6 +42,12 @@ type masterSession struct {
 	volume *wca.IAudioEndpointVolume
 
 	eventCtx *ole.GUID
+
+	// set while another app holds the device in exclusive mode, during which volume changes are pointless
+	exclusiveMode atomic.Bool
+
+	// whether this is a playback device, rather than a recording one
+	isOutput bool
 }
 
 func newWCASession(
@@ -39,42 +55,85 @@ func newWCASession(
 	control *wca.IAudioSessionControl2,
 	volume *wca.ISimpleAudioVolume,
 	pid uint32,
+	system bool,
 	eventCtx *ole.GUID,
 ) (*wcaSession, error) {
 
 	s := &wcaSession{
 		control:  control,
 		volume:   volume,
-		pid:      pid,
 		eventCtx: eventCtx,
 	}
 
-	// special treatment for system sounds session
-	if pid == 0 {
+	s.pid = pid
+
+	// the instance identifier is already unique, the PID only makes it easier to read in logs.
+	// should it be unavailable, the session's COM object is at least unique for as long as the session lives
+	instanceID, err := win.GetSessionInstanceIdentifier(control)
+	if err != nil {
+		logger.Debugw("Failed to get session instance identifier", "pid", pid, "error", err)
+		instanceID = fmt.Sprintf("%p", control)
+	}
+
+	s.id = fmt.Sprintf("%d|%s", pid, instanceID)
+
+	// special treatment for system sounds session, which is always keyed as "system"
+	if system {
 		s.system = true
 		s.name = systemSessionName
 		s.humanReadableDesc = "system sounds"
+		s.setDisplayName("System sounds")
 	} else {
 
+		// Store apps can be targeted by their package, which their session identifier names
+		var packageName string
+		if identifier, err := win.GetSessionIdentifier(control); err != nil {
+			logger.Debugw("Failed to get session identifier", "pid", pid, "error", err)
+		} else if name, familyName, ok := packageFromSessionIdentifier(identifier); ok {
+			packageName = name
+			s.alternateKeys = []string{uwpTargetPrefix + name, uwpTargetPrefix + familyName}
+		}
+
 		// find our session's process name
 		process, err := ps.FindProcess(int(pid))
-		if err != nil {
+
+		switch {
+
+		// Store apps don't always report a PID that leads to their executable, but their package still names them
+		case (err != nil || process == nil) && packageName != "":
+			logger.Debugw("Failed to find packaged app's process, naming session after its package",
+				"pid", pid,
+				"package", packageName,
+				"error", err)
+
+			s.processName = packageName
+
+		case err != nil:
 			logger.Warnw("Failed to find process name by ID", "pid", pid, "error", err)
 			defer s.Release()
 
 			return nil, fmt.Errorf("find process name by pid: %w", err)
-		}
 
 		// this PID may be invalid - this means the process has already been
 		// closed and we shouldn't create a session for it.
-		if process == nil {
+		case process == nil:
 			logger.Debugw("Process already exited, not creating audio session", "pid", pid)
 			return nil, errNoSuchProcess
+
+		default:
+			s.processName = process.Executable()
 		}
 
-		s.processName = process.Executable()
 		s.name = s.processName
 		s.humanReadableDesc = fmt.Sprintf("%s (pid %d)", s.processName, s.pid)
+
+		// most apps don't bother setting a display name, so fall back to the bare executable name
+		var displayName string
+		if err := control.GetDisplayName(&displayName); err != nil {
+			logger.Debugw("Failed to get session display name", "pid", pid, "error", err)
+		}
+
+		s.updateDisplayName(displayName)
 	}
 
 	// use a self-identifying session name e.g. deej.sessions.chrome
@@ -90,11 +149,14 @@ func newMasterSession(
 	eventCtx *ole.GUID,
 	key string,
 	loggerKey string,
+	deviceID string,
+	isOutput bool,
 ) (*masterSession, error) {
 
 	s := &masterSession{
 		volume:   volume,
 		eventCtx: eventCtx,
+		isOutput: isOutput,
 	}
 
 	s.logger = logger.Named(loggerKey)
@@ -102,11 +164,72 @@ func newMasterSession(
 	s.name = key
 	s.humanReadableDesc = key
 
+	// the same device can back more than one master session (i.e. "master" and its own name), told apart by key
+	s.id = key + "|" + deviceID
+
 	s.logger.Debugw(sessionCreationLogMessage, "session", s)
 
 	return s, nil
 }
 
+// AlternateKeys returns the "deej.uwp:" keys of a Store app's package, if the session belongs to one
+func (s *wcaSession) AlternateKeys() []string {
+	return s.alternateKeys
+}
+
+// packageFromSessionIdentifier finds the package a Store app's session belongs to, returning its name and
+// family name. session identifiers look like "{0.0.0.00000000}.{<device>}|<app>%b{<guid>}", where <app> is
+// either the app's user model ID, i.e. "Microsoft.ZuneMusic_8wekyb3d8bbwe!Microsoft.ZuneMusic", or its executable's
+// path - under WindowsApps\<name>_<version>_<architecture>_<resource id>_<publisher id> for packaged apps
+func packageFromSessionIdentifier(identifier string) (string, string, bool) {
+	_, app, found := strings.Cut(identifier, "|")
+	if !found {
+		return "", "", false
+	}
+
+	app, _, _ = strings.Cut(app, "%b")
+
+	// user model IDs are the package family name and the app's ID within the package
+	if familyName, _, isAUMID := strings.Cut(app, "!"); isAUMID && !strings.Contains(familyName, `\`) {
+		name, publisherID, ok := strings.Cut(familyName, "_")
+		if !ok || name == "" || publisherID == "" {
+			return "", "", false
+		}
+
+		return name, familyName, true
+	}
+
+	const packagesDir = `\windowsapps\`
+
+	dirIdx := strings.Index(strings.ToLower(app), packagesDir)
+	if dirIdx == -1 {
+		return "", "", false
+	}
+
+	// package names can't contain underscores, so the full name splits cleanly
+	fullName, _, _ := strings.Cut(app[dirIdx+len(packagesDir):], `\`)
+	parts := strings.Split(fullName, "_")
+	if len(parts) != 5 || parts[0] == "" || parts[4] == "" {
+		return "", "", false
+	}
+
+	return parts[0], parts[0] + "_" + parts[4], true
+}
+
+func (s *masterSession) IsOutputDevice() bool {
+	return s.isOutput
+}
+
+// updateDisplayName sets the session's display name as reported by the app. windows also reports
+// resource references here (i.e. "@%SystemRoot%\System32\AudioSrv.Dll,-202"), which aren't worth showing
+func (s *wcaSession) updateDisplayName(displayName string) {
+	if displayName == "" || strings.HasPrefix(displayName, "@") {
+		displayName = strings.TrimSuffix(s.processName, ".exe")
+	}
+
+	s.setDisplayName(displayName)
+}
+
 func (s *wcaSession) GetVolume() float32 {
 	var level float32
 
@@ -132,8 +255,13 @@ func (s *wcaSession) SetVolume(v float32) error {
 	}
 
 	if state == wca.AudioSessionStateExpired {
-		s.logger.Warnw("Audio session expired, triggering session refresh")
-		return errRefreshSessions
+		s.logger.Warnw("Audio session expired, removing it")
+
+		if s.onExpired != nil {
+			s.onExpired()
+		}
+
+		return errSessionExpired
 	}
 
 	s.logger.Debugw("Adjusting session volume", "to", fmt.Sprintf("%.2f", v))
@@ -141,6 +269,70 @@ func (s *wcaSession) SetVolume(v float32) error {
 	return nil
 }
 
+func (s *wcaSession) GetMute() bool {
+	var muted bool
+
+	if err := s.volume.GetMute(&muted); err != nil {
+		s.logger.Warnw("Failed to get session mute state", "error", err)
+	}
+
+	return muted
+}
+
+func (s *wcaSession) SetMute(m bool) error {
+	if err := s.volume.SetMute(m, s.eventCtx); err != nil {
+		s.logger.Warnw("Failed to set session mute state", "error", err)
+		return fmt.Errorf("adjust session mute state: %w", err)
+	}
+
+	s.logger.Debugw("Adjusting session mute state", "to", m)
+
+	return nil
+}
+
+// SetStereoVolume sets the session's volume to the louder side, and scales each channel relative to it.
+// sessions that can't control their channels separately get the average of both sides instead
+func (s *wcaSession) SetStereoVolume(left float32, right float32) error {
+	dispatch, err := s.control.QueryInterface(win.IID_IChannelAudioVolume)
+	if err != nil {
+		s.logger.Debugw("Session has no channel volume control, using average volume", "error", err)
+		return s.SetVolume((left + right) / 2)
+	}
+
+	channelVolume := (*win.IChannelAudioVolume)(unsafe.Pointer(dispatch))
+	defer channelVolume.Release()
+
+	var channelCount uint32
+	if err := channelVolume.GetChannelCount(&channelCount); err != nil || channelCount < 2 {
+		return s.SetVolume((left + right) / 2)
+	}
+
+	loudest := max(left, right)
+	if err := s.SetVolume(loudest); err != nil {
+		return err
+	}
+
+	for channel := range channelCount {
+
+		// channel volumes are relative to the session volume, which is already at the louder side
+		level := float32(0)
+		if loudest > 0 {
+			level = stereoChannelVolume(int(channel), int(channelCount), left, right) / loudest
+		}
+
+		if err := channelVolume.SetChannelVolume(channel, level, s.eventCtx); err != nil {
+			s.logger.Warnw("Failed to set session channel volume", "channel", channel, "error", err)
+			return fmt.Errorf("adjust session channel volume: %w", err)
+		}
+	}
+
+	s.logger.Debugw("Adjusting session stereo volume",
+		"left", fmt.Sprintf("%.2f", left),
+		"right", fmt.Sprintf("%.2f", right))
+
+	return nil
+}
+
 func (s *wcaSession) Release() {
 	s.logger.Debug("Releasing audio session")
 
@@ -163,6 +355,11 @@ func (s *masterSession) GetVolume() float32 {
 }
 
 func (s *masterSession) SetVolume(v float32) error {
+	if s.exclusiveMode.Load() {
+		s.logger.Debugw("Device is in exclusive mode, not adjusting session volume", "volume", v)
+		return nil
+	}
+
 	if err := s.volume.SetMasterVolumeLevelScalar(v, s.eventCtx); err != nil {
 		s.logger.Warnw("Failed to set session volume",
 			"error", err,
@@ -176,6 +373,93 @@ func (s *masterSession) SetVolume(v float32) error {
 	return nil
 }
 
+func (s *masterSession) GetMute() bool {
+	var muted bool
+
+	if err := s.volume.GetMute(&muted); err != nil {
+		s.logger.Warnw("Failed to get session mute state", "error", err)
+	}
+
+	return muted
+}
+
+func (s *masterSession) SetMute(m bool) error {
+	if s.exclusiveMode.Load() {
+		s.logger.Debugw("Device is in exclusive mode, not adjusting session mute state", "mute", m)
+		return nil
+	}
+
+	if err := s.volume.SetMute(m, s.eventCtx); err != nil {
+		s.logger.Warnw("Failed to set session mute state",
+			"error", err,
+			"mute", m)
+
+		return fmt.Errorf("adjust session mute state: %w", err)
+	}
+
+	s.logger.Debugw("Adjusting session mute state", "to", m)
+
+	return nil
+}
+
+func (s *masterSession) SetStereoVolume(left float32, right float32) error {
+	if s.exclusiveMode.Load() {
+		s.logger.Debugw("Device is in exclusive mode, not adjusting session volume", "left", left, "right", right)
+		return nil
+	}
+
+	var channelCount uint32
+	if err := s.volume.GetChannelCount(&channelCount); err != nil || channelCount < 2 {
+		return s.SetVolume((left + right) / 2)
+	}
+
+	for channel := range channelCount {
+		level := stereoChannelVolume(int(channel), int(channelCount), left, right)
+
+		if err := s.volume.SetChannelVolumeLevelScalar(channel, level, s.eventCtx); err != nil {
+			s.logger.Warnw("Failed to set session channel volume", "channel", channel, "error", err)
+			return fmt.Errorf("adjust session channel volume: %w", err)
+		}
+	}
+
+	s.logger.Debugw("Adjusting session stereo volume",
+		"left", fmt.Sprintf("%.2f", left),
+		"right", fmt.Sprintf("%.2f", right))
+
+	return nil
+}
+
+// SetChannelVolume sets one channel of the device, leaving the others as they are
+func (s *masterSession) SetChannelVolume(channel int, v float32) error {
+	if s.exclusiveMode.Load() {
+		s.logger.Debugw("Device is in exclusive mode, not adjusting session volume", "channel", channel, "volume", v)
+		return nil
+	}
+
+	var channelCount uint32
+	if err := s.volume.GetChannelCount(&channelCount); err != nil {
+		s.logger.Warnw("Failed to get session channel count", "error", err)
+		return fmt.Errorf("get session channel count: %w", err)
+	}
+
+	if channel < 0 || channel >= int(channelCount) {
+		return fmt.Errorf("no channel %d, session has %d", channel, channelCount)
+	}
+
+	if err := s.volume.SetChannelVolumeLevelScalar(uint32(channel), v, s.eventCtx); err != nil {
+		s.logger.Warnw("Failed to set session channel volume", "channel", channel, "error", err)
+		return fmt.Errorf("adjust session channel volume: %w", err)
+	}
+
+	s.logger.Debugw("Adjusting session channel volume", "channel", channel, "to", fmt.Sprintf("%.2f", v))
+
+	return nil
+}
+
+func (s *masterSession) setExclusiveMode(exclusive bool) {
+	s.exclusiveMode.Store(exclusive)
+}
+
 func (s *masterSession) Release() {
 	s.logger.Debug("Releasing audio session")
 
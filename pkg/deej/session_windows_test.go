@@ -0,0 +1,43 @@
+package deej
+
+import (
+	"testing"
+)
+
+func TestPackageFromSessionIdentifier(t *testing.T) {
+	const prefix = `{0.0.0.00000000}.{a3c1e1f0-1b2c-4d5e-8f90-123456789abc}|`
+	const suffix = `%b{00000000-0000-0000-0000-000000000000}`
+
+	tests := []struct {
+		name             string
+		identifier       string
+		expectName       string
+		expectFamilyName string
+		expectFound      bool
+	}{
+		{"UWP app", prefix + `Microsoft.ZuneMusic_8wekyb3d8bbwe!Microsoft.ZuneMusic` + suffix, "Microsoft.ZuneMusic", "Microsoft.ZuneMusic_8wekyb3d8bbwe", true},
+		{"packaged executable", prefix + `\Device\HarddiskVolume3\Program Files\WindowsApps\SpotifyAB.SpotifyMusic_1.2.3.0_x64__zpdnekdrzrea0\Spotify.exe` + suffix, "SpotifyAB.SpotifyMusic", "SpotifyAB.SpotifyMusic_zpdnekdrzrea0", true},
+
+		// the packages directory is matched regardless of case
+		{"packaged executable, lowercase", prefix + `\device\harddiskvolume3\program files\windowsapps\SpotifyAB.SpotifyMusic_1.2.3.0_x64__zpdnekdrzrea0\spotify.exe` + suffix, "SpotifyAB.SpotifyMusic", "SpotifyAB.SpotifyMusic_zpdnekdrzrea0", true},
+
+		// Win32 apps aren't packages, even when their path happens to contain an exclamation mark
+		{"Win32 app", prefix + `\Device\HarddiskVolume3\Program Files\Mozilla Firefox\firefox.exe` + suffix, "", "", false},
+		{"Win32 app with an exclamation mark", prefix + `\Device\HarddiskVolume3\Games\Wow!\game.exe` + suffix, "", "", false},
+
+		{"empty", "", "", "", false},
+		{"no app", `{0.0.0.00000000}.{a3c1e1f0-1b2c-4d5e-8f90-123456789abc}`, "", "", false},
+		{"UWP app without a publisher ID", prefix + `Microsoft.ZuneMusic!Microsoft.ZuneMusic` + suffix, "", "", false},
+		{"UWP app without a name", prefix + `_8wekyb3d8bbwe!Microsoft.ZuneMusic` + suffix, "", "", false},
+		{"packaged executable with a short full name", prefix + `\Device\HarddiskVolume3\Program Files\WindowsApps\SpotifyAB.SpotifyMusic_1.2.3.0\Spotify.exe` + suffix, "", "", false},
+	}
+
+	for _, test := range tests {
+		name, familyName, found := packageFromSessionIdentifier(test.identifier)
+
+		if found != test.expectFound || name != test.expectName || familyName != test.expectFamilyName {
+			t.Errorf("%s: got %q, %q, %v, expected %q, %q, %v",
+				test.name, name, familyName, found, test.expectName, test.expectFamilyName, test.expectFound)
+		}
+	}
+}
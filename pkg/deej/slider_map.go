@@ -2,7 +2,9 @@ package deej
 
 import (
 	"fmt"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/thoas/go-funk"
@@ -13,6 +15,13 @@ type sliderMap struct {
 	lock sync.Locker
 }
 
+// stereoPair is two sliders that control the left and right channels of the same targets,
+// as sent by boards with dual-gang pots
+type stereoPair struct {
+	Left  int
+	Right int
+}
+
 func newSliderMap() *sliderMap {
 	return &sliderMap{
 		m:    make(map[int][]string),
@@ -23,9 +32,12 @@ func newSliderMap() *sliderMap {
 func sliderMapFromConfigs(userMapping map[string][]string, internalMapping map[string][]string) *sliderMap {
 	resultMap := newSliderMap()
 
-	// copy targets from user config, ignoring empty values
+	// copy targets from user config, ignoring empty values and sliders that aren't numbers
 	for sliderIdxString, targets := range userMapping {
-		sliderIdx, _ := strconv.Atoi(sliderIdxString)
+		sliderIdx, ok := parseSliderIndex(sliderIdxString)
+		if !ok {
+			continue
+		}
 
 		resultMap.set(sliderIdx, funk.FilterString(targets, func(s string) bool {
 			return s != ""
@@ -34,7 +46,10 @@ func sliderMapFromConfigs(userMapping map[string][]string, internalMapping map[s
 
 	// add targets from internal configs, ignoring duplicate or empty values
 	for sliderIdxString, targets := range internalMapping {
-		sliderIdx, _ := strconv.Atoi(sliderIdxString)
+		sliderIdx, ok := parseSliderIndex(sliderIdxString)
+		if !ok {
+			continue
+		}
 
 		existingTargets, ok := resultMap.get(sliderIdx)
 		if !ok {
@@ -52,6 +67,16 @@ func sliderMapFromConfigs(userMapping map[string][]string, internalMapping map[s
 	return resultMap
 }
 
+// parseSliderIndex reads a slider_mapping key, which must be a non-negative number
+func parseSliderIndex(sliderIdxString string) (int, bool) {
+	sliderIdx, err := strconv.Atoi(strings.TrimSpace(sliderIdxString))
+	if err != nil || sliderIdx < 0 {
+		return 0, false
+	}
+
+	return sliderIdx, true
+}
+
 func (m *sliderMap) iterate(f func(int, []string)) {
 	m.lock.Lock()
 	defer m.lock.Unlock()
@@ -76,6 +101,57 @@ func (m *sliderMap) set(key int, value []string) {
 	m.m[key] = value
 }
 
+// conflictingTargets returns the plain targets (processes, master, mic and so on) mapped to more than one slider,
+// along with the sorted indexes of those sliders. special "deej." targets are left out, since
+// several of them on the same target can be intentional (i.e. an offset next to a plain mapping).
+// sliders whose mapping isn't used on its own can be skipped
+func (m *sliderMap) conflictingTargets(skipSliders map[int]bool) map[string][]int {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	sliders := map[string][]int{}
+
+	for sliderIdx, targets := range m.m {
+		if skipSliders[sliderIdx] {
+			continue
+		}
+
+		for _, target := range targets {
+			target = normalizeSessionKey(strings.TrimSpace(target))
+			if target == "" || strings.HasPrefix(target, specialTargetTransformPrefix) {
+				continue
+			}
+
+			if !funk.ContainsInt(sliders[target], sliderIdx) {
+				sliders[target] = append(sliders[target], sliderIdx)
+			}
+		}
+	}
+
+	result := map[string][]int{}
+	for target, sliderIdxs := range sliders {
+		if len(sliderIdxs) > 1 {
+			sort.Ints(sliderIdxs)
+			result[target] = sliderIdxs
+		}
+	}
+
+	return result
+}
+
+// targetCount returns the total number of targets across all sliders
+func (m *sliderMap) targetCount() int {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	count := 0
+	for _, value := range m.m {
+		count += len(value)
+	}
+
+	return count
+}
+
 func (m *sliderMap) String() string {
 	m.lock.Lock()
 	defer m.lock.Unlock()
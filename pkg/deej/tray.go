@@ -1,11 +1,16 @@
 package deej
 
 import (
+	"bytes"
+	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	"fyne.io/systray"
 	"github.com/nicksnyder/go-i18n/v2/i18n"
+	"go.bug.st/serial/enumerator"
+	"go.uber.org/zap"
 
 	"github.com/nik9play/deej/pkg/deej/util"
 	"github.com/nik9play/deej/pkg/icon"
@@ -28,6 +33,23 @@ func getConfigItemText(d *Deej) (string, string) {
 	return configTitle, configDescription
 }
 
+func getReloadConfigItemText(d *Deej) (string, string) {
+	reloadConfigTitle := d.localizer.MustLocalize(&i18n.LocalizeConfig{
+		DefaultMessage: &i18n.Message{
+			ID:    "ReloadConfigTitle",
+			Other: "Reload configuration now",
+		},
+	})
+	reloadConfigDescription := d.localizer.MustLocalize(&i18n.LocalizeConfig{
+		DefaultMessage: &i18n.Message{
+			ID:    "ReloadConfigDescription",
+			Other: "Apply config file changes deej didn't pick up by itself",
+		},
+	})
+
+	return reloadConfigTitle, reloadConfigDescription
+}
+
 func getSettingsItemText(d *Deej) (string, string) {
 	configTitle := d.localizer.MustLocalize(&i18n.LocalizeConfig{
 		DefaultMessage: &i18n.Message{
@@ -62,6 +84,79 @@ func getAutostartItemText(d *Deej) (string, string) {
 	return configTitle, configDescription
 }
 
+func getRawValuesItemText(d *Deej) (string, string) {
+	rawValuesTitle := d.localizer.MustLocalize(&i18n.LocalizeConfig{
+		DefaultMessage: &i18n.Message{
+			ID:    "RawValuesTitle",
+			Other: "Show raw slider values",
+		},
+	})
+	rawValuesDescription := d.localizer.MustLocalize(&i18n.LocalizeConfig{
+		DefaultMessage: &i18n.Message{
+			ID:    "RawValuesDescription",
+			Other: "Show the values the board sends (0-1023) instead of percentages, to check whether sliders reach their ends",
+		},
+	})
+
+	return rawValuesTitle, rawValuesDescription
+}
+
+func getCOMPortItemText(d *Deej) (string, string) {
+	comPortTitle := d.localizer.MustLocalize(&i18n.LocalizeConfig{
+		DefaultMessage: &i18n.Message{
+			ID:    "COMPortTitle",
+			Other: "COM port",
+		},
+	})
+	comPortDescription := d.localizer.MustLocalize(&i18n.LocalizeConfig{
+		DefaultMessage: &i18n.Message{
+			ID:    "COMPortDescription",
+			Other: "Choose the port the board is connected to",
+		},
+	})
+
+	return comPortTitle, comPortDescription
+}
+
+func getBaudRateItemText(d *Deej) (string, string) {
+	baudRateTitle := d.localizer.MustLocalize(&i18n.LocalizeConfig{
+		DefaultMessage: &i18n.Message{
+			ID:    "BaudRateTitle",
+			Other: "Baud rate",
+		},
+	})
+	baudRateDescription := d.localizer.MustLocalize(&i18n.LocalizeConfig{
+		DefaultMessage: &i18n.Message{
+			ID:    "BaudRateDescription",
+			Other: "Must match the rate the board's firmware uses",
+		},
+	})
+
+	return baudRateTitle, baudRateDescription
+}
+
+func getAutoCOMPortItemText(d *Deej) string {
+	return d.localizer.MustLocalize(&i18n.LocalizeConfig{
+		DefaultMessage: &i18n.Message{
+			ID:    "COMPortAutoTitle",
+			Other: "Detect automatically",
+		},
+	})
+}
+
+// getPortItemTitle describes a serial port by its name, along with what's connected to it if that's known
+func getPortItemTitle(port *enumerator.PortDetails) string {
+	if !port.IsUSB {
+		return port.Name
+	}
+
+	if port.Product != "" {
+		return fmt.Sprintf("%s - %s (%s:%s)", port.Name, port.Product, port.VID, port.PID)
+	}
+
+	return fmt.Sprintf("%s (%s:%s)", port.Name, port.VID, port.PID)
+}
+
 func getQuitItemText(d *Deej) (string, string) {
 	quitTitle := d.localizer.MustLocalize(&i18n.LocalizeConfig{
 		DefaultMessage: &i18n.Message{
@@ -79,10 +174,52 @@ func getQuitItemText(d *Deej) (string, string) {
 	return quitTitle, quitDescription
 }
 
+func getRestartAudioItemText(d *Deej) (string, string) {
+	restartAudioTitle := d.localizer.MustLocalize(&i18n.LocalizeConfig{
+		DefaultMessage: &i18n.Message{
+			ID:    "RestartAudioTitle",
+			Other: "Restart audio engine",
+		},
+	})
+	restartAudioDescription := d.localizer.MustLocalize(&i18n.LocalizeConfig{
+		DefaultMessage: &i18n.Message{
+			ID:    "RestartAudioDescription",
+			Other: "Reconnect to the system's audio sessions",
+		},
+	})
+
+	return restartAudioTitle, restartAudioDescription
+}
+
+func getStalledWarningText(d *Deej) string {
+	return d.localizer.MustLocalize(&i18n.LocalizeConfig{
+		DefaultMessage: &i18n.Message{
+			ID:    "SessionFinderStalledTitle",
+			Other: "Audio engine not responding",
+		},
+	})
+}
+
+func getSessionErrorText(d *Deej) string {
+	return d.localizer.MustLocalize(&i18n.LocalizeConfig{
+		DefaultMessage: &i18n.Message{
+			ID:    "SessionErrorStatus",
+			Other: "Audio error, volumes may not respond",
+		},
+	})
+}
+
 func getStatusItemTitle(d *Deej) string {
 	var title string
 
-	if d.serial.GetState() {
+	if d.serial.IsReconnecting() {
+		title = d.localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{
+				ID:    "StatusReconnectingTitle",
+				Other: "Reconnecting...",
+			},
+		})
+	} else if d.serial.GetState() {
 		title = d.localizer.MustLocalize(&i18n.LocalizeConfig{
 			DefaultMessage: &i18n.Message{
 				ID:    "StatusTrueTitle",
@@ -104,14 +241,64 @@ func getStatusItemTitle(d *Deej) string {
 	return title
 }
 
-func getValuesString(d *Deej) string {
+// getValuesString lists every slider's position in percent, or as the raw 0-1023 value the board sent
+// for checking the wiring - that's before inversion, deadzone and curve are applied.
+// labeled sliders are prefixed with their label, i.e. "Music: 45 | 80"
+func getValuesString(d *Deej, raw bool) string {
 	strs := make([]string, len(d.serial.currentSliderValues))
 	for i, num := range d.serial.currentSliderValues {
-		strs[i] = strconv.FormatFloat((float64(num)/1023.0)*100, 'f', 0, 32)
+		if raw {
+			strs[i] = strconv.Itoa(num)
+		} else {
+			strs[i] = strconv.FormatFloat((float64(num)/1023.0)*100, 'f', 0, 32)
+		}
+
+		if label := d.config.SliderLabel(i); label != "" {
+			strs[i] = label + ": " + strs[i]
+		}
 	}
 	return strings.Join(strs, " | ")
 }
 
+func getSliderSessionsItemTitle(d *Deej) string {
+	return d.localizer.MustLocalize(&i18n.LocalizeConfig{
+		DefaultMessage: &i18n.Message{
+			ID:    "SliderSessionsTitle",
+			Other: "Controlled sessions",
+		},
+	})
+}
+
+// getSliderSessionString describes a session a slider controls, i.e. "Slider 0 - spotify.exe: 45%",
+// or "Music - spotify.exe: 45%" if the slider has a label
+func getSliderSessionString(d *Deej, sliderSession sliderSessionVolume) string {
+	if label := d.config.SliderLabel(sliderSession.sliderID); label != "" {
+		return d.localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{
+				ID:    "LabeledSliderSessionVolume",
+				Other: "{{.Label}} - {{.Session}}: {{.Volume}}%",
+			},
+			TemplateData: map[string]interface{}{
+				"Label":   label,
+				"Session": sliderSession.key,
+				"Volume":  strconv.FormatFloat(float64(sliderSession.volume)*100, 'f', 0, 32),
+			},
+		})
+	}
+
+	return d.localizer.MustLocalize(&i18n.LocalizeConfig{
+		DefaultMessage: &i18n.Message{
+			ID:    "SliderSessionVolume",
+			Other: "Slider {{.Slider}} - {{.Session}}: {{.Volume}}%",
+		},
+		TemplateData: map[string]interface{}{
+			"Slider":  sliderSession.sliderID,
+			"Session": sliderSession.key,
+			"Volume":  strconv.FormatFloat(float64(sliderSession.volume)*100, 'f', 0, 32),
+		},
+	})
+}
+
 func getSessionsCountString(d *Deej) string {
 	count := d.sessions.getSessionCount()
 	return d.localizer.MustLocalize(&i18n.LocalizeConfig{
@@ -127,20 +314,91 @@ func getSessionsCountString(d *Deej) string {
 	})
 }
 
+// how often the COM port menu looks for ports that were plugged in or out
+const trayPortRefreshInterval = 5 * time.Second
+
+// sessions get their volume from a different consumer of the same slider move as the tray,
+// so give it (and any volume ramp) a moment before showing what the sessions ended up at
+const traySliderSessionsDelay = 100 * time.Millisecond
+
+// tray icons users can pick in the config, besides a path to their own
+const (
+	// follows the taskbar's theme where deej can tell it, otherwise the full-color logo
+	trayIconAuto = "auto"
+
+	// named for the theme they suit: a dark icon for light taskbars and the other way around
+	trayIconLight = "light"
+	trayIconDark  = "dark"
+)
+
+// the baud rates offered in the tray, other ones can still be set in the config file
+var trayBaudRates = []int{9600, 19200, 57600, 115200}
+
+// getTrayIcon picks the icon for the configured tray_icon, falling back to the logo if a custom one can't be loaded
+func getTrayIcon(d *Deej, logger *zap.SugaredLogger) []byte {
+	switch d.config.TrayIcon {
+	case trayIconLight:
+		return icon.TrayDeejLogoLightTheme
+
+	case trayIconDark:
+		return icon.TrayDeejLogoDarkTheme
+
+	case trayIconAuto:
+		light, known := util.SystemUsesLightTheme()
+		if !known {
+			return icon.TrayDeejLogo
+		}
+
+		if light {
+			return icon.TrayDeejLogoLightTheme
+		}
+
+		return icon.TrayDeejLogoDarkTheme
+	}
+
+	// validated when the config was loaded, but the file may have changed since
+	trayIcon, err := icon.TrayIconFromFile(d.config.TrayIcon)
+	if err != nil {
+		logger.Warnw("Failed to load custom tray icon, using default", "error", err)
+		return icon.TrayDeejLogo
+	}
+
+	return trayIcon
+}
+
 func (d *Deej) initializeTray(onDone func()) {
 	logger := d.logger.Named("tray")
 
 	onReady := func() {
 		logger.Debug("Tray instance ready")
 
-		systray.SetTemplateIcon(icon.TrayDeejLogo, icon.TrayDeejLogo)
+		// re-checked when the config is reloaded and every now and then, in case the taskbar's theme changed
+		var trayIcon []byte
+		setTrayIcon := func() {
+			newIcon := getTrayIcon(d, logger)
+			if bytes.Equal(newIcon, trayIcon) {
+				return
+			}
+
+			trayIcon = newIcon
+			systray.SetTemplateIcon(trayIcon, trayIcon)
+		}
+		setTrayIcon()
 
 		systray.SetTooltip("deej")
 
+		// toggled from the settings menu, for diagnosing sliders that don't seem to reach their ends
+		showRawValues := false
+
 		setTooltip := func() {
 			title := "deej\n" + getStatusItemTitle(d)
 			if d.serial.GetState() {
-				title += "\n" + getValuesString(d)
+				title += "\n" + getValuesString(d, showRawValues)
+			}
+			if d.sessions.sessionFinderStalled() {
+				title += "\n" + getStalledWarningText(d)
+			} else if d.sessions.sessionAcquisitionError() != nil {
+				title += "\n" + getSessionErrorText(d)
 			}
 			systray.SetTooltip(title)
 		}
@@ -153,13 +411,114 @@ func (d *Deej) initializeTray(onDone func()) {
 		configTitle, configDescription := getConfigItemText(d)
 		editConfig := settings.AddSubMenuItem(configTitle, configDescription)
 
+		reloadConfigTitle, reloadConfigDescription := getReloadConfigItemText(d)
+		reloadConfig := settings.AddSubMenuItem(reloadConfigTitle, reloadConfigDescription)
+
 		autostartTitle, autostartDescription := getAutostartItemText(d)
 		autostart := settings.AddSubMenuItemCheckbox(autostartTitle, autostartDescription, util.GetAutostartState())
 
-		if util.Linux() {
+		// autostart is only implemented on Windows and Linux
+		if !util.Windows() && !util.Linux() {
 			autostart.Hide()
 		}
 
+		rawValuesTitle, rawValuesDescription := getRawValuesItemText(d)
+		rawValues := settings.AddSubMenuItemCheckbox(rawValuesTitle, rawValuesDescription, false)
+
+		comPortTitle, comPortDescription := getCOMPortItemText(d)
+		comPort := settings.AddSubMenuItem(comPortTitle, comPortDescription)
+		autoCOMPort := comPort.AddSubMenuItemCheckbox(getAutoCOMPortItemText(d), "", false)
+
+		// like session items, port items are reused and hidden when there are fewer ports.
+		// every item reports its clicks by index, which portNames maps back to the port shown there
+		portItems := []*systray.MenuItem{}
+		portNames := []string{}
+		portClicked := make(chan int)
+
+		setPortItems := func() {
+			ports, err := enumerator.GetDetailedPortsList()
+			if err != nil {
+				logger.Debugw("Failed to list serial ports", "error", err)
+			}
+
+			configuredPort := d.config.ConnectionInfo.COMPort
+			if strings.EqualFold(configuredPort, "auto") {
+				autoCOMPort.Check()
+			} else {
+				autoCOMPort.Uncheck()
+			}
+
+			portNames = portNames[:0]
+			for idx, port := range ports {
+				if idx == len(portItems) {
+					item := comPort.AddSubMenuItemCheckbox(port.Name, "", false)
+					portItems = append(portItems, item)
+
+					go func() {
+						for range item.ClickedCh {
+							portClicked <- idx
+						}
+					}()
+				}
+
+				portItems[idx].SetTitle(getPortItemTitle(port))
+				if strings.EqualFold(configuredPort, port.Name) {
+					portItems[idx].Check()
+				} else {
+					portItems[idx].Uncheck()
+				}
+				portItems[idx].Show()
+
+				portNames = append(portNames, port.Name)
+			}
+
+			for _, item := range portItems[len(ports):] {
+				item.Hide()
+			}
+		}
+		setPortItems()
+
+		baudRateTitle, baudRateDescription := getBaudRateItemText(d)
+		baudRate := settings.AddSubMenuItem(baudRateTitle, baudRateDescription)
+
+		baudRateItems := make([]*systray.MenuItem, len(trayBaudRates))
+		baudRateClicked := make(chan int)
+
+		for idx, rate := range trayBaudRates {
+			baudRateItems[idx] = baudRate.AddSubMenuItemCheckbox(strconv.Itoa(rate), "", false)
+
+			go func() {
+				for range baudRateItems[idx].ClickedCh {
+					baudRateClicked <- rate
+				}
+			}()
+		}
+
+		setBaudRateItems := func() {
+			for idx, rate := range trayBaudRates {
+				if rate == d.config.ConnectionInfo.BaudRate {
+					baudRateItems[idx].Check()
+				} else {
+					baudRateItems[idx].Uncheck()
+				}
+			}
+		}
+		setBaudRateItems()
+
+		// the tray can't tell when a menu is opened, so ports coming and going are picked up every so often instead
+		portRefreshTicker := time.NewTicker(trayPortRefreshInterval)
+
+		setCOMPort := func(port string) {
+			logger.Infow("COM port menu item clicked, switching port", "port", port)
+
+			// saving the config reconnects the board, which can take a moment
+			go func() {
+				if err := d.config.SetCOMPort(port); err != nil {
+					logger.Warnw("Failed to save COM port", "error", err)
+				}
+			}()
+		}
+
 		systray.AddSeparator()
 
 		statusInfo := systray.AddMenuItem(getStatusItemTitle(d), "")
@@ -171,7 +530,7 @@ func (d *Deej) initializeTray(onDone func()) {
 
 		setValuesInfo := func() {
 			if d.serial.GetState() {
-				valuesInfo.SetTitle(getValuesString(d))
+				valuesInfo.SetTitle(getValuesString(d, showRawValues))
 				valuesInfo.Show()
 			} else {
 				valuesInfo.Hide()
@@ -179,11 +538,95 @@ func (d *Deej) initializeTray(onDone func()) {
 		}
 		setValuesInfo()
 
+		// only shown while sessions can't be acquired, with the error itself as the item's tooltip
+		sessionErrorInfo := systray.AddMenuItem(getSessionErrorText(d), "")
+		sessionErrorInfo.Disable()
+		sessionErrorInfo.Hide()
+
+		setSessionErrorInfo := func() {
+			if err := d.sessions.sessionAcquisitionError(); err != nil {
+				sessionErrorInfo.SetTooltip(err.Error())
+				sessionErrorInfo.Show()
+			} else {
+				sessionErrorInfo.Hide()
+			}
+		}
+		setSessionErrorInfo()
+
+		// what every slider controls right now, and at which volume. hidden while nothing is controlled
+		sliderSessionsInfo := systray.AddMenuItem(getSliderSessionsItemTitle(d), "")
+		sliderSessionItems := []*systray.MenuItem{}
+
+		setSliderSessionsInfo := func() {
+			sliderSessions := d.sessions.sliderSessionVolumes()
+			for idx, sliderSession := range sliderSessions {
+				if idx == len(sliderSessionItems) {
+					item := sliderSessionsInfo.AddSubMenuItem("", "")
+					item.Disable()
+					sliderSessionItems = append(sliderSessionItems, item)
+				}
+
+				sliderSessionItems[idx].SetTitle(getSliderSessionString(d, sliderSession))
+				sliderSessionItems[idx].Show()
+			}
+
+			for _, item := range sliderSessionItems[len(sliderSessions):] {
+				item.Hide()
+			}
+
+			if len(sliderSessions) > 0 {
+				sliderSessionsInfo.Show()
+			} else {
+				sliderSessionsInfo.Hide()
+			}
+		}
+		setSliderSessionsInfo()
+
+		sliderSessionsRefresh := make(chan struct{}, 1)
+		sliderSessionsTimer := time.AfterFunc(traySliderSessionsDelay, func() {
+			select {
+			case sliderSessionsRefresh <- struct{}{}:
+			default:
+			}
+		})
+
 		sessionsInfo := systray.AddMenuItem(getSessionsCountString(d), "")
-		sessionsInfo.Disable()
+
+		// submenu items can't be removed, so they're reused and hidden when there are fewer sessions
+		sessionItems := []*systray.MenuItem{}
 
 		setSessionsInfo := func() {
 			sessionsInfo.SetTitle(getSessionsCountString(d))
+
+			names := d.sessions.getSessionDisplayNames()
+			for idx, name := range names {
+				if idx == len(sessionItems) {
+					item := sessionsInfo.AddSubMenuItem(name, "")
+					item.Disable()
+					sessionItems = append(sessionItems, item)
+				}
+
+				sessionItems[idx].SetTitle(name)
+				sessionItems[idx].Show()
+			}
+
+			for _, item := range sessionItems[len(names):] {
+				item.Hide()
+			}
+		}
+		setSessionsInfo()
+
+		// only offered once the session finder looks stuck
+		restartAudioTitle, restartAudioDescription := getRestartAudioItemText(d)
+		restartAudio := systray.AddMenuItem(restartAudioTitle, restartAudioDescription)
+		restartAudio.Hide()
+
+		setRestartAudio := func() {
+			if d.sessions.sessionFinderStalled() {
+				restartAudio.Show()
+			} else {
+				restartAudio.Hide()
+			}
 		}
 
 		if d.version != "" {
@@ -196,9 +639,13 @@ func (d *Deej) initializeTray(onDone func()) {
 		quitTitle, quitDescription := getQuitItemText(d)
 		quit := systray.AddMenuItem(quitTitle, quitDescription)
 
-		sliderMovedChannel := d.serial.SubscribeToSliderMoveEvents()
+		sliderMovedChannel := d.serial.SubscribeToSliderMoveEvents(sliderMoveConsumerTray)
+		d.serial.ReplayCurrentValues(sliderMovedChannel)
 		stateChangeChannel := d.serial.SubscribeToStateChangeEvent()
 		sessionCountChangeChannel := d.sessions.SubscribeToSessionCountChange()
+		finderStallChangeChannel := d.sessions.SubscribeToFinderStallChange()
+		sessionErrorChannel := d.sessions.SubscribeToSessionErrors()
+		configReloadedChannel := d.config.SubscribeToChanges()
 
 		// wait on things to happen
 		go func() {
@@ -208,16 +655,43 @@ func (d *Deej) initializeTray(onDone func()) {
 				case <-sliderMovedChannel:
 					setTooltip()
 					setValuesInfo()
+					sliderSessionsTimer.Reset(traySliderSessionsDelay + d.config.VolumeRamp)
+
+				case <-sliderSessionsRefresh:
+					setSliderSessionsInfo()
 
 				// connection state changed
 				case <-stateChangeChannel:
 					setTooltip()
 					setValuesInfo()
+					setSliderSessionsInfo()
+					setPortItems()
+					setBaudRateItems()
 					statusInfo.SetTitle(getStatusItemTitle(d))
 
 				// session count changed
 				case <-sessionCountChangeChannel:
 					setSessionsInfo()
+					setSliderSessionsInfo()
+
+				// session finder stalled or recovered
+				case <-finderStallChangeChannel:
+					setTooltip()
+					setRestartAudio()
+
+				// sessions couldn't be acquired, or could again
+				case <-sessionErrorChannel:
+					setTooltip()
+					setSessionErrorInfo()
+
+				case <-restartAudio.ClickedCh:
+					logger.Info("Restart audio engine menu item clicked, restarting session finder")
+
+					go func() {
+						if err := d.sessions.restartSessionFinder(); err != nil {
+							logger.Warnw("Failed to restart session finder", "error", err)
+						}
+					}()
 
 				// quit
 				case <-quit.ClickedCh:
@@ -233,6 +707,52 @@ func (d *Deej) initializeTray(onDone func()) {
 						logger.Warnw("Failed to open config file for editing", "error", err)
 					}
 
+				case <-reloadConfig.ClickedCh:
+					logger.Info("Reload config menu item clicked, reloading config")
+
+					go func() {
+						if err := d.config.Reload(d.localizer); err != nil {
+							logger.Warnw("Failed to reload config file", "error", err)
+						}
+					}()
+
+				case <-rawValues.ClickedCh:
+					showRawValues = !showRawValues
+					if showRawValues {
+						rawValues.Check()
+					} else {
+						rawValues.Uncheck()
+					}
+
+					setTooltip()
+					setValuesInfo()
+
+				case <-portRefreshTicker.C:
+					setPortItems()
+					setBaudRateItems()
+					setTrayIcon()
+
+				case <-configReloadedChannel:
+					setTrayIcon()
+
+				case <-autoCOMPort.ClickedCh:
+					setCOMPort("auto")
+
+				case idx := <-portClicked:
+					if idx < len(portNames) {
+						setCOMPort(portNames[idx])
+					}
+
+				case rate := <-baudRateClicked:
+					logger.Infow("Baud rate menu item clicked, switching baud rate", "baudRate", rate)
+
+					// saving the config reconnects the board, which can take a moment
+					go func() {
+						if err := d.config.SetBaudRate(rate); err != nil {
+							logger.Warnw("Failed to save baud rate", "error", err)
+						}
+					}()
+
 				case <-autostart.ClickedCh:
 					util.SetAutostartState(!util.GetAutostartState())
 					if util.GetAutostartState() {
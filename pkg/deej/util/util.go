@@ -1,11 +1,17 @@
 package util
 
 import (
+	"context"
 	"fmt"
 	"math"
 	"os"
+	"os/exec"
 	"os/signal"
+	"path/filepath"
 	"runtime"
+	"slices"
+	"strconv"
+	"strings"
 	"syscall"
 
 	"go.uber.org/zap"
@@ -25,6 +31,11 @@ func OpenExternal(logger *zap.SugaredLogger, filename string) error {
 	return nil
 }
 
+// ShellCommand prepares a command line to be run by the platform's shell, killing it once ctx is done
+func ShellCommand(ctx context.Context, command string) *exec.Cmd {
+	return getShellCommand(ctx, command)
+}
+
 // EnsureDirExists creates the given directory path if it doesn't already exist
 func EnsureDirExists(path string) error {
 	if err := os.MkdirAll(path, os.ModePerm); err != nil {
@@ -34,6 +45,23 @@ func EnsureDirExists(path string) error {
 	return nil
 }
 
+// EnsureDirWritable creates the given directory path if needed, and makes sure files can be created in it
+func EnsureDirWritable(path string) error {
+	if err := EnsureDirExists(path); err != nil {
+		return err
+	}
+
+	probe, err := os.CreateTemp(path, ".deej-write-test-*")
+	if err != nil {
+		return fmt.Errorf("create file in directory (%s): %w", path, err)
+	}
+
+	probe.Close()
+	os.Remove(probe.Name())
+
+	return nil
+}
+
 // FileExists checks if a file exists and is not a directory before we
 // try using it to prevent further errors.
 func FileExists(filename string) bool {
@@ -44,11 +72,57 @@ func FileExists(filename string) bool {
 	return !info.IsDir()
 }
 
+// WriteFileAtomic replaces the contents of the given file by writing them to a temporary file
+// next to it and renaming that over the original, so readers never see a half-written file
+func WriteFileAtomic(filename string, data []byte) error {
+	perm := os.FileMode(0644)
+	if info, err := os.Stat(filename); err == nil {
+		perm = info.Mode().Perm()
+	}
+
+	tempFile, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("create temp file: %w", err)
+	}
+
+	tempFilename := tempFile.Name()
+	defer os.Remove(tempFilename)
+
+	if _, err := tempFile.Write(data); err != nil {
+		tempFile.Close()
+		return fmt.Errorf("write temp file: %w", err)
+	}
+
+	if err := tempFile.Sync(); err != nil {
+		tempFile.Close()
+		return fmt.Errorf("sync temp file: %w", err)
+	}
+
+	if err := tempFile.Close(); err != nil {
+		return fmt.Errorf("close temp file: %w", err)
+	}
+
+	if err := os.Chmod(tempFilename, perm); err != nil {
+		return fmt.Errorf("set temp file permissions: %w", err)
+	}
+
+	if err := os.Rename(tempFilename, filename); err != nil {
+		return fmt.Errorf("replace file: %w", err)
+	}
+
+	return nil
+}
+
 // Linux returns true if we're running on Linux
 func Linux() bool {
 	return runtime.GOOS == "linux"
 }
 
+// Windows returns true if we're running on Windows
+func Windows() bool {
+	return runtime.GOOS == "windows"
+}
+
 // SetupCloseHandler creates a 'listener' on a new goroutine which will notify the
 // program if it receives an interrupt from the OS
 func SetupCloseHandler() chan os.Signal {
@@ -65,6 +139,136 @@ func GetCurrentWindowProcessNames(checkFullscreen bool) ([]string, error) {
 	return getCurrentWindowProcessNames(checkFullscreen)
 }
 
+// RemoteSession returns true if deej runs inside a remote desktop session or in session 0,
+// where audio devices and per-app volume may not behave like they do locally.
+// This is currently only implemented for Windows
+func RemoteSession() bool {
+	return remoteSession()
+}
+
+// media keys that can be pressed with PressMediaKey
+const (
+	MediaKeyNext      = "medianext"
+	MediaKeyPrevious  = "mediaprev"
+	MediaKeyPlayPause = "mediaplaypause"
+	MediaKeyStop      = "mediastop"
+)
+
+// MediaKeys lists every media key PressMediaKey knows about
+var MediaKeys = []string{MediaKeyNext, MediaKeyPrevious, MediaKeyPlayPause, MediaKeyStop}
+
+// PressMediaKey presses and releases one of the MediaKeys, as if it was pressed on a keyboard.
+// This is currently only implemented for Windows and Linux (X11 only)
+func PressMediaKey(key string) error {
+	if !slices.Contains(MediaKeys, key) {
+		return fmt.Errorf("unknown media key %q, expected one of %v", key, MediaKeys)
+	}
+
+	return pressMediaKey(key)
+}
+
+// Hotkey is a key combination that can be listened for system-wide with RegisterHotkey
+type Hotkey struct {
+	Modifiers HotkeyModifier
+
+	// a lowercase letter or digit, f1 through f24, or one of hotkeyNamedKeys
+	Key string
+}
+
+// HotkeyModifier is a set of modifier keys that have to be held down for a hotkey
+type HotkeyModifier int
+
+const (
+	HotkeyCtrl HotkeyModifier = 1 << iota
+	HotkeyAlt
+	HotkeyShift
+	HotkeySuper
+)
+
+var hotkeyModifierNames = map[string]HotkeyModifier{
+	"ctrl":    HotkeyCtrl,
+	"control": HotkeyCtrl,
+	"alt":     HotkeyAlt,
+	"shift":   HotkeyShift,
+	"win":     HotkeySuper,
+	"super":   HotkeySuper,
+}
+
+// keys besides letters, digits and function keys that hotkeys can use
+var hotkeyNamedKeys = []string{"space", "insert", "delete", "home", "end", "pageup", "pagedown", "pause", "scrolllock"}
+
+// ParseHotkey reads a hotkey written like "ctrl+alt+m". letters, digits and space need at least one modifier,
+// otherwise they'd be taken away from typing
+func ParseHotkey(hotkey string) (Hotkey, error) {
+	parts := strings.Split(strings.ToLower(strings.ReplaceAll(hotkey, " ", "")), "+")
+
+	result := Hotkey{Key: parts[len(parts)-1]}
+
+	for _, part := range parts[:len(parts)-1] {
+		modifier, ok := hotkeyModifierNames[part]
+		if !ok {
+			return Hotkey{}, fmt.Errorf("unknown modifier %q, expected ctrl, alt, shift or win", part)
+		}
+
+		result.Modifiers |= modifier
+	}
+
+	_, isFunctionKey := hotkeyFunctionKey(result.Key)
+	isCharacter := len(result.Key) == 1 && (result.Key[0] >= 'a' && result.Key[0] <= 'z' || result.Key[0] >= '0' && result.Key[0] <= '9')
+
+	if !isFunctionKey && !isCharacter && !slices.Contains(hotkeyNamedKeys, result.Key) {
+		return Hotkey{}, fmt.Errorf("unknown key %q, expected a letter, a digit, f1-f24 or one of %v", result.Key, hotkeyNamedKeys)
+	}
+
+	if result.Modifiers == 0 && (isCharacter || result.Key == "space") {
+		return Hotkey{}, fmt.Errorf("key %q needs at least one modifier", result.Key)
+	}
+
+	return result, nil
+}
+
+// String writes the hotkey out the same way ParseHotkey reads it, with modifiers in a fixed order
+func (h Hotkey) String() string {
+	parts := []string{}
+
+	for _, modifier := range []struct {
+		modifier HotkeyModifier
+		name     string
+	}{{HotkeyCtrl, "ctrl"}, {HotkeyAlt, "alt"}, {HotkeyShift, "shift"}, {HotkeySuper, "win"}} {
+		if h.Modifiers&modifier.modifier != 0 {
+			parts = append(parts, modifier.name)
+		}
+	}
+
+	return strings.Join(append(parts, h.Key), "+")
+}
+
+// hotkeyFunctionKey returns the number of a function key, i.e. 5 for f5
+func hotkeyFunctionKey(key string) (int, bool) {
+	if !strings.HasPrefix(key, "f") {
+		return 0, false
+	}
+
+	number, err := strconv.Atoi(key[1:])
+	if err != nil || number < 1 || number > 24 {
+		return 0, false
+	}
+
+	return number, true
+}
+
+// RegisterHotkey grabs the hotkey system-wide and calls pressed (from a goroutine of its own) every time it's pressed,
+// until the returned function is called. This is currently only implemented for Windows and Linux (X11 only)
+func RegisterHotkey(hotkey Hotkey, pressed func()) (unregister func(), err error) {
+	return registerHotkey(hotkey, pressed)
+}
+
+// SystemUsesLightTheme tells whether the taskbar uses a light theme, and so wants a dark tray icon.
+// known is false where deej can't tell, which is everywhere but Windows 10 and later
+func SystemUsesLightTheme() (light bool, known bool) {
+	return systemUsesLightTheme()
+}
+
 func GetAutostartState() bool {
 	return getAutostartState()
 }
@@ -79,6 +283,88 @@ func NormalizeScalar(v float32) float32 {
 	return float32(math.Round(float64(v)*100) / 100.0)
 }
 
+// ApplyDeadzone snaps slider positions within the given distance of either end to exactly 0.0 or 1.0,
+// for sliders that can't physically reach their ends
+func ApplyDeadzone(scalar float32, deadzone float64) float32 {
+	if deadzone <= 0 {
+		return scalar
+	}
+
+	if float64(scalar) <= deadzone {
+		return 0
+	}
+
+	if float64(scalar) >= 1-deadzone {
+		return 1
+	}
+
+	return scalar
+}
+
+// CurveSpec describes how slider positions map to volume levels
+type CurveSpec struct {
+	Kind string
+
+	// only used by power curves
+	Exponent float64
+}
+
+const (
+	CurveLinear      = "linear"
+	CurveLogarithmic = "logarithmic"
+	CurvePower       = "power"
+
+	// the logarithmic curve covers this many decibels between the slider's bottom and top, roughly
+	// matching an audio taper pot. it's adjusted so the bottom still ends up at exactly 0
+	logarithmicCurveRangeDB = 40.0
+)
+
+// ParseCurve reads a curve spec written as "linear", "logarithmic" or "power:<exponent>", i.e. "power:2.0"
+func ParseCurve(value string) (CurveSpec, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+
+	kind, argument, hasArgument := strings.Cut(value, ":")
+
+	switch {
+	case (kind == CurveLinear || kind == CurveLogarithmic) && !hasArgument:
+		return CurveSpec{Kind: kind}, nil
+
+	case kind == CurvePower && hasArgument:
+		exponent, err := strconv.ParseFloat(strings.TrimSpace(argument), 64)
+		if err != nil || exponent <= 0 || math.IsInf(exponent, 0) {
+			return CurveSpec{}, fmt.Errorf("invalid power curve exponent: %s", argument)
+		}
+
+		return CurveSpec{Kind: CurvePower, Exponent: exponent}, nil
+	}
+
+	return CurveSpec{}, fmt.Errorf("unknown volume curve: %s", value)
+}
+
+// ApplyCurve maps a slider position between 0.0 and 1.0 onto the given curve.
+// 0.0 and 1.0 always stay put, whatever the curve
+func ApplyCurve(scalar float32, curve CurveSpec) float32 {
+	if scalar <= 0 {
+		return 0
+	}
+
+	if scalar >= 1 {
+		return 1
+	}
+
+	v := float64(scalar)
+
+	switch curve.Kind {
+	case CurveLogarithmic:
+		base := math.Pow(10, logarithmicCurveRangeDB/20)
+		v = (math.Pow(base, v) - 1) / (base - 1)
+	case CurvePower:
+		v = math.Pow(v, curve.Exponent)
+	}
+
+	return NormalizeScalar(float32(v))
+}
+
 // SignificantlyDifferent returns true if there's a significant enough volume difference between two given values
 func SignificantlyDifferent(oldValue int, newValue int, noiseReductionLevel string) bool {
 	const (
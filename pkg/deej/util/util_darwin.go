@@ -0,0 +1,45 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+)
+
+func getCurrentWindowProcessNames(_ bool) ([]string, error) {
+	return nil, errors.New("not implemented")
+}
+
+func getOpenExternalCommand(filename string) *exec.Cmd {
+	return exec.Command("open", filename)
+}
+
+func getShellCommand(ctx context.Context, command string) *exec.Cmd {
+	return exec.CommandContext(ctx, "/bin/sh", "-c", command)
+}
+
+func remoteSession() bool {
+	return false
+}
+
+func pressMediaKey(_ string) error {
+	return errors.New("not implemented")
+}
+
+func registerHotkey(_ Hotkey, _ func()) (func(), error) {
+	return nil, errors.New("not implemented")
+}
+
+func systemUsesLightTheme() (bool, bool) {
+	return false, false
+}
+
+// do nothing
+func getAutostartState() bool {
+	return false
+}
+
+// do nothing
+func setAutostartState(_ bool) error {
+	return errors.New("not implemented")
+}
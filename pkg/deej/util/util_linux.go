@@ -1,24 +1,478 @@
 package util
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jezek/xgb"
+	"github.com/jezek/xgb/xproto"
+	"github.com/jezek/xgb/xtest"
+)
+
+const (
+	getCurrentWindowInternalCooldown = time.Millisecond * 350
+)
+
+var (
+	lastGetCurrentWindowResult []string
+	lastGetCurrentWindowCall   = time.Now()
+
+	// the X connection is kept around between calls, and dropped whenever something goes wrong with it
+	x11Lock  sync.Mutex
+	x11Conn  *xgb.Conn
+	x11Atoms map[string]xproto.Atom
+
+	// whether the XTest extension, used for pressing keys, was set up on the current X connection
+	x11XTestReady bool
 )
 
-func getCurrentWindowProcessNames(_ bool) ([]string, error) {
-	return nil, errors.New("not implemented")
+var x11AtomNames = []string{
+	"_NET_ACTIVE_WINDOW",
+	"_NET_WM_PID",
+	"_NET_WM_STATE",
+	"_NET_WM_STATE_FULLSCREEN",
+}
+
+func getCurrentWindowProcessNames(checkFullscreen bool) ([]string, error) {
+
+	// wayland doesn't let clients see other clients' windows at all, and XWayland only knows about X11 apps
+	if os.Getenv("XDG_SESSION_TYPE") == "wayland" || os.Getenv("DISPLAY") == "" {
+		return nil, errors.New("active window is only available under X11")
+	}
+
+	x11Lock.Lock()
+	defer x11Lock.Unlock()
+
+	// same internal cooldown as on windows, this gets called on every slider move
+	now := time.Now()
+	if lastGetCurrentWindowCall.Add(getCurrentWindowInternalCooldown).After(now) {
+		return lastGetCurrentWindowResult, nil
+	}
+
+	lastGetCurrentWindowCall = now
+
+	conn, err := getX11Conn()
+	if err != nil {
+		lastGetCurrentWindowResult = nil
+		return nil, err
+	}
+
+	root := xproto.Setup(conn).DefaultScreen(conn).Root
+
+	activeWindow, err := getX11Property(conn, root, x11Atoms["_NET_ACTIVE_WINDOW"], xproto.AtomWindow)
+	if err != nil {
+		dropX11Conn()
+		lastGetCurrentWindowResult = nil
+		return nil, fmt.Errorf("get active window: %w", err)
+	}
+
+	// no window has focus (i.e. the desktop)
+	if len(activeWindow) == 0 || activeWindow[0] == 0 {
+		lastGetCurrentWindowResult = nil
+		return nil, nil
+	}
+
+	window := xproto.Window(activeWindow[0])
+
+	if checkFullscreen {
+		state, err := getX11Property(conn, window, x11Atoms["_NET_WM_STATE"], xproto.AtomAtom)
+		if err != nil || !containsUint32(state, uint32(x11Atoms["_NET_WM_STATE_FULLSCREEN"])) {
+			lastGetCurrentWindowResult = nil
+			return nil, fmt.Errorf("window is not in fullscreen mode")
+		}
+	}
+
+	pid, err := getX11Property(conn, window, x11Atoms["_NET_WM_PID"], xproto.AtomCardinal)
+	if err != nil || len(pid) == 0 || pid[0] == 0 {
+
+		// not every client sets its PID, there's nothing to go on in that case
+		lastGetCurrentWindowResult = nil
+		return nil, nil
+	}
+
+	result := processNamesForPID(pid[0])
+
+	// cache & return whichever executable names we ended up with
+	lastGetCurrentWindowResult = result
+	return result, nil
+}
+
+// getX11Conn returns the shared X connection, connecting and interning the atoms we need if there's none yet.
+// must be called with x11Lock held
+func getX11Conn() (*xgb.Conn, error) {
+	if x11Conn != nil {
+		return x11Conn, nil
+	}
+
+	conn, err := xgb.NewConn()
+	if err != nil {
+		return nil, fmt.Errorf("connect to X server: %w", err)
+	}
+
+	atoms := make(map[string]xproto.Atom, len(x11AtomNames))
+	for _, name := range x11AtomNames {
+		reply, err := xproto.InternAtom(conn, false, uint16(len(name)), name).Reply()
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("intern atom %s: %w", name, err)
+		}
+
+		atoms[name] = reply.Atom
+	}
+
+	x11Conn = conn
+	x11Atoms = atoms
+
+	return conn, nil
+}
+
+// dropX11Conn closes the shared X connection so the next call reconnects. must be called with x11Lock held
+func dropX11Conn() {
+	if x11Conn == nil {
+		return
+	}
+
+	x11Conn.Close()
+	x11Conn = nil
+	x11Atoms = nil
+	x11XTestReady = false
+}
+
+// getX11Property reads a property made of 32-bit values (windows, atoms, cardinals) off a window
+func getX11Property(conn *xgb.Conn, window xproto.Window, property xproto.Atom, propertyType xproto.Atom) ([]uint32, error) {
+	reply, err := xproto.GetProperty(conn, false, window, property, propertyType, 0, 64).Reply()
+	if err != nil {
+		return nil, err
+	}
+
+	if reply.Format != 32 {
+		return nil, nil
+	}
+
+	values := make([]uint32, 0, reply.ValueLen)
+	for i := 0; i+4 <= len(reply.Value) && len(values) < int(reply.ValueLen); i += 4 {
+		values = append(values, xgb.Get32(reply.Value[i:]))
+	}
+
+	return values, nil
+}
+
+// processNamesForPID returns the names a process could be mapped by. /proc/<pid>/comm is cut off at 15 characters,
+// so the executable's file name is included too when it's different (and readable)
+func processNamesForPID(pid uint32) []string {
+	result := []string{}
+
+	if comm, err := os.ReadFile(fmt.Sprintf("/proc/%d/comm", pid)); err == nil {
+		result = append(result, strings.TrimSpace(string(comm)))
+	}
+
+	if exe, err := os.Readlink(fmt.Sprintf("/proc/%d/exe", pid)); err == nil {
+		if name := filepath.Base(exe); len(result) == 0 || name != result[0] {
+			result = append(result, name)
+		}
+	}
+
+	return result
+}
+
+func containsUint32(values []uint32, value uint32) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// media keys go by these keysyms (XF86AudioNext and friends) in X
+var mediaKeySyms = map[string]xproto.Keysym{
+	MediaKeyNext:      0x1008FF17,
+	MediaKeyPrevious:  0x1008FF16,
+	MediaKeyPlayPause: 0x1008FF14,
+	MediaKeyStop:      0x1008FF15,
+}
+
+func pressMediaKey(key string) error {
+	if os.Getenv("XDG_SESSION_TYPE") == "wayland" || os.Getenv("DISPLAY") == "" {
+		return errors.New("media keys are only available under X11")
+	}
+
+	x11Lock.Lock()
+	defer x11Lock.Unlock()
+
+	conn, err := getX11Conn()
+	if err != nil {
+		return err
+	}
+
+	if !x11XTestReady {
+		if err := xtest.Init(conn); err != nil {
+			return fmt.Errorf("initialize XTest extension: %w", err)
+		}
+
+		x11XTestReady = true
+	}
+
+	setup := xproto.Setup(conn)
+	keycodeCount := byte(setup.MaxKeycode - setup.MinKeycode + 1)
+
+	mapping, err := xproto.GetKeyboardMapping(conn, setup.MinKeycode, keycodeCount).Reply()
+	if err != nil {
+		dropX11Conn()
+		return fmt.Errorf("get keyboard mapping: %w", err)
+	}
+
+	// each keycode has KeysymsPerKeycode keysyms, for shift and the like
+	keycode := xproto.Keycode(0)
+	for idx, keysym := range mapping.Keysyms {
+		if keysym == mediaKeySyms[key] {
+			keycode = setup.MinKeycode + xproto.Keycode(idx/int(mapping.KeysymsPerKeycode))
+			break
+		}
+	}
+
+	if keycode == 0 {
+		return fmt.Errorf("keyboard layout has no %s key", key)
+	}
+
+	root := setup.DefaultScreen(conn).Root
+
+	for _, eventType := range []byte{xproto.KeyPress, xproto.KeyRelease} {
+		if err := xtest.FakeInputChecked(conn, eventType, byte(keycode), 0, root, 0, 0, 0).Check(); err != nil {
+			dropX11Conn()
+			return fmt.Errorf("press %s key: %w", key, err)
+		}
+	}
+
+	return nil
+}
+
+var hotkeyKeySyms = map[string]xproto.Keysym{
+	"space":      0x0020,
+	"insert":     0xFF63,
+	"delete":     0xFFFF,
+	"home":       0xFF50,
+	"end":        0xFF57,
+	"pageup":     0xFF55,
+	"pagedown":   0xFF56,
+	"pause":      0xFF13,
+	"scrolllock": 0xFF14,
+}
+
+var hotkeyModifierMasks = map[HotkeyModifier]uint16{
+	HotkeyCtrl:  xproto.ModMaskControl,
+	HotkeyAlt:   xproto.ModMask1,
+	HotkeyShift: xproto.ModMaskShift,
+	HotkeySuper: xproto.ModMask4,
+}
+
+// X only delivers a grabbed key with exactly the grabbed modifiers, so the hotkey is grabbed again with
+// every combination of caps lock and num lock (mod2), otherwise it'd stop working whenever one of them is on
+var hotkeyIgnoredModifierMasks = []uint16{0, xproto.ModMaskLock, xproto.ModMask2, xproto.ModMaskLock | xproto.ModMask2}
+
+const hotkeyKeySymF1 = 0xFFBE
+
+func registerHotkey(hotkey Hotkey, pressed func()) (func(), error) {
+	if os.Getenv("XDG_SESSION_TYPE") == "wayland" || os.Getenv("DISPLAY") == "" {
+		return nil, errors.New("global hotkeys are only available under X11")
+	}
+
+	keysym, ok := hotkeyKeySyms[hotkey.Key]
+	if number, isFunctionKey := hotkeyFunctionKey(hotkey.Key); isFunctionKey {
+		keysym = xproto.Keysym(hotkeyKeySymF1 + number - 1)
+	} else if !ok {
+
+		// letters and digits have the keysyms of their (lowercase) ASCII characters
+		keysym = xproto.Keysym(hotkey.Key[0])
+	}
+
+	modifiers := uint16(0)
+	for modifier, mask := range hotkeyModifierMasks {
+		if hotkey.Modifiers&modifier != 0 {
+			modifiers |= mask
+		}
+	}
+
+	// this needs a connection of its own, since it spends its life waiting for events
+	conn, err := xgb.NewConn()
+	if err != nil {
+		return nil, fmt.Errorf("connect to X server: %w", err)
+	}
+
+	setup := xproto.Setup(conn)
+	keycodeCount := byte(setup.MaxKeycode - setup.MinKeycode + 1)
+
+	mapping, err := xproto.GetKeyboardMapping(conn, setup.MinKeycode, keycodeCount).Reply()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("get keyboard mapping: %w", err)
+	}
+
+	keycode := xproto.Keycode(0)
+	for idx, mappedKeysym := range mapping.Keysyms {
+		if mappedKeysym == keysym {
+			keycode = setup.MinKeycode + xproto.Keycode(idx/int(mapping.KeysymsPerKeycode))
+			break
+		}
+	}
+
+	if keycode == 0 {
+		conn.Close()
+		return nil, fmt.Errorf("keyboard layout has no %s key", hotkey.Key)
+	}
+
+	root := setup.DefaultScreen(conn).Root
+
+	for _, ignoredMask := range hotkeyIgnoredModifierMasks {
+		if err := xproto.GrabKeyChecked(conn, true, root, modifiers|ignoredMask, keycode,
+			xproto.GrabModeAsync, xproto.GrabModeAsync).Check(); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("grab hotkey %s (is another app using it?): %w", hotkey, err)
+		}
+	}
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		var lastRelease xproto.Timestamp
+
+		for {
+			event, err := conn.WaitForEvent()
+
+			// both are nil once the connection is closed
+			if event == nil && err == nil {
+				return
+			}
+
+			switch event := event.(type) {
+			case xproto.KeyReleaseEvent:
+				lastRelease = event.Time
+
+			// a held key repeats as release and press pairs that share a timestamp, those don't count
+			case xproto.KeyPressEvent:
+				if event.Detail == keycode && event.Time != lastRelease {
+					go pressed()
+				}
+			}
+		}
+	}()
+
+	// closing the connection also releases the grabs
+	return func() {
+		conn.Close()
+		<-done
+	}, nil
 }
 
 func getOpenExternalCommand(filename string) *exec.Cmd {
 	return exec.Command("xdg-open", filename)
 }
 
-// do nothing
-func getAutostartState() bool {
+func getShellCommand(ctx context.Context, command string) *exec.Cmd {
+	return exec.CommandContext(ctx, "/bin/sh", "-c", command)
+}
+
+func remoteSession() bool {
 	return false
 }
 
-// do nothing
-func setAutostartState(_ bool) error {
-	return errors.New("not implemented")
+// desktops don't agree on a way to tell the panel's theme
+func systemUsesLightTheme() (bool, bool) {
+	return false, false
+}
+
+// autostart on linux follows the XDG autostart spec: desktop entries in ~/.config/autostart are launched on login
+const autostartDesktopEntryName = "deej.desktop"
+
+const autostartDesktopEntryFormat = `[Desktop Entry]
+Type=Application
+Name=deej
+Comment=Hardware volume mixer
+Exec=%s
+Terminal=false
+X-GNOME-Autostart-enabled=true
+`
+
+func autostartDesktopEntryPath() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("get user config dir: %w", err)
+	}
+
+	return filepath.Join(configDir, "autostart", autostartDesktopEntryName), nil
+}
+
+func getAutostartState() bool {
+	path, err := autostartDesktopEntryPath()
+	if err != nil {
+		return false
+	}
+
+	return FileExists(path)
+}
+
+func setAutostartState(state bool) error {
+	path, err := autostartDesktopEntryPath()
+	if err != nil {
+		return err
+	}
+
+	if !state {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("remove desktop entry: %w", err)
+		}
+
+		return nil
+	}
+
+	ex, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("get executable path: %w", err)
+	}
+
+	execArgs := []string{quoteDesktopEntryArg(ex)}
+	for _, arg := range os.Args[1:] {
+		execArgs = append(execArgs, quoteDesktopEntryArg(arg))
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("create autostart dir: %w", err)
+	}
+
+	contents := fmt.Sprintf(autostartDesktopEntryFormat, strings.Join(execArgs, " "))
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		return fmt.Errorf("write desktop entry: %w", err)
+	}
+
+	return nil
+}
+
+// quoteDesktopEntryArg quotes an argument for a desktop entry's Exec key, so paths with spaces survive.
+// the spec escapes some characters inside quotes, then escapes backslashes again for the string value,
+// and field codes start with a percent sign - so a literal one is doubled
+func quoteDesktopEntryArg(arg string) string {
+	arg = strings.ReplaceAll(arg, "%", "%%")
+
+	if !strings.ContainsAny(arg, " \t\n\"'\\><~|&;$*?#()`") {
+		return arg
+	}
+
+	escaped := strings.NewReplacer(
+		`\`, `\\\\`,
+		`"`, `\\"`,
+		"`", "\\\\`",
+		`$`, `\\$`,
+	).Replace(arg)
+
+	return `"` + escaped + `"`
 }
@@ -0,0 +1,44 @@
+package util
+
+import (
+	"testing"
+)
+
+func TestParseHotkey(t *testing.T) {
+	tests := []struct {
+		name          string
+		hotkey        string
+		expected      Hotkey
+		expectFailure bool
+	}{
+		{"modifier and letter", "ctrl+m", Hotkey{Modifiers: HotkeyCtrl, Key: "m"}, false},
+		{"all modifiers", "ctrl+alt+shift+win+1", Hotkey{Modifiers: HotkeyCtrl | HotkeyAlt | HotkeyShift | HotkeySuper, Key: "1"}, false},
+		{"modifier aliases", "control+super+space", Hotkey{Modifiers: HotkeyCtrl | HotkeySuper, Key: "space"}, false},
+		{"upper case and spaces", "Ctrl + Alt + M", Hotkey{Modifiers: HotkeyCtrl | HotkeyAlt, Key: "m"}, false},
+
+		// keys that don't type anything work on their own
+		{"function key alone", "F13", Hotkey{Key: "f13"}, false},
+		{"named key alone", "PageDown", Hotkey{Key: "pagedown"}, false},
+
+		{"letter alone", "m", Hotkey{}, true},
+		{"space alone", "space", Hotkey{}, true},
+		{"unknown key", "ctrl+enter", Hotkey{}, true},
+		{"function key out of range", "ctrl+f25", Hotkey{}, true},
+		{"unknown modifier", "meta+m", Hotkey{}, true},
+		{"no key", "ctrl+", Hotkey{}, true},
+		{"empty", "", Hotkey{}, true},
+	}
+
+	for _, test := range tests {
+		hotkey, err := ParseHotkey(test.hotkey)
+
+		if (err != nil) != test.expectFailure {
+			t.Errorf("%s: err = %v, expected failure: %v", test.name, err, test.expectFailure)
+			continue
+		}
+
+		if hotkey != test.expected {
+			t.Errorf("%s: ParseHotkey(%q) = %+v, expected %+v", test.name, test.hotkey, hotkey, test.expected)
+		}
+	}
+}
@@ -1,10 +1,12 @@
 package util
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"sync"
 	"syscall"
@@ -106,7 +108,7 @@ func getCurrentWindowProcessNames(checkFullscreen bool) ([]string, error) {
 
 	// create callback once
 	enumChildOnce.Do(func() {
-		enumChildCallbackPtr = syscall.NewCallback(enumChildWindowsCallback)
+		enumChildCallbackPtr = win.NewCallback(enumChildWindowsCallback)
 	})
 
 	ctx := &enumChildContext{
@@ -126,6 +128,20 @@ func getOpenExternalCommand(filename string) *exec.Cmd {
 	return exec.Command(filepath.Join(os.Getenv("SYSTEMROOT"), "System32", "rundll32.exe"), "url.dll,FileProtocolHandler", filename)
 }
 
+func getShellCommand(ctx context.Context, command string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, filepath.Join(os.Getenv("SYSTEMROOT"), "System32", "cmd.exe"))
+
+	// cmd.exe doesn't follow the usual argument quoting rules, so the command line is passed as-is.
+	// deej has no console of its own, so don't pop one up either
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		CmdLine:       `cmd.exe /S /C "` + command + `"`,
+		HideWindow:    true,
+		CreationFlags: windows.CREATE_NO_WINDOW,
+	}
+
+	return cmd
+}
+
 // check if the window is in fullscreen mode
 //
 // inspired by https://chromium.googlesource.com/chromium/src/+/refs/tags/134.0.6996.1/ui/base/fullscreen_win.cc
@@ -174,6 +190,139 @@ func isWindowFullscreen(hwnd windows.HWND) bool {
 		(exStyle&(win.WS_EX_WINDOWEDGE|win.WS_EX_TOOLWINDOW)) != 0)
 }
 
+func remoteSession() bool {
+	if win.GetSystemMetrics(win.SM_REMOTESESSION) != 0 {
+		return true
+	}
+
+	// session 0 is reserved for services and has no interactive audio
+	var sessionID uint32
+	if err := windows.ProcessIdToSessionId(windows.GetCurrentProcessId(), &sessionID); err != nil {
+		return false
+	}
+
+	return sessionID == 0
+}
+
+var mediaKeyCodes = map[string]byte{
+	MediaKeyNext:      win.VK_MEDIA_NEXT_TRACK,
+	MediaKeyPrevious:  win.VK_MEDIA_PREV_TRACK,
+	MediaKeyPlayPause: win.VK_MEDIA_PLAY_PAUSE,
+	MediaKeyStop:      win.VK_MEDIA_STOP,
+}
+
+func pressMediaKey(key string) error {
+	vk := mediaKeyCodes[key]
+
+	win.KeybdEvent(vk, win.KEYEVENTF_EXTENDEDKEY)
+	win.KeybdEvent(vk, win.KEYEVENTF_EXTENDEDKEY|win.KEYEVENTF_KEYUP)
+
+	return nil
+}
+
+var hotkeyVirtualKeys = map[string]uint32{
+	"space":      win.VK_SPACE,
+	"insert":     win.VK_INSERT,
+	"delete":     win.VK_DELETE,
+	"home":       win.VK_HOME,
+	"end":        win.VK_END,
+	"pageup":     win.VK_PRIOR,
+	"pagedown":   win.VK_NEXT,
+	"pause":      win.VK_PAUSE,
+	"scrolllock": win.VK_SCROLL,
+}
+
+var hotkeyModifierFlags = map[HotkeyModifier]uint32{
+	HotkeyCtrl:  win.MOD_CONTROL,
+	HotkeyAlt:   win.MOD_ALT,
+	HotkeyShift: win.MOD_SHIFT,
+	HotkeySuper: win.MOD_WIN,
+}
+
+// hotkeys are registered without a window, so the ID only has to be unique within the registering thread
+const hotkeyID = 1
+
+func registerHotkey(hotkey Hotkey, pressed func()) (func(), error) {
+	vk, ok := hotkeyVirtualKeys[hotkey.Key]
+	if number, isFunctionKey := hotkeyFunctionKey(hotkey.Key); isFunctionKey {
+		vk = win.VK_F1 + uint32(number-1)
+	} else if !ok {
+
+		// letters and digits have the virtual key codes of their uppercase ASCII characters
+		vk = uint32(strings.ToUpper(hotkey.Key)[0])
+	}
+
+	// holding the hotkey down shouldn't toggle anything over and over
+	modifiers := uint32(win.MOD_NOREPEAT)
+	for modifier, flag := range hotkeyModifierFlags {
+		if hotkey.Modifiers&modifier != 0 {
+			modifiers |= flag
+		}
+	}
+
+	registered := make(chan error, 1)
+	done := make(chan struct{})
+	var threadID uint32
+
+	go func() {
+		defer close(done)
+
+		// WM_HOTKEY is posted to the thread that registered the hotkey, so this goroutine has to stay on it
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+
+		// make sure the thread has a message queue before unregister gets a chance to post WM_QUIT to it
+		var msg win.MSG
+		win.PeekMessage(&msg, 0, 0, 0, win.PM_NOREMOVE)
+		threadID = windows.GetCurrentThreadId()
+
+		if err := win.RegisterHotKey(0, hotkeyID, modifiers, vk); err != nil {
+			registered <- fmt.Errorf("register hotkey %s: %w", hotkey, err)
+			return
+		}
+
+		defer win.UnregisterHotKey(0, hotkeyID)
+
+		registered <- nil
+
+		for {
+			ok, err := win.GetMessage(&msg, 0, 0, 0)
+			if !ok || err != nil {
+				return
+			}
+
+			if msg.Message == win.WM_HOTKEY {
+				go pressed()
+			}
+		}
+	}()
+
+	if err := <-registered; err != nil {
+		return nil, err
+	}
+
+	return func() {
+		_ = win.PostThreadMessage(threadID, win.WM_QUIT, 0, 0)
+		<-done
+	}, nil
+}
+
+func systemUsesLightTheme() (bool, bool) {
+	k, err := registry.OpenKey(registry.CURRENT_USER, `SOFTWARE\Microsoft\Windows\CurrentVersion\Themes\Personalize`, registry.QUERY_VALUE)
+	if err != nil {
+		return false, false
+	}
+	defer k.Close()
+
+	// unlike AppsUseLightTheme, this one covers the taskbar
+	value, _, err := k.GetIntegerValue("SystemUsesLightTheme")
+	if err != nil {
+		return false, false
+	}
+
+	return value != 0, true
+}
+
 const registryValue = "deej"
 
 func getAutostartState() bool {
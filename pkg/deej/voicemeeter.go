@@ -0,0 +1,366 @@
+package deej
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// VoicemeeterClient sets strip and bus gains in Voicemeeter through its Remote API DLL (Windows-only).
+// the DLL is loaded once, and deej logs in again whenever Voicemeeter is restarted
+type VoicemeeterClient struct {
+	deej   *Deej
+	logger *zap.SugaredLogger
+
+	// guards remote, loggedIn and edition
+	lock     sync.Mutex
+	remote   *voicemeeterRemote
+	loggedIn bool
+	edition  voicemeeterEdition
+
+	stopChannel chan struct{}
+	errChannel  chan error
+	wg          sync.WaitGroup
+
+	// so a missing Voicemeeter install is only logged once
+	notInstalledLogged bool
+}
+
+// voicemeeterEdition is what VBVMR_GetVoicemeeterType reports, telling how many strips and buses there are
+type voicemeeterEdition int
+
+const (
+	voicemeeterBasic  voicemeeterEdition = 1
+	voicemeeterBanana voicemeeterEdition = 2
+	voicemeeterPotato voicemeeterEdition = 3
+
+	// the 64-bit Potato reports itself separately
+	voicemeeterPotato64 voicemeeterEdition = 6
+)
+
+// voicemeeterLayout is the number of physical and virtual strips and buses of an edition.
+// strips and buses are numbered physical ones first, i.e. Banana's B1 is bus 3
+type voicemeeterLayout struct {
+	physicalStrips int
+	virtualStrips  int
+	physicalBuses  int
+	virtualBuses   int
+}
+
+var voicemeeterLayouts = map[voicemeeterEdition]voicemeeterLayout{
+	voicemeeterBasic:    {physicalStrips: 2, virtualStrips: 1, physicalBuses: 1, virtualBuses: 1},
+	voicemeeterBanana:   {physicalStrips: 3, virtualStrips: 2, physicalBuses: 3, virtualBuses: 2},
+	voicemeeterPotato:   {physicalStrips: 5, virtualStrips: 3, physicalBuses: 5, virtualBuses: 3},
+	voicemeeterPotato64: {physicalStrips: 5, virtualStrips: 3, physicalBuses: 5, virtualBuses: 3},
+}
+
+const (
+	voicemeeterRetryDelay = 5 * time.Second
+
+	// Voicemeeter wants its parameters polled regularly, which doubles as noticing it was closed
+	voicemeeterPollInterval = 2 * time.Second
+
+	// the bounds of Voicemeeter's gain faders
+	voicemeeterMinGainDb = -60.0
+	voicemeeterMaxGainDb = 12.0
+)
+
+var (
+	errVoicemeeterNotInstalled = errors.New("voicemeeter isn't installed")
+	errVoicemeeterNotRunning   = errors.New("voicemeeter isn't running")
+)
+
+func NewVoicemeeterClient(deej *Deej, logger *zap.SugaredLogger) *VoicemeeterClient {
+	logger = logger.Named("voicemeeter")
+
+	v := &VoicemeeterClient{
+		deej:       deej,
+		logger:     logger,
+		errChannel: make(chan error, 1),
+	}
+
+	logger.Debug("Created Voicemeeter client instance")
+
+	v.setupOnConfigReload()
+
+	return v
+}
+
+func (v *VoicemeeterClient) Start() {
+	v.stopChannel = make(chan struct{})
+	v.logger.Info("Voicemeeter client starting")
+
+	go v.managerLoop()
+}
+
+func (v *VoicemeeterClient) Stop() {
+	if v.stopChannel == nil {
+		return
+	}
+
+	close(v.stopChannel)
+	v.wg.Wait()
+
+	v.logger.Info("Voicemeeter client stopped")
+}
+
+func (v *VoicemeeterClient) IsConnected() bool {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	return v.loggedIn
+}
+
+// SetStripGain sets the gain of an input strip by its index, i.e. 0 for the first hardware input
+func (v *VoicemeeterClient) SetStripGain(strip string, volume float32) error {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	if !v.loggedIn {
+		return fmt.Errorf("not connected to Voicemeeter")
+	}
+
+	layout := voicemeeterLayouts[v.edition]
+
+	index, err := strconv.Atoi(strings.TrimSpace(strip))
+	if err != nil || index < 0 || index >= layout.physicalStrips+layout.virtualStrips {
+		return fmt.Errorf("no strip %q in this Voicemeeter edition", strip)
+	}
+
+	return v.setGain(fmt.Sprintf("Strip[%d].Gain", index), volume)
+}
+
+// SetBusGain sets the gain of an output bus by its name (i.e. A1 or B2) or its index
+func (v *VoicemeeterClient) SetBusGain(bus string, volume float32) error {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	if !v.loggedIn {
+		return fmt.Errorf("not connected to Voicemeeter")
+	}
+
+	index, ok := voicemeeterLayouts[v.edition].busIndex(bus)
+	if !ok {
+		return fmt.Errorf("no bus %q in this Voicemeeter edition", bus)
+	}
+
+	return v.setGain(fmt.Sprintf("Bus[%d].Gain", index), volume)
+}
+
+// setGain converts a slider value between 0.0 and 1.0 into a gain within the configured range. needs lock held
+func (v *VoicemeeterClient) setGain(parameter string, volume float32) error {
+	cfg := v.deej.config.VoicemeeterConfig
+	gain := cfg.MinGain + float64(volume)*(cfg.MaxGain-cfg.MinGain)
+	gain = math.Max(voicemeeterMinGainDb, math.Min(voicemeeterMaxGainDb, gain))
+
+	if err := v.remote.setParameters(fmt.Sprintf("%s=%.1f;", parameter, gain)); err != nil {
+		return err
+	}
+
+	v.logger.Debugw("Set Voicemeeter gain", "parameter", parameter, "volume", volume, "gain", gain)
+
+	return nil
+}
+
+// busIndex finds a bus by its name as shown in Voicemeeter (A1, B1 and so on), or by its plain index
+func (l voicemeeterLayout) busIndex(bus string) (int, bool) {
+	bus = strings.ToUpper(strings.TrimSpace(bus))
+
+	if index, err := strconv.Atoi(bus); err == nil {
+		return index, index >= 0 && index < l.physicalBuses+l.virtualBuses
+	}
+
+	if len(bus) < 2 {
+		return 0, false
+	}
+
+	number, err := strconv.Atoi(bus[1:])
+	if err != nil || number < 1 {
+		return 0, false
+	}
+
+	switch bus[0] {
+	case 'A':
+		return number - 1, number <= l.physicalBuses
+	case 'B':
+		return l.physicalBuses + number - 1, number <= l.virtualBuses
+	}
+
+	return 0, false
+}
+
+func (v *VoicemeeterClient) signalError(err error) {
+	select {
+	case v.errChannel <- err:
+	default:
+		// channel full, error already pending
+	}
+}
+
+// connect loads the Remote API DLL if it isn't yet, and logs in to a running Voicemeeter
+func (v *VoicemeeterClient) connect() error {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	if v.loggedIn {
+		return fmt.Errorf("already connected")
+	}
+
+	if v.remote == nil {
+		remote, err := newVoicemeeterRemote()
+		if err != nil {
+			return err
+		}
+
+		v.remote = remote
+	}
+
+	if err := v.remote.login(); err != nil {
+		return err
+	}
+
+	edition, err := v.remote.edition()
+	if err != nil {
+		v.remote.logout()
+		return fmt.Errorf("get Voicemeeter edition: %w", err)
+	}
+
+	if _, ok := voicemeeterLayouts[edition]; !ok {
+		v.remote.logout()
+		return fmt.Errorf("unknown Voicemeeter edition %d", edition)
+	}
+
+	v.loggedIn = true
+	v.edition = edition
+
+	v.logger.Infow("Connected to Voicemeeter", "edition", edition)
+
+	return nil
+}
+
+func (v *VoicemeeterClient) disconnect() {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	if !v.loggedIn {
+		return
+	}
+
+	v.remote.logout()
+	v.loggedIn = false
+
+	v.logger.Info("Disconnected from Voicemeeter")
+}
+
+// poll checks that Voicemeeter is still there
+func (v *VoicemeeterClient) poll() error {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	if !v.loggedIn {
+		return nil
+	}
+
+	return v.remote.poll()
+}
+
+func (v *VoicemeeterClient) managerLoop() {
+	v.wg.Add(1)
+	defer v.wg.Done()
+
+	for {
+		// check if Voicemeeter is enabled
+		if !v.deej.config.VoicemeeterConfig.Enabled {
+			select {
+			case <-v.stopChannel:
+				v.logger.Debug("managerLoop: stop signal")
+				return
+			case <-time.After(voicemeeterRetryDelay):
+				continue
+			}
+		}
+
+		// logging in is a quick local call, unlike OBS and Discord there's no need to wait on it in a goroutine
+		if err := v.connect(); err != nil {
+			switch {
+			case errors.Is(err, errVoicemeeterNotInstalled):
+				if !v.notInstalledLogged {
+					v.logger.Infow("Voicemeeter doesn't seem to be installed, Voicemeeter targets won't do anything", "error", err)
+					v.notInstalledLogged = true
+				}
+			case errors.Is(err, errVoicemeeterNotRunning):
+				// the usual case when Voicemeeter is closed, don't bother logging it
+			default:
+				v.logger.Debugw("Voicemeeter connection error, retrying...", "error", err)
+			}
+
+			select {
+			case <-v.stopChannel:
+				v.logger.Debug("managerLoop: stop signal")
+				return
+			case <-time.After(voicemeeterRetryDelay):
+				continue
+			}
+		}
+
+		// drain any stale errors from previous connection
+		select {
+		case <-v.errChannel:
+		default:
+		}
+
+		err := v.waitForDisconnect()
+		if err == nil {
+			v.disconnect()
+			return
+		}
+
+		v.logger.Infow("Voicemeeter connection lost, reconnecting...", "error", err)
+		v.disconnect()
+	}
+}
+
+// waitForDisconnect polls Voicemeeter until it goes away or the config asks to disconnect, returning why.
+// returns nil if deej is stopping instead
+func (v *VoicemeeterClient) waitForDisconnect() error {
+	ticker := time.NewTicker(voicemeeterPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-v.stopChannel:
+			v.logger.Debug("managerLoop: stop signal")
+			return nil
+
+		case err := <-v.errChannel:
+			return err
+
+		case <-ticker.C:
+			if err := v.poll(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (v *VoicemeeterClient) setupOnConfigReload() {
+	configReloadedChannel := v.deej.config.SubscribeToChanges()
+
+	go func() {
+		for {
+			<-configReloadedChannel
+
+			// gains are read on every slider move, so only turning Voicemeeter off needs a reconnect
+			if v.IsConnected() && !v.deej.config.VoicemeeterConfig.Enabled {
+				v.logger.Debug("Voicemeeter disabled, triggering disconnect")
+				v.signalError(errors.New("config changed"))
+			}
+		}
+	}()
+}
@@ -0,0 +1,28 @@
+//go:build !windows
+
+package deej
+
+// voicemeeterRemote does nothing outside of Windows, Voicemeeter is Windows-only
+type voicemeeterRemote struct{}
+
+func newVoicemeeterRemote() (*voicemeeterRemote, error) {
+	return nil, errVoicemeeterNotInstalled
+}
+
+func (r *voicemeeterRemote) login() error {
+	return errVoicemeeterNotInstalled
+}
+
+func (r *voicemeeterRemote) logout() {}
+
+func (r *voicemeeterRemote) edition() (voicemeeterEdition, error) {
+	return 0, errVoicemeeterNotInstalled
+}
+
+func (r *voicemeeterRemote) poll() error {
+	return errVoicemeeterNotInstalled
+}
+
+func (r *voicemeeterRemote) setParameters(_ string) error {
+	return errVoicemeeterNotInstalled
+}
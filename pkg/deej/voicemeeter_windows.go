@@ -0,0 +1,147 @@
+package deej
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+)
+
+// voicemeeterRemote calls into VoicemeeterRemote.dll, which ships with every Voicemeeter edition
+type voicemeeterRemote struct {
+	procLogin             *windows.LazyProc
+	procLogout            *windows.LazyProc
+	procGetType           *windows.LazyProc
+	procIsParametersDirty *windows.LazyProc
+	procSetParameters     *windows.LazyProc
+}
+
+const (
+
+	// the installer's uninstall entry is the only reliable pointer to where Voicemeeter is installed
+	voicemeeterUninstallKey = `SOFTWARE\Microsoft\Windows\CurrentVersion\Uninstall\VB:Voicemeeter {17359A74-1236-5467}`
+	voicemeeterDefaultDir   = `C:\Program Files (x86)\VB\Voicemeeter`
+
+	// VBVMR_Login returns this when the DLL works, but Voicemeeter itself isn't running
+	voicemeeterLoginNotRunning = 1
+)
+
+func newVoicemeeterRemote() (*voicemeeterRemote, error) {
+	dllName := "VoicemeeterRemote64.dll"
+	if runtime.GOARCH == "386" {
+		dllName = "VoicemeeterRemote.dll"
+	}
+
+	dll := windows.NewLazyDLL(filepath.Join(voicemeeterInstallDir(), dllName))
+	if err := dll.Load(); err != nil {
+		return nil, fmt.Errorf("%w: %w", errVoicemeeterNotInstalled, err)
+	}
+
+	return &voicemeeterRemote{
+		procLogin:             dll.NewProc("VBVMR_Login"),
+		procLogout:            dll.NewProc("VBVMR_Logout"),
+		procGetType:           dll.NewProc("VBVMR_GetVoicemeeterType"),
+		procIsParametersDirty: dll.NewProc("VBVMR_IsParametersDirty"),
+		procSetParameters:     dll.NewProc("VBVMR_SetParameters"),
+	}, nil
+}
+
+// voicemeeterInstallDir looks up where Voicemeeter's installer put it, falling back to its default location
+func voicemeeterInstallDir() string {
+	k, err := registry.OpenKey(registry.LOCAL_MACHINE, voicemeeterUninstallKey, registry.QUERY_VALUE|registry.WOW64_32KEY)
+	if err != nil {
+		return voicemeeterDefaultDir
+	}
+	defer k.Close()
+
+	uninstallString, _, err := k.GetStringValue("UninstallString")
+	if err != nil || uninstallString == "" {
+		return voicemeeterDefaultDir
+	}
+
+	return filepath.Dir(uninstallString)
+}
+
+// callVoicemeeter calls a Remote API function, all of which return a long that's negative on failure
+func callVoicemeeter(proc *windows.LazyProc, args ...uintptr) (int32, error) {
+	if err := proc.Find(); err != nil {
+		return 0, err
+	}
+
+	result, _, _ := proc.Call(args...)
+
+	return int32(result), nil
+}
+
+func (r *voicemeeterRemote) login() error {
+	result, err := callVoicemeeter(r.procLogin)
+	if err != nil {
+		return err
+	}
+
+	if result == voicemeeterLoginNotRunning {
+		r.logout()
+		return errVoicemeeterNotRunning
+	}
+
+	if result != 0 {
+		return fmt.Errorf("voicemeeter login failed with %d", result)
+	}
+
+	return nil
+}
+
+func (r *voicemeeterRemote) logout() {
+	_, _ = callVoicemeeter(r.procLogout)
+}
+
+func (r *voicemeeterRemote) edition() (voicemeeterEdition, error) {
+	var edition int32
+
+	result, err := callVoicemeeter(r.procGetType, uintptr(unsafe.Pointer(&edition)))
+	if err != nil {
+		return 0, err
+	}
+
+	if result != 0 {
+		return 0, fmt.Errorf("%w: error %d", errVoicemeeterNotRunning, result)
+	}
+
+	return voicemeeterEdition(edition), nil
+}
+
+// poll asks Voicemeeter whether parameters changed, which fails once it's no longer running
+func (r *voicemeeterRemote) poll() error {
+	result, err := callVoicemeeter(r.procIsParametersDirty)
+	if err != nil {
+		return err
+	}
+
+	if result < 0 {
+		return fmt.Errorf("%w: error %d", errVoicemeeterNotRunning, result)
+	}
+
+	return nil
+}
+
+// setParameters runs a Voicemeeter script, i.e. "Strip[0].Gain=-6.0;"
+func (r *voicemeeterRemote) setParameters(script string) error {
+	scriptPtr, err := windows.BytePtrFromString(script)
+	if err != nil {
+		return fmt.Errorf("convert Voicemeeter script: %w", err)
+	}
+
+	result, err := callVoicemeeter(r.procSetParameters, uintptr(unsafe.Pointer(scriptPtr)))
+	if err != nil {
+		return err
+	}
+
+	if result != 0 {
+		return fmt.Errorf("voicemeeter rejected %q with %d", script, result)
+	}
+
+	return nil
+}
@@ -1,6 +1,12 @@
 package icon
 
-import _ "embed"
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
 
 // EditConfig is the cog icon in the edit config menu option
 //go:embed assets/edit-config.ico
@@ -9,3 +15,21 @@ var EditConfigIcon []byte
 // RefreshSessions is the reload icon in the refresh sessions menu option
 //go:embed assets/refresh-sessions.ico
 var RefreshSessionsIcon []byte
+
+var errUnsupportedTrayIcon = errors.New("unsupported tray icon format")
+
+// TrayIconFromFile reads a custom tray icon (.ico or .png, though only Windows takes .ico files)
+// and returns it in the format the platform's tray expects
+func TrayIconFromFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read tray icon: %w", err)
+	}
+
+	icon, err := trayIconFromBytes(filepath.Ext(path), data)
+	if err != nil {
+		return nil, fmt.Errorf("load tray icon %s: %w", path, err)
+	}
+
+	return icon, nil
+}
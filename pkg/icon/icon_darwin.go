@@ -0,0 +1,36 @@
+package icon
+
+import (
+	"bytes"
+	_ "embed"
+	"image/png"
+	"strings"
+)
+
+// DeejLogo is a binary representation of the deej logo; used for notifications and tray icon
+//
+//go:embed assets/logo.png
+var TrayDeejLogo []byte
+
+// TrayDeejLogoLightTheme is a dark outline of the sliders, for light panels
+//
+//go:embed assets/tray-icon-light.png
+var TrayDeejLogoLightTheme []byte
+
+// TrayDeejLogoDarkTheme is a white outline of the sliders, for dark panels
+//
+//go:embed assets/tray-icon-dark.png
+var TrayDeejLogoDarkTheme []byte
+
+// the tray decodes icons with the image package, which only knows PNG out of the supported formats
+func trayIconFromBytes(extension string, data []byte) ([]byte, error) {
+	if strings.ToLower(extension) != ".png" {
+		return nil, errUnsupportedTrayIcon
+	}
+
+	if _, err := png.DecodeConfig(bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
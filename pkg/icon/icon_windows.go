@@ -1,7 +1,73 @@
 package icon
 
-import _ "embed"
+import (
+	"bytes"
+	_ "embed"
+	"encoding/binary"
+	"errors"
+	"image/png"
+	"strings"
+)
 
 // TrayDeejLogo is a binary representation of the deej logo; used for notifications and tray icon
 //go:embed assets/tray-icon.ico
 var TrayDeejLogo []byte
+
+// TrayDeejLogoLightTheme is a dark outline of the sliders, for light taskbars
+//go:embed assets/tray-icon-light.ico
+var TrayDeejLogoLightTheme []byte
+
+// TrayDeejLogoDarkTheme is a white outline of the sliders, for dark taskbars
+//go:embed assets/tray-icon-dark.ico
+var TrayDeejLogoDarkTheme []byte
+
+var icoHeader = []byte{0, 0, 1, 0}
+
+// the Windows tray only takes .ico files, so PNGs get wrapped into one (supported since Vista)
+func trayIconFromBytes(extension string, data []byte) ([]byte, error) {
+	switch strings.ToLower(extension) {
+	case ".ico":
+		if !bytes.HasPrefix(data, icoHeader) {
+			return nil, errors.New("not an ico file")
+		}
+
+		return data, nil
+
+	case ".png":
+		config, err := png.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+
+		return wrapPNG(data, config.Width, config.Height), nil
+	}
+
+	return nil, errUnsupportedTrayIcon
+}
+
+// wrapPNG makes an .ico file with a single PNG-compressed image
+func wrapPNG(data []byte, width int, height int) []byte {
+	const headerSize = 6 + 16
+
+	// a size of 0 in the directory entry means 256 or more
+	dimension := func(size int) byte {
+		if size >= 256 {
+			return 0
+		}
+
+		return byte(size)
+	}
+
+	ico := make([]byte, headerSize, headerSize+len(data))
+	copy(ico, icoHeader)
+	binary.LittleEndian.PutUint16(ico[4:], 1)
+
+	ico[6] = dimension(width)
+	ico[7] = dimension(height)
+	binary.LittleEndian.PutUint16(ico[10:], 1)
+	binary.LittleEndian.PutUint16(ico[12:], 32)
+	binary.LittleEndian.PutUint32(ico[14:], uint32(len(data)))
+	binary.LittleEndian.PutUint32(ico[18:], headerSize)
+
+	return append(ico, data...)
+}
@@ -0,0 +1,18 @@
+package notify
+
+import (
+	"os/exec"
+)
+
+// Notify shows a notification through AppleScript. macOS always shows the calling app's own icon,
+// so the icon path isn't used
+func Notify(title, message, _, _ string) error {
+	// passing the texts as arguments rather than splicing them into the script avoids any quoting issues
+	c := exec.Command("osascript",
+		"-e", "on run argv",
+		"-e", "display notification (item 2 of argv) with title (item 1 of argv)",
+		"-e", "end run",
+		title, message)
+
+	return c.Run()
+}
@@ -0,0 +1,41 @@
+package win
+
+import (
+	"syscall"
+	"unsafe"
+
+	ole "github.com/go-ole/go-ole"
+	"github.com/moutend/go-wca/pkg/wca"
+	"golang.org/x/sys/windows"
+)
+
+// go-wca's own wrappers for the session identifiers read the returned string even when the call failed,
+// so it's safer to make these calls here
+
+// GetSessionIdentifier returns the identifier an audio session shares with other sessions of the same app.
+// for packaged (Store) apps it holds the app's user model ID or install path, either of which names its package
+func GetSessionIdentifier(control *wca.IAudioSessionControl2) (string, error) {
+	return getSessionString(control.VTable().GetSessionIdentifier, control)
+}
+
+// GetSessionInstanceIdentifier returns the identifier of this particular instance of an audio session,
+// which unlike the session identifier differs between two sessions of the same app
+func GetSessionInstanceIdentifier(control *wca.IAudioSessionControl2) (string, error) {
+	return getSessionString(control.VTable().GetSessionInstanceIdentifier, control)
+}
+
+func getSessionString(method uintptr, control *wca.IAudioSessionControl2) (string, error) {
+	var valuePtr *uint16
+
+	hr, _, _ := syscall.SyscallN(
+		method,
+		uintptr(unsafe.Pointer(control)),
+		uintptr(unsafe.Pointer(&valuePtr)))
+
+	if hr != 0 {
+		return "", ole.NewError(hr)
+	}
+	defer ole.CoTaskMemFree(uintptr(unsafe.Pointer(valuePtr)))
+
+	return windows.UTF16PtrToString(valuePtr), nil
+}
@@ -0,0 +1,58 @@
+package win
+
+import (
+	"math"
+	"syscall"
+	"unsafe"
+
+	ole "github.com/go-ole/go-ole"
+)
+
+// IID_IChannelAudioVolume identifies the per-channel volume control of an audio session.
+// go-wca doesn't wrap this one, so it lives here
+var IID_IChannelAudioVolume = ole.NewGUID("{1C158861-B533-4B30-B1CF-E853E51C59B8}")
+
+type IChannelAudioVolume struct {
+	ole.IUnknown
+}
+
+type IChannelAudioVolumeVtbl struct {
+	ole.IUnknownVtbl
+	GetChannelCount  uintptr
+	SetChannelVolume uintptr
+	GetChannelVolume uintptr
+	SetAllVolumes    uintptr
+	GetAllVolumes    uintptr
+}
+
+func (v *IChannelAudioVolume) VTable() *IChannelAudioVolumeVtbl {
+	return (*IChannelAudioVolumeVtbl)(unsafe.Pointer(v.RawVTable))
+}
+
+func (v *IChannelAudioVolume) GetChannelCount(channelCount *uint32) (err error) {
+	hr, _, _ := syscall.SyscallN(
+		v.VTable().GetChannelCount,
+		uintptr(unsafe.Pointer(v)),
+		uintptr(unsafe.Pointer(channelCount)))
+
+	if hr != 0 {
+		err = ole.NewError(hr)
+	}
+
+	return
+}
+
+func (v *IChannelAudioVolume) SetChannelVolume(index uint32, level float32, eventContext *ole.GUID) (err error) {
+	hr, _, _ := syscall.SyscallN(
+		v.VTable().SetChannelVolume,
+		uintptr(unsafe.Pointer(v)),
+		uintptr(index),
+		uintptr(math.Float32bits(level)),
+		uintptr(unsafe.Pointer(eventContext)))
+
+	if hr != 0 {
+		err = ole.NewError(hr)
+	}
+
+	return
+}
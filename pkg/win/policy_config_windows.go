@@ -0,0 +1,79 @@
+package win
+
+import (
+	"syscall"
+	"unsafe"
+
+	ole "github.com/go-ole/go-ole"
+	"github.com/moutend/go-wca/pkg/wca"
+)
+
+// IPolicyConfig is the undocumented interface the sound control panel uses to change default devices.
+// it's been stable since Windows 7, but neither go-wca nor the Windows SDK declare it, so it lives here
+var (
+	CLSID_PolicyConfigClient = ole.NewGUID("{870AF99C-171D-4F9E-AF0D-E63DF40C2BC9}")
+	IID_IPolicyConfig        = ole.NewGUID("{F8679F50-850A-41CF-9C72-430F290290C8}")
+)
+
+type IPolicyConfig struct {
+	ole.IUnknown
+}
+
+type IPolicyConfigVtbl struct {
+	ole.IUnknownVtbl
+	GetMixFormat          uintptr
+	GetDeviceFormat       uintptr
+	ResetDeviceFormat     uintptr
+	SetDeviceFormat       uintptr
+	GetProcessingPeriod   uintptr
+	SetProcessingPeriod   uintptr
+	GetShareMode          uintptr
+	SetShareMode          uintptr
+	GetPropertyValue      uintptr
+	SetPropertyValue      uintptr
+	SetDefaultEndpoint    uintptr
+	SetEndpointVisibility uintptr
+}
+
+func (v *IPolicyConfig) VTable() *IPolicyConfigVtbl {
+	return (*IPolicyConfigVtbl)(unsafe.Pointer(v.RawVTable))
+}
+
+func (v *IPolicyConfig) SetDefaultEndpoint(deviceID string, role wca.ERole) (err error) {
+	idPtr, err := syscall.UTF16PtrFromString(deviceID)
+	if err != nil {
+		return err
+	}
+
+	hr, _, _ := syscall.SyscallN(
+		v.VTable().SetDefaultEndpoint,
+		uintptr(unsafe.Pointer(v)),
+		uintptr(unsafe.Pointer(idPtr)),
+		uintptr(role))
+
+	if hr != 0 {
+		err = ole.NewError(hr)
+	}
+
+	return
+}
+
+// SetDefaultDevice makes a device the default for every role, like picking it in the sound control panel does.
+// must be called from a thread that initialized COM
+func SetDefaultDevice(deviceID string) error {
+	unknown, err := ole.CreateInstance(CLSID_PolicyConfigClient, IID_IPolicyConfig)
+	if err != nil {
+		return err
+	}
+
+	policyConfig := (*IPolicyConfig)(unsafe.Pointer(unknown))
+	defer policyConfig.Release()
+
+	for _, role := range []wca.ERole{wca.EConsole, wca.EMultimedia, wca.ECommunications} {
+		if err := policyConfig.SetDefaultEndpoint(deviceID, role); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -17,6 +17,13 @@ var (
 	procIntersectRect                = moduser32.NewProc("IntersectRect")
 	procEqualRect                    = moduser32.NewProc("EqualRect")
 	procGetWindowLong                = moduser32.NewProc("GetWindowLongPtrW")
+	procGetSystemMetrics             = moduser32.NewProc("GetSystemMetrics")
+	procKeybdEvent                   = moduser32.NewProc("keybd_event")
+	procRegisterHotKey               = moduser32.NewProc("RegisterHotKey")
+	procUnregisterHotKey             = moduser32.NewProc("UnregisterHotKey")
+	procGetMessage                   = moduser32.NewProc("GetMessageW")
+	procPeekMessage                  = moduser32.NewProc("PeekMessageW")
+	procPostThreadMessage            = moduser32.NewProc("PostThreadMessageW")
 )
 
 const (
@@ -104,6 +111,54 @@ const (
 	WS_EX_NOACTIVATE       = 0x08000000
 )
 
+const (
+	SM_REMOTESESSION = 0x1000
+)
+
+// Virtual key codes of the media keys
+const (
+	VK_MEDIA_NEXT_TRACK = 0xB0
+	VK_MEDIA_PREV_TRACK = 0xB1
+	VK_MEDIA_STOP       = 0xB2
+	VK_MEDIA_PLAY_PAUSE = 0xB3
+)
+
+// Virtual key codes of the keys hotkeys can use, besides letters and digits (which match their ASCII codes)
+const (
+	VK_PAUSE  = 0x13
+	VK_SPACE  = 0x20
+	VK_PRIOR  = 0x21
+	VK_NEXT   = 0x22
+	VK_END    = 0x23
+	VK_HOME   = 0x24
+	VK_INSERT = 0x2D
+	VK_DELETE = 0x2E
+	VK_F1     = 0x70
+	VK_SCROLL = 0x91
+)
+
+const (
+	MOD_ALT      = 0x0001
+	MOD_CONTROL  = 0x0002
+	MOD_SHIFT    = 0x0004
+	MOD_WIN      = 0x0008
+	MOD_NOREPEAT = 0x4000
+)
+
+const (
+	WM_QUIT   = 0x0012
+	WM_HOTKEY = 0x0312
+)
+
+const (
+	PM_NOREMOVE = 0x0000
+)
+
+const (
+	KEYEVENTF_EXTENDEDKEY = 0x0001
+	KEYEVENTF_KEYUP       = 0x0002
+)
+
 const (
 	MONITOR_DEFAULTTONULL    = 0x0
 	MONITOR_DEFAULTTOPRIMARY = 0x1
@@ -124,6 +179,19 @@ type MONITORINFO struct {
 	Flags uint32
 }
 
+type POINT struct {
+	X, Y int32
+}
+
+type MSG struct {
+	Hwnd    windows.HWND
+	Message uint32
+	WParam  uintptr
+	LParam  uintptr
+	Time    uint32
+	Pt      POINT
+}
+
 func SHQueryUserNotificationState(state *uint32) (err error) {
 	r1, _, lastErr := procSHQueryUserNotificationState.Call(uintptr(unsafe.Pointer(state)))
 
@@ -183,3 +251,61 @@ func GetWindowLongPtr(hwnd windows.HWND, nindex int32) (style uintptr) {
 
 	return
 }
+
+func GetSystemMetrics(nindex int32) int32 {
+	r0, _, _ := procGetSystemMetrics.Call(uintptr(nindex))
+
+	return int32(r0)
+}
+
+func KeybdEvent(vk byte, flags uint32) {
+	procKeybdEvent.Call(uintptr(vk), 0, uintptr(flags), 0)
+}
+
+func RegisterHotKey(hwnd windows.HWND, id int32, modifiers uint32, vk uint32) (err error) {
+	r1, _, lastErr := procRegisterHotKey.Call(uintptr(hwnd), uintptr(id), uintptr(modifiers), uintptr(vk))
+
+	if r1 == 0 {
+		err = lastErr
+	}
+
+	return
+}
+
+func UnregisterHotKey(hwnd windows.HWND, id int32) (err error) {
+	r1, _, lastErr := procUnregisterHotKey.Call(uintptr(hwnd), uintptr(id))
+
+	if r1 == 0 {
+		err = lastErr
+	}
+
+	return
+}
+
+// GetMessage waits for the next message of the calling thread. it returns false once WM_QUIT arrives
+func GetMessage(msg *MSG, hwnd windows.HWND, msgFilterMin uint32, msgFilterMax uint32) (ok bool, err error) {
+	r1, _, lastErr := procGetMessage.Call(uintptr(unsafe.Pointer(msg)), uintptr(hwnd), uintptr(msgFilterMin), uintptr(msgFilterMax))
+
+	// -1 means the call itself failed
+	if int32(r1) == -1 {
+		return false, lastErr
+	}
+
+	return r1 != 0, nil
+}
+
+func PeekMessage(msg *MSG, hwnd windows.HWND, msgFilterMin uint32, msgFilterMax uint32, removeMsg uint32) bool {
+	r0, _, _ := procPeekMessage.Call(uintptr(unsafe.Pointer(msg)), uintptr(hwnd), uintptr(msgFilterMin), uintptr(msgFilterMax), uintptr(removeMsg))
+
+	return r0 != 0
+}
+
+func PostThreadMessage(threadID uint32, msg uint32, wParam uintptr, lParam uintptr) (err error) {
+	r1, _, lastErr := procPostThreadMessage.Call(uintptr(threadID), uintptr(msg), wParam, lParam)
+
+	if r1 == 0 {
+		err = lastErr
+	}
+
+	return
+}
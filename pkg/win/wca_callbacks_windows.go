@@ -1,6 +1,7 @@
 package win
 
 import (
+	"sync/atomic"
 	"syscall"
 	"unsafe"
 
@@ -193,31 +194,52 @@ var (
 	mmncVTable iMMNotificationClientVtbl
 )
 
+// MaxCallbacks is how many callbacks deej may create over its lifetime. it only needs one per vtable
+// entry and a handful elsewhere, so going over this means something creates them per session or per
+// event, which would eventually hit the runtime's limit and crash after a long enough uptime
+const MaxCallbacks = 64
+
+var callbackCount atomic.Int32
+
+// NewCallback wraps syscall.NewCallback, counting every callback created so that anything creating
+// them repeatedly shows up in CallbackCount (and the tests) long before the runtime's limit is reached.
+// create callbacks once and reuse them
+func NewCallback(fn any) uintptr {
+	callbackCount.Add(1)
+
+	return syscall.NewCallback(fn)
+}
+
+// CallbackCount returns how many syscall callbacks have been created so far
+func CallbackCount() int {
+	return int(callbackCount.Load())
+}
+
 func init() {
-	aseVTable.QueryInterface = syscall.NewCallback(aseQueryInterface)
-	aseVTable.AddRef = syscall.NewCallback(aseAddRef)
-	aseVTable.Release = syscall.NewCallback(aseRelease)
-	aseVTable.OnDisplayNameChanged = syscall.NewCallback(aseOnDisplayNameChanged)
-	aseVTable.OnIconPathChanged = syscall.NewCallback(aseOnIconPathChanged)
-	aseVTable.OnSimpleVolumeChanged = syscall.NewCallback(aseOnSimpleVolumeChanged)
-	aseVTable.OnChannelVolumeChanged = syscall.NewCallback(aseOnChannelVolumeChanged)
-	aseVTable.OnGroupingParamChanged = syscall.NewCallback(aseOnGroupingParamChanged)
-	aseVTable.OnStateChanged = syscall.NewCallback(aseOnStateChanged)
-	aseVTable.OnSessionDisconnected = syscall.NewCallback(aseOnSessionDisconnected)
-
-	asnVTable.QueryInterface = syscall.NewCallback(asnQueryInterface)
-	asnVTable.AddRef = syscall.NewCallback(asnAddRef)
-	asnVTable.Release = syscall.NewCallback(asnRelease)
-	asnVTable.OnSessionCreated = syscall.NewCallback(asnOnSessionCreated)
-
-	mmncVTable.QueryInterface = syscall.NewCallback(mmncQueryInterface)
-	mmncVTable.AddRef = syscall.NewCallback(mmncAddRef)
-	mmncVTable.Release = syscall.NewCallback(mmncRelease)
-	mmncVTable.OnDeviceStateChanged = syscall.NewCallback(mmncOnDeviceStateChanged)
-	mmncVTable.OnDeviceAdded = syscall.NewCallback(mmncOnDeviceAdded)
-	mmncVTable.OnDeviceRemoved = syscall.NewCallback(mmncOnDeviceRemoved)
-	mmncVTable.OnDefaultDeviceChanged = syscall.NewCallback(mmncOnDefaultDeviceChanged)
-	mmncVTable.OnPropertyValueChanged = syscall.NewCallback(mmncOnPropertyValueChanged)
+	aseVTable.QueryInterface = NewCallback(aseQueryInterface)
+	aseVTable.AddRef = NewCallback(aseAddRef)
+	aseVTable.Release = NewCallback(aseRelease)
+	aseVTable.OnDisplayNameChanged = NewCallback(aseOnDisplayNameChanged)
+	aseVTable.OnIconPathChanged = NewCallback(aseOnIconPathChanged)
+	aseVTable.OnSimpleVolumeChanged = NewCallback(aseOnSimpleVolumeChanged)
+	aseVTable.OnChannelVolumeChanged = NewCallback(aseOnChannelVolumeChanged)
+	aseVTable.OnGroupingParamChanged = NewCallback(aseOnGroupingParamChanged)
+	aseVTable.OnStateChanged = NewCallback(aseOnStateChanged)
+	aseVTable.OnSessionDisconnected = NewCallback(aseOnSessionDisconnected)
+
+	asnVTable.QueryInterface = NewCallback(asnQueryInterface)
+	asnVTable.AddRef = NewCallback(asnAddRef)
+	asnVTable.Release = NewCallback(asnRelease)
+	asnVTable.OnSessionCreated = NewCallback(asnOnSessionCreated)
+
+	mmncVTable.QueryInterface = NewCallback(mmncQueryInterface)
+	mmncVTable.AddRef = NewCallback(mmncAddRef)
+	mmncVTable.Release = NewCallback(mmncRelease)
+	mmncVTable.OnDeviceStateChanged = NewCallback(mmncOnDeviceStateChanged)
+	mmncVTable.OnDeviceAdded = NewCallback(mmncOnDeviceAdded)
+	mmncVTable.OnDeviceRemoved = NewCallback(mmncOnDeviceRemoved)
+	mmncVTable.OnDefaultDeviceChanged = NewCallback(mmncOnDefaultDeviceChanged)
+	mmncVTable.OnPropertyValueChanged = NewCallback(mmncOnPropertyValueChanged)
 }
 
 // NewIAudioSessionEvents creates a new IAudioSessionEvents callback interface
@@ -454,6 +476,27 @@ func (mmnc *IMMNotificationClient) ToWCA() *wca.IMMNotificationClient {
 	return (*wca.IMMNotificationClient)(unsafe.Pointer(mmnc))
 }
 
+// RefCount returns how many references COM holds to the client. it goes back to 0 once the client is unregistered
+func (mmnc *IMMNotificationClient) RefCount() int {
+	return mmnc.refCount
+}
+
+// UnregisterEndpointNotificationCallback calls IMMDeviceEnumerator::UnregisterEndpointNotificationCallback
+// directly via vtable, working around go-wca's unimplemented stub that always returns E_NOTIMPL.
+func UnregisterEndpointNotificationCallback(mmde *wca.IMMDeviceEnumerator, mmnc *IMMNotificationClient) error {
+	hr, _, _ := syscall.SyscallN(
+		mmde.VTable().UnregisterEndpointNotificationCallback,
+		uintptr(unsafe.Pointer(mmde)),
+		uintptr(unsafe.Pointer(mmnc)),
+	)
+
+	if hr != 0 {
+		return ole.NewError(hr)
+	}
+
+	return nil
+}
+
 // GetDevice calls IMMDeviceEnumerator::GetDevice directly via vtable,
 // working around go-wca's unimplemented stub that always returns E_NOTIMPL.
 func GetDevice(mmde *wca.IMMDeviceEnumerator, pwstrId string, ppDevice **wca.IMMDevice) error {
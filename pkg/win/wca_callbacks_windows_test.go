@@ -0,0 +1,24 @@
+package win
+
+import "testing"
+
+func TestCallbackCountWithinLimit(t *testing.T) {
+	if count := CallbackCount(); count > MaxCallbacks {
+		t.Fatalf("created %d syscall callbacks, more than the expected %d - callbacks must be created once and reused",
+			count, MaxCallbacks)
+	}
+}
+
+func TestCallbackInterfacesShareVTables(t *testing.T) {
+	before := CallbackCount()
+
+	for range 10 {
+		NewIAudioSessionEvents(IAudioSessionEventsCallback{})
+		NewIAudioSessionNotification(IAudioSessionNotificationCallback{})
+		NewIMMNotificationClient(IMMNotificationClientCallback{})
+	}
+
+	if after := CallbackCount(); after != before {
+		t.Fatalf("creating callback interfaces created %d syscall callbacks, expected none", after-before)
+	}
+}